@@ -21,8 +21,10 @@ import (
 	"shelley.exe.dev/llm"
 )
 
-// PermissionCallback is a function type for checking if a command is allowed to run
-type PermissionCallback func(command string) error
+// PermissionCallback is a function type for checking if a command is allowed to run.
+// It may block (e.g. while waiting for a user to approve the command) and should
+// return promptly with an error if ctx is cancelled.
+type PermissionCallback func(ctx context.Context, command string) error
 
 // BashTool specifies an llm.Tool for executing shell commands.
 type BashTool struct {
@@ -174,7 +176,7 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 
 	// Custom permission callback if set
 	if b.CheckPermission != nil {
-		if err := b.CheckPermission(req.Command); err != nil {
+		if err := b.CheckPermission(ctx, req.Command); err != nil {
 			return llm.ErrorToolOut(err)
 		}
 	}
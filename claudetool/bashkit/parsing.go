@@ -2,6 +2,8 @@ package bashkit
 
 import (
 	"fmt"
+	"path"
+	"slices"
 	"strings"
 
 	"mvdan.cc/sh/v3/interp"
@@ -65,3 +67,214 @@ func ExtractCommands(command string) ([]string, error) {
 
 	return commands, nil
 }
+
+// codeBuiltins are builtins that run shell code given as arguments or read
+// from a file, which cannot be checked without running it.
+var codeBuiltins = map[string]bool{
+	"eval":   true,
+	"source": true,
+	".":      true,
+	"trap":   true,
+}
+
+// wrapper describes a command that runs the program named in its arguments.
+type wrapper struct {
+	shortArgOpts string   // short options that take a separate argument, e.g. "u" for -u
+	longArgOpts  []string // long options that take a separate argument
+	operands     int      // operands before the program, e.g. timeout's duration
+	codeOpts     []string // options whose argument is shell code or a command line
+	assignments  bool     // NAME=value arguments may come before the program
+}
+
+// wrappers are the commands Programs looks through to the program they run.
+var wrappers = map[string]wrapper{
+	"builtin": {},
+	"command": {},
+	"exec":    {shortArgOpts: "a"},
+	"env":     {shortArgOpts: "uCS", longArgOpts: []string{"--unset", "--chdir", "--split-string"}, codeOpts: []string{"-S", "--split-string"}, assignments: true},
+	"sudo":    {shortArgOpts: "CDghpRrTtUu", longArgOpts: []string{"--close-from", "--chdir", "--group", "--host", "--prompt", "--chroot", "--role", "--type", "--command-timeout", "--other-user", "--user"}},
+	"xargs":   {shortArgOpts: "adEILnPs", longArgOpts: []string{"--arg-file", "--delimiter", "--eof", "--replace", "--max-lines", "--max-args", "--max-procs", "--max-chars"}},
+	"nice":    {shortArgOpts: "n", longArgOpts: []string{"--adjustment"}},
+	"nohup":   {},
+	"setsid":  {},
+	"stdbuf":  {shortArgOpts: "ioe", longArgOpts: []string{"--input", "--output", "--error"}},
+	"time":    {shortArgOpts: "fo", longArgOpts: []string{"--format", "--output"}},
+	"timeout": {shortArgOpts: "ks", longArgOpts: []string{"--kill-after", "--signal"}, operands: 1},
+}
+
+// Programs parses a bash command and returns the programs it runs, for
+// permission checks. Unlike ExtractCommands, it does not leave out programs
+// that are awkward to install:
+//
+//   - programs run by path are named by their base name (/bin/rm → rm)
+//   - wrappers such as command, exec, env, sudo and xargs are looked through
+//     to the program they run, which is listed along with any wrapper that is
+//     not a builtin
+//   - quoting is removed from literal names (r""m → rm)
+//
+// Other builtins are left out, since they cannot run programs themselves.
+// opaque reports that the command runs code that cannot be seen without
+// running it: eval, source, ., trap, or a command name that is not a literal.
+//
+// Examples:
+//
+//	"/bin/rm -rf x" → ["rm"]
+//	"sudo -u root env FOO=1 rm x" → ["sudo", "env", "rm"]
+//	"eval 'rm x'" → [], opaque
+func Programs(command string) (programs []string, opaque bool, err error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse bash command: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			programs = append(programs, name)
+		}
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		callExpr, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		args := callExpr.Args
+		for len(args) > 0 {
+			name, ok := literalWord(args[0])
+			if !ok || name == "" {
+				opaque = true
+				return true
+			}
+			if strings.Contains(name, "/") {
+				name = path.Base(name)
+			}
+			if codeBuiltins[name] {
+				opaque = true
+				return true
+			}
+			if !interp.IsBuiltin(name) {
+				add(name)
+			}
+			w, ok := wrappers[name]
+			if !ok {
+				return true
+			}
+			var code bool
+			args, code = w.program(args[1:])
+			if code {
+				opaque = true
+				return true
+			}
+		}
+		return true
+	})
+
+	return programs, opaque, nil
+}
+
+// program skips the wrapper's options and operands in args, and returns the
+// arguments from the program it runs onward. code reports that an option
+// holds shell code, or that an option could not be read.
+func (w wrapper) program(args []*syntax.Word) (rest []*syntax.Word, code bool) {
+	operands := w.operands
+	for len(args) > 0 {
+		arg, ok := literalWord(args[0])
+		if !ok {
+			// A computed option could be anything, including one that takes
+			// the program as its argument.
+			return nil, true
+		}
+		switch {
+		case arg == "--":
+			args = args[1:]
+			if operands > 0 {
+				args = args[min(operands, len(args)):]
+			}
+			return args, false
+		case strings.HasPrefix(arg, "--"):
+			name, _, hasValue := strings.Cut(arg, "=")
+			if slices.Contains(w.codeOpts, name) {
+				return nil, true
+			}
+			args = args[1:]
+			if !hasValue && slices.Contains(w.longArgOpts, name) && len(args) > 0 {
+				args = args[1:]
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if slices.Contains(w.codeOpts, arg[:2]) {
+				return nil, true
+			}
+			args = args[1:]
+			// The argument is attached (-uroot) unless the option ends the cluster.
+			last := arg[len(arg)-1:]
+			if strings.IndexByte(w.shortArgOpts, arg[1]) >= 0 && len(arg) > 2 {
+				continue
+			}
+			if strings.Contains(w.shortArgOpts, last) && len(args) > 0 {
+				args = args[1:]
+			}
+		case w.assignments && strings.Contains(arg, "="):
+			args = args[1:]
+		case operands > 0:
+			operands--
+			args = args[1:]
+		default:
+			return args, false
+		}
+	}
+	return nil, false
+}
+
+// literalWord returns the value of a word that has no expansions, with its
+// quoting removed. It reports false for words whose value is only known when
+// the command runs, including globs and brace expansions.
+func literalWord(word *syntax.Word) (string, bool) {
+	var sb strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			if strings.ContainsAny(part.Value, "*?[{") {
+				return "", false
+			}
+			sb.WriteString(unescape(part.Value, ""))
+		case *syntax.SglQuoted:
+			if part.Dollar {
+				return "", false
+			}
+			sb.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range part.Parts {
+				lit, ok := inner.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(unescape(lit.Value, "$`\"\\\n"))
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// unescape removes the backslashes that quote characters in s: any character
+// if escapable is empty, as outside quotes, or only those in escapable. An
+// escaped newline is removed entirely.
+func unescape(s, escapable string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (escapable == "" || strings.IndexByte(escapable, s[i+1]) >= 0) {
+			i++
+			if s[i] != '\n' {
+				sb.WriteByte(s[i])
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
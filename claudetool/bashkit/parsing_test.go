@@ -203,3 +203,53 @@ func TestExtractCommandsEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		opaque   bool
+	}{
+		{"ls -la | grep test", []string{"ls", "grep"}, false},
+		{"mkdir test && cd test", []string{"mkdir"}, false},
+		{"/bin/rm -rf x", []string{"rm"}, false},
+		{"./deploy.sh && echo done", []string{"deploy.sh"}, false},
+		{"command rm x", []string{"rm"}, false},
+		{"exec rm x", []string{"rm"}, false},
+		{"exec -a name rm x", []string{"rm"}, false},
+		{"builtin command /usr/bin/rm x", []string{"rm"}, false},
+		{"env FOO=1 -u BAR rm x", []string{"env", "rm"}, false},
+		{"env -S 'rm x'", []string{"env"}, true},
+		{"sudo -u root -E rm x", []string{"sudo", "rm"}, false},
+		{"sudo -uroot --chdir=/ rm x", []string{"sudo", "rm"}, false},
+		{"find . -name '*.o' | xargs -0 -n 1 rm", []string{"find", "xargs", "rm"}, false},
+		{"timeout -s KILL 5 nice -n 10 rm x", []string{"timeout", "nice", "rm"}, false},
+		{"sudo $FLAGS rm x", []string{"sudo"}, true},
+		{`r""m x`, []string{"rm"}, false},
+		{`'r'\m x`, []string{"rm"}, false},
+		{"eval 'rm x'", nil, true},
+		{"source ./script.sh", nil, true},
+		{". ./script.sh", nil, true},
+		{"trap 'rm x' EXIT", nil, true},
+		{`"$(echo rm)" x`, nil, true},
+		{"$CMD x", nil, true},
+		{"r* x", nil, true},
+		{"{r,}m x", nil, true},
+		{"FOO=bar", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, opaque, err := Programs(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) || opaque != tt.opaque {
+				t.Errorf("Programs(%q) = %q, %v; want %q, %v", tt.input, got, opaque, tt.expected, tt.opaque)
+			}
+		})
+	}
+
+	if _, _, err := Programs("if then fi (("); err == nil {
+		t.Error("expected an error for invalid syntax")
+	}
+}
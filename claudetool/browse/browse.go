@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -587,7 +586,8 @@ func (b *BrowseTools) ReadImageTool() *llm.Tool {
 			},
 			"required": ["path"]
 		}`),
-		Run: b.readImageRun,
+		Parallel: true,
+		Run:      b.readImageRun,
 	}
 }
 
@@ -677,40 +677,20 @@ func (b *BrowseTools) readImageRun(ctx context.Context, m json.RawMessage) llm.T
 		return llm.ErrorfToolOut("failed to read image file: %w", err)
 	}
 
-	// Convert HEIC to PNG if needed (Go's image library doesn't support HEIC)
-	converted := false
-	if imageutil.IsHEIC(imageData) {
-		imageData, err = imageutil.ConvertHEICToPNG(imageData)
-		if err != nil {
-			return llm.ErrorfToolOut("failed to convert HEIC image: %w", err)
-		}
-		converted = true
-	}
-
-	detectedType := http.DetectContentType(imageData)
-	if !strings.HasPrefix(detectedType, "image/") {
-		return llm.ErrorfToolOut("file is not an image: %s", detectedType)
-	}
-
-	// Resize image if needed to fit within model's image dimension limits
-	resized := false
-	format := strings.TrimPrefix(detectedType, "image/")
-	if b.maxImageDimension > 0 {
-		var err error
-		imageData, format, resized, err = imageutil.ResizeImage(imageData, b.maxImageDimension)
-		if err != nil {
-			return llm.ErrorToolOut(fmt.Errorf("failed to resize image: %w", err))
-		}
+	// Convert HEIC to PNG and resize to fit within the model's image dimension limits
+	img, err := imageutil.Prepare(imageData, b.maxImageDimension)
+	if err != nil {
+		return llm.ErrorToolOut(err)
 	}
 
-	base64Data := base64.StdEncoding.EncodeToString(imageData)
-	mediaType := "image/" + format
+	base64Data := base64.StdEncoding.EncodeToString(img.Data)
+	mediaType := img.MediaType
 
 	description := fmt.Sprintf("Image from %s (type: %s)", input.Path, mediaType)
-	if converted {
+	if img.Converted {
 		description += " [converted from HEIC]"
 	}
-	if resized {
+	if img.Resized {
 		description += " [resized]"
 	}
 
@@ -0,0 +1,219 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiagnosticCommand is a check for the patch tool to run on files with one of
+// the given extensions. The command is run with sh -c in the file's directory,
+// with the file's path in $FILE; each line of output is a diagnostic.
+type DiagnosticCommand struct {
+	Extensions []string `json:"extensions"`
+	Command    string   `json:"command"`
+}
+
+const (
+	defaultDiagnosticsTimeout = 30 * time.Second
+	maxDiagnosticsOutput      = 8 * 1024
+)
+
+// Diagnostics runs fast checks on the files the patch tool changes, so that
+// the model sees a problem it just introduced on the same turn. Go files get
+// gofmt and go vet on their package; other languages are configured with
+// Commands.
+//
+// Only new diagnostics are reported: each check's output is compared, ignoring
+// line and column numbers, with its output from before the patch.
+type Diagnostics struct {
+	Commands []DiagnosticCommand
+	// Timeout bounds each check. Zero means defaultDiagnosticsTimeout.
+	Timeout time.Duration
+
+	mu sync.Mutex
+	// last holds the most recent output of each check, by check key.
+	last map[string][]string
+}
+
+// diagnosticCheck is one command to run for a changed file.
+type diagnosticCheck struct {
+	key  string // identifies the check and what it covers, for caching
+	name string
+	dir  string
+	args []string
+	env  []string
+	// rewrite, if set, turns an output line into a readable diagnostic.
+	rewrite func(line string) string
+}
+
+// checksFor returns the checks that apply to path.
+func (d *Diagnostics) checksFor(path string) []diagnosticCheck {
+	dir := filepath.Dir(path)
+	ext := filepath.Ext(path)
+	var checks []diagnosticCheck
+	if ext == ".go" {
+		checks = append(checks,
+			diagnosticCheck{
+				key:  "gofmt " + path,
+				name: "gofmt",
+				dir:  dir,
+				args: []string{"gofmt", "-e", "-l", path},
+				rewrite: func(line string) string {
+					if line == path {
+						return path + " is not gofmt-formatted"
+					}
+					return line
+				},
+			},
+			// go vet also type-checks, so it catches compile errors in the package.
+			diagnosticCheck{key: "go vet " + dir, name: "go vet", dir: dir, args: []string{"go", "vet", "."}},
+		)
+	}
+	for _, c := range d.Commands {
+		if slices.Contains(c.Extensions, ext) && c.Command != "" {
+			checks = append(checks, diagnosticCheck{
+				key:  c.Command + " " + path,
+				name: c.Command,
+				dir:  dir,
+				args: []string{"sh", "-c", c.Command},
+				env:  []string{"FILE=" + path},
+			})
+		}
+	}
+	return checks
+}
+
+// prepare records the current diagnostics for path, before it is changed, for
+// any check that has not already run. It returns the checks for report.
+func (d *Diagnostics) prepare(ctx context.Context, path string) []diagnosticCheck {
+	if d == nil {
+		return nil
+	}
+	checks := d.checksFor(path)
+	for _, check := range checks {
+		d.mu.Lock()
+		_, ok := d.last[check.key]
+		d.mu.Unlock()
+		if ok {
+			continue
+		}
+		lines, ok := d.run(ctx, check)
+		if !ok {
+			continue
+		}
+		d.store(check.key, lines)
+	}
+	return checks
+}
+
+// report reruns checks after their file has changed and returns a description
+// of the diagnostics that were not there before, or "" if there are none.
+func (d *Diagnostics) report(ctx context.Context, checks []diagnosticCheck) string {
+	if d == nil {
+		return ""
+	}
+	var report strings.Builder
+	for _, check := range checks {
+		lines, ok := d.run(ctx, check)
+		if !ok {
+			continue
+		}
+		d.mu.Lock()
+		before, hadBefore := d.last[check.key]
+		d.mu.Unlock()
+		d.store(check.key, lines)
+		if !hadBefore {
+			continue
+		}
+
+		seen := make(map[string]bool, len(before))
+		for _, line := range before {
+			seen[normalizeDiagnostic(line)] = true
+		}
+		var added []string
+		for _, line := range lines {
+			if !seen[normalizeDiagnostic(line)] {
+				added = append(added, line)
+			}
+		}
+		if len(added) > 0 {
+			fmt.Fprintf(&report, "%s:\n%s\n", check.name, strings.Join(added, "\n"))
+		}
+	}
+	out := report.String()
+	if len(out) > maxDiagnosticsOutput {
+		out = out[:maxDiagnosticsOutput] + "\n[diagnostics truncated]\n"
+	}
+	return out
+}
+
+func (d *Diagnostics) store(key string, lines []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last == nil {
+		d.last = make(map[string][]string)
+	}
+	d.last[key] = lines
+}
+
+// run runs check and returns its output lines. ok is false if the check could
+// not run to completion, for example because the tool is not installed.
+func (d *Diagnostics) run(ctx context.Context, check diagnosticCheck) (lines []string, ok bool) {
+	if _, err := exec.LookPath(check.args[0]); err != nil {
+		return nil, false
+	}
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = defaultDiagnosticsTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, check.args[0], check.args[1:]...)
+	cmd.Dir = check.dir
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env = append(os.Environ(), check.env...)
+	cmd.WaitDelay = 5 * time.Second
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		slog.DebugContext(ctx, "diagnostic check did not finish", "check", check.name, "error", ctx.Err())
+		return nil, false
+	}
+	if _, isExit := err.(*exec.ExitError); err != nil && !isExit {
+		return nil, false
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		// go vet prints a "# package" header before each package's diagnostics.
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if check.rewrite != nil {
+			line = check.rewrite(line)
+		}
+		lines = append(lines, line)
+	}
+	return lines, true
+}
+
+// positionPattern matches the :line or :line:col after a file name, or a line
+// number at the start of the line as printed by grep -n.
+var positionPattern = regexp.MustCompile(`^\d+(:\d+)?|:\d+(:\d+)?`)
+
+// normalizeDiagnostic strips positions from a diagnostic so that it still
+// matches after edits move it to another line.
+func normalizeDiagnostic(line string) string {
+	return positionPattern.ReplaceAllString(line, "")
+}
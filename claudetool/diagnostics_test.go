@@ -0,0 +1,99 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runPatch(t *testing.T, patch *PatchTool, input PatchInput) string {
+	t.Helper()
+	m, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := patch.Run(context.Background(), m)
+	if out.Error != nil {
+		t.Fatalf("patch failed: %v", out.Error)
+	}
+	return out.LLMContent[0].Text
+}
+
+func TestDiagnosticsCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("fine\nTODO: old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	patch := &PatchTool{
+		WorkingDir: NewMutableWorkingDir(dir),
+		Diagnostics: &Diagnostics{Commands: []DiagnosticCommand{
+			{Extensions: []string{".txt"}, Command: `grep -n TODO "$FILE"`},
+		}},
+	}
+
+	// A TODO that was already there, even on another line, is not new.
+	text := runPatch(t, patch, PatchInput{Path: "notes.txt", Patches: []PatchRequest{
+		{Operation: "prepend_bof", NewText: "header\n"},
+	}})
+	if strings.Contains(text, "<diagnostics>") {
+		t.Errorf("expected no new diagnostics, got %q", text)
+	}
+
+	text = runPatch(t, patch, PatchInput{Path: "notes.txt", Patches: []PatchRequest{
+		{Operation: "replace", OldText: "fine", NewText: "TODO: new"},
+	}})
+	if !strings.Contains(text, "<diagnostics>") || !strings.Contains(text, "2:TODO: new") || strings.Contains(text, "old") {
+		t.Errorf("expected only the new TODO to be reported, got %q", text)
+	}
+
+	// Files the command does not cover are not checked.
+	text = runPatch(t, patch, PatchInput{Path: "other.md", Patches: []PatchRequest{
+		{Operation: "overwrite", NewText: "TODO\n"},
+	}})
+	if strings.Contains(text, "<diagnostics>") {
+		t.Errorf("expected no diagnostics for .md, got %q", text)
+	}
+}
+
+func TestDiagnosticsGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/diag\n\ngo 1.21\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package diag\n\nfunc A() int { return 1 }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOFLAGS", "")
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(dir), Diagnostics: &Diagnostics{}}
+
+	text := runPatch(t, patch, PatchInput{Path: "a.go", Patches: []PatchRequest{
+		{Operation: "replace", OldText: "return 1", NewText: "return missing"},
+	}})
+	if !strings.Contains(text, "<diagnostics>") || !strings.Contains(text, "undefined: missing") {
+		t.Errorf("expected go vet to report the new compile error, got %q", text)
+	}
+
+	// The error is not reported again for an unrelated, badly formatted edit.
+	text = runPatch(t, patch, PatchInput{Path: "a.go", Patches: []PatchRequest{
+		{Operation: "append_eof", NewText: "func   B() {}\n"},
+	}})
+	if strings.Contains(text, "undefined") || !strings.Contains(text, "is not gofmt-formatted") {
+		t.Errorf("expected only the formatting problem, got %q", text)
+	}
+}
+
+func TestNormalizeDiagnostic(t *testing.T) {
+	a := normalizeDiagnostic("./a.go:3:23: undefined: missing")
+	b := normalizeDiagnostic("./a.go:10:2: undefined: missing")
+	if a != b || a != "./a.go: undefined: missing" {
+		t.Errorf("normalizeDiagnostic = %q, %q", a, b)
+	}
+}
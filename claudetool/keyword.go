@@ -41,6 +41,7 @@ func (k *KeywordTool) Tool() *llm.Tool {
 		Name:        keywordName,
 		Description: keywordDescription,
 		InputSchema: llm.MustSchema(keywordInputSchema),
+		Parallel:    true,
 		Run:         k.keywordRun,
 	}
 }
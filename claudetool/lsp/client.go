@@ -0,0 +1,662 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxMessageSize bounds a single message from a server.
+const maxMessageSize = 64 << 20
+
+// ServerConfig describes how to start a language server.
+type ServerConfig struct {
+	// Name identifies the server. A configured server replaces the default
+	// server of the same name.
+	Name string `json:"name"`
+
+	// Command and Args start a server that speaks LSP over stdio.
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+
+	// Extensions are the file extensions, such as ".py", the server handles.
+	Extensions []string `json:"extensions"`
+
+	// LanguageID is sent with opened documents. If empty, it is derived from
+	// the file extension.
+	LanguageID string `json:"language_id,omitempty"`
+
+	// InitializationOptions are passed to the server as-is.
+	InitializationOptions json.RawMessage `json:"initialization_options,omitempty"`
+}
+
+// DefaultServers are the language servers used without any configuration.
+// Servers whose command is not installed are ignored.
+var DefaultServers = []ServerConfig{
+	{Name: "gopls", Command: "gopls", Extensions: []string{".go"}, LanguageID: "go"},
+}
+
+// languageIDs maps extensions to LSP language identifiers where they differ
+// from the extension itself.
+var languageIDs = map[string]string{
+	".c":   "c",
+	".cc":  "cpp",
+	".cpp": "cpp",
+	".h":   "c",
+	".hpp": "cpp",
+	".js":  "javascript",
+	".jsx": "javascriptreact",
+	".py":  "python",
+	".rb":  "ruby",
+	".rs":  "rust",
+	".ts":  "typescript",
+	".tsx": "typescriptreact",
+}
+
+func (c ServerConfig) languageID(path string) string {
+	if c.LanguageID != "" {
+		return c.LanguageID
+	}
+	ext := filepath.Ext(path)
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// Validate checks that c names a server, a command, and the files it handles.
+func (c ServerConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("language server config: name is required")
+	}
+	if c.Command == "" {
+		return fmt.Errorf("language server %q: command is required", c.Name)
+	}
+	if len(c.Extensions) == 0 {
+		return fmt.Errorf("language server %q: extensions are required", c.Name)
+	}
+	return nil
+}
+
+// ParseServerConfigs parses and validates a JSON array of ServerConfig.
+func ParseServerConfigs(data string) ([]ServerConfig, error) {
+	var configs []ServerConfig
+	if err := json.Unmarshal([]byte(data), &configs); err != nil {
+		return nil, fmt.Errorf("invalid language server config: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("language server %q configured more than once", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return configs, nil
+}
+
+// document is a file the client has opened on the server.
+type document struct {
+	version int
+	text    string
+}
+
+// Client is a connection to a single language server for one workspace root.
+// It is safe for concurrent use.
+type Client struct {
+	cfg    ServerConfig
+	root   string
+	t      *transport
+	nextID atomic.Int64
+	// utf8 is set if the server agreed to count characters in bytes rather
+	// than UTF-16 code units.
+	utf8 bool
+
+	mu   sync.Mutex
+	docs map[string]*document // by absolute path
+}
+
+// Start starts the server described by cfg for the workspace at root and
+// performs the LSP handshake. ctx bounds the handshake only; the server runs
+// until Close.
+func Start(ctx context.Context, cfg ServerConfig, root string, logger *slog.Logger) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("languageServer", cfg.Name, "root", root)
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = root
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start language server %q: %w", cfg.Name, err)
+	}
+
+	go func() {
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			logger.Debug("language server stderr", "line", s.Text())
+		}
+	}()
+
+	t := newTransport(stdout, stdin)
+	t.stop = func() error {
+		stdin.Close()
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+		select {
+		case <-exited:
+		case <-time.After(2 * time.Second):
+			cmd.Process.Kill()
+			<-exited
+		}
+		return nil
+	}
+
+	c, err := newClient(ctx, cfg, root, t)
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("language server %q: %w", cfg.Name, err)
+	}
+	logger.Info("started language server")
+	return c, nil
+}
+
+// newClient performs the handshake over an already-connected transport; used
+// by Start and by tests.
+func newClient(ctx context.Context, cfg ServerConfig, root string, t *transport) (*Client, error) {
+	c := &Client{cfg: cfg, root: root, t: t, docs: make(map[string]*document)}
+	if err := c.initialize(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close asks the server to shut down and stops it.
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.call(ctx, "shutdown", nil, nil); err == nil {
+		c.notify("exit", nil)
+	}
+	return c.t.close()
+}
+
+// alive reports whether the connection to the server is still open.
+func (c *Client) alive() bool {
+	select {
+	case <-c.t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	rootURI := fileURI(c.root)
+	capabilities := map[string]any{
+		"general": map[string]any{
+			"positionEncodings": []string{"utf-8", "utf-16"},
+		},
+		"textDocument": map[string]any{
+			"synchronization": map[string]any{"didSave": false},
+			"hover":           map[string]any{"contentFormat": []string{"plaintext", "markdown"}},
+			"definition":      map[string]any{"linkSupport": true},
+			"references":      map[string]any{},
+			"rename":          map[string]any{"prepareSupport": false},
+		},
+		"workspace": map[string]any{
+			"configuration":    true,
+			"workspaceFolders": true,
+			"symbol":           map[string]any{},
+			"workspaceEdit":    map[string]any{"documentChanges": true},
+		},
+	}
+	params := map[string]any{
+		"processId":        os.Getpid(),
+		"rootUri":          rootURI,
+		"workspaceFolders": []workspaceFolder{{URI: rootURI, Name: filepath.Base(c.root)}},
+		"capabilities":     capabilities,
+		"clientInfo":       map[string]string{"name": "shelley"},
+	}
+	if len(c.cfg.InitializationOptions) > 0 {
+		params["initializationOptions"] = c.cfg.InitializationOptions
+	}
+	var res initializeResult
+	if err := c.call(ctx, "initialize", params, &res); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	c.utf8 = res.Capabilities.PositionEncoding == "utf-8"
+	return c.notify("initialized", map[string]any{})
+}
+
+// call sends a request and decodes its result into out.
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	resp, err := c.t.roundTrip(ctx, &request{JSONRPC: jsonrpcVersion, ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func (c *Client) notify(method string, params any) error {
+	return c.t.write(&request{JSONRPC: jsonrpcVersion, Method: method, Params: params})
+}
+
+// sync opens path on the server, or updates it if it changed on disk since it
+// was last sent, and returns its current contents. Servers answer queries
+// about open documents from the text they were sent rather than from disk.
+func (c *Client) sync(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	uri := fileURI(path)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[path]
+	switch {
+	case !ok:
+		c.docs[path] = &document{version: 1, text: text}
+		err = c.notify("textDocument/didOpen", map[string]any{
+			"textDocument": textDocumentItem{URI: uri, LanguageID: c.cfg.languageID(path), Version: 1, Text: text},
+		})
+	case doc.text != text:
+		doc.version++
+		doc.text = text
+		err = c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   versionedTextDocumentIdentifier{URI: uri, Version: doc.version},
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+	}
+	return text, err
+}
+
+func positionParams(path string, pos Position) textDocumentPositionParams {
+	return textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: fileURI(path)}, Position: pos}
+}
+
+// Definition returns the locations where the symbol at pos in path is defined.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.call(ctx, "textDocument/definition", positionParams(path, pos), &raw); err != nil {
+		return nil, fmt.Errorf("textDocument/definition: %w", err)
+	}
+	return decodeLocations(raw)
+}
+
+// References returns the locations that refer to the symbol at pos in path,
+// including its declaration.
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	params := referenceParams{textDocumentPositionParams: positionParams(path, pos)}
+	params.Context.IncludeDeclaration = true
+	var locs []Location
+	if err := c.call(ctx, "textDocument/references", params, &locs); err != nil {
+		return nil, fmt.Errorf("textDocument/references: %w", err)
+	}
+	return locs, nil
+}
+
+// Hover returns the server's description of the symbol at pos in path, such
+// as its type and documentation, or "" if there is none.
+func (c *Client) Hover(ctx context.Context, path string, pos Position) (string, error) {
+	var h *hover
+	if err := c.call(ctx, "textDocument/hover", positionParams(path, pos), &h); err != nil {
+		return "", fmt.Errorf("textDocument/hover: %w", err)
+	}
+	if h == nil {
+		return "", nil
+	}
+	return hoverText(h.Contents), nil
+}
+
+// WorkspaceSymbols returns the symbols in the workspace that match query.
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]SymbolInformation, error) {
+	var symbols []SymbolInformation
+	if err := c.call(ctx, "workspace/symbol", workspaceSymbolParams{Query: query}, &symbols); err != nil {
+		return nil, fmt.Errorf("workspace/symbol: %w", err)
+	}
+	return symbols, nil
+}
+
+// Rename returns the edits, by file path, that would rename the symbol at pos
+// in path to newName. Nothing is changed on disk.
+func (c *Client) Rename(ctx context.Context, path string, pos Position, newName string) (map[string][]TextEdit, error) {
+	params := renameParams{textDocumentPositionParams: positionParams(path, pos), NewName: newName}
+	var edit *workspaceEdit
+	if err := c.call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return nil, fmt.Errorf("textDocument/rename: %w", err)
+	}
+	edits := make(map[string][]TextEdit)
+	if edit == nil {
+		return edits, nil
+	}
+	for uri, e := range edit.Changes {
+		edits[uriPath(uri)] = append(edits[uriPath(uri)], e...)
+	}
+	for _, dc := range edit.DocumentChanges {
+		p := uriPath(dc.TextDocument.URI)
+		edits[p] = append(edits[p], dc.Edits...)
+	}
+	return edits, nil
+}
+
+// decodeLocations decodes a definition result, which may be null, a Location,
+// a list of Locations, or a list of LocationLinks.
+func decodeLocations(raw json.RawMessage) ([]Location, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		var loc Location
+		if err := json.Unmarshal(raw, &loc); err != nil {
+			return nil, err
+		}
+		return []Location{loc}, nil
+	}
+	var items []struct {
+		Location
+		locationLink
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	locs := make([]Location, 0, len(items))
+	for _, item := range items {
+		if item.TargetURI != "" {
+			locs = append(locs, Location{URI: item.TargetURI, Range: item.TargetSelectionRange})
+		} else {
+			locs = append(locs, item.Location)
+		}
+	}
+	return locs, nil
+}
+
+// hoverText flattens hover contents, which may be MarkupContent, a
+// MarkedString, or a list of MarkedStrings.
+func hoverText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var content struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(raw, &content) == nil && content.Value != "" {
+		if content.Language != "" {
+			return "```" + content.Language + "\n" + strings.TrimSpace(content.Value) + "\n```"
+		}
+		return strings.TrimSpace(content.Value)
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		var parts []string
+		for _, item := range list {
+			if text := hoverText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// character converts a byte offset within line to a character offset in the
+// position encoding agreed with the server.
+func (c *Client) character(line string, offset int) int {
+	offset = min(offset, len(line))
+	if c.utf8 {
+		return offset
+	}
+	n := 0
+	for _, r := range line[:offset] {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// offset converts a character offset in the server's position encoding to a
+// byte offset within line.
+func (c *Client) offset(line string, character int) int {
+	if c.utf8 {
+		return min(character, len(line))
+	}
+	n := 0
+	for i, r := range line {
+		if n >= character {
+			return i
+		}
+		n += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// column returns the 1-based column, counted in characters, of a position on
+// line.
+func (c *Client) column(line string, character int) int {
+	return utf8.RuneCountInString(line[:c.offset(line, character)]) + 1
+}
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// transport speaks JSON-RPC with Content-Length framing over a pair of streams.
+type transport struct {
+	w       io.WriteCloser
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	err     error         // set when the read loop exits
+	done    chan struct{} // closed when the read loop exits
+
+	stop func() error
+}
+
+func newTransport(r io.Reader, w io.WriteCloser) *transport {
+	t := &transport{
+		w:       w,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(r)
+	return t
+}
+
+// readMessage reads one framed message body.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid message length %d", length)
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func (t *transport) readLoop(r io.Reader) {
+	br := bufio.NewReader(r)
+	var err error
+	for {
+		var body []byte
+		body, err = readMessage(br)
+		if err != nil {
+			break
+		}
+		var m message
+		if json.Unmarshal(body, &m) != nil {
+			continue
+		}
+		switch {
+		case m.isResponse():
+			t.mu.Lock()
+			ch, ok := t.pending[string(*m.ID)]
+			delete(t.pending, string(*m.ID))
+			t.mu.Unlock()
+			if ok {
+				ch <- &m
+			}
+		case m.ID != nil:
+			// Reply without blocking the read loop: the server may be
+			// waiting for us to read its output before it reads ours.
+			go t.reply(&m)
+		}
+		// Notifications, such as diagnostics and progress, are ignored.
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("language server connection closed: %w", err)
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// reply answers a request from the server. We have no settings to give and
+// register no capabilities dynamically, so every answer is empty.
+func (t *transport) reply(m *message) {
+	resp := map[string]any{"jsonrpc": jsonrpcVersion, "id": m.ID}
+	switch m.Method {
+	case "workspace/configuration":
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(m.Params, &params)
+		resp["result"] = make([]any, len(params.Items))
+	case "client/registerCapability", "client/unregisterCapability", "window/workDoneProgress/create", "window/showMessageRequest":
+		resp["result"] = nil
+	case "workspace/workspaceFolders":
+		resp["result"] = []workspaceFolder{}
+	default:
+		resp["error"] = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+	}
+	t.write(resp)
+}
+
+const codeMethodNotFound = -32601
+
+func (t *transport) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := fmt.Fprintf(t.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = t.w.Write(data)
+	return err
+}
+
+func (t *transport) roundTrip(ctx context.Context, req *request) (*message, error) {
+	key := string(*req.ID)
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(req); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case m := <-ch:
+		return m, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		t.write(request{JSONRPC: jsonrpcVersion, Method: "$/cancelRequest", Params: map[string]any{"id": req.ID}})
+		return nil, ctx.Err()
+	}
+}
+
+func (t *transport) close() error {
+	if t.stop != nil {
+		return t.stop()
+	}
+	return t.w.Close()
+}
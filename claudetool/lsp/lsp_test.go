@@ -0,0 +1,404 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticDir string
+
+func (d staticDir) Get() string { return string(d) }
+
+// testSource has a non-ASCII character before Hello on its last line, where
+// UTF-16, byte, and character columns all differ.
+const testSource = "package a\n\nfunc Hello() {}\n\nvar s, y = \"😀\", Hello()\n"
+
+// fakeServer is a tiny language server that answers from canned results and
+// records what it is sent.
+type fakeServer struct {
+	uri string
+
+	mu        sync.Mutex
+	opened    []string
+	positions []Position
+	config    json.RawMessage // the client's answer to workspace/configuration
+	shutdown  bool
+}
+
+func (f *fakeServer) handle(m message) any {
+	var p struct {
+		Position Position `json:"position"`
+	}
+	json.Unmarshal(m.Params, &p)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	at := func(line, char int) Location {
+		return Location{URI: f.uri, Range: Range{Start: Position{line, char}, End: Position{line, char + 5}}}
+	}
+	switch m.Method {
+	case "initialize":
+		return map[string]any{"capabilities": map[string]any{}}
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument textDocumentItem `json:"textDocument"`
+		}
+		json.Unmarshal(m.Params, &p)
+		f.opened = append(f.opened, p.TextDocument.Text)
+	case "textDocument/definition":
+		f.positions = append(f.positions, p.Position)
+		return []map[string]any{{"targetUri": f.uri, "targetRange": at(2, 0).Range, "targetSelectionRange": at(2, 5).Range}}
+	case "textDocument/references":
+		f.positions = append(f.positions, p.Position)
+		return []Location{at(2, 5), at(4, 17)}
+	case "textDocument/hover":
+		return map[string]any{"contents": map[string]string{"kind": "markdown", "value": "```go\nfunc Hello()\n```"}}
+	case "workspace/symbol":
+		return []SymbolInformation{{Name: "Hello", Kind: 12, ContainerName: "a", Location: at(2, 5)}}
+	case "textDocument/rename":
+		var p renameParams
+		json.Unmarshal(m.Params, &p)
+		edits := []TextEdit{{Range: at(2, 5).Range, NewText: p.NewName}, {Range: at(4, 17).Range, NewText: p.NewName}}
+		return map[string]any{"changes": map[string][]TextEdit{f.uri: edits}}
+	case "shutdown":
+		f.shutdown = true
+	}
+	return nil
+}
+
+// serve runs the fake server over a pair of pipes and returns the client's
+// ends of them.
+func (f *fakeServer) serve(t *testing.T) (io.Reader, io.WriteCloser) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	t.Cleanup(func() {
+		clientW.Close()
+		serverW.Close()
+	})
+	write := func(v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(serverW, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	go func() {
+		r := bufio.NewReader(serverR)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			var m message
+			json.Unmarshal(body, &m)
+			switch {
+			case m.Method == "initialized":
+				// Ask for configuration, as gopls does.
+				write(map[string]any{"jsonrpc": jsonrpcVersion, "id": "config", "method": "workspace/configuration", "params": map[string]any{"items": []any{map[string]any{"section": "gopls"}}}})
+			case m.isResponse():
+				f.mu.Lock()
+				f.config = m.Result
+				f.mu.Unlock()
+			case m.ID != nil:
+				write(map[string]any{"jsonrpc": jsonrpcVersion, "id": m.ID, "result": f.handle(m)})
+			default:
+				f.handle(m)
+			}
+		}
+	}()
+	return clientR, clientW
+}
+
+func newTestTool(t *testing.T) (*Tool, *fakeServer, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(path, []byte(testSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeServer{uri: fileURI(path)}
+	tool := NewTool(staticDir(dir), nil, nil)
+	tool.pool.start = func(ctx context.Context, cfg ServerConfig, root string) (*Client, error) {
+		if root != dir {
+			t.Errorf("server started for %q, want %q", root, dir)
+		}
+		r, w := fake.serve(t)
+		return newClient(ctx, cfg, root, newTransport(r, w))
+	}
+	t.Cleanup(tool.Close)
+	return tool, fake, path
+}
+
+func runTool(t *testing.T, tool *Tool, input toolInput) (string, error) {
+	t.Helper()
+	m, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := tool.Run(context.Background(), m)
+	if out.Error != nil {
+		return "", out.Error
+	}
+	return out.LLMContent[0].Text, nil
+}
+
+func TestTool(t *testing.T) {
+	tool, fake, path := newTestTool(t)
+
+	tests := []struct {
+		name  string
+		input toolInput
+		want  []string
+	}{
+		{
+			name:  "definition",
+			input: toolInput{Operation: "definition", Path: "a.go", Line: 5, Symbol: "Hello"},
+			want:  []string{"a.go:3:6: func Hello() {}"},
+		},
+		{
+			name:  "references by column",
+			input: toolInput{Operation: "references", Path: path, Line: 5, Column: 17},
+			want:  []string{"2 references:", "a.go:3:6: func Hello() {}", `a.go:5:17: var s, y = "😀", Hello()`},
+		},
+		{
+			name:  "hover",
+			input: toolInput{Operation: "hover", Path: "a.go", Line: 3, Symbol: "Hello"},
+			want:  []string{"```go\nfunc Hello()\n```"},
+		},
+		{
+			name:  "workspace symbols",
+			input: toolInput{Operation: "workspace_symbols", Path: "a.go", Query: "Hel"},
+			want:  []string{"function a.Hello a.go:3:6"},
+		},
+		{
+			name:  "rename preview",
+			input: toolInput{Operation: "rename_preview", Path: "a.go", Line: 3, Symbol: "Hello", NewName: "Greet"},
+			want: []string{
+				"2 edits in 1 files",
+				"3: - func Hello() {}\n  3: + func Greet() {}",
+				`5: + var s, y = "😀", Greet()`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runTool(t, tool, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output %q does not contain %q", got, want)
+				}
+			}
+		})
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	// Positions are sent in UTF-16 code units: the emoji counts as two.
+	want := []Position{{Line: 4, Character: 17}, {Line: 4, Character: 17}}
+	if fmt.Sprint(fake.positions) != fmt.Sprint(want) {
+		t.Errorf("server got positions %v, want %v", fake.positions, want)
+	}
+	if len(fake.opened) != 1 || fake.opened[0] != testSource {
+		t.Errorf("expected the file to be opened once, got %q", fake.opened)
+	}
+	if string(fake.config) != "[null]" {
+		t.Errorf("unexpected configuration answer %s", fake.config)
+	}
+}
+
+func TestToolErrors(t *testing.T) {
+	tool, _, _ := newTestTool(t)
+	tests := []struct {
+		input toolInput
+		want  string
+	}{
+		{toolInput{Operation: "definition", Path: "a.go", Line: 3, Symbol: "Missing"}, `"Missing" does not appear on this line`},
+		{toolInput{Operation: "definition", Path: "a.go", Line: 99, Symbol: "Hello"}, "past the end of the file"},
+		{toolInput{Operation: "definition", Path: "a.go", Line: 3}, "requires symbol or column"},
+		{toolInput{Operation: "definition", Path: "notes.txt", Line: 1, Column: 1}, "no language server is configured for .txt files"},
+		{toolInput{Operation: "rename_preview", Path: "a.go", Line: 3, Symbol: "Hello"}, "requires new_name"},
+		{toolInput{Operation: "workspace_symbols"}, "requires query"},
+		{toolInput{Operation: "format"}, "unknown operation"},
+	}
+	for _, tt := range tests {
+		_, err := runTool(t, tool, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestToolClose(t *testing.T) {
+	tool, fake, _ := newTestTool(t)
+	if _, err := runTool(t, tool, toolInput{Operation: "hover", Path: "a.go", Line: 3, Symbol: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	tool.Close()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if !fake.shutdown {
+		t.Error("expected Close to shut the server down")
+	}
+}
+
+func TestPoolSharesServers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(path, []byte(testSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeServer{uri: fileURI(path)}
+	pool := NewPool(nil)
+	pool.idleTimeout = 10 * time.Millisecond
+	var starts int
+	pool.start = func(ctx context.Context, cfg ServerConfig, root string) (*Client, error) {
+		starts++
+		r, w := fake.serve(t)
+		return newClient(ctx, cfg, root, newTransport(r, w))
+	}
+	t.Cleanup(pool.Close)
+	isShutdown := func() bool {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return fake.shutdown
+	}
+
+	a, b := NewTool(staticDir(dir), nil, pool), NewTool(staticDir(dir), nil, pool)
+	input := toolInput{Operation: "hover", Path: "a.go", Line: 3, Symbol: "Hello"}
+	for _, tool := range []*Tool{a, b} {
+		if _, err := runTool(t, tool, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if starts != 1 {
+		t.Errorf("expected both tools to share one server, got %d starts", starts)
+	}
+
+	a.Close()
+	time.Sleep(50 * time.Millisecond)
+	if isShutdown() {
+		t.Fatal("server shut down while another tool still holds it")
+	}
+
+	b.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !isShutdown() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the idle server to shut down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if len(pool.clients) != 0 {
+		t.Errorf("expected the idle server to leave the pool, got %d", len(pool.clients))
+	}
+}
+
+func TestSyncSendsChanges(t *testing.T) {
+	tool, fake, path := newTestTool(t)
+	input := toolInput{Operation: "hover", Path: "a.go", Line: 3, Symbol: "Hello"}
+	if _, err := runTool(t, tool, input); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(testSource, "Hello()", "Hello(n int)", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runTool(t, tool, input); err != nil {
+		t.Fatal(err)
+	}
+
+	var c *Client
+	tool.mu.Lock()
+	for _, pc := range tool.held {
+		c = pc.client
+	}
+	tool.mu.Unlock()
+	c.mu.Lock()
+	doc := c.docs[path]
+	c.mu.Unlock()
+	if doc.version != 2 || !strings.Contains(doc.text, "Hello(n int)") {
+		t.Errorf("expected the change to be sent as version 2, got %+v", doc)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.opened) != 1 {
+		t.Errorf("expected the file to be opened once, got %d", len(fake.opened))
+	}
+}
+
+func TestFindIdentifier(t *testing.T) {
+	tests := []struct {
+		line, symbol string
+		want         int
+	}{
+		{"a := ab + b", "b", 10},
+		{"x.Hello()", "Hello", 2},
+		{"Hellos()", "Hello", 0}, // no whole-word match; fall back to the first
+		{"nothing", "Hello", -1},
+	}
+	for _, tt := range tests {
+		if got := findIdentifier(tt.line, tt.symbol); got != tt.want {
+			t.Errorf("findIdentifier(%q, %q) = %d, want %d", tt.line, tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeLocations(t *testing.T) {
+	for _, raw := range []string{
+		`{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}`,
+		`[{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}]`,
+		`[{"targetUri":"file:///a.go","targetSelectionRange":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}]`,
+	} {
+		locs, err := decodeLocations(json.RawMessage(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locs) != 1 || uriPath(locs[0].URI) != "/a.go" || locs[0].Range.Start != (Position{1, 2}) {
+			t.Errorf("decodeLocations(%s) = %+v", raw, locs)
+		}
+	}
+	if locs, err := decodeLocations(json.RawMessage("null")); err != nil || locs != nil {
+		t.Errorf("decodeLocations(null) = %+v, %v", locs, err)
+	}
+}
+
+func TestHoverText(t *testing.T) {
+	got := hoverText(json.RawMessage(`[{"language":"go","value":"func F()"}, "Docs."]`))
+	if got != "```go\nfunc F()\n```\n\nDocs." {
+		t.Errorf("hoverText = %q", got)
+	}
+}
+
+func TestGopls(t *testing.T) {
+	if _, err := exec.LookPath("gopls"); err != nil {
+		t.Skip("gopls not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/a\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(testSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOFLAGS", "")
+	tool := NewTool(staticDir(dir), nil, nil)
+	defer tool.Close()
+
+	got, err := runTool(t, tool, toolInput{Operation: "definition", Path: "a.go", Line: 5, Symbol: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "a.go:3:6: func Hello() {}") {
+		t.Errorf("unexpected definition %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package lsp
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// defaultIdleTimeout is how long a language server nobody is using keeps
+// running, so that the next conversation in the same workspace can reuse it.
+const defaultIdleTimeout = 5 * time.Minute
+
+// Pool shares running language servers between Tools, one per server and
+// workspace root, so that conversations in the same repository use a single
+// process. Each Tool holds a reference to the clients it has used until it is
+// closed; a server with no references is shut down after the idle timeout.
+type Pool struct {
+	logger      *slog.Logger
+	idleTimeout time.Duration
+	// start starts a client; tests replace it.
+	start func(ctx context.Context, cfg ServerConfig, root string) (*Client, error)
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient // by server name and root
+}
+
+// pooledClient is a language server in a Pool. The fields after ready are
+// written once, before ready is closed.
+type pooledClient struct {
+	key   string
+	cfg   ServerConfig
+	ready chan struct{}
+	refs  int         // guarded by Pool.mu
+	idle  *time.Timer // guarded by Pool.mu; set while refs is 0
+
+	client *Client
+	err    error
+}
+
+// started reports whether starting the server has finished, successfully or not.
+func (pc *pooledClient) started() bool {
+	select {
+	case <-pc.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops the server once it has finished starting, without blocking.
+func (pc *pooledClient) close() {
+	go func() {
+		<-pc.ready
+		if pc.client != nil {
+			pc.client.Close()
+		}
+	}()
+}
+
+// NewPool returns an empty Pool.
+func NewPool(logger *slog.Logger) *Pool {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p := &Pool{logger: logger, idleTimeout: defaultIdleTimeout, clients: make(map[string]*pooledClient)}
+	p.start = func(ctx context.Context, cfg ServerConfig, root string) (*Client, error) {
+		return Start(ctx, cfg, root, p.logger)
+	}
+	return p
+}
+
+func poolKey(cfg ServerConfig, root string) string {
+	return cfg.Name + "\x00" + root
+}
+
+// acquire returns a running client for cfg and root, starting one if needed,
+// and takes a reference to it that the caller must give back with release.
+func (p *Pool) acquire(ctx context.Context, cfg ServerConfig, root string) (*pooledClient, error) {
+	key := poolKey(cfg, root)
+	p.mu.Lock()
+	pc := p.clients[key]
+	if pc != nil && (!reflect.DeepEqual(pc.cfg, cfg) || pc.started() && (pc.err != nil || !pc.client.alive())) {
+		// The config changed or the server exited; its holders close it
+		// when they let go.
+		p.forget(key, pc)
+		pc = nil
+	}
+	if pc == nil {
+		pc = &pooledClient{key: key, cfg: cfg, ready: make(chan struct{})}
+		p.clients[key] = pc
+		go p.startClient(pc, root)
+	}
+	pc.refs++
+	if pc.idle != nil {
+		pc.idle.Stop()
+		pc.idle = nil
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-pc.ready:
+	case <-ctx.Done():
+		p.release(pc)
+		return nil, ctx.Err()
+	}
+	if pc.err != nil {
+		p.release(pc)
+		return nil, pc.err
+	}
+	return pc, nil
+}
+
+// startClient starts pc's server, then closes pc.ready. It does not use the
+// acquiring caller's context, since other callers may be waiting too.
+func (p *Pool) startClient(pc *pooledClient, root string) {
+	defer close(pc.ready)
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	pc.client, pc.err = p.start(ctx, pc.cfg, root)
+}
+
+// release gives back a reference taken by acquire.
+func (p *Pool) release(pc *pooledClient) {
+	key := pc.key
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc.refs--
+	if pc.refs > 0 {
+		return
+	}
+	if p.clients[key] != pc || pc.started() && pc.err != nil {
+		p.forget(key, pc)
+		return
+	}
+	pc.idle = time.AfterFunc(p.idleTimeout, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.clients[key] == pc && pc.refs == 0 {
+			p.forget(key, pc)
+		}
+	})
+}
+
+// forget removes pc from the pool, and stops it if nothing holds it.
+// p.mu must be held.
+func (p *Pool) forget(key string, pc *pooledClient) {
+	if p.clients[key] == pc {
+		delete(p.clients, key)
+	}
+	if pc.refs == 0 {
+		pc.close()
+	}
+}
+
+// Close stops every language server in the pool, including ones still in use.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = make(map[string]*pooledClient)
+	for _, pc := range clients {
+		if pc.idle != nil {
+			pc.idle.Stop()
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, pc := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-pc.ready
+			if pc.client != nil {
+				pc.client.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
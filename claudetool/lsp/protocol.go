@@ -0,0 +1,169 @@
+// Package lsp implements a small Language Server Protocol client and a tool
+// that lets the agent navigate code with it: definitions, references, hover
+// information, workspace symbols and rename previews.
+//
+// See https://microsoft.github.io/language-server-protocol/ for the protocol.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const jsonrpcVersion = "2.0"
+
+// request is a JSON-RPC request or, when ID is nil, a notification.
+type request struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  any              `json:"params,omitempty"`
+}
+
+// message is any incoming JSON-RPC message: a response, a request, or a notification.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *RPCError        `json:"error,omitempty"`
+}
+
+// isResponse reports whether m is a response to one of our requests.
+func (m *message) isResponse() bool {
+	return m.Method == "" && m.ID != nil
+}
+
+// RPCError is a JSON-RPC error object.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// Position is a zero-based line and character offset. How characters are
+// counted depends on the position encoding negotiated with the server.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a half-open range between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// locationLink is the alternative result of textDocument/definition.
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type versionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type referenceParams struct {
+	textDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type renameParams struct {
+	textDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+type workspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+// SymbolInformation is a symbol found by workspace/symbol.
+type SymbolInformation struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName,omitempty"`
+}
+
+// symbolKinds names the LSP SymbolKind values, which start at 1.
+var symbolKinds = []string{
+	"file", "module", "namespace", "package", "class", "method", "property",
+	"field", "constructor", "enum", "interface", "function", "variable",
+	"constant", "string", "number", "boolean", "array", "object", "key", "null",
+	"enum member", "struct", "event", "operator", "type parameter",
+}
+
+func symbolKindName(kind int) string {
+	if kind < 1 || kind > len(symbolKinds) {
+		return "symbol"
+	}
+	return symbolKinds[kind-1]
+}
+
+// hover is the result of textDocument/hover. Contents may be MarkupContent, a
+// MarkedString, or an array of MarkedStrings.
+type hover struct {
+	Contents json.RawMessage `json:"contents"`
+}
+
+// TextEdit replaces the text in Range with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// workspaceEdit is the result of textDocument/rename. Servers use either
+// Changes or DocumentChanges.
+type workspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []struct {
+		TextDocument versionedTextDocumentIdentifier `json:"textDocument"`
+		Edits        []TextEdit                      `json:"edits"`
+	} `json:"documentChanges,omitempty"`
+}
+
+type workspaceFolder struct {
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+}
+
+type initializeResult struct {
+	Capabilities struct {
+		PositionEncoding string `json:"positionEncoding,omitempty"`
+	} `json:"capabilities"`
+	ServerInfo struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
@@ -0,0 +1,513 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"shelley.exe.dev/llm"
+)
+
+const (
+	toolName        = "code_navigation"
+	toolDescription = `Navigate code with a language server (gopls for Go, plus any configured servers).
+
+Operations:
+- definition: where the symbol at a position is defined
+- references: every place the symbol at a position is used
+- hover: the type, signature and documentation of the symbol at a position
+- workspace_symbols: find functions, types, methods, etc. by name across the project
+- rename_preview: the edits a rename of the symbol at a position would make (nothing is changed)
+
+Positions are given as path, line, and the symbol as it appears on that line.
+Use column instead of symbol only to disambiguate repeated names on a line.
+
+Prefer this tool over grep when you need exact answers about code structure:
+it understands scopes, imports, methods and interfaces.
+`
+	toolInputSchema = `{
+  "type": "object",
+  "required": ["operation"],
+  "properties": {
+    "operation": {
+      "type": "string",
+      "enum": ["definition", "references", "hover", "workspace_symbols", "rename_preview"]
+    },
+    "path": {
+      "type": "string",
+      "description": "File containing the position (absolute or relative to the working directory). For workspace_symbols, optionally selects the project and language to search."
+    },
+    "line": {
+      "type": "integer",
+      "description": "1-based line number of the position"
+    },
+    "symbol": {
+      "type": "string",
+      "description": "The identifier at the position, as written on that line"
+    },
+    "column": {
+      "type": "integer",
+      "description": "1-based column of the position, in characters; used when symbol is not given"
+    },
+    "query": {
+      "type": "string",
+      "description": "Symbol name or prefix to search for (workspace_symbols)"
+    },
+    "new_name": {
+      "type": "string",
+      "description": "The new name (rename_preview)"
+    }
+  }
+}`
+)
+
+const (
+	// callTimeout bounds a single operation, including starting the server
+	// and letting it load the workspace.
+	callTimeout    = 2 * time.Minute
+	maxLocations   = 100
+	maxSymbols     = 50
+	maxOutputBytes = 32 * 1024
+)
+
+type toolInput struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Symbol    string `json:"symbol"`
+	Column    int    `json:"column"`
+	Query     string `json:"query"`
+	NewName   string `json:"new_name"`
+}
+
+// WorkingDir supplies the directory relative paths are resolved against.
+type WorkingDir interface {
+	Get() string
+}
+
+// Tool answers code navigation questions using language servers. Servers are
+// started on first use, one per server and workspace root, and are shared with
+// other Tools through a Pool.
+type Tool struct {
+	wd      WorkingDir
+	servers []ServerConfig
+	pool    *Pool
+	ownPool bool // pool was created for this Tool, and is closed with it
+
+	mu   sync.Mutex
+	held map[string]*pooledClient // clients this Tool holds a reference to, by pool key
+}
+
+// NewTool returns a tool that uses the given servers in addition to
+// DefaultServers. A server with the same name as a default replaces it.
+// If pool is nil, the Tool gets a pool of its own.
+func NewTool(wd WorkingDir, servers []ServerConfig, pool *Pool) *Tool {
+	var all []ServerConfig
+	for _, def := range DefaultServers {
+		if !slices.ContainsFunc(servers, func(s ServerConfig) bool { return s.Name == def.Name }) {
+			all = append(all, def)
+		}
+	}
+	all = append(all, servers...)
+	t := &Tool{wd: wd, servers: all, pool: pool, held: make(map[string]*pooledClient)}
+	if t.pool == nil {
+		t.pool = NewPool(nil)
+		t.ownPool = true
+	}
+	return t
+}
+
+// Available reports whether any configured language server is installed.
+// There is no point offering the tool otherwise.
+func (t *Tool) Available() bool {
+	for _, s := range t.servers {
+		if _, err := exec.LookPath(s.Command); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Tool returns the llm.Tool.
+func (t *Tool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        toolName,
+		Description: toolDescription,
+		InputSchema: llm.MustSchema(toolInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Close lets go of the language servers this Tool used. They keep running for
+// other Tools until the pool's idle timeout.
+func (t *Tool) Close() {
+	t.mu.Lock()
+	held := t.held
+	t.held = make(map[string]*pooledClient)
+	t.mu.Unlock()
+
+	for _, pc := range held {
+		t.pool.release(pc)
+	}
+	if t.ownPool {
+		t.pool.Close()
+	}
+}
+
+// Run executes the tool.
+func (t *Tool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req toolInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse %s input: %w", toolName, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	var out string
+	var err error
+	switch req.Operation {
+	case "definition", "references", "hover", "rename_preview":
+		out, err = t.runAtPosition(ctx, req)
+	case "workspace_symbols":
+		out, err = t.workspaceSymbols(ctx, req)
+	default:
+		return llm.ErrorfToolOut("unknown operation %q", req.Operation)
+	}
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if len(out) > maxOutputBytes {
+		out = out[:maxOutputBytes] + "\n[output truncated]"
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(out)}
+}
+
+func (t *Tool) resolve(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(t.wd.Get(), path)
+	}
+	return filepath.Clean(path)
+}
+
+// display returns path relative to the working directory if it is inside it.
+func (t *Tool) display(path string) string {
+	if rel, err := filepath.Rel(t.wd.Get(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// serverFor returns the configured server for path's extension.
+func (t *Tool) serverFor(path string) (ServerConfig, error) {
+	ext := filepath.Ext(path)
+	for _, s := range t.servers {
+		if slices.Contains(s.Extensions, ext) {
+			return s, nil
+		}
+	}
+	return ServerConfig{}, fmt.Errorf("no language server is configured for %s files", ext)
+}
+
+// workspaceRoot returns the git root containing dir, or dir itself.
+func workspaceRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// client returns the running client for cfg and root, getting it from the pool
+// if this Tool doesn't hold one yet.
+func (t *Tool) client(ctx context.Context, cfg ServerConfig, root string) (*Client, error) {
+	key := poolKey(cfg, root)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if pc, ok := t.held[key]; ok {
+		if pc.client.alive() {
+			return pc.client, nil
+		}
+		// The server exited; let the pool start another.
+		delete(t.held, key)
+		t.pool.release(pc)
+	}
+	pc, err := t.pool.acquire(ctx, cfg, root)
+	if err != nil {
+		return nil, err
+	}
+	t.held[key] = pc
+	return pc.client, nil
+}
+
+// runAtPosition runs an operation on the symbol at a position in a file.
+func (t *Tool) runAtPosition(ctx context.Context, req toolInput) (string, error) {
+	if req.Path == "" || req.Line < 1 {
+		return "", fmt.Errorf("%s requires path and line", req.Operation)
+	}
+	if req.Symbol == "" && req.Column < 1 {
+		return "", fmt.Errorf("%s requires symbol or column", req.Operation)
+	}
+	if req.Operation == "rename_preview" && req.NewName == "" {
+		return "", errors.New("rename_preview requires new_name")
+	}
+	path := t.resolve(req.Path)
+	cfg, err := t.serverFor(path)
+	if err != nil {
+		return "", err
+	}
+	c, err := t.client(ctx, cfg, workspaceRoot(filepath.Dir(path)))
+	if err != nil {
+		return "", err
+	}
+	text, err := c.sync(path)
+	if err != nil {
+		return "", err
+	}
+	pos, err := c.findPosition(text, req.Line, req.Symbol, req.Column)
+	if err != nil {
+		return "", fmt.Errorf("%s:%d: %w", t.display(path), req.Line, err)
+	}
+
+	switch req.Operation {
+	case "definition":
+		locs, err := c.Definition(ctx, path, pos)
+		if err != nil {
+			return "", err
+		}
+		if len(locs) == 0 {
+			return "No definition found.", nil
+		}
+		return t.formatLocations(c, locs), nil
+	case "references":
+		locs, err := c.References(ctx, path, pos)
+		if err != nil {
+			return "", err
+		}
+		if len(locs) == 0 {
+			return "No references found.", nil
+		}
+		return fmt.Sprintf("%d references:\n%s", len(locs), t.formatLocations(c, locs)), nil
+	case "hover":
+		text, err := c.Hover(ctx, path, pos)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			return "No information available for this position.", nil
+		}
+		return text, nil
+	default:
+		edits, err := c.Rename(ctx, path, pos, req.NewName)
+		if err != nil {
+			return "", err
+		}
+		if len(edits) == 0 {
+			return "The rename would not change anything.", nil
+		}
+		return t.formatRename(c, edits), nil
+	}
+}
+
+// findPosition locates the symbol on the 1-based line of text, or the 1-based
+// character column if symbol is empty, in the server's position encoding.
+func (c *Client) findPosition(text string, line int, symbol string, column int) (Position, error) {
+	lines := strings.Split(text, "\n")
+	if line > len(lines) {
+		return Position{}, fmt.Errorf("line is past the end of the file (%d lines)", len(lines))
+	}
+	lineText := strings.TrimSuffix(lines[line-1], "\r")
+	offset := -1
+	if symbol != "" {
+		offset = findIdentifier(lineText, symbol)
+		if offset < 0 {
+			return Position{}, fmt.Errorf("%q does not appear on this line: %s", symbol, strings.TrimSpace(lineText))
+		}
+	} else {
+		offset = len(lineText)
+		for i := range lineText {
+			if column--; column == 0 {
+				offset = i
+				break
+			}
+		}
+	}
+	return Position{Line: line - 1, Character: c.character(lineText, offset)}, nil
+}
+
+// findIdentifier returns the byte offset of the first occurrence of symbol in
+// line that is not part of a longer identifier, or any occurrence if there is
+// no such match, or -1.
+func findIdentifier(line, symbol string) int {
+	isIdent := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	first := -1
+	for start := 0; ; {
+		i := strings.Index(line[start:], symbol)
+		if i < 0 {
+			return first
+		}
+		i += start
+		if first < 0 {
+			first = i
+		}
+		before, _ := utf8.DecodeLastRuneInString(line[:i])
+		after, _ := utf8.DecodeRuneInString(line[i+len(symbol):])
+		if (i == 0 || !isIdent(before)) && (i+len(symbol) == len(line) || !isIdent(after)) {
+			return i
+		}
+		start = i + 1
+	}
+}
+
+// fileLines reads files for formatting results, caching them per call.
+type fileLines map[string][]string
+
+func (f fileLines) line(path string, n int) string {
+	lines, ok := f[path]
+	if !ok {
+		data, _ := os.ReadFile(path)
+		lines = strings.Split(string(data), "\n")
+		f[path] = lines
+	}
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n], "\r")
+}
+
+// formatLocations lists locations as path:line:column followed by the code
+// on that line.
+func (t *Tool) formatLocations(c *Client, locs []Location) string {
+	files := fileLines{}
+	var b strings.Builder
+	for i, loc := range locs {
+		if i == maxLocations {
+			fmt.Fprintf(&b, "[%d more not shown]\n", len(locs)-maxLocations)
+			break
+		}
+		path := uriPath(loc.URI)
+		start := loc.Range.Start
+		line := files.line(path, start.Line)
+		fmt.Fprintf(&b, "%s:%d:%d: %s\n", t.display(path), start.Line+1, c.column(line, start.Character), strings.TrimSpace(line))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// workspaceSymbols searches for symbols by name.
+func (t *Tool) workspaceSymbols(ctx context.Context, req toolInput) (string, error) {
+	if req.Query == "" {
+		return "", errors.New("workspace_symbols requires query")
+	}
+	var cfg ServerConfig
+	var root string
+	if req.Path != "" {
+		path := t.resolve(req.Path)
+		var err error
+		if cfg, err = t.serverFor(path); err != nil {
+			return "", err
+		}
+		dir := path
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			dir = filepath.Dir(path)
+		}
+		root = workspaceRoot(dir)
+	} else {
+		i := slices.IndexFunc(t.servers, func(s ServerConfig) bool {
+			_, err := exec.LookPath(s.Command)
+			return err == nil
+		})
+		if i < 0 {
+			return "", errors.New("no language server is installed")
+		}
+		cfg = t.servers[i]
+		root = workspaceRoot(t.wd.Get())
+	}
+	c, err := t.client(ctx, cfg, root)
+	if err != nil {
+		return "", err
+	}
+	symbols, err := c.WorkspaceSymbols(ctx, req.Query)
+	if err != nil {
+		return "", err
+	}
+	if len(symbols) == 0 {
+		return fmt.Sprintf("No symbols matching %q.", req.Query), nil
+	}
+
+	files := fileLines{}
+	var b strings.Builder
+	for i, s := range symbols {
+		if i == maxSymbols {
+			fmt.Fprintf(&b, "[%d more not shown; refine the query]\n", len(symbols)-maxSymbols)
+			break
+		}
+		path := uriPath(s.Location.URI)
+		start := s.Location.Range.Start
+		name := s.Name
+		if s.ContainerName != "" {
+			name = s.ContainerName + "." + s.Name
+		}
+		col := c.column(files.line(path, start.Line), start.Character)
+		fmt.Fprintf(&b, "%s %s %s:%d:%d\n", symbolKindName(s.Kind), name, t.display(path), start.Line+1, col)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// formatRename shows each line a rename would change, before and after.
+func (t *Tool) formatRename(c *Client, edits map[string][]TextEdit) string {
+	paths := make([]string, 0, len(edits))
+	total := 0
+	for path, e := range edits {
+		paths = append(paths, path)
+		total += len(e)
+	}
+	sort.Strings(paths)
+
+	files := fileLines{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Renaming would make %d edits in %d files. Nothing has been changed; use the patch tool to apply them.\n", total, len(paths))
+	for _, path := range paths {
+		fmt.Fprintf(&b, "\n%s:\n", t.display(path))
+		byLine := make(map[int][]TextEdit)
+		var lines []int
+		for _, e := range edits[path] {
+			if e.Range.Start.Line != e.Range.End.Line {
+				fmt.Fprintf(&b, "  %d:%d-%d:%d: replace with %q\n", e.Range.Start.Line+1, e.Range.Start.Character+1, e.Range.End.Line+1, e.Range.End.Character+1, e.NewText)
+				continue
+			}
+			if _, ok := byLine[e.Range.Start.Line]; !ok {
+				lines = append(lines, e.Range.Start.Line)
+			}
+			byLine[e.Range.Start.Line] = append(byLine[e.Range.Start.Line], e)
+		}
+		sort.Ints(lines)
+		for _, n := range lines {
+			before := files.line(path, n)
+			after := before
+			lineEdits := byLine[n]
+			// Apply from the end of the line so earlier offsets stay valid.
+			sort.Slice(lineEdits, func(i, j int) bool { return lineEdits[i].Range.Start.Character > lineEdits[j].Range.Start.Character })
+			for _, e := range lineEdits {
+				start, end := c.offset(before, e.Range.Start.Character), c.offset(before, e.Range.End.Character)
+				after = after[:start] + e.NewText + after[end:]
+			}
+			fmt.Fprintf(&b, "  %d: - %s\n  %d: + %s\n", n+1, strings.TrimSpace(before), n+1, strings.TrimSpace(after))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,532 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientInfo identifies Shelley to MCP servers during initialization.
+var ClientInfo = Implementation{Name: "shelley", Version: "dev"}
+
+// maxMessageSize bounds a single stdio message; tool results can be large.
+const maxMessageSize = 64 << 20
+
+// ServerConfig describes how to reach an MCP server.
+// Exactly one of Command (stdio) or URL (streamable HTTP) must be set.
+type ServerConfig struct {
+	// Name identifies the server; it prefixes the names of its tools.
+	Name string `json:"name"`
+
+	// Command, Args and Env launch a server that speaks MCP over stdio.
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+
+	// URL and Headers reach a server over the streamable HTTP transport.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Disabled servers are skipped.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Validate checks that c names a server and exactly one transport.
+func (c ServerConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("mcp server config: name is required")
+	}
+	if (c.Command == "") == (c.URL == "") {
+		return fmt.Errorf("mcp server %q: exactly one of command or url must be set", c.Name)
+	}
+	return nil
+}
+
+// ParseServerConfigs parses and validates a JSON array of ServerConfig.
+func ParseServerConfigs(data string) ([]ServerConfig, error) {
+	var configs []ServerConfig
+	if err := json.Unmarshal([]byte(data), &configs); err != nil {
+		return nil, fmt.Errorf("invalid mcp server config: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("mcp server %q configured more than once", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return configs, nil
+}
+
+// transport carries JSON-RPC messages to and from a server.
+type transport interface {
+	// roundTrip sends a request and waits for its response.
+	roundTrip(ctx context.Context, req *request) (*message, error)
+	// notify sends a notification, which has no response.
+	notify(ctx context.Context, req *request) error
+	// lost reports whether the connection is gone for good, so the client
+	// must reconnect.
+	lost() bool
+	close() error
+}
+
+// Client is a connection to a single MCP server.
+// It is safe for concurrent use.
+type Client struct {
+	name       string
+	t          transport
+	nextID     atomic.Int64
+	serverInfo Implementation
+}
+
+// Connect starts or dials the server described by cfg and performs the MCP handshake.
+// ctx bounds the handshake only; the connection lives until Close.
+func Connect(ctx context.Context, cfg ServerConfig, logger *slog.Logger) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("mcpServer", cfg.Name)
+
+	var t transport
+	var err error
+	if cfg.Command != "" {
+		t, err = startStdio(cfg, logger)
+	} else {
+		t = newHTTPTransport(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{name: cfg.Name, t: t}
+	if err := c.initialize(ctx); err != nil {
+		t.close()
+		return nil, fmt.Errorf("mcp server %q: %w", cfg.Name, err)
+	}
+	logger.Info("connected to MCP server", "serverName", c.serverInfo.Name, "serverVersion", c.serverInfo.Version)
+	return c, nil
+}
+
+// newClient wraps an already-connected transport; used by tests.
+func newClient(ctx context.Context, name string, t transport) (*Client, error) {
+	c := &Client{name: name, t: t}
+	if err := c.initialize(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Lost reports whether the connection has dropped: the server process exited,
+// or the server forgot the HTTP session.
+func (c *Client) Lost() bool {
+	return c.t.lost()
+}
+
+// Close shuts down the connection (and the server process, for stdio servers).
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var res initializeResult
+	err := c.call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      ClientInfo,
+	}, &res)
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	c.serverInfo = res.ServerInfo
+	return c.t.notify(ctx, &request{JSONRPC: jsonrpcVersion, Method: "notifications/initialized"})
+}
+
+// call sends a request and decodes its result into out.
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	resp, err := c.t.roundTrip(ctx, &request{JSONRPC: jsonrpcVersion, ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// ListTools returns every tool the server advertises, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var res listToolsResult
+		if err := c.call(ctx, "tools/list", listToolsParams{Cursor: cursor}, &res); err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		tools = append(tools, res.Tools...)
+		if res.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// CallTool invokes a tool on the server.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, error) {
+	var res CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// stdioTransport speaks newline-delimited JSON-RPC to a subprocess.
+type stdioTransport struct {
+	w       io.WriteCloser
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	err     error         // set when the read loop exits
+	done    chan struct{} // closed when the read loop exits
+
+	stop func() error
+}
+
+func startStdio(cfg ServerConfig, logger *slog.Logger) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server %q: %w", cfg.Name, err)
+	}
+
+	go func() {
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			logger.Debug("mcp server stderr", "line", s.Text())
+		}
+	}()
+
+	t := newStdioTransport(stdout, stdin)
+	t.stop = func() error {
+		stdin.Close()
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+		select {
+		case <-exited:
+		case <-time.After(2 * time.Second):
+			cmd.Process.Kill()
+			<-exited
+		}
+		return nil
+	}
+	return t, nil
+}
+
+func newStdioTransport(r io.Reader, w io.WriteCloser) *stdioTransport {
+	t := &stdioTransport{
+		w:       w,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(r)
+	return t
+}
+
+func (t *stdioTransport) readLoop(r io.Reader) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var m message
+		if err := json.Unmarshal(line, &m); err != nil {
+			continue
+		}
+		switch {
+		case m.isResponse():
+			t.mu.Lock()
+			ch, ok := t.pending[string(*m.ID)]
+			delete(t.pending, string(*m.ID))
+			t.mu.Unlock()
+			if ok {
+				ch <- &m
+			}
+		case m.ID != nil:
+			// A request from the server. We advertise no client capabilities,
+			// so the only one we need to answer is ping.
+			t.reply(&m)
+		}
+	}
+	err := s.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("mcp connection closed: %w", err)
+	t.mu.Unlock()
+	close(t.done)
+}
+
+func (t *stdioTransport) reply(m *message) {
+	resp := map[string]any{"jsonrpc": jsonrpcVersion, "id": m.ID}
+	if m.Method == "ping" {
+		resp["result"] = map[string]any{}
+	} else {
+		resp["error"] = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+	}
+	t.write(resp)
+}
+
+func (t *stdioTransport) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.w.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req *request) (*message, error) {
+	key := string(*req.ID)
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(req); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case m := <-ch:
+		return m, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		t.write(request{JSONRPC: jsonrpcVersion, Method: "notifications/cancelled", Params: map[string]any{"requestId": req.ID}})
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, req *request) error {
+	return t.write(req)
+}
+
+func (t *stdioTransport) lost() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *stdioTransport) close() error {
+	if t.stop != nil {
+		return t.stop()
+	}
+	return t.w.Close()
+}
+
+// httpTransport implements the streamable HTTP transport.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+	expired   bool // the server answered 404 to our session
+}
+
+func newHTTPTransport(cfg ServerConfig) *httpTransport {
+	return &httpTransport{url: cfg.URL, headers: cfg.Headers, client: http.DefaultClient}
+}
+
+func (t *httpTransport) post(ctx context.Context, req *request) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	httpReq.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+	for k, v := range t.headers {
+		httpReq.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		httpReq.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		t.mu.Lock()
+		t.sessionID = sid
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && httpReq.Header.Get("Mcp-Session-Id") != "" {
+		t.mu.Lock()
+		t.expired = true
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("mcp server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req *request) (*message, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readSSEResponse(resp.Body, *req.ID)
+	}
+	var m message
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMessageSize)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode mcp response: %w", err)
+	}
+	return &m, nil
+}
+
+// readSSEResponse reads server-sent events until the response with the given ID arrives.
+// Other messages on the stream (progress notifications, server requests) are ignored.
+func readSSEResponse(r io.Reader, id json.RawMessage) (*message, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxMessageSize)
+	var data strings.Builder
+	for s.Scan() {
+		line := s.Text()
+		if after, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(after, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		var m message
+		err := json.Unmarshal([]byte(data.String()), &m)
+		data.Reset()
+		if err == nil && m.isResponse() && string(*m.ID) == string(id) {
+			return &m, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("mcp event stream ended without a response")
+}
+
+func (t *httpTransport) notify(ctx context.Context, req *request) error {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *httpTransport) lost() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.expired
+}
+
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sid := t.sessionID
+	t.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", sid)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
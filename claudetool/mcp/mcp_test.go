@@ -0,0 +1,393 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// handleFake implements a tiny MCP server with two pages of tools:
+// "echo" returns its "text" argument, and "fail" reports a tool error.
+func handleFake(m message) (any, *RPCError) {
+	switch m.Method {
+	case "initialize":
+		return initializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "fake", Version: "1"}}, nil
+	case "tools/list":
+		var p listToolsParams
+		json.Unmarshal(m.Params, &p)
+		if p.Cursor == "" {
+			return listToolsResult{
+				Tools:      []Tool{{Name: "echo", Description: "Echo text", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)}},
+				NextCursor: "page2",
+			}, nil
+		}
+		return listToolsResult{Tools: []Tool{{Name: "fail", Title: "Always fails"}}}, nil
+	case "tools/call":
+		var p struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		json.Unmarshal(m.Params, &p)
+		switch p.Name {
+		case "echo":
+			return CallToolResult{Content: []Content{{Type: "text", Text: p.Arguments.Text}}}, nil
+		case "fail":
+			return CallToolResult{Content: []Content{{Type: "text", Text: "boom"}}, IsError: true}, nil
+		}
+		return nil, &RPCError{Code: -32602, Message: "unknown tool " + p.Name}
+	}
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found"}
+}
+
+func fakeResponse(m message) map[string]any {
+	result, rpcErr := handleFake(m)
+	resp := map[string]any{"jsonrpc": jsonrpcVersion, "id": m.ID}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	return resp
+}
+
+// serveFakeStdio runs the fake server over r/w until r is closed.
+// Before answering tools/call it sends a ping and waits for the reply,
+// to exercise server-to-client requests.
+func serveFakeStdio(t *testing.T, r io.Reader, w io.Writer) {
+	s := bufio.NewScanner(r)
+	enc := json.NewEncoder(w)
+	for s.Scan() {
+		var m message
+		if err := json.Unmarshal(s.Bytes(), &m); err != nil {
+			t.Errorf("fake server: bad message %q: %v", s.Text(), err)
+			return
+		}
+		if m.ID == nil {
+			continue // notification
+		}
+		if m.Method == "tools/call" {
+			enc.Encode(map[string]any{"jsonrpc": jsonrpcVersion, "id": "ping-1", "method": "ping"})
+			if !s.Scan() || !strings.Contains(s.Text(), `"ping-1"`) || !strings.Contains(s.Text(), `"result"`) {
+				t.Errorf("fake server: expected ping reply, got %q", s.Text())
+			}
+		}
+		enc.Encode(fakeResponse(m))
+	}
+}
+
+func newStdioTestClient(t *testing.T) *Client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go func() {
+		serveFakeStdio(t, serverR, serverW)
+		serverW.Close()
+	}()
+	c, err := newClient(context.Background(), "fake", newStdioTransport(clientR, clientW))
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestStdioClient(t *testing.T) {
+	c := newStdioTestClient(t)
+	ctx := context.Background()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+
+	res, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "hello" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	if _, err := c.CallTool(ctx, "nope", nil); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("expected unknown tool error, got %v", err)
+	}
+}
+
+func TestStdioClientClosedConnection(t *testing.T) {
+	c := newStdioTestClient(t)
+	c.Close()
+	if _, err := c.ListTools(context.Background()); err == nil {
+		t.Fatal("expected error after close")
+	}
+}
+
+func newFakeHTTPServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	const sessionID = "session-123"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var m message
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if m.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", sessionID)
+		} else if r.Header.Get("Mcp-Session-Id") != sessionID {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		if m.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		resp, _ := json.Marshal(fakeResponse(m))
+		if m.Method == "tools/call" {
+			// Stream the response, preceded by an unrelated notification.
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := newFakeHTTPServer(t)
+	ctx := context.Background()
+
+	if _, err := Connect(ctx, ServerConfig{Name: "fake", URL: srv.URL}, nil); err == nil {
+		t.Fatal("expected error without auth header")
+	}
+
+	c, err := Connect(ctx, ServerConfig{Name: "fake", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(tools))
+	}
+
+	res, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"streamed"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "streamed" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestManagerTools(t *testing.T) {
+	srv := newFakeHTTPServer(t)
+	configs := []ServerConfig{
+		{Name: "fake.server", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "broken", URL: "http://127.0.0.1:1/mcp"},
+		{Name: "off", Command: "/nonexistent", Disabled: true},
+	}
+	m := NewManager(func(context.Context) ([]ServerConfig, error) { return configs, nil }, nil)
+	defer m.Close()
+
+	tools := m.Tools(context.Background())
+	if len(tools) != 2 {
+		t.Fatalf("expected 2 tools from the reachable server, got %d", len(tools))
+	}
+	if tools[0].Name != "mcp__fake_server__echo" || tools[1].Description != "Always fails" {
+		t.Errorf("unexpected tools: %s %q", tools[0].Name, tools[1].Description)
+	}
+
+	out := tools[0].Run(context.Background(), json.RawMessage(`{"text":"via manager"}`))
+	if out.Error != nil || len(out.LLMContent) != 1 || out.LLMContent[0].Text != "via manager" {
+		t.Errorf("unexpected echo output: %+v", out)
+	}
+	out = tools[1].Run(context.Background(), json.RawMessage(`{}`))
+	if out.Error == nil || out.Error.Error() != "boom" {
+		t.Errorf("expected tool error 'boom', got %+v", out)
+	}
+
+	// The tool list is cached while the config and connection are unchanged.
+	cached := m.clients["fake.server"]
+	if tools := m.Tools(context.Background()); len(tools) != 2 || m.clients["fake.server"] != cached {
+		t.Errorf("expected the cached connection to be reused, got %d tools", len(tools))
+	}
+
+	// A second server whose name sanitizes the same way gets suffixed tool names.
+	configs = append(configs, ServerConfig{Name: "fake_server", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	tools = m.Tools(context.Background())
+	if len(tools) != 4 || tools[2].Name != "mcp__fake_server__echo_2" {
+		t.Errorf("expected a suffixed duplicate tool name, got %d tools", len(tools))
+	}
+
+	// Removing a server from the config drops its tools.
+	configs = configs[1:3]
+	if tools := m.Tools(context.Background()); len(tools) != 0 {
+		t.Errorf("expected no tools after removing server, got %d", len(tools))
+	}
+}
+
+func TestManagedClientStale(t *testing.T) {
+	mc := &managedClient{ready: make(chan struct{}), client: newStdioTestClient(t)}
+	if mc.stale() {
+		t.Error("a connecting server should not be stale")
+	}
+	close(mc.ready)
+	if mc.stale() {
+		t.Error("a live connection should not be stale")
+	}
+	mc.client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !mc.stale() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a dropped connection to be stale")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if got := ToolName("my server", "do.thing"); got != "mcp__my_server__do_thing" {
+		t.Errorf("got %q", got)
+	}
+	if got := ToolName("s", strings.Repeat("x", 100)); len(got) != maxToolNameLen {
+		t.Errorf("expected name truncated to %d, got %d", maxToolNameLen, len(got))
+	}
+
+	taken := make(map[string]bool)
+	long := ToolName("s", strings.Repeat("x", 100))
+	if got := uniqueToolName(long, taken); got != long {
+		t.Errorf("expected the first name unchanged, got %q", got)
+	}
+	if got := uniqueToolName(long, taken); len(got) != maxToolNameLen || !strings.HasSuffix(got, "_2") {
+		t.Errorf("expected a suffixed name of %d characters, got %q", maxToolNameLen, got)
+	}
+}
+
+func TestParseServerConfigs(t *testing.T) {
+	if _, err := ParseServerConfigs(`[{"name":"a","command":"a-mcp"},{"name":"b","url":"http://x"}]`); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []string{
+		`{}`,
+		`[{"command":"x"}]`,
+		`[{"name":"a"}]`,
+		`[{"name":"a","command":"x","url":"http://x"}]`,
+		`[{"name":"a","command":"x"},{"name":"a","url":"http://x"}]`,
+	} {
+		if _, err := ParseServerConfigs(bad); err == nil {
+			t.Errorf("ParseServerConfigs(%s): expected error", bad)
+		}
+	}
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	block := make(chan struct{})
+	tools := []*llm.Tool{
+		{
+			Name:        "upper",
+			Description: "Uppercase text",
+			InputSchema: llm.MustSchema(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				var in struct{ Text string }
+				json.Unmarshal(input, &in)
+				return llm.ToolOut{LLMContent: llm.TextContent(strings.ToUpper(in.Text))}
+			},
+		},
+		{
+			Name:        "block",
+			InputSchema: llm.EmptySchema(),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				select {
+				case <-block:
+					return llm.ToolOut{LLMContent: llm.TextContent("unblocked")}
+				case <-ctx.Done():
+					return llm.ErrorToolOut(ctx.Err())
+				}
+			},
+		},
+	}
+	server := NewServer(Implementation{Name: "test", Version: "1"}, tools)
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(context.Background(), serverR, serverW)
+		serverW.Close()
+	}()
+
+	ctx := context.Background()
+	c, err := newClient(ctx, "shelley", newStdioTransport(clientR, clientW))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.serverInfo.Name != "test" {
+		t.Errorf("unexpected server info: %+v", c.serverInfo)
+	}
+
+	listed, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].Name != "upper" {
+		t.Fatalf("unexpected tools: %+v", listed)
+	}
+
+	// A blocked call must not hold up other requests.
+	blocked := make(chan *CallToolResult, 1)
+	go func() {
+		res, _ := c.CallTool(ctx, "block", nil)
+		blocked <- res
+	}()
+
+	res, err := c.CallTool(ctx, "upper", json.RawMessage(`{"text":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "HI" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	close(block)
+	if res := <-blocked; res == nil || res.Content[0].Text != "unblocked" {
+		t.Errorf("unexpected blocked result: %+v", res)
+	}
+
+	if _, err := c.CallTool(ctx, "missing", nil); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+
+	c.Close()
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
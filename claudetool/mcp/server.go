@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"shelley.exe.dev/llm"
+)
+
+// Server serves a fixed set of llm.Tools to an MCP client over stdio.
+type Server struct {
+	info  Implementation
+	tools map[string]*llm.Tool
+	order []*llm.Tool
+}
+
+// NewServer creates a Server offering tools.
+func NewServer(info Implementation, tools []*llm.Tool) *Server {
+	s := &Server{info: info, tools: make(map[string]*llm.Tool), order: tools}
+	for _, t := range tools {
+		s.tools[t.Name] = t
+	}
+	return s
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes responses to w
+// until r is exhausted or ctx is cancelled. Requests are handled concurrently,
+// so a long-running tool call does not block pings or other calls.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		writeMu  sync.Mutex
+		mu       sync.Mutex
+		inflight = make(map[string]context.CancelFunc)
+		wg       sync.WaitGroup
+	)
+	enc := json.NewEncoder(w)
+	send := func(v any) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		enc.Encode(v)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			send(map[string]any{"jsonrpc": jsonrpcVersion, "id": nil, "error": &RPCError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		if m.ID == nil {
+			if m.Method == "notifications/cancelled" {
+				var p struct {
+					RequestID json.RawMessage `json:"requestId"`
+				}
+				json.Unmarshal(m.Params, &p)
+				mu.Lock()
+				if cancelReq, ok := inflight[string(p.RequestID)]; ok {
+					cancelReq()
+				}
+				mu.Unlock()
+			}
+			continue
+		}
+		if m.isResponse() {
+			continue // we never send requests
+		}
+
+		key := string(*m.ID)
+		reqCtx, cancelReq := context.WithCancel(ctx)
+		mu.Lock()
+		inflight[key] = cancelReq
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(inflight, key)
+				mu.Unlock()
+				cancelReq()
+			}()
+			resp := map[string]any{"jsonrpc": jsonrpcVersion, "id": m.ID}
+			result, rpcErr := s.handle(reqCtx, &m)
+			if rpcErr != nil {
+				resp["error"] = rpcErr
+			} else {
+				resp["result"] = result
+			}
+			send(resp)
+		}()
+	}
+	cancel()
+	wg.Wait()
+	return scanner.Err()
+}
+
+func (s *Server) handle(ctx context.Context, m *message) (any, *RPCError) {
+	switch m.Method {
+	case "initialize":
+		return initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.info,
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]Tool, 0, len(s.order))
+		for _, t := range s.order {
+			tools = append(tools, Tool{Name: t.Name, Description: t.Description, InputSchema: t.InputSchema})
+		}
+		return listToolsResult{Tools: tools}, nil
+	case "tools/call":
+		var p callToolParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		tool, ok := s.tools[p.Name]
+		if !ok {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+		}
+		input := p.Arguments
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		return callResult(tool.Run(ctx, input)), nil
+	}
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+}
+
+// callResult converts llm.ToolOut to a tools/call result.
+func callResult(out llm.ToolOut) CallToolResult {
+	if out.Error != nil {
+		return CallToolResult{Content: []Content{{Type: "text", Text: out.Error.Error()}}, IsError: true}
+	}
+	res := CallToolResult{Content: []Content{}}
+	for _, c := range out.LLMContent {
+		if c.MediaType != "" && c.Data != "" {
+			res.Content = append(res.Content, Content{Type: "image", Data: c.Data, MimeType: c.MediaType})
+		} else {
+			res.Content = append(res.Content, Content{Type: "text", Text: c.Text})
+		}
+	}
+	return res
+}
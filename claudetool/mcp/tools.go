@@ -0,0 +1,292 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+const (
+	connectTimeout   = 30 * time.Second
+	listToolsTimeout = 10 * time.Second
+	// retryDelay is how long a server that failed to connect is skipped
+	// before the next attempt.
+	retryDelay = time.Minute
+	// maxToolNameLen is the longest tool name the LLM APIs accept.
+	maxToolNameLen = 64
+)
+
+// Manager maintains connections to the configured MCP servers and exposes
+// their tools as llm.Tools. A single Manager is shared by all conversations.
+type Manager struct {
+	load   func(context.Context) ([]ServerConfig, error)
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	clients map[string]*managedClient
+}
+
+// managedClient is one server's connection and its cached tool list.
+// The fields after ready are written once, before ready is closed.
+type managedClient struct {
+	cfg   ServerConfig
+	ready chan struct{}
+
+	client   *Client
+	tools    []*llm.Tool
+	err      error
+	failedAt time.Time
+}
+
+// stale reports whether mc should be replaced by a new connection.
+func (mc *managedClient) stale() bool {
+	select {
+	case <-mc.ready:
+	default:
+		return false // still connecting
+	}
+	if mc.err != nil {
+		return time.Since(mc.failedAt) > retryDelay
+	}
+	return mc.client.Lost()
+}
+
+// close disconnects once any in-flight connect finishes, without blocking.
+func (mc *managedClient) close() {
+	go func() {
+		<-mc.ready
+		if mc.client != nil {
+			mc.client.Close()
+		}
+	}()
+}
+
+// NewManager creates a Manager. load is called each time tools are requested,
+// so configuration changes take effect for new conversations without a restart.
+func NewManager(load func(context.Context) ([]ServerConfig, error), logger *slog.Logger) *Manager {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Manager{
+		load:    load,
+		logger:  logger,
+		clients: make(map[string]*managedClient),
+	}
+}
+
+// Tools returns the tools of every configured MCP server, connecting to servers
+// as needed. Servers that cannot be reached are logged and skipped, so a broken
+// server never prevents a conversation from starting.
+//
+// Each server's tool list is cached until its config changes or its connection
+// drops, and connections are made concurrently without holding the lock, so
+// a slow server only delays the conversations that are waiting for it.
+func (m *Manager) Tools(ctx context.Context) []*llm.Tool {
+	configs, err := m.load(ctx)
+	if err != nil {
+		m.logger.Error("failed to load MCP server config", "error", err)
+		return nil
+	}
+
+	m.mu.Lock()
+	servers := m.sync(configs)
+	m.mu.Unlock()
+
+	var tools []*llm.Tool
+	taken := make(map[string]bool)
+	for _, mc := range servers {
+		select {
+		case <-mc.ready:
+		case <-ctx.Done():
+			return tools
+		}
+		for _, t := range mc.tools {
+			tool := *t
+			tool.Name = uniqueToolName(t.Name, taken)
+			tools = append(tools, &tool)
+		}
+	}
+	return tools
+}
+
+// sync brings m.clients in line with configs, starting connections to new,
+// changed and dropped servers, and returns the enabled servers in config order.
+// m.mu must be held.
+func (m *Manager) sync(configs []ServerConfig) []*managedClient {
+	wanted := make(map[string]bool)
+	var servers []*managedClient
+	for _, cfg := range configs {
+		if cfg.Disabled {
+			continue
+		}
+		wanted[cfg.Name] = true
+		mc, ok := m.clients[cfg.Name]
+		if ok && (!reflect.DeepEqual(mc.cfg, cfg) || mc.stale()) {
+			mc.close()
+			ok = false
+		}
+		if !ok {
+			mc = &managedClient{cfg: cfg, ready: make(chan struct{})}
+			m.clients[cfg.Name] = mc
+			go m.connect(mc)
+		}
+		servers = append(servers, mc)
+	}
+	for name, mc := range m.clients {
+		if !wanted[name] {
+			mc.close()
+			delete(m.clients, name)
+		}
+	}
+	return servers
+}
+
+// connect connects to mc's server and lists its tools, then closes mc.ready.
+func (m *Manager) connect(mc *managedClient) {
+	defer close(mc.ready)
+	fail := func(msg string, err error) {
+		m.logger.Warn(msg, "mcpServer", mc.cfg.Name, "error", err)
+		mc.err = err
+		mc.failedAt = time.Now()
+	}
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	client, err := Connect(connectCtx, mc.cfg, m.logger)
+	cancel()
+	if err != nil {
+		fail("failed to connect to MCP server", err)
+		return
+	}
+	listCtx, cancel := context.WithTimeout(context.Background(), listToolsTimeout)
+	serverTools, err := client.ListTools(listCtx)
+	cancel()
+	if err != nil {
+		client.Close()
+		fail("failed to list MCP tools", err)
+		return
+	}
+	mc.client = client
+	for _, t := range serverTools {
+		mc.tools = append(mc.tools, client.llmTool(t))
+	}
+}
+
+// Close disconnects from all servers, waiting for in-flight connects.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*managedClient)
+	m.mu.Unlock()
+	for _, mc := range clients {
+		<-mc.ready
+		if mc.client != nil {
+			mc.client.Close()
+		}
+	}
+}
+
+// ToolName returns the llm.Tool name for tool on the named server.
+// Names are namespaced to avoid collisions with built-in tools and other servers,
+// and restricted to the characters the LLM APIs allow.
+func ToolName(server, tool string) string {
+	name := "mcp__" + sanitizeName(server) + "__" + sanitizeName(tool)
+	if len(name) > maxToolNameLen {
+		name = name[:maxToolNameLen]
+	}
+	return name
+}
+
+// uniqueToolName returns name, or if it is already taken (two servers with
+// the same sanitized name, or names that only differ past the length limit),
+// name with a numeric suffix. The result is added to taken.
+func uniqueToolName(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		suffix := "_" + strconv.Itoa(i)
+		unique = name[:min(len(name), maxToolNameLen-len(suffix))] + suffix
+	}
+	taken[unique] = true
+	return unique
+}
+
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// llmTool adapts an MCP tool to an llm.Tool whose Run proxies to the server.
+func (c *Client) llmTool(t Tool) *llm.Tool {
+	schema := t.InputSchema
+	if len(schema) == 0 {
+		schema = llm.EmptySchema()
+	}
+	description := t.Description
+	if description == "" {
+		description = t.Title
+	}
+	name := t.Name
+	return &llm.Tool{
+		Name:        ToolName(c.name, name),
+		Description: description,
+		InputSchema: schema,
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			res, err := c.CallTool(ctx, name, input)
+			if err != nil {
+				return llm.ErrorfToolOut("mcp tool %s failed: %w", name, err)
+			}
+			return toolOut(res)
+		},
+	}
+}
+
+// toolOut converts a tools/call result to llm.ToolOut.
+func toolOut(res *CallToolResult) llm.ToolOut {
+	var contents []llm.Content
+	var texts []string
+	for _, c := range res.Content {
+		switch c.Type {
+		case "text":
+			contents = append(contents, llm.Content{Type: llm.ContentTypeText, Text: c.Text})
+			texts = append(texts, c.Text)
+		case "image":
+			contents = append(contents, llm.Content{Type: llm.ContentTypeText, MediaType: c.MimeType, Data: c.Data})
+		case "resource":
+			var r struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			}
+			json.Unmarshal(c.Resource, &r)
+			text := fmt.Sprintf("[resource %s]\n%s", r.URI, r.Text)
+			contents = append(contents, llm.Content{Type: llm.ContentTypeText, Text: text})
+			texts = append(texts, text)
+		default:
+			text := fmt.Sprintf("[unsupported %s content omitted]", c.Type)
+			contents = append(contents, llm.Content{Type: llm.ContentTypeText, Text: text})
+			texts = append(texts, text)
+		}
+	}
+	if res.IsError {
+		msg := strings.Join(texts, "\n")
+		if msg == "" {
+			msg = "tool reported an error"
+		}
+		return llm.ErrorToolOut(errors.New(msg))
+	}
+	if len(contents) == 0 {
+		contents = llm.TextContent("(no output)")
+	}
+	return llm.ToolOut{LLMContent: contents}
+}
@@ -1,13 +1,10 @@
 package claudetool
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go/parser"
-	"go/token"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -29,6 +26,8 @@ type PatchCallback func(input PatchInput, output llm.ToolOut) llm.ToolOut
 // PatchTools are not concurrency-safe.
 type PatchTool struct {
 	Callback PatchCallback // may be nil
+	// Diagnostics, if set, checks changed files and reports new problems.
+	Diagnostics *Diagnostics
 	// WorkingDir is the shared mutable working directory.
 	WorkingDir *MutableWorkingDir
 	// Simplified indicates whether to use the simplified input schema.
@@ -535,6 +534,7 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if err := os.MkdirAll(filepath.Dir(input.Path), 0o700); err != nil {
 		return llm.ErrorfToolOut("failed to create directory %q: %w", filepath.Dir(input.Path), err)
 	}
+	checks := p.Diagnostics.prepare(ctx, input.Path)
 	if err := os.WriteFile(input.Path, patched, 0o600); err != nil {
 		return llm.ErrorfToolOut("failed to write patched contents to file %q: %w", input.Path, err)
 	}
@@ -549,6 +549,10 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 		fmt.Fprintf(response, "<warning>%q appears to be autogenerated. Patches were applied anyway.</warning>\n", input.Path)
 	}
 
+	if report := p.Diagnostics.report(ctx, checks); report != "" {
+		fmt.Fprintf(response, "<diagnostics>\nThe file was patched, but these problems are new since the patch:\n%s</diagnostics>\n", report)
+	}
+
 	diff := generateUnifiedDiff(input.Path, string(orig), string(patched))
 
 	// Display data for the UI includes structured content for Monaco diff editor
@@ -565,51 +569,6 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	}
 }
 
-// IsAutogeneratedGoFile reports whether a Go file has markers indicating it was autogenerated.
-func IsAutogeneratedGoFile(buf []byte) bool {
-	for _, sig := range autogeneratedSignals {
-		if bytes.Contains(buf, []byte(sig)) {
-			return true
-		}
-	}
-
-	// https://pkg.go.dev/cmd/go#hdr-Generate_Go_files_by_processing_source
-	// "This line must appear before the first non-comment, non-blank text in the file."
-	// Approximate that by looking for it at the top of the file, before the last of the imports.
-	// (Sometimes people put it after the package declaration, because of course they do.)
-	// At least in the imports region we know it's not part of their actual code;
-	// we don't want to ignore the generator (which also includes these strings!),
-	// just the generated code.
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, "x.go", buf, parser.ImportsOnly|parser.ParseComments)
-	if err == nil {
-		for _, cg := range f.Comments {
-			t := strings.ToLower(cg.Text())
-			for _, sig := range autogeneratedHeaderSignals {
-				if strings.Contains(t, sig) {
-					return true
-				}
-			}
-		}
-	}
-
-	return false
-}
-
-// autogeneratedSignals are signals that a file is autogenerated, when present anywhere in the file.
-var autogeneratedSignals = [][]byte{
-	[]byte("\nfunc bindataRead("), // pre-embed bindata packed file
-}
-
-// autogeneratedHeaderSignals are signals that a file is autogenerated, when present at the top of the file.
-var autogeneratedHeaderSignals = []string{
-	// canonical would be `(?m)^// Code generated .* DO NOT EDIT\.$`
-	// but people screw it up, a lot, so be more lenient
-	strings.ToLower("generate"),
-	strings.ToLower("DO NOT EDIT"),
-	strings.ToLower("export by"),
-}
-
 func generateUnifiedDiff(filePath, original, patched string) string {
 	buf := new(strings.Builder)
 	err := diff.Text(filePath, filePath, original, patched, buf)
@@ -0,0 +1,155 @@
+package claudetool
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"shelley.exe.dev/claudetool/bashkit"
+)
+
+// PermissionAction is the outcome of evaluating a bash command against a PermissionPolicy.
+type PermissionAction string
+
+const (
+	PermissionAllow PermissionAction = "allow"
+	PermissionAsk   PermissionAction = "ask"
+	PermissionDeny  PermissionAction = "deny"
+)
+
+// valid reports whether a is a known action.
+func (a PermissionAction) valid() bool {
+	switch a {
+	case PermissionAllow, PermissionAsk, PermissionDeny:
+		return true
+	}
+	return false
+}
+
+// stricter reports whether a is more restrictive than b (deny > ask > allow).
+func (a PermissionAction) stricter(b PermissionAction) bool {
+	rank := func(x PermissionAction) int {
+		switch x {
+		case PermissionDeny:
+			return 2
+		case PermissionAsk:
+			return 1
+		}
+		return 0
+	}
+	return rank(a) > rank(b)
+}
+
+// PermissionRule matches a program name and assigns it an action.
+type PermissionRule struct {
+	// Program is a path.Match pattern matched against program names
+	// as returned by bashkit.Programs (e.g. "git", "rm", "docker*", "*").
+	Program string           `json:"program"`
+	Action  PermissionAction `json:"action"`
+}
+
+// PermissionPolicy is a declarative allow/deny/ask policy for bash commands.
+//
+// Each program invoked by a command is matched against Rules in order; the first
+// matching rule decides that program's action, and Default applies to programs
+// that match no rule (and to commands that invoke no external program at all).
+// The command's overall action is the strictest of its programs' actions.
+// Commands that run code the policy cannot see, such as eval or a computed
+// command name, are asked about at least.
+//
+// Like bashkit.Check, this is a guard rail, not a sandbox: programs run from
+// inside scripts or by other programs (find -exec, sh -c) are not visible to it.
+type PermissionPolicy struct {
+	Rules   []PermissionRule `json:"rules"`
+	Default PermissionAction `json:"default,omitempty"`
+}
+
+// ParsePermissionPolicy parses and validates a JSON-encoded PermissionPolicy.
+func ParsePermissionPolicy(data string) (*PermissionPolicy, error) {
+	var p PermissionPolicy
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return nil, fmt.Errorf("invalid permission policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks that every rule has a well-formed pattern and a known action.
+func (p *PermissionPolicy) Validate() error {
+	if p.Default != "" && !p.Default.valid() {
+		return fmt.Errorf("invalid permission policy: unknown default action %q", p.Default)
+	}
+	for i, r := range p.Rules {
+		if r.Program == "" {
+			return fmt.Errorf("invalid permission policy: rule %d has no program", i)
+		}
+		if _, err := path.Match(r.Program, ""); err != nil {
+			return fmt.Errorf("invalid permission policy: rule %d: bad pattern %q: %w", i, r.Program, err)
+		}
+		if !r.Action.valid() {
+			return fmt.Errorf("invalid permission policy: rule %d: unknown action %q", i, r.Action)
+		}
+	}
+	return nil
+}
+
+// defaultAction returns p.Default, treating an empty value as allow.
+func (p *PermissionPolicy) defaultAction() PermissionAction {
+	if p.Default == "" {
+		return PermissionAllow
+	}
+	return p.Default
+}
+
+// restrictive reports whether p denies or asks about anything.
+func (p *PermissionPolicy) restrictive() bool {
+	if p.defaultAction() != PermissionAllow {
+		return true
+	}
+	for _, r := range p.Rules {
+		if r.Action != PermissionAllow {
+			return true
+		}
+	}
+	return false
+}
+
+// actionFor returns the action for a single program name.
+func (p *PermissionPolicy) actionFor(program string) PermissionAction {
+	for _, r := range p.Rules {
+		if ok, _ := path.Match(r.Program, program); ok {
+			return r.Action
+		}
+	}
+	return p.defaultAction()
+}
+
+// Evaluate returns the action for command, along with the programs it invokes.
+// Commands that cannot be parsed are never allowed outright: they get the
+// default action if that is deny, and ask otherwise. Neither are commands that
+// run code the policy cannot see, or that run no program it can match against
+// a rule, unless the policy allows everything.
+func (p *PermissionPolicy) Evaluate(command string) (PermissionAction, []string) {
+	programs, opaque, err := bashkit.Programs(command)
+	if err != nil {
+		if p.defaultAction() == PermissionDeny {
+			return PermissionDeny, nil
+		}
+		return PermissionAsk, nil
+	}
+	action := PermissionAllow
+	if len(programs) == 0 {
+		action = p.defaultAction()
+	}
+	for _, prog := range programs {
+		if a := p.actionFor(prog); a.stricter(action) {
+			action = a
+		}
+	}
+	if (opaque || len(programs) == 0) && p.restrictive() && PermissionAsk.stricter(action) {
+		action = PermissionAsk
+	}
+	return action, programs
+}
@@ -0,0 +1,113 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPermissionPolicyEvaluate(t *testing.T) {
+	policy := &PermissionPolicy{
+		Rules: []PermissionRule{
+			{Program: "rm", Action: PermissionDeny},
+			{Program: "git", Action: PermissionAllow},
+			{Program: "docker*", Action: PermissionAsk},
+			{Program: "ls", Action: PermissionAllow},
+		},
+		Default: PermissionAsk,
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		want    PermissionAction
+	}{
+		{"allowed program", "git status", PermissionAllow},
+		{"denied program", "rm -rf build", PermissionDeny},
+		{"glob ask", "docker-compose up", PermissionAsk},
+		{"unmatched uses default", "curl example.com", PermissionAsk},
+		{"strictest wins", "git status && rm -f x", PermissionDeny},
+		{"all allowed", "ls | git diff", PermissionAllow},
+		{"builtins only uses default", "echo hi", PermissionAsk},
+		{"unparseable is not allowed", "if then fi ((", PermissionAsk},
+		{"path is matched by name", "/bin/rm -rf x", PermissionDeny},
+		{"command wrapper", "command rm x", PermissionDeny},
+		{"exec wrapper", "exec rm x", PermissionDeny},
+		{"sudo wrapper", "sudo -u root rm x", PermissionDeny},
+		{"quoted name", `r""m x`, PermissionDeny},
+		{"eval asks", "git status && eval 'rm x'", PermissionAsk},
+		{"computed name asks", `"$(echo rm)" x`, PermissionAsk},
+		{"deny beats opaque", "eval 'ls' && rm x", PermissionDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := policy.Evaluate(tt.command)
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty default allows", func(t *testing.T) {
+		p := &PermissionPolicy{Rules: []PermissionRule{{Program: "rm", Action: PermissionDeny}}}
+		if got, _ := p.Evaluate("cat foo"); got != PermissionAllow {
+			t.Errorf("got %q, want allow", got)
+		}
+		// Commands the policy cannot see into are not allowed just because
+		// no rule matched.
+		for _, command := range []string{"echo hi", "eval 'rm x'", "source ./script.sh", ". ./script.sh", "$CMD x"} {
+			if got, _ := p.Evaluate(command); got != PermissionAsk {
+				t.Errorf("Evaluate(%q) = %q, want ask", command, got)
+			}
+		}
+	})
+
+	t.Run("allow-all policy allows everything", func(t *testing.T) {
+		p := &PermissionPolicy{Rules: []PermissionRule{{Program: "*", Action: PermissionAllow}}}
+		if got, _ := p.Evaluate("eval 'rm x'"); got != PermissionAllow {
+			t.Errorf("got %q, want allow", got)
+		}
+	})
+}
+
+func TestParsePermissionPolicy(t *testing.T) {
+	p, err := ParsePermissionPolicy(`{"rules":[{"program":"rm","action":"deny"}],"default":"ask"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.Rules) != 1 || p.Rules[0].Action != PermissionDeny || p.Default != PermissionAsk {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+
+	for _, bad := range []string{
+		`not json`,
+		`{"rules":[{"program":"rm","action":"maybe"}]}`,
+		`{"rules":[{"program":"","action":"deny"}]}`,
+		`{"rules":[{"program":"[","action":"deny"}]}`,
+		`{"default":"sometimes"}`,
+	} {
+		if _, err := ParsePermissionPolicy(bad); err == nil {
+			t.Errorf("ParsePermissionPolicy(%q): expected error", bad)
+		}
+	}
+}
+
+func TestBashToolCheckPermission(t *testing.T) {
+	tool := &BashTool{
+		WorkingDir: NewMutableWorkingDir(t.TempDir()),
+		CheckPermission: func(ctx context.Context, command string) error {
+			if strings.Contains(command, "forbidden") {
+				return errors.New("permission denied: nope")
+			}
+			return nil
+		},
+	}
+
+	input, _ := json.Marshal(bashInput{Command: "echo forbidden"})
+	out := tool.Run(context.Background(), input)
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "permission denied") {
+		t.Fatalf("expected permission error, got %v", out.Error)
+	}
+}
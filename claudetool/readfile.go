@@ -0,0 +1,282 @@
+package claudetool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/imageutil"
+)
+
+// ReadFileTool reads text files with line numbers, and images.
+type ReadFileTool struct {
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// MaxImageDimension is the largest image width or height the model
+	// accepts; larger images are scaled down. Zero means no limit.
+	MaxImageDimension int
+}
+
+const (
+	readFileName        = "read_file"
+	readFileDescription = `Read a file from the filesystem.
+
+Text files are returned with line numbers, starting at line 1 or at offset.
+At most limit lines are returned (default 2000); use offset and limit to page through large files.
+Very long lines are truncated.
+
+Image files (PNG, JPEG, GIF, WebP, HEIC) are returned as images.
+Other binary files are not returned; use bash tools such as xxd or file to inspect them.
+
+Prefer this tool over cat, head, sed -n, etc. for reading files.
+`
+	readFileInputSchema = `{
+  "type": "object",
+  "required": ["path"],
+  "properties": {
+    "path": {
+      "type": "string",
+      "description": "The file to read (absolute or relative to the working directory)"
+    },
+    "offset": {
+      "type": "integer",
+      "description": "1-based line number to start reading from (default 1)"
+    },
+    "limit": {
+      "type": "integer",
+      "description": "Maximum number of lines to read (default 2000)"
+    }
+  }
+}`
+)
+
+const (
+	readFileDefaultLimit = 2000
+	// readFileMaxLineLength bounds each displayed line; minified code can
+	// have lines of megabytes.
+	readFileMaxLineLength = 2000
+	// readFileMaxOutput bounds the text returned by a single call.
+	readFileMaxOutput = 256 * 1024
+	// readFileMaxImageSize bounds the images read into memory.
+	readFileMaxImageSize = 32 * 1024 * 1024
+	// binarySniffLen is how much of a file is examined to decide if it is an
+	// image or binary.
+	binarySniffLen = 8 * 1024
+)
+
+type readFileInput struct {
+	Path   string `json:"path"`
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// Tool returns an llm.Tool for reading files.
+func (r *ReadFileTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        readFileName,
+		Description: readFileDescription,
+		InputSchema: llm.MustSchema(readFileInputSchema),
+		Parallel:    true,
+		Run:         r.Run,
+	}
+}
+
+// Run executes the read_file tool.
+func (r *ReadFileTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req readFileInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse read_file input: %w", err)
+	}
+	if req.Path == "" {
+		return llm.ErrorfToolOut("path is required")
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return llm.ErrorfToolOut("offset and limit must not be negative")
+	}
+
+	path := req.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.WorkingDir.Get(), path)
+	}
+	path = filepath.Clean(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return llm.ErrorfToolOut("file does not exist: %s", path)
+		}
+		return llm.ErrorfToolOut("failed to open file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return llm.ErrorfToolOut("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return llm.ErrorfToolOut("%s is a directory; use bash to list it", path)
+	}
+
+	// Decide what the file is from its start, so that large files are never
+	// read into memory whole.
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, head)
+	complete := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	if err != nil && !complete {
+		return llm.ErrorfToolOut("failed to read file: %w", err)
+	}
+	head = head[:n]
+
+	if imageutil.IsImage(head) {
+		if info.Size() > readFileMaxImageSize {
+			return llm.ErrorfToolOut("%s is an image of %s, too large to read; use bash tools to shrink it first",
+				path, humanizeBytes(int(info.Size())))
+		}
+		rest, err := io.ReadAll(f)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to read file: %w", err)
+		}
+		return r.readImage(path, append(head, rest...))
+	}
+	if isBinary(head, complete) {
+		return llm.ErrorfToolOut("%s is a binary file (%s, %s); use bash tools such as xxd or file to inspect it",
+			path, http.DetectContentType(head), humanizeBytes(int(info.Size())))
+	}
+	text, err := formatFileLines(path, head, io.MultiReader(bytes.NewReader(head), f), req.Offset, req.Limit)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to read file: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text)}
+}
+
+// readImage returns an image the same way read_image does.
+func (r *ReadFileTool) readImage(path string, data []byte) llm.ToolOut {
+	img, err := imageutil.Prepare(data, r.MaxImageDimension)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	description := fmt.Sprintf("Image from %s (type: %s)", path, img.MediaType)
+	if img.Converted {
+		description += " [converted from HEIC]"
+	}
+	if img.Resized {
+		description += " [resized]"
+	}
+	return llm.ToolOut{LLMContent: []llm.Content{
+		{
+			Type: llm.ContentTypeText,
+			Text: description,
+		},
+		{
+			Type:      llm.ContentTypeText,
+			MediaType: img.MediaType,
+			Data:      base64.StdEncoding.EncodeToString(img.Data),
+		},
+	}}
+}
+
+// isBinary reports whether a file starting with sniff looks like something
+// other than text: it has a NUL byte or invalid UTF-8. complete is whether
+// sniff is the whole file.
+func isBinary(sniff []byte, complete bool) bool {
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	// A truncated prefix may end partway through a multi-byte character.
+	for i := 0; !complete && len(sniff) > 0 && i < utf8.UTFMax-1 && !utf8.Valid(sniff); i++ {
+		sniff = sniff[:len(sniff)-1]
+	}
+	return !utf8.Valid(sniff)
+}
+
+// formatFileLines returns the lines read from r starting at the 1-based
+// offset, numbered like cat -n, with a note on what was left out. head is the
+// start of the file, used to spot autogenerated code. The rest of the file is
+// scanned to count its lines, but only the lines shown are kept.
+func formatFileLines(path string, head []byte, r io.Reader, offset, limit int) (string, error) {
+	if offset == 0 {
+		offset = 1
+	}
+	if limit == 0 {
+		limit = readFileDefaultLimit
+	}
+
+	var b strings.Builder
+	if IsAutogeneratedFile(path, head) {
+		b.WriteString("[this file appears to be autogenerated; change its source rather than editing it directly]\n")
+	}
+	if len(head) == 0 {
+		b.WriteString("[empty file]")
+		return b.String(), nil
+	}
+
+	br := bufio.NewReader(r)
+	total, last := 0, offset-1
+	full := false
+	for {
+		line, truncated, err := readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		total++
+		if full || total < offset || total >= offset+limit {
+			continue
+		}
+		if truncated {
+			line += "... [line truncated]"
+		}
+		if b.Len()+len(line) > readFileMaxOutput {
+			full = true
+			continue
+		}
+		fmt.Fprintf(&b, "%6d\t%s\n", total, line)
+		last = total
+	}
+	if offset > total {
+		fmt.Fprintf(&b, "[offset %d is past the end of the file, which has %d lines]", offset, total)
+		return b.String(), nil
+	}
+	if offset > 1 || last < total {
+		fmt.Fprintf(&b, "[showing lines %d-%d of %d", offset, last, total)
+		if last < total {
+			fmt.Fprintf(&b, "; use offset=%d to read more", last+1)
+		}
+		b.WriteString("]")
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// readLine returns the next line from br without its line ending, keeping at
+// most readFileMaxLineLength bytes of it; truncated reports whether any were
+// dropped. It returns io.EOF after the last line.
+func readLine(br *bufio.Reader) (line string, truncated bool, err error) {
+	var buf []byte
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return "", false, err
+		}
+		room := readFileMaxLineLength - len(buf)
+		if len(chunk) > room {
+			chunk = chunk[:room]
+			truncated = true
+		}
+		buf = append(buf, chunk...)
+		if !isPrefix {
+			return string(buf), truncated, nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runReadFile(t *testing.T, tool *ReadFileTool, input readFileInput) (string, error) {
+	t.Helper()
+	m, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := tool.Run(context.Background(), m)
+	if out.Error != nil {
+		return "", out.Error
+	}
+	return out.LLMContent[0].Text, nil
+}
+
+func TestReadFileText(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ten.txt"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ReadFileTool{WorkingDir: NewMutableWorkingDir(dir)}
+
+	tests := []struct {
+		name  string
+		input readFileInput
+		want  string
+	}{
+		{
+			name:  "whole file",
+			input: readFileInput{Path: "ten.txt"},
+			want:  "     1\tline 1\n     2\tline 2\n     3\tline 3\n     4\tline 4\n     5\tline 5\n     6\tline 6\n     7\tline 7\n     8\tline 8\n     9\tline 9\n    10\tline 10",
+		},
+		{
+			name:  "range",
+			input: readFileInput{Path: filepath.Join(dir, "ten.txt"), Offset: 4, Limit: 2},
+			want:  "     4\tline 4\n     5\tline 5\n[showing lines 4-5 of 10; use offset=6 to read more]",
+		},
+		{
+			name:  "tail",
+			input: readFileInput{Path: "ten.txt", Offset: 10},
+			want:  "    10\tline 10\n[showing lines 10-10 of 10]",
+		},
+		{
+			name:  "past the end",
+			input: readFileInput{Path: "ten.txt", Offset: 11},
+			want:  "[offset 11 is past the end of the file, which has 10 lines]",
+		},
+		{
+			name:  "empty",
+			input: readFileInput{Path: "empty.txt"},
+			want:  "[empty file]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runReadFile(t, tool, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileLarge(t *testing.T) {
+	dir := t.TempDir()
+	// A line far longer than the read buffer, then enough lines to run well
+	// past the part of the file sniffed for binary content.
+	var src strings.Builder
+	src.WriteString(strings.Repeat("x", 1<<20) + "\n")
+	for i := 2; i <= 5000; i++ {
+		fmt.Fprintf(&src, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "big.txt"), []byte(src.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ReadFileTool{WorkingDir: NewMutableWorkingDir(dir)}
+
+	got, err := runReadFile(t, tool, readFileInput{Path: "big.txt", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "     1\t" + strings.Repeat("x", readFileMaxLineLength) + "... [line truncated]\n     2\tline 2\n[showing lines 1-2 of 5000; use offset=3 to read more]"
+	if got != want {
+		t.Errorf("unexpected output %q", got[max(0, len(got)-200):])
+	}
+
+	got, err = runReadFile(t, tool, readFileInput{Path: "big.txt", Offset: 4999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "  4999\tline 4999\n  5000\tline 5000\n[showing lines 4999-5000 of 5000]" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestReadFileAutogenerated(t *testing.T) {
+	dir := t.TempDir()
+	src := "// Code generated by sqlc. DO NOT EDIT.\n\npackage db\n"
+	if err := os.WriteFile(filepath.Join(dir, "query.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ReadFileTool{WorkingDir: NewMutableWorkingDir(dir)}
+	got, err := runReadFile(t, tool, readFileInput{Path: "query.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "[this file appears to be autogenerated") || !strings.Contains(got, "     3\tpackage db") {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), []byte("ELF\x00\x01\x02"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "latin1.txt"), []byte("caf\xe9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ReadFileTool{WorkingDir: NewMutableWorkingDir(dir)}
+
+	tests := []struct {
+		input readFileInput
+		want  string
+	}{
+		{readFileInput{Path: "data.bin"}, "is a binary file"},
+		{readFileInput{Path: "latin1.txt"}, "is a binary file"},
+		{readFileInput{Path: "missing.txt"}, "does not exist"},
+		{readFileInput{Path: "."}, "is a directory"},
+		{readFileInput{}, "path is required"},
+		{readFileInput{Path: "data.bin", Offset: -1}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		_, err := runReadFile(t, tool, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestReadFileImage(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shot.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ReadFileTool{WorkingDir: NewMutableWorkingDir(dir), MaxImageDimension: 100}
+
+	m, _ := json.Marshal(readFileInput{Path: "shot.png"})
+	out := tool.Run(context.Background(), m)
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if len(out.LLMContent) != 2 {
+		t.Fatalf("expected a description and an image, got %d contents", len(out.LLMContent))
+	}
+	if !strings.Contains(out.LLMContent[0].Text, "[resized]") {
+		t.Errorf("expected the image to be resized, got %q", out.LLMContent[0].Text)
+	}
+	if out.LLMContent[1].MediaType != "image/png" || out.LLMContent[1].Data == "" {
+		t.Errorf("unexpected image content: %+v", out.LLMContent[1].MediaType)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	// A multi-byte character cut off by the sniff length is still text.
+	text := strings.Repeat("a", binarySniffLen-1) + "é"
+	if isBinary([]byte(text), false) {
+		t.Error("expected text with a split UTF-8 character at the sniff boundary not to be binary")
+	}
+	if !isBinary([]byte(text[:binarySniffLen]), true) {
+		t.Error("expected a file ending partway through a character to be binary")
+	}
+	if !isBinary([]byte("a\x00b"), true) {
+		t.Error("expected NUL bytes to mean binary")
+	}
+}
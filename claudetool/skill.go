@@ -0,0 +1,212 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/skills"
+)
+
+// SkillTool loads skills: instructions, and files bundled with them, that
+// extend what the agent can do. While a skill that declares allowed-tools is
+// active, the conversation's tools are narrowed to those it allows.
+type SkillTool struct {
+	// Skills are the skills that can be loaded.
+	Skills []skills.Skill
+	// OnLoad, if set, is called with the name of each skill loaded, to record its use.
+	OnLoad func(name string)
+	// Restrict narrows the tool set to the named tools, or with nil restores
+	// every tool. It returns the names of the tools now available.
+	Restrict func(allowed []string) []string
+}
+
+const (
+	skillName        = "skill"
+	skillDescription = `Load a skill from <available_skills>.
+
+Use action "load" when a task matches a skill's description. It returns the skill's instructions and a list of the files bundled with it.
+Use action "read" with a file from that list to read a bundled script, reference or template.
+
+Some skills limit which tools may be used while they are active. The limit lasts until you call this tool with action "finish", load another skill, or the user sends a new message.
+`
+	skillInputSchema = `{
+  "type": "object",
+  "properties": {
+    "action": {
+      "type": "string",
+      "enum": ["load", "read", "finish"],
+      "description": "load a skill (default), read one of its bundled files, or finish the active skill"
+    },
+    "name": {
+      "type": "string",
+      "description": "The skill's name (required for load and read)"
+    },
+    "file": {
+      "type": "string",
+      "description": "For read: a bundled file, relative to the skill's directory"
+    }
+  }
+}`
+)
+
+// skillMaxFileSize bounds the bundled files the read action returns.
+const skillMaxFileSize = 256 * 1024
+
+// skillToolAliases maps tool names used by skills written for other agents
+// to the corresponding Shelley tools.
+var skillToolAliases = map[string]string{
+	"read":      readFileName,
+	"edit":      PatchName,
+	"multiedit": PatchName,
+	"write":     PatchName,
+	"task":      subagentName,
+}
+
+type skillInput struct {
+	Action string `json:"action,omitempty"`
+	Name   string `json:"name,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+// Tool returns an llm.Tool for loading skills.
+func (t *SkillTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        skillName,
+		Description: skillDescription,
+		InputSchema: llm.MustSchema(skillInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run executes the skill tool.
+func (t *SkillTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req skillInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse skill input: %w", err)
+	}
+
+	switch req.Action {
+	case "", "load":
+		skill, err := t.find(req.Name)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		return t.load(skill)
+	case "read":
+		skill, err := t.find(req.Name)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		return readSkillFile(skill, req.File)
+	case "finish":
+		if t.Restrict != nil {
+			t.Restrict(nil)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent("Skill finished; every tool is available again.")}
+	default:
+		return llm.ErrorfToolOut("unknown action %q (use load, read or finish)", req.Action)
+	}
+}
+
+func (t *SkillTool) find(name string) (skills.Skill, error) {
+	if name == "" {
+		return skills.Skill{}, fmt.Errorf("name is required")
+	}
+	for _, skill := range t.Skills {
+		if skill.Name == name {
+			return skill, nil
+		}
+	}
+	names := make([]string, len(t.Skills))
+	for i, skill := range t.Skills {
+		names[i] = skill.Name
+	}
+	return skills.Skill{}, fmt.Errorf("no skill named %q; available skills: %s", name, strings.Join(names, ", "))
+}
+
+func (t *SkillTool) load(skill skills.Skill) llm.ToolOut {
+	body, err := skills.Body(skill.Path)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to read %s: %w", skill.Path, err)
+	}
+	resources, err := skills.Resources(skill)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to list files for skill %s: %w", skill.Name, err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<skill name=%q path=%q>\n%s\n</skill>\n", skill.Name, skill.Path, body)
+	if len(resources) > 0 {
+		sb.WriteString("\nBundled files (read them with action \"read\"):\n")
+		for _, r := range resources {
+			fmt.Fprintf(&sb, "- %s\n", r)
+		}
+	}
+
+	if t.Restrict != nil {
+		allowed := skillAllowedTools(skill)
+		available := t.Restrict(allowed)
+		if allowed != nil {
+			fmt.Fprintf(&sb, "\nWhile this skill is active, only these tools are available: %s\n", strings.Join(available, ", "))
+		}
+	}
+
+	if t.OnLoad != nil {
+		t.OnLoad(skill.Name)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(sb.String())}
+}
+
+// skillAllowedTools returns the Shelley tools a skill's allowed-tools field
+// names, or nil if the skill does not limit its tools.
+func skillAllowedTools(skill skills.Skill) []string {
+	names := skill.AllowedToolNames()
+	if names == nil {
+		return nil
+	}
+	allowed := []string{}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if alias, ok := skillToolAliases[name]; ok {
+			name = alias
+		}
+		if !slices.Contains(allowed, name) {
+			allowed = append(allowed, name)
+		}
+	}
+	return allowed
+}
+
+// readSkillFile returns a file bundled with a skill. The file must be inside
+// the skill's directory.
+func readSkillFile(skill skills.Skill, file string) llm.ToolOut {
+	if file == "" {
+		return llm.ErrorfToolOut("file is required for read")
+	}
+	dir := filepath.Dir(skill.Path)
+	path := filepath.Join(dir, filepath.FromSlash(file))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return llm.ErrorfToolOut("%s is not inside the skill's directory", file)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if info.IsDir() {
+		return llm.ErrorfToolOut("%s is a directory", file)
+	}
+	if info.Size() > skillMaxFileSize {
+		return llm.ErrorfToolOut("%s is %d bytes, more than the %d this tool returns; use bash to inspect it at %s", file, info.Size(), skillMaxFileSize, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(string(data))}
+}
@@ -0,0 +1,117 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/skills"
+)
+
+func writeTestSkill(t *testing.T, frontmatter string) skills.Skill {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "release")
+	if err := os.MkdirAll(filepath.Join(dir, "scripts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\nname: release\ndescription: Cut a release.\n" + frontmatter + "---\n\nRun scripts/tag.sh.\n"
+	if err := os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "scripts", "tag.sh"), []byte("git tag v1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	skill, err := skills.Parse(filepath.Join(dir, "SKILL.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return skill
+}
+
+func runSkillTool(t *testing.T, tool *SkillTool, input skillInput) (string, error) {
+	t.Helper()
+	raw, _ := json.Marshal(input)
+	out := tool.Run(context.Background(), raw)
+	if out.Error != nil {
+		return "", out.Error
+	}
+	return out.LLMContent[0].Text, nil
+}
+
+func TestSkillTool(t *testing.T) {
+	skill := writeTestSkill(t, "")
+	var loaded []string
+	tool := &SkillTool{Skills: []skills.Skill{skill}, OnLoad: func(name string) { loaded = append(loaded, name) }}
+
+	text, err := runSkillTool(t, tool, skillInput{Name: "release"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Run scripts/tag.sh.") || !strings.Contains(text, "- scripts/tag.sh") {
+		t.Errorf("expected the skill's instructions and files, got %q", text)
+	}
+	if !slices.Equal(loaded, []string{"release"}) {
+		t.Errorf("expected OnLoad to record the skill, got %v", loaded)
+	}
+
+	text, err = runSkillTool(t, tool, skillInput{Action: "read", Name: "release", File: "scripts/tag.sh"})
+	if err != nil || text != "git tag v1\n" {
+		t.Errorf("read: got %q, %v", text, err)
+	}
+	if _, err := runSkillTool(t, tool, skillInput{Action: "read", Name: "release", File: "../../etc/passwd"}); err == nil {
+		t.Error("expected an error reading outside the skill's directory")
+	}
+	if _, err := runSkillTool(t, tool, skillInput{Name: "missing"}); err == nil {
+		t.Error("expected an error loading an unknown skill")
+	}
+}
+
+func TestSkillToolRestrictsTools(t *testing.T) {
+	skill := writeTestSkill(t, "allowed-tools: Bash(git:*) Read\n")
+	ts := NewToolSet(context.Background(), ToolSetConfig{
+		LLMProvider: &mockLLMProvider{},
+		ModelID:     "test-model",
+		WorkingDir:  "/test",
+		Skills:      []skills.Skill{skill},
+	})
+	names := func() []string {
+		var names []string
+		for _, tool := range ts.Tools() {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+	all := names()
+	if !slices.Contains(all, skillName) {
+		t.Fatalf("expected the skill tool, got %v", all)
+	}
+	run := func(input skillInput) {
+		t.Helper()
+		raw, _ := json.Marshal(input)
+		tools := ts.Tools()
+		i := slices.IndexFunc(tools, func(tool *llm.Tool) bool { return tool.Name == skillName })
+		if out := tools[i].Run(context.Background(), raw); out.Error != nil {
+			t.Fatal(out.Error)
+		}
+	}
+
+	run(skillInput{Name: "release"})
+	if got, want := names(), []string{"bash", readFileName, skillName}; !slices.Equal(got, want) {
+		t.Errorf("while the skill is active expected %v, got %v", want, got)
+	}
+	run(skillInput{Action: "finish"})
+	if got := names(); !slices.Equal(got, all) {
+		t.Errorf("after finish expected %v, got %v", all, got)
+	}
+
+	run(skillInput{Name: "release"})
+	ts.EndSkill()
+	if got := names(); !slices.Equal(got, all) {
+		t.Errorf("after EndSkill expected %v, got %v", all, got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,7 +18,28 @@ type SubagentRunner interface {
 	// If wait is false, it starts processing in background and returns immediately.
 	// timeout is the maximum time to wait for a response.
 	// modelID is the model to use for the subagent (inherited from parent if provided).
-	RunSubagent(ctx context.Context, conversationID, prompt string, wait bool, timeout time.Duration, modelID string) (string, error)
+	// tools, if non-nil, restricts a new subagent to the named tools.
+	RunSubagent(ctx context.Context, conversationID, prompt string, wait bool, timeout time.Duration, modelID string, tools []string) (string, error)
+	// SubagentStatus reports whether a subagent is working, what it has used so far,
+	// and, if it is idle, its last response. The Slug field is left empty.
+	SubagentStatus(ctx context.Context, conversationID string) (SubagentStatus, error)
+	// WaitForSubagents blocks until all (or, if all is false, any) of the given
+	// subagents are idle, the timeout elapses, or ctx is done.
+	WaitForSubagents(ctx context.Context, conversationIDs []string, all bool, timeout time.Duration) error
+	// CancelSubagent stops a subagent's current turn. It is a no-op if the subagent is idle.
+	CancelSubagent(ctx context.Context, conversationID string) error
+}
+
+// SubagentStatus describes the state of one subagent conversation.
+type SubagentStatus struct {
+	Slug           string
+	ConversationID string
+	Working        bool
+	InputTokens    uint64 // including cache reads and writes
+	OutputTokens   uint64
+	CostUSD        float64
+	LastUpdate     time.Time // when the latest message was recorded; zero if there are none
+	LastResponse   string    // the latest assistant text; only set when the subagent is idle
 }
 
 // SubagentDB is the database interface for subagent operations.
@@ -27,6 +49,8 @@ type SubagentDB interface {
 	// Returns the conversation ID and the actual slug used (may differ from requested
 	// slug if a numeric suffix was added for uniqueness).
 	GetOrCreateSubagentConversation(ctx context.Context, slug, parentID, cwd string) (conversationID, actualSlug string, err error)
+	// ListSubagentConversations returns the conversation ID of each subagent of parentID, keyed by slug.
+	ListSubagentConversations(ctx context.Context, parentID string) (map[string]string, error)
 }
 
 // SubagentTool provides the ability to spawn and interact with subagent conversations.
@@ -35,7 +59,8 @@ type SubagentTool struct {
 	ParentConversationID string
 	WorkingDir           *MutableWorkingDir
 	Runner               SubagentRunner
-	ModelID              string // Parent conversation's model ID
+	ModelID              string   // Parent conversation's model ID
+	ToolNames            []string // Tools a subagent may be given: the parent's own
 }
 
 const (
@@ -52,20 +77,50 @@ Use subagents for:
 Each subagent has its own slug identifier within this conversation.
 You can send messages to existing subagents by using the same slug.
 The tool returns the subagent's last response, or a status if the timeout is reached.
+
+To run several subagents in parallel, send each one a prompt with wait=false, then:
+- "status" reports whether each subagent is working, its token usage, and when it last made progress
+- "wait_all" waits until every listed subagent is idle and returns their responses
+- "wait_any" waits until at least one listed subagent is idle and returns what is available
+- "cancel" stops the listed subagents' current work
+These actions apply to the subagents named in slugs (or slug), or to all of this conversation's
+subagents if none are named.
+
+When starting a subagent you can pick its model (for example a cheaper one for broad exploration)
+and restrict its tools (for example a read-only explorer with only read_file and keyword_search).
+Both are fixed by the first message to a slug; use a new slug to change them.
 `
 	subagentInputSchema = `
 {
   "type": "object",
-  "required": ["slug", "prompt"],
   "properties": {
+    "action": {
+      "type": "string",
+      "enum": ["send", "status", "wait_all", "wait_any", "cancel"],
+      "description": "What to do (default: send). send requires slug and prompt."
+    },
     "slug": {
       "type": "string",
       "description": "A short identifier for this subagent (e.g., 'research-api', 'test-runner')"
     },
+    "slugs": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Subagents for status, wait_all, wait_any and cancel (default: all subagents)"
+    },
     "prompt": {
       "type": "string",
       "description": "The message to send to the subagent"
     },
+    "model": {
+      "type": "string",
+      "description": "Model for a new subagent (default: this conversation's model)"
+    },
+    "tools": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Tool names a new subagent may use (default: all of this conversation's tools)"
+    },
     "timeout_seconds": {
       "type": "integer",
       "description": "How long to wait for a response (default: 60, max: 300)"
@@ -80,10 +135,14 @@ The tool returns the subagent's last response, or a status if the timeout is rea
 )
 
 type subagentInput struct {
-	Slug           string `json:"slug"`
-	Prompt         string `json:"prompt"`
-	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
-	Wait           *bool  `json:"wait,omitempty"`
+	Action         string   `json:"action,omitempty"`
+	Slug           string   `json:"slug"`
+	Slugs          []string `json:"slugs,omitempty"`
+	Prompt         string   `json:"prompt"`
+	Model          string   `json:"model,omitempty"`
+	Tools          []string `json:"tools,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	Wait           *bool    `json:"wait,omitempty"`
 }
 
 // Tool returns an llm.Tool for the subagent functionality.
@@ -102,6 +161,14 @@ func (s *SubagentTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 		return llm.ErrorfToolOut("failed to parse subagent input: %w", err)
 	}
 
+	switch req.Action {
+	case "", "send":
+	case "status", "wait_all", "wait_any", "cancel":
+		return s.runAction(ctx, req)
+	default:
+		return llm.ErrorfToolOut("unknown action %q", req.Action)
+	}
+
 	// Validate slug
 	if req.Slug == "" {
 		return llm.ErrorfToolOut("slug is required")
@@ -115,14 +182,20 @@ func (s *SubagentTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 		return llm.ErrorfToolOut("prompt is required")
 	}
 
-	// Set defaults
-	timeout := 60 * time.Second
-	if req.TimeoutSeconds > 0 {
-		if req.TimeoutSeconds > 300 {
-			req.TimeoutSeconds = 300
+	if s.ToolNames != nil {
+		for _, name := range req.Tools {
+			if !slices.Contains(s.ToolNames, name) {
+				return llm.ErrorfToolOut("unknown tool %q; available tools: %s", name, strings.Join(s.ToolNames, ", "))
+			}
 		}
-		timeout = time.Duration(req.TimeoutSeconds) * time.Second
 	}
+	modelID := s.ModelID
+	if req.Model != "" {
+		modelID = req.Model
+	}
+
+	// Set defaults
+	timeout := req.timeout()
 
 	wait := true
 	if req.Wait != nil {
@@ -136,7 +209,7 @@ func (s *SubagentTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	}
 
 	// Use the runner to execute the subagent
-	response, err := s.Runner.RunSubagent(ctx, conversationID, req.Prompt, wait, timeout, s.ModelID)
+	response, err := s.Runner.RunSubagent(ctx, conversationID, req.Prompt, wait, timeout, modelID, req.Tools)
 	if err != nil {
 		return llm.ErrorfToolOut("subagent error: %w", err)
 	}
@@ -156,6 +229,89 @@ func (s *SubagentTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	}
 }
 
+// timeout returns the requested timeout, defaulting to 60 seconds and capped at 300.
+func (req *subagentInput) timeout() time.Duration {
+	if req.TimeoutSeconds <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(min(req.TimeoutSeconds, 300)) * time.Second
+}
+
+// runAction handles the status, wait_all, wait_any and cancel actions.
+func (s *SubagentTool) runAction(ctx context.Context, req subagentInput) llm.ToolOut {
+	known, err := s.DB.ListSubagentConversations(ctx, s.ParentConversationID)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to list subagents: %w", err)
+	}
+
+	slugs := req.Slugs
+	if req.Slug != "" {
+		slugs = append(slugs, req.Slug)
+	}
+	if len(slugs) == 0 {
+		for slug := range known {
+			slugs = append(slugs, slug)
+		}
+		slices.Sort(slugs)
+	}
+	if len(slugs) == 0 {
+		return llm.ErrorfToolOut("this conversation has no subagents")
+	}
+	var ids []string
+	for i, slug := range slugs {
+		slugs[i] = sanitizeSlug(slug)
+		id, ok := known[slugs[i]]
+		if !ok {
+			return llm.ErrorfToolOut("no subagent with slug %q", slug)
+		}
+		ids = append(ids, id)
+	}
+
+	switch req.Action {
+	case "cancel":
+		for i, id := range ids {
+			if err := s.Runner.CancelSubagent(ctx, id); err != nil {
+				return llm.ErrorfToolOut("failed to cancel subagent '%s': %w", slugs[i], err)
+			}
+		}
+	case "wait_all", "wait_any":
+		if err := s.Runner.WaitForSubagents(ctx, ids, req.Action == "wait_all", req.timeout()); err != nil {
+			return llm.ErrorfToolOut("failed to wait for subagents: %w", err)
+		}
+	}
+
+	var report strings.Builder
+	now := time.Now()
+	for i, id := range ids {
+		status, err := s.Runner.SubagentStatus(ctx, id)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to get status of subagent '%s': %w", slugs[i], err)
+		}
+		status.Slug = slugs[i]
+		if i > 0 {
+			report.WriteString("\n")
+		}
+		writeSubagentStatus(&report, status, now)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(report.String())}
+}
+
+// writeSubagentStatus appends a human-readable description of status to b.
+func writeSubagentStatus(b *strings.Builder, status SubagentStatus, now time.Time) {
+	state := "idle"
+	if status.Working {
+		state = "working"
+	}
+	fmt.Fprintf(b, "Subagent '%s': %s, %d input / %d output tokens ($%.2f)", status.Slug, state, status.InputTokens, status.OutputTokens, status.CostUSD)
+	if !status.LastUpdate.IsZero() {
+		fmt.Fprintf(b, ", last update %s ago", now.Sub(status.LastUpdate).Round(time.Second))
+	}
+	b.WriteString("\n")
+	if !status.Working && status.LastResponse != "" {
+		fmt.Fprintf(b, "Last response:\n%s\n", status.LastResponse)
+	}
+}
+
 // SubagentDisplayData is the display data sent to the UI for subagent tool results.
 type SubagentDisplayData struct {
 	Slug           string `json:"slug"`
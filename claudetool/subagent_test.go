@@ -3,6 +3,8 @@ package claudetool
 import (
 	"context"
 	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,21 +30,58 @@ func (m *mockSubagentDB) GetOrCreateSubagentConversation(ctx context.Context, sl
 	return id, slug, nil
 }
 
+func (m *mockSubagentDB) ListSubagentConversations(ctx context.Context, parentID string) (map[string]string, error) {
+	ids := make(map[string]string)
+	for key, id := range m.conversations {
+		if slug, ok := strings.CutPrefix(key, parentID+":"); ok {
+			ids[slug] = id
+		}
+	}
+	return ids, nil
+}
+
 // mockSubagentRunner implements SubagentRunner for testing.
 type mockSubagentRunner struct {
 	response    string
 	err         error
 	lastModelID string // Capture for assertions
+	lastTools   []string
+
+	working   map[string]bool // conversationID -> working
+	waitedFor []string
+	waitAll   bool
+	cancelled []string
 }
 
-func (m *mockSubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt string, wait bool, timeout time.Duration, modelID string) (string, error) {
+func (m *mockSubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt string, wait bool, timeout time.Duration, modelID string, tools []string) (string, error) {
 	m.lastModelID = modelID
+	m.lastTools = tools
 	if m.err != nil {
 		return "", m.err
 	}
 	return m.response, nil
 }
 
+func (m *mockSubagentRunner) SubagentStatus(ctx context.Context, conversationID string) (SubagentStatus, error) {
+	status := SubagentStatus{ConversationID: conversationID, Working: m.working[conversationID], InputTokens: 100, OutputTokens: 10}
+	if !status.Working {
+		status.LastResponse = "done: " + conversationID
+	}
+	return status, nil
+}
+
+func (m *mockSubagentRunner) WaitForSubagents(ctx context.Context, conversationIDs []string, all bool, timeout time.Duration) error {
+	m.waitedFor = conversationIDs
+	m.waitAll = all
+	return nil
+}
+
+func (m *mockSubagentRunner) CancelSubagent(ctx context.Context, conversationID string) error {
+	m.cancelled = append(m.cancelled, conversationID)
+	m.working[conversationID] = false
+	return nil
+}
+
 func TestSubagentTool_SanitizeSlug(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -177,3 +216,105 @@ func TestSubagentTool_InheritsModel(t *testing.T) {
 		t.Errorf("expected model 'claude-sonnet-4-20250514', got %q", runner.lastModelID)
 	}
 }
+
+func TestSubagentTool_Actions(t *testing.T) {
+	db := newMockSubagentDB()
+	runner := &mockSubagentRunner{response: "OK", working: make(map[string]bool)}
+	tool := &SubagentTool{
+		DB:                   db,
+		ParentConversationID: "parent-123",
+		WorkingDir:           NewMutableWorkingDir("/tmp"),
+		Runner:               runner,
+	}
+	run := func(input subagentInput) string {
+		t.Helper()
+		inputJSON, _ := json.Marshal(input)
+		result := tool.Run(context.Background(), inputJSON)
+		if result.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", input.Action, result.Error)
+		}
+		return result.LLMContent[0].Text
+	}
+
+	// No subagents yet.
+	inputJSON, _ := json.Marshal(subagentInput{Action: "status"})
+	if result := tool.Run(context.Background(), inputJSON); result.Error == nil {
+		t.Error("expected error when there are no subagents")
+	}
+
+	f := false
+	run(subagentInput{Slug: "alpha", Prompt: "a", Wait: &f})
+	run(subagentInput{Slug: "beta", Prompt: "b", Wait: &f})
+	// A subagent of another conversation must not be visible.
+	db.GetOrCreateSubagentConversation(context.Background(), "gamma", "other-parent", "/tmp")
+	runner.working["subagent-alpha"] = true
+
+	text := run(subagentInput{Action: "status"})
+	if !strings.Contains(text, "Subagent 'alpha': working, 100 input / 10 output tokens") {
+		t.Errorf("status should report alpha as working:\n%s", text)
+	}
+	if !strings.Contains(text, "Subagent 'beta': idle") || !strings.Contains(text, "done: subagent-beta") {
+		t.Errorf("status should report beta's last response:\n%s", text)
+	}
+	if strings.Contains(text, "gamma") || strings.Contains(text, "done: subagent-alpha") {
+		t.Errorf("unexpected status output:\n%s", text)
+	}
+
+	run(subagentInput{Action: "wait_any", Slugs: []string{"beta", "alpha"}})
+	if runner.waitAll || !slices.Equal(runner.waitedFor, []string{"subagent-beta", "subagent-alpha"}) {
+		t.Errorf("wait_any waited for %v (all=%v)", runner.waitedFor, runner.waitAll)
+	}
+	run(subagentInput{Action: "wait_all"})
+	if !runner.waitAll || !slices.Equal(runner.waitedFor, []string{"subagent-alpha", "subagent-beta"}) {
+		t.Errorf("wait_all waited for %v (all=%v)", runner.waitedFor, runner.waitAll)
+	}
+
+	text = run(subagentInput{Action: "cancel", Slug: "alpha"})
+	if !slices.Equal(runner.cancelled, []string{"subagent-alpha"}) {
+		t.Errorf("expected alpha to be cancelled, got %v", runner.cancelled)
+	}
+	if !strings.Contains(text, "Subagent 'alpha': idle") {
+		t.Errorf("cancel should report alpha as idle:\n%s", text)
+	}
+
+	for _, input := range []subagentInput{
+		{Action: "status", Slug: "gamma"},
+		{Action: "restart"},
+	} {
+		inputJSON, _ := json.Marshal(input)
+		if result := tool.Run(context.Background(), inputJSON); result.Error == nil {
+			t.Errorf("%+v: expected error", input)
+		}
+	}
+}
+
+func TestSubagentTool_ModelAndTools(t *testing.T) {
+	runner := &mockSubagentRunner{response: "OK"}
+	tool := &SubagentTool{
+		DB:                   newMockSubagentDB(),
+		ParentConversationID: "parent-123",
+		WorkingDir:           NewMutableWorkingDir("/tmp"),
+		Runner:               runner,
+		ModelID:              "strong-model",
+		ToolNames:            []string{"bash", "patch", "keyword_search", "subagent"},
+	}
+
+	input, _ := json.Marshal(subagentInput{Slug: "explorer", Prompt: "look around", Model: "cheap-model", Tools: []string{"bash", "keyword_search"}})
+	if result := tool.Run(context.Background(), input); result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if runner.lastModelID != "cheap-model" || !slices.Equal(runner.lastTools, []string{"bash", "keyword_search"}) {
+		t.Errorf("expected cheap-model with bash and keyword_search, got %q with %v", runner.lastModelID, runner.lastTools)
+	}
+
+	input, _ = json.Marshal(subagentInput{Slug: "editor", Prompt: "fix it"})
+	tool.Run(context.Background(), input)
+	if runner.lastModelID != "strong-model" || runner.lastTools != nil {
+		t.Errorf("expected the parent's model and all tools, got %q with %v", runner.lastModelID, runner.lastTools)
+	}
+
+	input, _ = json.Marshal(subagentInput{Slug: "explorer", Prompt: "look around", Tools: []string{"browser_navigate"}})
+	if result := tool.Run(context.Background(), input); result.Error == nil {
+		t.Error("expected an error for an unknown tool")
+	}
+}
@@ -3,11 +3,15 @@ package claudetool
 import (
 	"context"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/claudetool/lsp"
+	"shelley.exe.dev/claudetool/mcp"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/skills"
 )
 
 // WorkingDir is a thread-safe mutable working directory.
@@ -69,6 +73,26 @@ type ToolSetConfig struct {
 	// A value of 0 means no limit (but SubagentRunner/SubagentDB must still be set).
 	// Set to 1 to allow only top-level conversations (depth 0) to spawn subagents.
 	MaxSubagentDepth int
+	// AllowedTools, if non-nil, restricts the set to the named tools.
+	AllowedTools []string
+	// CheckBashPermission, if set, is consulted before each bash command runs.
+	CheckBashPermission PermissionCallback
+	// MCP, if set, supplies tools from external MCP servers.
+	MCP *mcp.Manager
+	// LSP, if set, shares language servers between conversations. Otherwise
+	// each ToolSet starts its own.
+	LSP *lsp.Pool
+	// DiagnosticCommands are checks for the patch tool to run on changed files,
+	// in addition to the built-in gofmt and go vet checks for Go.
+	DiagnosticCommands []DiagnosticCommand
+	// LanguageServers are language servers for the code navigation tool, in
+	// addition to gopls. The tool is offered only if one of them is installed.
+	LanguageServers []lsp.ServerConfig
+	// Skills are the skills the skill tool can load. The tool is offered only
+	// if there are some.
+	Skills []skills.Skill
+	// OnSkillLoad, if set, is called with the name of each skill the agent loads.
+	OnSkillLoad func(name string)
 }
 
 // ToolSet holds a set of tools for a single conversation.
@@ -77,14 +101,43 @@ type ToolSet struct {
 	tools   []*llm.Tool
 	cleanup func()
 	wd      *MutableWorkingDir
+
+	mu sync.Mutex
+	// skillTools, if non-nil, are the tools allowed by the active skill.
+	skillTools []string
 }
 
-// Tools returns the tools in this set.
+// Tools returns the tools available now. While a skill that limits its tools
+// is active, these are the ones it allows, and the skill tool itself.
 func (ts *ToolSet) Tools() []*llm.Tool {
-	return ts.tools
+	ts.mu.Lock()
+	allowed := ts.skillTools
+	ts.mu.Unlock()
+	if allowed == nil {
+		return ts.tools
+	}
+	return filterTools(ts.tools, slices.Concat(allowed, []string{skillName}))
+}
+
+// restrictTools limits Tools to the named tools, or with nil ends the limit,
+// and returns the names of the tools now available.
+func (ts *ToolSet) restrictTools(allowed []string) []string {
+	ts.mu.Lock()
+	ts.skillTools = allowed
+	ts.mu.Unlock()
+	var names []string
+	for _, tool := range ts.Tools() {
+		names = append(names, tool.Name)
+	}
+	return names
 }
 
-// Cleanup releases resources held by the tools (e.g., browser).
+// EndSkill ends any limit on the tools set by the active skill.
+func (ts *ToolSet) EndSkill() {
+	ts.restrictTools(nil)
+}
+
+// Cleanup releases resources held by the tools (e.g., browser, language servers).
 func (ts *ToolSet) Cleanup() {
 	if ts.cleanup != nil {
 		ts.cleanup()
@@ -96,6 +149,20 @@ func (ts *ToolSet) WorkingDir() *MutableWorkingDir {
 	return ts.wd
 }
 
+// filterTools returns the tools whose names are in allowed, or all tools if allowed is nil.
+func filterTools(tools []*llm.Tool, allowed []string) []*llm.Tool {
+	if allowed == nil {
+		return tools
+	}
+	var filtered []*llm.Tool
+	for _, tool := range tools {
+		if slices.Contains(allowed, tool.Name) {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
 // NewToolSet creates a new set of tools for a conversation.
 // isStrongModel returns true for models that can handle complex tool schemas.
 func isStrongModel(modelID string) bool {
@@ -119,6 +186,7 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		LLMProvider:      cfg.LLMProvider,
 		EnableJITInstall: cfg.EnableJITInstall,
 		ConversationID:   cfg.ConversationID,
+		CheckPermission:  cfg.CheckBashPermission,
 	}
 
 	// Use simplified patch schema for weaker models, full schema for sonnet/opus
@@ -127,6 +195,7 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		Simplified:       simplified,
 		WorkingDir:       wd,
 		ClipboardEnabled: true,
+		Diagnostics:      &Diagnostics{Commands: cfg.DiagnosticCommands},
 	}
 
 	keywordTool := NewKeywordToolWithWorkingDir(cfg.LLMProvider, wd)
@@ -138,17 +207,64 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 
 	outputIframeTool := &OutputIframeTool{WorkingDir: wd}
 
+	// Get max image dimension from the LLM service
+	maxImageDimension := 0
+	if cfg.LLMProvider != nil && cfg.ModelID != "" {
+		if svc, err := cfg.LLMProvider.GetService(cfg.ModelID); err == nil {
+			maxImageDimension = svc.MaxImageDimension()
+		}
+	}
+
+	readFileTool := &ReadFileTool{WorkingDir: wd, MaxImageDimension: maxImageDimension}
+
 	tools := []*llm.Tool{
 		bashTool.Tool(),
 		patchTool.Tool(),
 		keywordTool.Tool(),
 		changeDirTool.Tool(),
 		outputIframeTool.Tool(),
+		readFileTool.Tool(),
+	}
+
+	var extraTools []*llm.Tool
+	if cfg.MCP != nil {
+		extraTools = append(extraTools, cfg.MCP.Tools(ctx)...)
+	}
+
+	var cleanups []func()
+	if navTool := lsp.NewTool(wd, cfg.LanguageServers, cfg.LSP); navTool.Available() {
+		tools = append(tools, navTool.Tool())
+		cleanups = append(cleanups, navTool.Close)
+	}
+
+	if cfg.EnableBrowser {
+		browserTools, browserCleanup := browse.RegisterBrowserTools(ctx, maxImageDimension)
+		if len(browserTools) > 0 {
+			extraTools = append(extraTools, browserTools...)
+		}
+		cleanups = append(cleanups, browserCleanup)
+	}
+
+	ts := &ToolSet{wd: wd}
+	if len(cfg.Skills) > 0 {
+		skillTool := &SkillTool{
+			Skills:   cfg.Skills,
+			OnLoad:   cfg.OnSkillLoad,
+			Restrict: ts.restrictTools,
+		}
+		tools = append(tools, skillTool.Tool())
 	}
 
-	// Add subagent tool if configured and depth limit not reached.
+	tools = filterTools(tools, cfg.AllowedTools)
+	extraTools = filterTools(extraTools, cfg.AllowedTools)
+
+	// Add subagent tool if configured, allowed, and depth limit not reached.
 	// MaxSubagentDepth of 0 means no limit; otherwise, only add if depth < max.
+	// A subagent can be given at most the tools this conversation has.
 	canSpawnSubagents := cfg.SubagentRunner != nil && cfg.SubagentDB != nil && cfg.ParentConversationID != ""
+	if cfg.AllowedTools != nil && !slices.Contains(cfg.AllowedTools, subagentName) {
+		canSpawnSubagents = false
+	}
 	if canSpawnSubagents && (cfg.MaxSubagentDepth == 0 || cfg.SubagentDepth < cfg.MaxSubagentDepth) {
 		subagentTool := &SubagentTool{
 			DB:                   cfg.SubagentDB,
@@ -156,29 +272,21 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 			WorkingDir:           wd,
 			Runner:               cfg.SubagentRunner,
 			ModelID:              cfg.ModelID, // Inherit parent's model
+			ToolNames:            []string{subagentName},
+		}
+		for _, tool := range slices.Concat(tools, extraTools) {
+			subagentTool.ToolNames = append(subagentTool.ToolNames, tool.Name)
 		}
 		tools = append(tools, subagentTool.Tool())
 	}
+	tools = append(tools, extraTools...)
 
-	var cleanup func()
-	if cfg.EnableBrowser {
-		// Get max image dimension from the LLM service
-		maxImageDimension := 0
-		if cfg.LLMProvider != nil && cfg.ModelID != "" {
-			if svc, err := cfg.LLMProvider.GetService(cfg.ModelID); err == nil {
-				maxImageDimension = svc.MaxImageDimension()
-			}
+	cleanup := func() {
+		for _, c := range cleanups {
+			c()
 		}
-		browserTools, browserCleanup := browse.RegisterBrowserTools(ctx, maxImageDimension)
-		if len(browserTools) > 0 {
-			tools = append(tools, browserTools...)
-		}
-		cleanup = browserCleanup
-	}
-
-	return &ToolSet{
-		tools:   tools,
-		cleanup: cleanup,
-		wd:      wd,
 	}
+	ts.tools = tools
+	ts.cleanup = cleanup
+	return ts
 }
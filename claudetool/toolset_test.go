@@ -2,7 +2,9 @@ package claudetool
 
 import (
 	"context"
+	"encoding/json"
 	"os"
+	"slices"
 	"testing"
 )
 
@@ -301,3 +303,37 @@ func TestNewToolSet_SubagentDepthLimit(t *testing.T) {
 		}
 	})
 }
+
+func TestNewToolSet_AllowedTools(t *testing.T) {
+	names := func(ts *ToolSet) []string {
+		var names []string
+		for _, tool := range ts.Tools() {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+	cfg := ToolSetConfig{
+		LLMProvider:          &mockLLMProvider{},
+		ModelID:              "test-model",
+		WorkingDir:           "/test",
+		SubagentRunner:       &mockSubagentRunner{response: "ok"},
+		SubagentDB:           newMockSubagentDB(),
+		ParentConversationID: "parent-123",
+	}
+
+	cfg.AllowedTools = []string{"bash", "keyword_search"}
+	if got := names(NewToolSet(context.Background(), cfg)); !slices.Equal(got, cfg.AllowedTools) {
+		t.Errorf("expected only the allowed tools, got %v", got)
+	}
+
+	cfg.AllowedTools = []string{"bash", "subagent"}
+	ts := NewToolSet(context.Background(), cfg)
+	if got := names(ts); !slices.Equal(got, cfg.AllowedTools) {
+		t.Fatalf("expected bash and subagent, got %v", got)
+	}
+	// A subagent can be given at most the parent's tools.
+	input, _ := json.Marshal(subagentInput{Slug: "explorer", Prompt: "look around", Tools: []string{"patch"}})
+	if result := ts.Tools()[1].Run(context.Background(), input); result.Error == nil {
+		t.Error("expected an error when giving a subagent a tool its parent lacks")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -16,23 +17,65 @@ import (
 	"strings"
 )
 
-// DefaultSocketPath returns the default Unix socket path (~/.config/shelley/shelley.sock).
-func DefaultSocketPath() string {
-	configDir := os.Getenv("XDG_CONFIG_HOME")
-	if configDir == "" {
+// configDir returns the directory for Shelley's client files (~/.config/shelley).
+func configDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
 		home, err := os.UserHomeDir()
 		if err != nil {
 			home = "/tmp"
 		}
-		configDir = filepath.Join(home, ".config")
+		dir = filepath.Join(home, ".config")
 	}
-	return filepath.Join(configDir, "shelley", "shelley.sock")
+	return filepath.Join(dir, "shelley")
+}
+
+// DefaultSocketPath returns the default Unix socket path (~/.config/shelley/shelley.sock).
+func DefaultSocketPath() string {
+	return filepath.Join(configDir(), "shelley.sock")
+}
+
+// tokenEnvVar names the environment variable holding an API token.
+const tokenEnvVar = "SHELLEY_TOKEN"
+
+// clientFile is the optional client configuration in ~/.config/shelley/client.json.
+// It lets remote automation keep its server URL and API token out of its command lines.
+type clientFile struct {
+	URL   string `json:"url,omitempty"`
+	Token string `json:"token,omitempty"`
 }
 
-func defaultClientURL() string {
+// loadClientFile reads clientFile, returning an empty one if there is none.
+func loadClientFile() clientFile {
+	var cf clientFile
+	data, err := os.ReadFile(filepath.Join(configDir(), "client.json"))
+	if err != nil {
+		return cf
+	}
+	if err := json.Unmarshal(data, &cf); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid client.json: %v\n", err)
+		return clientFile{}
+	}
+	return cf
+}
+
+func defaultClientURL(cf clientFile) string {
+	if cf.URL != "" {
+		return cf.URL
+	}
 	return "unix://" + DefaultSocketPath()
 }
 
+// newClientConfig returns a clientConfig for serverURL. The API token comes
+// from $SHELLEY_TOKEN, or else from client.json.
+func newClientConfig(serverURL string, headers map[string]string, cf clientFile) *clientConfig {
+	token := os.Getenv(tokenEnvVar)
+	if token == "" {
+		token = cf.Token
+	}
+	return &clientConfig{serverURL: serverURL, headers: headers, token: token}
+}
+
 func parseClientURL(rawURL string) (scheme, address string, err error) {
 	if strings.HasPrefix(rawURL, "unix://") {
 		sockPath := strings.TrimPrefix(rawURL, "unix://")
@@ -59,6 +102,7 @@ func (f *multiFlag) Set(value string) error {
 type clientConfig struct {
 	serverURL string
 	headers   map[string]string
+	token     string // API token sent as a bearer token, if set
 }
 
 func (cc *clientConfig) newHTTPClient() (*http.Client, string, error) {
@@ -82,13 +126,13 @@ func (cc *clientConfig) newHTTPClient() (*http.Client, string, error) {
 	}
 }
 
-func (cc *clientConfig) newRequest(method, url string, body *strings.Reader) (*http.Request, error) {
+func (cc *clientConfig) newRequest(ctx context.Context, method, url string, body *strings.Reader) (*http.Request, error) {
 	var req *http.Request
 	var err error
 	if body != nil {
-		req, err = http.NewRequest(method, url, body)
+		req, err = http.NewRequestWithContext(ctx, method, url, body)
 	} else {
-		req, err = http.NewRequest(method, url, nil)
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 	if err != nil {
 		return nil, err
@@ -96,16 +140,33 @@ func (cc *clientConfig) newRequest(method, url string, body *strings.Reader) (*h
 	if method == http.MethodPost {
 		req.Header.Set("X-Shelley-Request", "1")
 	}
+	if cc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cc.token)
+	}
 	for k, v := range cc.headers {
 		req.Header.Set(k, v)
 	}
 	return req, nil
 }
 
+// parseHeaders parses -H flag values of the form "Name: Value".
+func parseHeaders(headerFlags []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, h := range headerFlags {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid header %q (expected \"Name: Value\")", h)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
+
 // Run is the entry point for "shelley client [args...]".
 func Run(args []string) {
 	fs := flag.NewFlagSet("client", flag.ExitOnError)
-	urlFlag := fs.String("url", defaultClientURL(), "Server URL (unix:///path, http://host:port, https://host:port)")
+	cf := loadClientFile()
+	urlFlag := fs.String("url", defaultClientURL(cf), "Server URL (unix:///path, http://host:port, https://host:port)")
 	var headerFlags multiFlag
 	fs.Var(&headerFlags, "H", `Extra HTTP header ("Name: Value", can be repeated)`)
 	fs.Usage = func() {
@@ -118,21 +179,21 @@ func Run(args []string) {
 		fmt.Fprintf(fs.Output(), "  read     Read conversation messages\n")
 		fmt.Fprintf(fs.Output(), "  list     List conversations\n")
 		fmt.Fprintf(fs.Output(), "  archive  Archive a conversation\n")
+		fmt.Fprintf(fs.Output(), "  usage    Report token usage and cost\n")
+		fmt.Fprintf(fs.Output(), "  export   Export a conversation as JSON or Markdown\n")
+		fmt.Fprintf(fs.Output(), "  import   Import a conversation from an export\n")
+		fmt.Fprintf(fs.Output(), "  tokens   Manage API tokens\n")
 		fmt.Fprintf(fs.Output(), "  help     Print detailed help\n")
 	}
 	fs.Parse(args)
 
-	headers := make(map[string]string)
-	for _, h := range headerFlags {
-		parts := strings.SplitN(h, ":", 2)
-		if len(parts) != 2 {
-			fmt.Fprintf(os.Stderr, "Error: invalid header %q (expected \"Name: Value\")\n", h)
-			os.Exit(1)
-		}
-		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	headers, err := parseHeaders(headerFlags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	cc := &clientConfig{serverURL: *urlFlag, headers: headers}
+	cc := newClientConfig(*urlFlag, headers, cf)
 
 	subArgs := fs.Args()
 	if len(subArgs) == 0 {
@@ -149,6 +210,14 @@ func Run(args []string) {
 		cmdList(cc, subArgs[1:])
 	case "archive":
 		cmdArchive(cc, subArgs[1:])
+	case "usage":
+		cmdUsage(cc, subArgs[1:])
+	case "export":
+		cmdExport(cc, subArgs[1:])
+	case "import":
+		cmdImport(cc, subArgs[1:])
+	case "tokens":
+		cmdTokens(cc, subArgs[1:])
 	case "help":
 		cmdHelp()
 	default:
@@ -171,75 +240,80 @@ func cmdChat(cc *clientConfig, args []string) {
 		os.Exit(1)
 	}
 
-	client, baseURL, err := cc.newHTTPClient()
+	result, err := cc.chat(context.Background(), *convID, *prompt, *model, *cwd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	json.NewEncoder(os.Stdout).Encode(result)
+}
+
+// chatResult is the output of a chat request.
+type chatResult struct {
+	ConversationID string `json:"conversation_id"`
+	Slug           any    `json:"slug,omitempty"`
+}
 
-	reqBody := map[string]string{"message": *prompt}
-	if *model != "" {
-		reqBody["model"] = *model
+// chat sends prompt to conversationID, or starts a new conversation if conversationID is empty.
+func (cc *clientConfig) chat(ctx context.Context, conversationID, prompt, model, cwd string) (*chatResult, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody := map[string]string{"message": prompt}
+	if model != "" {
+		reqBody["model"] = model
 	}
-	if *cwd != "" {
-		reqBody["cwd"] = *cwd
+	if cwd != "" {
+		reqBody["cwd"] = cwd
 	}
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	var apiURL string
-	if *convID != "" {
-		apiURL = baseURL + "/api/conversation/" + *convID + "/chat"
+	if conversationID != "" {
+		apiURL = baseURL + "/api/conversation/" + conversationID + "/chat"
 	} else {
 		apiURL = baseURL + "/api/conversations/new"
 	}
 
-	req, err := cc.newRequest("POST", apiURL, strings.NewReader(string(bodyBytes)))
+	req, err := cc.newRequest(ctx, "POST", apiURL, strings.NewReader(string(bodyBytes)))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		var errBody map[string]any
 		if json.NewDecoder(resp.Body).Decode(&errBody) == nil {
-			fmt.Fprintf(os.Stderr, "Error (HTTP %d): %v\n", resp.StatusCode, errBody)
-		} else {
-			fmt.Fprintf(os.Stderr, "Error: HTTP %d\n", resp.StatusCode)
+			return nil, fmt.Errorf("HTTP %d: %v", resp.StatusCode, errBody)
 		}
-		os.Exit(1)
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	var respBody map[string]any
 	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("parsing response: %w", err)
 	}
 
-	cid := respBody["conversation_id"]
-	if cid == nil {
-		cid = *convID // when continuing, the chat endpoint doesn't echo the ID back
-	}
-	output := map[string]any{
-		"conversation_id": cid,
+	result := &chatResult{ConversationID: conversationID} // when continuing, the chat endpoint doesn't echo the ID back
+	if cid, ok := respBody["conversation_id"].(string); ok {
+		result.ConversationID = cid
 	}
 	if slug, ok := respBody["slug"]; ok {
-		output["slug"] = slug
+		result.Slug = slug
 	}
-
-	json.NewEncoder(os.Stdout).Encode(output)
+	return result, nil
 }
 
 // streamEvent is the simplified output format for read.
@@ -251,6 +325,11 @@ type streamEvent struct {
 	EndOfTurn  bool   `json:"end_of_turn"`
 }
 
+// endsTurn reports whether e is the last message of an agent turn.
+func (e streamEvent) endsTurn() bool {
+	return (e.Type == "agent" || e.Type == "error") && e.EndOfTurn
+}
+
 func cmdRead(cc *clientConfig, args []string) {
 	fs := flag.NewFlagSet("client read", flag.ExitOnError)
 	wait := fs.Bool("wait", false, "Wait for agent turn to finish (stream new messages)")
@@ -262,67 +341,86 @@ func cmdRead(cc *clientConfig, args []string) {
 	}
 	conversationID := fs.Arg(0)
 
-	client, baseURL, err := cc.newHTTPClient()
+	enc := json.NewEncoder(os.Stdout)
+	var err error
+	if *wait {
+		err = cc.streamMessages(context.Background(), conversationID, -1, func(event streamEvent) bool {
+			enc.Encode(event)
+			return !event.endsTurn()
+		})
+	} else {
+		var events []streamEvent
+		events, err = cc.readMessages(context.Background(), conversationID)
+		for _, event := range events {
+			enc.Encode(event)
+		}
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	if *wait {
-		readStream(cc, client, baseURL, conversationID)
-	} else {
-		readSnapshot(cc, client, baseURL, conversationID)
+// readMessages returns all messages in a conversation.
+func (cc *clientConfig) readMessages(ctx context.Context, conversationID string) ([]streamEvent, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
 	}
-}
 
-func readSnapshot(cc *clientConfig, client *http.Client, baseURL, conversationID string) {
-	req, err := cc.newRequest("GET", baseURL+"/api/conversation/"+conversationID, nil)
+	req, err := cc.newRequest(ctx, "GET", baseURL+"/api/conversation/"+conversationID, nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "Error: HTTP %d\n", resp.StatusCode)
-		os.Exit(1)
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	var sr streamResponseWire
 	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("parsing response: %w", err)
 	}
 
+	events := make([]streamEvent, 0, len(sr.Messages))
 	for _, msg := range sr.Messages {
-		json.NewEncoder(os.Stdout).Encode(simplifyMessage(msg))
+		events = append(events, simplifyMessage(msg))
 	}
+	return events, nil
 }
 
-func readStream(cc *clientConfig, client *http.Client, baseURL, conversationID string) {
-	req, err := cc.newRequest("GET", baseURL+"/api/conversation/"+conversationID+"/stream", nil)
+// streamMessages streams messages with sequence IDs greater than afterSeq
+// (all messages if afterSeq is negative), calling fn for each until fn returns false.
+func (cc *clientConfig) streamMessages(ctx context.Context, conversationID string, afterSeq int64, fn func(streamEvent) bool) error {
+	client, baseURL, err := cc.newHTTPClient()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+
+	streamURL := baseURL + "/api/conversation/" + conversationID + "/stream"
+	if afterSeq >= 0 {
+		streamURL += fmt.Sprintf("?last_sequence_id=%d", afterSeq)
+	}
+	req, err := cc.newRequest(ctx, "GET", streamURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Accept", "text/event-stream")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "Error: HTTP %d\n", resp.StatusCode)
-		os.Exit(1)
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	seenSeqIDs := make(map[int64]bool)
@@ -346,24 +444,21 @@ func readStream(cc *clientConfig, client *http.Client, baseURL, conversationID s
 		}
 
 		for _, msg := range sr.Messages {
-			if seenSeqIDs[msg.SequenceID] {
+			if msg.SequenceID <= afterSeq || seenSeqIDs[msg.SequenceID] {
 				continue
 			}
 			seenSeqIDs[msg.SequenceID] = true
 
-			event := simplifyMessage(msg)
-			json.NewEncoder(os.Stdout).Encode(event)
-
-			if (msg.Type == "agent" || msg.Type == "error") && event.EndOfTurn {
-				return
+			if !fn(simplifyMessage(msg)) {
+				return nil
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading stream: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("reading stream: %w", err)
 	}
+	return nil
 }
 
 func cmdList(cc *clientConfig, args []string) {
@@ -373,59 +468,71 @@ func cmdList(cc *clientConfig, args []string) {
 	query := fs.String("q", "", "Search query")
 	fs.Parse(args)
 
-	client, baseURL, err := cc.newHTTPClient()
+	conversations, err := cc.listConversations(context.Background(), *archived, *limit, *query)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	for _, c := range conversations {
+		json.NewEncoder(os.Stdout).Encode(c)
+	}
+}
+
+// conversationSummary is the output format for list.
+type conversationSummary struct {
+	ConversationID string  `json:"conversation_id"`
+	Slug           *string `json:"slug"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+	Working        bool    `json:"working"`
+	Model          *string `json:"model"`
+}
+
+// listConversations returns active (or archived) conversations, optionally filtered by query.
+func (cc *clientConfig) listConversations(ctx context.Context, archived bool, limit int, query string) ([]conversationSummary, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
 
 	endpoint := "/api/conversations"
-	if *archived {
+	if archived {
 		endpoint = "/api/conversations/archived"
 	}
 
-	params := fmt.Sprintf("?limit=%d", *limit)
-	if *query != "" {
-		params += "&q=" + url.QueryEscape(*query)
+	params := fmt.Sprintf("?limit=%d", limit)
+	if query != "" {
+		params += "&q=" + url.QueryEscape(query)
 	}
 
-	req, err := cc.newRequest("GET", baseURL+endpoint+params, nil)
+	req, err := cc.newRequest(ctx, "GET", baseURL+endpoint+params, nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "Error: HTTP %d\n", resp.StatusCode)
-		os.Exit(1)
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	var conversations []json.RawMessage
 	if err := json.NewDecoder(resp.Body).Decode(&conversations); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing response: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("parsing response: %w", err)
 	}
 
+	summaries := make([]conversationSummary, 0, len(conversations))
 	for _, conv := range conversations {
-		var c struct {
-			ConversationID string  `json:"conversation_id"`
-			Slug           *string `json:"slug"`
-			CreatedAt      string  `json:"created_at"`
-			UpdatedAt      string  `json:"updated_at"`
-			Working        bool    `json:"working"`
-			Model          *string `json:"model"`
-		}
+		var c conversationSummary
 		if json.Unmarshal(conv, &c) == nil {
-			json.NewEncoder(os.Stdout).Encode(c)
+			summaries = append(summaries, c)
 		}
 	}
+	return summaries, nil
 }
 
 func cmdArchive(cc *clientConfig, args []string) {
@@ -444,7 +551,7 @@ func cmdArchive(cc *clientConfig, args []string) {
 		os.Exit(1)
 	}
 
-	req, err := cc.newRequest("POST", baseURL+"/api/conversation/"+conversationID+"/archive", nil)
+	req, err := cc.newRequest(context.Background(), "POST", baseURL+"/api/conversation/"+conversationID+"/archive", nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating request: %v\n", err)
 		os.Exit(1)
@@ -465,6 +572,248 @@ func cmdArchive(cc *clientConfig, args []string) {
 	fmt.Fprintf(os.Stderr, "Archived %s\n", conversationID)
 }
 
+func cmdUsage(cc *clientConfig, args []string) {
+	fs := flag.NewFlagSet("client usage", flag.ExitOnError)
+	from := fs.String("from", "", "Start of the range, inclusive (YYYY-MM-DD or RFC 3339; default 30 days before -to)")
+	to := fs.String("to", "", "End of the range, exclusive (YYYY-MM-DD or RFC 3339; default now)")
+	groupBy := fs.String("group-by", "model", "Group by model, day, or conversation")
+	fs.Parse(args)
+
+	report, err := cc.usage(context.Background(), *from, *to, *groupBy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(report)
+}
+
+// usage fetches the usage report as raw JSON.
+func (cc *clientConfig) usage(ctx context.Context, from, to, groupBy string) (json.RawMessage, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("group_by", groupBy)
+	if from != "" {
+		params.Set("from", from)
+	}
+	if to != "" {
+		params.Set("to", to)
+	}
+
+	req, err := cc.newRequest(ctx, "GET", baseURL+"/api/usage?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func cmdExport(cc *clientConfig, args []string) {
+	fs := flag.NewFlagSet("client export", flag.ExitOnError)
+	format := fs.String("format", "json", "Export format: json or markdown")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: shelley client export [-format json|markdown] CONVERSATION_ID\n")
+		os.Exit(1)
+	}
+
+	export, err := cc.export(context.Background(), fs.Arg(0), *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(export)
+}
+
+// export fetches a conversation export in the given format.
+func (cc *clientConfig) export(ctx context.Context, conversationID, format string) ([]byte, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("format", format)
+	req, err := cc.newRequest(ctx, "GET", baseURL+"/api/conversation/"+url.PathEscape(conversationID)+"/export?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func cmdImport(cc *clientConfig, args []string) {
+	fs := flag.NewFlagSet("client import", flag.ExitOnError)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: shelley client import FILE\n")
+		os.Exit(1)
+	}
+
+	var data []byte
+	var err error
+	if path := fs.Arg(0); path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := cc.importConversation(context.Background(), data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(result)
+}
+
+// importConversation uploads a JSON export and returns the server's response,
+// which includes the new conversation_id.
+func (cc *clientConfig) importConversation(ctx context.Context, export []byte) (json.RawMessage, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := cc.newRequest(ctx, "POST", baseURL+"/api/conversations/import", strings.NewReader(string(export)))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func cmdTokens(cc *clientConfig, args []string) {
+	usage := "Usage: shelley client tokens list | create -name NAME [-scope read|chat|admin] [-user USERNAME] | revoke TOKEN_ID\n"
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	var method, path string
+	var body []byte
+	wantStatus := http.StatusOK
+	switch args[0] {
+	case "list":
+		method, path = "GET", "/api/tokens"
+	case "create":
+		fs := flag.NewFlagSet("client tokens create", flag.ExitOnError)
+		name := fs.String("name", "", "What the token is for")
+		scope := fs.String("scope", "chat", "read (GET only), chat (read and act on conversations), or admin")
+		user := fs.String("user", "", "User the token acts as (default: the signed-in user)")
+		fs.Parse(args[1:])
+		if *name == "" {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(1)
+		}
+		body, _ = json.Marshal(map[string]string{"name": *name, "scope": *scope, "username": *user})
+		method, path, wantStatus = "POST", "/api/tokens", http.StatusCreated
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(1)
+		}
+		method, path, wantStatus = "DELETE", "/api/tokens/"+url.PathEscape(args[1]), http.StatusNoContent
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	result, err := cc.tokensRequest(context.Background(), method, path, body, wantStatus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if wantStatus == http.StatusNoContent {
+		fmt.Fprintf(os.Stderr, "Revoked %s\n", args[1])
+		return
+	}
+	os.Stdout.Write(result)
+}
+
+// tokensRequest makes a request to the /api/tokens endpoints and returns the response body.
+func (cc *clientConfig) tokensRequest(ctx context.Context, method, path string, body []byte, wantStatus int) ([]byte, error) {
+	client, baseURL, err := cc.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var reqBody *strings.Reader
+	if body != nil {
+		reqBody = strings.NewReader(string(body))
+	}
+	req, err := cc.newRequest(ctx, method, baseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != wantStatus {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
 // --- Wire types for JSON parsing ---
 
 type streamResponseWire struct {
@@ -562,12 +911,35 @@ Subcommands:
   archive CONVERSATION_ID
       Archive a conversation.
 
+  usage [-from DATE] [-to DATE] [-group-by model|day|conversation]
+      Print token counts, cost, and LLM request latency percentiles as JSON.
+      The range is [from, to) in UTC, defaulting to the last 30 days.
+
+  export [-format json|markdown] CONVERSATION_ID
+      Print a conversation, including every branch and subagent, to stdout.
+      JSON exports can be imported; Markdown is for reading.
+
+  import FILE
+      Recreate a conversation from a JSON export (- reads stdin).
+      Prints JSON with the new conversation_id to stdout.
+
+  tokens list
+  tokens create -name NAME [-scope read|chat|admin] [-user USERNAME]
+  tokens revoke TOKEN_ID
+      Manage API tokens. The token is printed once, when it is created.
+      Over the Unix socket, -user is required.
+
   help
       Print this help text.
 
 Connecting over HTTP with auth headers:
   shelley client -url http://localhost:9999 -H "X-Exedev-Userid: user" list
 
+Connecting over HTTP with an API token:
+  SHELLEY_TOKEN=shelley_... shelley client -url https://shelley.example.com list
+  The URL and token can also be kept in ~/.config/shelley/client.json:
+  {"url": "https://shelley.example.com", "token": "shelley_..."}
+
 Examples:
   # Start a conversation and wait for the agent
   ID=$(shelley client chat -p "list files" | jq -r .conversation_id)
@@ -579,6 +951,13 @@ Examples:
   # Read current state
   shelley client read "$ID"
 
+  # Cost per day for September
+  shelley client usage -from 2026-09-01 -to 2026-10-01 -group-by day
+
+  # Copy a conversation to another server
+  shelley client export "$ID" > convo.json
+  shelley client -url http://other:9999 import convo.json
+
 NOTE: This feature is EXPERIMENTAL and may change without notice.
 `, DefaultSocketPath())
 }
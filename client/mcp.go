@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/llm"
+)
+
+// RunMCP is the entry point for "shelley mcp [args...]".
+// It serves MCP over stdin/stdout, proxying tool calls to a running Shelley server
+// through the same HTTP API that "shelley client" uses.
+func RunMCP(args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	cf := loadClientFile()
+	urlFlag := fs.String("url", defaultClientURL(cf), "Server URL (unix:///path, http://host:port, https://host:port)")
+	var headerFlags multiFlag
+	fs.Var(&headerFlags, "H", `Extra HTTP header ("Name: Value", can be repeated)`)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley mcp [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Serves MCP over stdio, exposing a running Shelley server's conversations as tools.\n\n")
+		fmt.Fprintf(fs.Output(), "Flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	headers, err := parseHeaders(headerFlags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cc := newClientConfig(*urlFlag, headers, cf)
+	server := mcp.NewServer(mcp.Implementation{Name: "shelley", Version: mcp.ClientInfo.Version}, cc.mcpTools())
+	if err := server.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+const (
+	mcpStartSchema = `
+{
+  "type": "object",
+  "required": ["message"],
+  "properties": {
+    "message": {"type": "string", "description": "The first message of the conversation"},
+    "model": {"type": "string", "description": "Model to use (server default if omitted)"},
+    "cwd": {"type": "string", "description": "Working directory for the conversation"},
+    "wait": {"type": "boolean", "description": "Wait for the agent to finish its turn and return its messages"}
+  }
+}`
+	mcpSendSchema = `
+{
+  "type": "object",
+  "required": ["conversation_id", "message"],
+  "properties": {
+    "conversation_id": {"type": "string"},
+    "message": {"type": "string"},
+    "wait": {"type": "boolean", "description": "Wait for the agent to finish its turn and return its messages"}
+  }
+}`
+	mcpReadSchema = `
+{
+  "type": "object",
+  "required": ["conversation_id"],
+  "properties": {
+    "conversation_id": {"type": "string"}
+  }
+}`
+	mcpListSchema = `
+{
+  "type": "object",
+  "properties": {
+    "query": {"type": "string", "description": "Only return conversations matching this search query"},
+    "limit": {"type": "integer", "description": "Maximum number of conversations (default 50)"},
+    "archived": {"type": "boolean", "description": "List archived conversations instead"}
+  }
+}`
+)
+
+type mcpChatInput struct {
+	ConversationID string `json:"conversation_id"`
+	Message        string `json:"message"`
+	Model          string `json:"model"`
+	Cwd            string `json:"cwd"`
+	Wait           bool   `json:"wait"`
+}
+
+// mcpTools returns the tools served by "shelley mcp".
+func (cc *clientConfig) mcpTools() []*llm.Tool {
+	return []*llm.Tool{
+		{
+			Name:        "start_conversation",
+			Description: "Start a new Shelley conversation. Returns its conversation_id (and, with wait, the agent's reply).",
+			InputSchema: llm.MustSchema(mcpStartSchema),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				var in mcpChatInput
+				if err := json.Unmarshal(input, &in); err != nil {
+					return llm.ErrorfToolOut("invalid input: %w", err)
+				}
+				in.ConversationID = ""
+				return cc.mcpChat(ctx, in)
+			},
+		},
+		{
+			Name:        "send_message",
+			Description: "Send a message to an existing Shelley conversation.",
+			InputSchema: llm.MustSchema(mcpSendSchema),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				var in mcpChatInput
+				if err := json.Unmarshal(input, &in); err != nil {
+					return llm.ErrorfToolOut("invalid input: %w", err)
+				}
+				if in.ConversationID == "" {
+					return llm.ErrorfToolOut("conversation_id is required")
+				}
+				return cc.mcpChat(ctx, in)
+			},
+		},
+		{
+			Name:        "read_conversation",
+			Description: "Read the messages of a Shelley conversation as JSON lines.",
+			InputSchema: llm.MustSchema(mcpReadSchema),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				var in struct {
+					ConversationID string `json:"conversation_id"`
+				}
+				if err := json.Unmarshal(input, &in); err != nil {
+					return llm.ErrorfToolOut("invalid input: %w", err)
+				}
+				events, err := cc.readMessages(ctx, in.ConversationID)
+				if err != nil {
+					return llm.ErrorToolOut(err)
+				}
+				return llm.ToolOut{LLMContent: llm.TextContent(jsonLines(events))}
+			},
+		},
+		{
+			Name:        "list_conversations",
+			Description: "List Shelley conversations as JSON lines, most recently updated first.",
+			InputSchema: llm.MustSchema(mcpListSchema),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				var in struct {
+					Query    string `json:"query"`
+					Limit    int    `json:"limit"`
+					Archived bool   `json:"archived"`
+				}
+				if err := json.Unmarshal(input, &in); err != nil {
+					return llm.ErrorfToolOut("invalid input: %w", err)
+				}
+				if in.Limit <= 0 {
+					in.Limit = 50
+				}
+				conversations, err := cc.listConversations(ctx, in.Archived, in.Limit, in.Query)
+				if err != nil {
+					return llm.ErrorToolOut(err)
+				}
+				return llm.ToolOut{LLMContent: llm.TextContent(jsonLines(conversations))}
+			},
+		},
+	}
+}
+
+// mcpChat sends a message and, if requested, waits for the agent's turn to end.
+func (cc *clientConfig) mcpChat(ctx context.Context, in mcpChatInput) llm.ToolOut {
+	if in.Message == "" {
+		return llm.ErrorfToolOut("message is required")
+	}
+
+	// Note where the conversation stands before sending, so that waiting
+	// only returns messages from the turn we start.
+	afterSeq := int64(-1)
+	if in.ConversationID != "" && in.Wait {
+		events, err := cc.readMessages(ctx, in.ConversationID)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		for _, e := range events {
+			afterSeq = max(afterSeq, e.SequenceID)
+		}
+	}
+
+	result, err := cc.chat(ctx, in.ConversationID, in.Message, in.Model, in.Cwd)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	summary, _ := json.Marshal(result)
+	if !in.Wait {
+		return llm.ToolOut{LLMContent: llm.TextContent(string(summary))}
+	}
+
+	var events []streamEvent
+	err = cc.streamMessages(ctx, result.ConversationID, afterSeq, func(e streamEvent) bool {
+		if e.Type != "system" {
+			events = append(events, e)
+		}
+		return !e.endsTurn()
+	})
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(string(summary) + "\n" + jsonLines(events))}
+}
+
+func jsonLines[T any](items []T) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	for _, item := range items {
+		enc.Encode(item)
+	}
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeShelley serves just enough of the Shelley HTTP API for the MCP tools.
+// Conversation "c1" has a finished first turn; chatting appends a second turn.
+func fakeShelley(t *testing.T) *httptest.Server {
+	t.Helper()
+	agentMsg := func(seq int64, text string) string {
+		llmData, _ := json.Marshal(map[string]any{"Content": []map[string]any{{"Type": contentTypeText, "Text": text}}})
+		return fmt.Sprintf(`{"sequence_id":%d,"type":"agent","llm_data":%q,"end_of_turn":true}`, seq, llmData)
+	}
+	messages := []string{`{"sequence_id":1,"type":"user"}`, agentMsg(2, "first answer")}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/conversation/c1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"messages":[%s]}`, strings.Join(messages, ","))
+	})
+	mux.HandleFunc("POST /api/conversation/c1/chat", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Shelley-Request") == "" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		messages = append(messages, `{"sequence_id":3,"type":"user"}`, agentMsg(4, "second answer"))
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"status":"accepted"}`)
+	})
+	mux.HandleFunc("GET /api/conversation/c1/stream", func(w http.ResponseWriter, r *http.Request) {
+		// Like the real server, a resumed stream only carries newer messages,
+		// but send everything to check that the client filters too.
+		fmt.Fprintf(w, "data: {\"heartbeat\":true}\n\n")
+		fmt.Fprintf(w, "data: {\"messages\":[%s]}\n\n", strings.Join(messages, ","))
+	})
+	mux.HandleFunc("GET /api/conversations", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "deploy" || r.URL.Query().Get("limit") != "50" {
+			t.Errorf("unexpected list query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"conversation_id":"c1","slug":"deploy-fix","working":false,"extra":"ignored"}]`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func mcpTool(cc *clientConfig, name string) func(input string) (string, error) {
+	for _, tool := range cc.mcpTools() {
+		if tool.Name == name {
+			return func(input string) (string, error) {
+				out := tool.Run(context.Background(), json.RawMessage(input))
+				if out.Error != nil {
+					return "", out.Error
+				}
+				return out.LLMContent[0].Text, nil
+			}
+		}
+	}
+	return nil
+}
+
+func TestMCPTools(t *testing.T) {
+	srv := fakeShelley(t)
+	cc := &clientConfig{serverURL: srv.URL}
+
+	out, err := mcpTool(cc, "send_message")(`{"conversation_id":"c1","message":"again","wait":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"conversation_id":"c1"`) || !strings.Contains(out, "second answer") {
+		t.Errorf("expected the new turn, got: %s", out)
+	}
+	if strings.Contains(out, "first answer") {
+		t.Errorf("wait should only return the new turn, got: %s", out)
+	}
+
+	out, err = mcpTool(cc, "read_conversation")(`{"conversation_id":"c1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 4 {
+		t.Errorf("expected 4 messages, got: %s", out)
+	}
+
+	out, err = mcpTool(cc, "list_conversations")(`{"query":"deploy"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"slug":"deploy-fix"`) || strings.Contains(out, "extra") {
+		t.Errorf("unexpected list output: %s", out)
+	}
+
+	if _, err := mcpTool(cc, "send_message")(`{"message":"no id"}`); err == nil {
+		t.Error("expected error without conversation_id")
+	}
+}
@@ -69,6 +69,9 @@ func TS() *go2ts.Go2TS {
 		streamResponseForTS{},
 		conversationWithStateForTS{},
 		notificationEventForTS{},
+		permissionRequestForTS{},
+		messageBranchesForTS{},
+		streamDeltaForTS{},
 	)
 
 	// Generate clean nominal types
@@ -97,20 +100,26 @@ type conversationStateForTS struct {
 }
 
 type conversationWithStateForTS struct {
-	ConversationID       string  `json:"conversation_id"`
-	Slug                 *string `json:"slug"`
-	UserInitiated        bool    `json:"user_initiated"`
-	CreatedAt            string  `json:"created_at"`
-	UpdatedAt            string  `json:"updated_at"`
-	Cwd                  *string `json:"cwd"`
-	Archived             bool    `json:"archived"`
-	ParentConversationID *string `json:"parent_conversation_id"`
-	Model                *string `json:"model"`
-	Working              bool    `json:"working"`
-	GitRepoRoot          string  `json:"git_repo_root,omitempty"`
-	GitWorktreeRoot      string  `json:"git_worktree_root,omitempty"`
-	GitCommit            string  `json:"git_commit,omitempty"`
-	GitSubject           string  `json:"git_subject,omitempty"`
+	ConversationID           string  `json:"conversation_id"`
+	Slug                     *string `json:"slug"`
+	UserInitiated            bool    `json:"user_initiated"`
+	CreatedAt                string  `json:"created_at"`
+	UpdatedAt                string  `json:"updated_at"`
+	Cwd                      *string `json:"cwd"`
+	Archived                 bool    `json:"archived"`
+	ParentConversationID     *string `json:"parent_conversation_id"`
+	Model                    *string `json:"model"`
+	ForkedFromConversationID *string `json:"forked_from_conversation_id"`
+	ForkedFromSequenceID     *int64  `json:"forked_from_sequence_id"`
+	ActiveMessageID          *string `json:"active_message_id"`
+	AllowedTools             *string `json:"allowed_tools"`
+	UserID                   *string `json:"user_id"`
+	Skills                   *string `json:"skills"`
+	Working                  bool    `json:"working"`
+	GitRepoRoot              string  `json:"git_repo_root,omitempty"`
+	GitWorktreeRoot          string  `json:"git_worktree_root,omitempty"`
+	GitCommit                string  `json:"git_commit,omitempty"`
+	GitSubject               string  `json:"git_subject,omitempty"`
 }
 
 type streamResponseForTS struct {
@@ -119,6 +128,31 @@ type streamResponseForTS struct {
 	ConversationState *conversationStateForTS `json:"conversation_state,omitempty"`
 	Heartbeat         bool                    `json:"heartbeat,omitempty"`
 	NotificationEvent *notificationEventForTS `json:"notification_event,omitempty"`
+	PermissionRequest *permissionRequestForTS `json:"permission_request,omitempty"`
+	Branches          []messageBranchesForTS  `json:"branches,omitempty"`
+	ResetMessages     bool                    `json:"reset_messages,omitempty"`
+	StreamDelta       *streamDeltaForTS       `json:"stream_delta,omitempty"`
+	SystemPromptStale *bool                   `json:"system_prompt_stale,omitempty"`
+}
+
+type streamDeltaForTS struct {
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+	Restart  bool   `json:"restart,omitempty"`
+}
+
+type messageBranchesForTS struct {
+	MessageID    string   `json:"message_id"`
+	Alternatives []string `json:"alternatives"`
+}
+
+type permissionRequestForTS struct {
+	ID             string   `json:"id"`
+	ConversationID string   `json:"conversation_id"`
+	Command        string   `json:"command"`
+	Programs       []string `json:"programs"`
+	Resolved       bool     `json:"resolved,omitempty"`
+	Approved       bool     `json:"approved,omitempty"`
 }
 
 type notificationEventForTS struct {
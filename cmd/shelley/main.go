@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/lsp"
+	"shelley.exe.dev/claudetool/mcp"
 	"shelley.exe.dev/client"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/models"
@@ -50,6 +52,9 @@ func main() {
 		fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  serve [flags]                 Start the web server\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  client [flags] <subcommand>   CLI client (chat, read, list, archive) (experimental)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  mcp [flags]                   Serve MCP over stdio, proxying to a running server\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  run [flags]                   Run one prompt to completion without a server (for CI and scripts)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  users <subcommand>            Manage users that can sign in (add, list, passwd, admin, remove)\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  unpack-template <name> <dir>  Unpack a project template to a directory\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  version                       Print version information as JSON\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUse '%s <command> -h' for command-specific help\n", os.Args[0])
@@ -64,12 +69,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	mcp.ClientInfo.Version = version.Version
+
 	command := args[0]
 	switch command {
 	case "serve":
 		runServe(global, args[1:])
 	case "client":
 		client.Run(args[1:])
+	case "mcp":
+		client.RunMCP(args[1:])
+	case "run":
+		runRun(global, args[1:])
+	case "users":
+		runUsers(global, args[1:])
 	case "unpack-template":
 		runUnpackTemplate(args[1:])
 	case "version":
@@ -86,8 +99,12 @@ func runServe(global GlobalConfig, args []string) {
 	port := fs.String("port", "9000", "Port to listen on")
 	portFile := fs.String("port-file", "", "Write the actual listening port to this file (useful with --port 0)")
 	systemdActivation := fs.Bool("systemd-activation", false, "Use systemd socket activation (listen on fd from systemd)")
-	requireHeader := fs.String("require-header", "", "Require this header on all API requests (e.g., X-Exedev-Userid)")
+	requireHeader := fs.String("require-header", "", "Require this header on all API requests and use its value as the username (e.g., X-Exedev-Userid)")
+	passwordAuth := fs.Bool("password-auth", false, "Require users to sign in with a password set by 'shelley users'")
+	admins := fs.String("admins", "", "Comma-separated usernames that can see every user's conversations")
 	socketPath := fs.String("socket", client.DefaultSocketPath(), "Path to Unix socket for local CLI client access (set to 'none' to disable)")
+	maxSubagentDepth := fs.Int("max-subagent-depth", 1, "How deeply subagents may nest; 1 lets only top-level conversations spawn them (0 for no limit)")
+	maxConcurrentSubagents := fs.Int("max-concurrent-subagents", 0, "Maximum number of subagents working at once (0 for no limit)")
 	fs.Parse(args)
 
 	logger := setupLogging(global.Debug)
@@ -109,15 +126,35 @@ func runServe(global GlobalConfig, args []string) {
 	logger.Info("Available models", "models", strings.Join(availableModels, ", "))
 
 	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.MCP = server.NewMCPManager(database, llmConfig.MCPServers, logger)
+	toolSetConfig.LSP = lsp.NewPool(logger)
+	toolSetConfig.DiagnosticCommands = llmConfig.Diagnostics
+	toolSetConfig.LanguageServers = llmConfig.LanguageServers
 
 	// Create server
 	svr := server.NewServer(database, llmManager, toolSetConfig, logger, global.PredictableOnly, llmConfig.TerminalURL, llmConfig.DefaultModel, *requireHeader, llmConfig.Links)
+	svr.SetSubagentLimits(*maxSubagentDepth, *maxConcurrentSubagents)
+	authConfig := server.AuthConfig{Header: *requireHeader, PasswordLogin: *passwordAuth}
+	for _, name := range strings.Split(*admins, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			authConfig.Admins = append(authConfig.Admins, name)
+		}
+	}
+	svr.SetAuth(authConfig)
 
 	// Seed notification channels from config file if DB is empty (one-time migration)
 	svr.SeedNotificationChannelsFromConfig(llmConfig.NotificationChannels)
 	// Load notification channels from DB
 	svr.ReloadNotificationChannels()
 
+	// Apply changes to shelley.json, guidance files and skills without a restart
+	reloadConfig := func() *server.LLMConfig {
+		return buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database)
+	}
+	if err := svr.WatchFiles(global.ConfigPath, reloadConfig); err != nil {
+		logger.Warn("Failed to watch files for changes", "error", err)
+	}
+
 	// Resolve socket path: "none" disables the Unix socket listener
 	effectiveSocket := *socketPath
 	if effectiveSocket == "none" {
@@ -298,6 +335,10 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			DefaultModel         string           `json:"default_model"`
 			Links                []server.Link    `json:"links"`
 			NotificationChannels []map[string]any `json:"notification_channels"`
+			MCPServers           json.RawMessage  `json:"mcp_servers"`
+			Diagnostics          json.RawMessage  `json:"diagnostics"`
+			LanguageServers      json.RawMessage  `json:"language_servers"`
+			ModelGroups          json.RawMessage  `json:"model_groups"`
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			logger.Warn("Failed to parse config file", "path", configPath, "error", err)
@@ -346,6 +387,46 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			llmCfg.NotificationChannels = cfg.NotificationChannels
 			logger.Info("Notification channels configured", "count", len(cfg.NotificationChannels))
 		}
+
+		if len(cfg.MCPServers) > 0 {
+			servers, err := mcp.ParseServerConfigs(string(cfg.MCPServers))
+			if err != nil {
+				logger.Warn("Ignoring invalid mcp_servers in config file", "path", configPath, "error", err)
+			} else {
+				llmCfg.MCPServers = servers
+				logger.Info("MCP servers configured", "count", len(servers))
+			}
+		}
+
+		if len(cfg.Diagnostics) > 0 {
+			var commands []claudetool.DiagnosticCommand
+			if err := json.Unmarshal(cfg.Diagnostics, &commands); err != nil {
+				logger.Warn("Ignoring invalid diagnostics in config file", "path", configPath, "error", err)
+			} else {
+				llmCfg.Diagnostics = commands
+				logger.Info("Diagnostic commands configured", "count", len(commands))
+			}
+		}
+
+		if len(cfg.LanguageServers) > 0 {
+			servers, err := lsp.ParseServerConfigs(string(cfg.LanguageServers))
+			if err != nil {
+				logger.Warn("Ignoring invalid language_servers in config file", "path", configPath, "error", err)
+			} else {
+				llmCfg.LanguageServers = servers
+				logger.Info("Language servers configured", "count", len(servers))
+			}
+		}
+
+		if len(cfg.ModelGroups) > 0 {
+			groups, err := models.ParseGroupConfigs(string(cfg.ModelGroups))
+			if err != nil {
+				logger.Warn("Ignoring invalid model_groups in config file", "path", configPath, "error", err)
+			} else {
+				llmCfg.ModelGroups = groups
+				logger.Info("Model groups configured", "count", len(groups))
+			}
+		}
 	}
 
 	return llmCfg
@@ -86,6 +86,34 @@ func TestCLICommands(t *testing.T) {
 		// If no error or different error, that's also fine for this basic test
 		t.Logf("Serve command output: %s", string(output))
 	})
+
+	t.Run("run", func(t *testing.T) {
+		run := func(prompt string) (string, int) {
+			t.Helper()
+			promptFile := filepath.Join(tempDir, "prompt.txt")
+			if err := os.WriteFile(promptFile, []byte(prompt), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(binary, "-predictable-only", "run", "-model", "predictable", "-cwd", tempDir, "-prompt-file", promptFile)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			if exitError, ok := err.(*exec.ExitError); ok {
+				return stdout.String(), exitError.ExitCode()
+			} else if err != nil {
+				t.Fatalf("run failed: %v\n%s", err, stderr.String())
+			}
+			return stdout.String(), 0
+		}
+
+		if output, code := run("echo: fixed it"); code != exitRunSuccess || strings.TrimSpace(output) != "fixed it" {
+			t.Errorf("expected exit %d with the response, got %d: %q", exitRunSuccess, code, output)
+		}
+		if _, code := run("error: boom"); code != exitRunLLMError {
+			t.Errorf("expected exit %d for an LLM error, got %d", exitRunLLMError, code)
+		}
+	})
 }
 
 func TestSystemdListenerErrors(t *testing.T) {
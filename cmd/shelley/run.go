@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"shelley.exe.dev/claudetool/lsp"
+	"shelley.exe.dev/server"
+)
+
+// Exit codes for `shelley run`, so that scripts can tell failures apart.
+const (
+	exitRunSuccess    = 0
+	exitRunFailure    = 1 // Bad usage or setup
+	exitRunLLMError   = 2
+	exitRunOverBudget = 3
+	exitRunTimeout    = 4
+)
+
+// runExitCodes maps each headless outcome to its exit code.
+var runExitCodes = map[server.HeadlessOutcome]int{
+	server.HeadlessSuccess:    exitRunSuccess,
+	server.HeadlessLLMError:   exitRunLLMError,
+	server.HeadlessOverBudget: exitRunOverBudget,
+	server.HeadlessTimeout:    exitRunTimeout,
+}
+
+// runRun runs a single prompt to completion in-process, without a server.
+func runRun(global GlobalConfig, args []string) {
+	os.Exit(runPrompt(global, args))
+}
+
+// runPrompt implements runRun and returns the exit code, so that deferred
+// cleanup (such as removing a temporary database) happens before exiting.
+func runPrompt(global GlobalConfig, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	model := fs.String("model", global.Model, "LLM model to use")
+	cwd := fs.String("cwd", ".", "Working directory for the agent")
+	promptFile := fs.String("prompt-file", "", "File containing the prompt ('-' for stdin)")
+	dbPath := fs.String("db", "", "Path to SQLite database file (default: a temporary database)")
+	timeout := fs.Duration("timeout", 30*time.Minute, "Cancel the run after this long (0 for no limit)")
+	jsonOutput := fs.Bool("json", false, "Print the outcome and full transcript as JSON instead of the final response")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley [global-flags] run -prompt-file FILE [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Runs one prompt to completion and prints the agent's final response.\n\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nExit codes: %d success, %d usage or setup error, %d LLM error, %d budget reached, %d timeout\n",
+			exitRunSuccess, exitRunFailure, exitRunLLMError, exitRunOverBudget, exitRunTimeout)
+	}
+	fs.Parse(args)
+
+	if *promptFile == "" {
+		fs.Usage()
+		return exitRunFailure
+	}
+	prompt, err := readPrompt(*promptFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading prompt: %v\n", err)
+		return exitRunFailure
+	}
+	workingDir, err := filepath.Abs(*cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving working directory: %v\n", err)
+		return exitRunFailure
+	}
+	if info, err := os.Stat(workingDir); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", workingDir)
+		return exitRunFailure
+	}
+
+	// Stdout is for the result, so logs go to stderr and only warnings are shown unless -debug is set.
+	logLevel := slog.LevelWarn
+	if global.Debug {
+		logLevel = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+	slog.SetDefault(logger)
+
+	if *dbPath == "" {
+		tempDir, err := os.MkdirTemp("", "shelley-run-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating temporary directory: %v\n", err)
+			return exitRunFailure
+		}
+		defer os.RemoveAll(tempDir)
+		*dbPath = filepath.Join(tempDir, "shelley.db")
+	}
+	database := setupDatabase(*dbPath, logger)
+	defer database.Close()
+	server.DBPath = *dbPath
+
+	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, *model, database)
+	llmManager := server.NewLLMServiceManager(llmConfig)
+	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.WorkingDir = workingDir
+	toolSetConfig.MCP = server.NewMCPManager(database, llmConfig.MCPServers, logger)
+	toolSetConfig.LSP = lsp.NewPool(logger)
+	defer toolSetConfig.LSP.Close()
+	toolSetConfig.DiagnosticCommands = llmConfig.Diagnostics
+	toolSetConfig.LanguageServers = llmConfig.LanguageServers
+	svr := server.NewServer(database, llmManager, toolSetConfig, logger, global.PredictableOnly, llmConfig.TerminalURL, *model, "", llmConfig.Links)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := svr.RunHeadless(ctx, server.HeadlessOptions{
+		Model:   *model,
+		Cwd:     workingDir,
+		Prompt:  prompt,
+		Timeout: *timeout,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitRunFailure
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding result: %v\n", err)
+			return exitRunFailure
+		}
+	} else if result.Outcome == server.HeadlessSuccess {
+		fmt.Println(result.Response)
+	} else {
+		fmt.Fprintf(os.Stderr, "Run ended with %s: %s\n", result.Outcome, result.Response)
+	}
+	return runExitCodes[result.Outcome]
+}
+
+// readPrompt reads the prompt from path, or from stdin if path is "-".
+func readPrompt(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return string(data), nil
+}
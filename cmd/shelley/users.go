@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/server"
+)
+
+// runUsers manages the users that can sign in to the web UI.
+func runUsers(global GlobalConfig, args []string) {
+	if err := manageUsers(global, args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usersUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: shelley [global-flags] users <subcommand>\n\n")
+	fmt.Fprintf(w, "Subcommands:\n")
+	fmt.Fprintf(w, "  add [-admin] [-password] <name>  Add a user, optionally reading a password from stdin\n")
+	fmt.Fprintf(w, "  list                             List users\n")
+	fmt.Fprintf(w, "  passwd <name>                    Set a user's password, read from stdin\n")
+	fmt.Fprintf(w, "  admin <name> true|false          Grant or revoke admin rights\n")
+	fmt.Fprintf(w, "  remove <name>                    Remove a user; their conversations are kept\n")
+	fmt.Fprintf(w, "\nThe first user added is an admin and owns any existing conversations.\n")
+}
+
+func manageUsers(global GlobalConfig, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		usersUsage(os.Stderr)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	database, err := db.New(db.Config{DSN: global.DBPath})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		return fmt.Errorf("failed to run database migrations: %w", err)
+	}
+
+	subcommand, args := args[0], args[1:]
+	switch subcommand {
+	case "add":
+		fs := flag.NewFlagSet("users add", flag.ExitOnError)
+		admin := fs.Bool("admin", false, "Make the user an admin")
+		withPassword := fs.Bool("password", false, "Read a password for the built-in login page from stdin")
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: shelley users add [-admin] [-password] <name>")
+		}
+		var passwordHash *string
+		if *withPassword {
+			hash, err := readPasswordHash(stdin, stdout)
+			if err != nil {
+				return err
+			}
+			passwordHash = &hash
+		}
+		user, err := database.CreateUser(ctx, fs.Arg(0), passwordHash, *admin)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Added user %s (%s)\n", user.Username, user.UserID)
+
+	case "list":
+		users, err := database.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			var flags []string
+			if user.IsAdmin {
+				flags = append(flags, "admin")
+			}
+			if user.PasswordHash != nil {
+				flags = append(flags, "password")
+			}
+			fmt.Fprintf(stdout, "%s\t%s\t%s\n", user.UserID, user.Username, strings.Join(flags, ","))
+		}
+
+	case "passwd":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: shelley users passwd <name>")
+		}
+		user, err := lookupUser(ctx, database, args[0])
+		if err != nil {
+			return err
+		}
+		hash, err := readPasswordHash(stdin, stdout)
+		if err != nil {
+			return err
+		}
+		if err := database.SetUserPassword(ctx, user.UserID, &hash); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Password set for %s\n", user.Username)
+
+	case "admin":
+		if len(args) != 2 || (args[1] != "true" && args[1] != "false") {
+			return fmt.Errorf("usage: shelley users admin <name> true|false")
+		}
+		user, err := lookupUser(ctx, database, args[0])
+		if err != nil {
+			return err
+		}
+		if err := database.SetUserAdmin(ctx, user.UserID, args[1] == "true"); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Set admin=%s for %s\n", args[1], user.Username)
+
+	case "remove":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: shelley users remove <name>")
+		}
+		user, err := lookupUser(ctx, database, args[0])
+		if err != nil {
+			return err
+		}
+		if err := database.DeleteUser(ctx, user.UserID); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Removed user %s\n", user.Username)
+
+	default:
+		usersUsage(os.Stderr)
+		return fmt.Errorf("unknown subcommand: %s", subcommand)
+	}
+	return nil
+}
+
+func lookupUser(ctx context.Context, database *db.DB, username string) (*generated.User, error) {
+	user, err := database.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no such user: %s", username)
+	}
+	return user, err
+}
+
+// readPasswordHash reads a password from the first line of stdin and hashes it.
+func readPasswordHash(stdin io.Reader, stdout io.Writer) (string, error) {
+	if f, ok := stdin.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(stdout, "Password: ")
+		}
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	return server.HashPassword(password)
+}
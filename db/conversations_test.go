@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -184,7 +186,7 @@ func TestConversationService_List(t *testing.T) {
 	}
 
 	// Test listing with pagination
-	conversations, err := db.ListConversations(ctx, 3, 0)
+	conversations, err := db.ListConversations(ctx, nil, 3, 0)
 	if err != nil {
 		t.Errorf("List() error = %v", err)
 		return
@@ -216,7 +218,7 @@ func TestConversationService_Search(t *testing.T) {
 	}
 
 	// Search for "project" should return 2 conversations
-	results, err := db.SearchConversations(ctx, "project", 10, 0)
+	results, err := db.SearchConversations(ctx, nil, "project", 10, 0)
 	if err != nil {
 		t.Errorf("Search() error = %v", err)
 		return
@@ -472,7 +474,7 @@ func TestConversationService_ListArchivedConversations(t *testing.T) {
 	}
 
 	// Test ListArchivedConversations
-	conversations, err := db.ListArchivedConversations(ctx, 10, 0)
+	conversations, err := db.ListArchivedConversations(ctx, nil, 10, 0)
 	if err != nil {
 		t.Errorf("ListArchivedConversations() error = %v", err)
 	}
@@ -520,7 +522,7 @@ func TestConversationService_SearchArchivedConversations(t *testing.T) {
 	}
 
 	// Test SearchArchivedConversations
-	conversations, err := db.SearchArchivedConversations(ctx, "test-conversation", 10, 0)
+	conversations, err := db.SearchArchivedConversations(ctx, nil, "test-conversation", 10, 0)
 	if err != nil {
 		t.Errorf("SearchArchivedConversations() error = %v", err)
 	}
@@ -604,3 +606,140 @@ func TestConversationService_UpdateConversationCwd(t *testing.T) {
 		t.Errorf("Expected cwd %s, got %s", newCwd, *updatedConv.Cwd)
 	}
 }
+
+func TestConversationService_Fork(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	source, err := db.CreateConversation(ctx, stringPtr("source"), true, stringPtr("/tmp"), stringPtr("predictable"))
+	if err != nil {
+		t.Fatalf("Failed to create source conversation: %v", err)
+	}
+	for _, text := range []string{"first", "second", "third"} {
+		_, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: source.ConversationID,
+			Type:           MessageTypeUser,
+			UserData:       map[string]string{"text": text},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+
+	fork, err := db.ForkConversation(ctx, source.ConversationID, 2, nil)
+	if err != nil {
+		t.Fatalf("ForkConversation() error = %v", err)
+	}
+	if fork.ForkedFromConversationID == nil || *fork.ForkedFromConversationID != source.ConversationID {
+		t.Errorf("Expected fork of %s, got %v", source.ConversationID, fork.ForkedFromConversationID)
+	}
+	if fork.ForkedFromSequenceID == nil || *fork.ForkedFromSequenceID != 2 {
+		t.Errorf("Expected forked_from_sequence_id 2, got %v", fork.ForkedFromSequenceID)
+	}
+	if fork.Cwd == nil || *fork.Cwd != "/tmp" || fork.Model == nil || *fork.Model != "predictable" {
+		t.Errorf("Expected cwd and model from source, got %v %v", fork.Cwd, fork.Model)
+	}
+
+	messages, err := db.ListMessages(ctx, fork.ConversationID)
+	if err != nil {
+		t.Fatalf("Failed to list fork messages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 copied messages, got %d", len(messages))
+	}
+	if messages[1].SequenceID != 2 || messages[1].UserData == nil || !strings.Contains(*messages[1].UserData, "second") {
+		t.Errorf("Unexpected copied message: %+v", messages[1])
+	}
+
+	if _, err := db.ForkConversation(ctx, source.ConversationID, 10, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for unknown sequence_id, got %v", err)
+	}
+
+	// Deleting the source keeps the fork but clears its lineage.
+	if err := db.DeleteConversation(ctx, source.ConversationID); err != nil {
+		t.Fatalf("Failed to delete source: %v", err)
+	}
+	fork, err = db.GetConversationByID(ctx, fork.ConversationID)
+	if err != nil {
+		t.Fatalf("Failed to get fork after deleting source: %v", err)
+	}
+	if fork.ForkedFromConversationID != nil {
+		t.Errorf("Expected lineage to be cleared, got %v", *fork.ForkedFromConversationID)
+	}
+}
+
+func TestUserConversationOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	legacy, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := db.CreateUser(ctx, "first", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.IsAdmin {
+		t.Error("expected the first user to be an admin")
+	}
+	adopted, err := db.GetConversationByID(ctx, legacy.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted.UserID == nil || *adopted.UserID != first.UserID {
+		t.Errorf("expected the first user to adopt existing conversations, got owner %v", adopted.UserID)
+	}
+	if _, err := db.CreateUser(ctx, "first", nil, false); !errors.Is(err, ErrUserExists) {
+		t.Errorf("expected ErrUserExists, got %v", err)
+	}
+
+	second, err := db.GetOrCreateUser(ctx, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.IsAdmin {
+		t.Error("expected only the first user to be an admin")
+	}
+	owned, err := db.CreateUserConversation(ctx, &second.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subagent, err := db.CreateSubagentConversation(ctx, "helper", owned.ConversationID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subagent.UserID == nil || *subagent.UserID != second.UserID {
+		t.Errorf("expected the subagent to inherit its owner, got %v", subagent.UserID)
+	}
+
+	list, err := db.ListConversations(ctx, &second.UserID, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ConversationID != owned.ConversationID {
+		t.Errorf("expected only the second user's conversation, got %d", len(list))
+	}
+	all, err := db.ListConversations(ctx, nil, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 conversations without a filter, got %d", len(all))
+	}
+
+	if err := db.DeleteUser(ctx, second.UserID); err != nil {
+		t.Fatal(err)
+	}
+	orphaned, err := db.GetConversationByID(ctx, owned.ConversationID)
+	if err != nil {
+		t.Fatalf("expected the conversation to survive its owner: %v", err)
+	}
+	if orphaned.UserID != nil {
+		t.Errorf("expected the conversation to become unowned, got %v", *orphaned.UserID)
+	}
+}
@@ -4,6 +4,7 @@ package db
 //go:generate go tool github.com/sqlc-dev/sqlc/cmd/sqlc generate -f ../sqlc.yaml
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
 	"database/sql"
@@ -15,6 +16,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -236,6 +238,11 @@ func WithTxRes[T any](db *DB, ctx context.Context, fn func(*generated.Queries) (
 
 // CreateConversation creates a new conversation with an optional slug
 func (db *DB) CreateConversation(ctx context.Context, slug *string, userInitiated bool, cwd, model *string) (*generated.Conversation, error) {
+	return db.CreateUserConversation(ctx, nil, slug, userInitiated, cwd, model)
+}
+
+// CreateUserConversation creates a new conversation owned by userID, which may be nil.
+func (db *DB) CreateUserConversation(ctx context.Context, userID, slug *string, userInitiated bool, cwd, model *string) (*generated.Conversation, error) {
 	conversationID, err := generateConversationID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate conversation ID: %w", err)
@@ -249,6 +256,7 @@ func (db *DB) CreateConversation(ctx context.Context, slug *string, userInitiate
 			UserInitiated:  userInitiated,
 			Cwd:            cwd,
 			Model:          model,
+			UserID:         userID,
 		})
 		return err
 	})
@@ -285,13 +293,16 @@ func (db *DB) GetConversationBySlug(ctx context.Context, slug string) (*generate
 	return &conversation, err
 }
 
-// ListConversations retrieves conversations with pagination
-func (db *DB) ListConversations(ctx context.Context, limit, offset int64) ([]generated.Conversation, error) {
+// ListConversations retrieves conversations with pagination. If userID is
+// non-nil, only that user's conversations are listed; the same goes for the
+// other list and search methods.
+func (db *DB) ListConversations(ctx context.Context, userID *string, limit, offset int64) ([]generated.Conversation, error) {
 	var conversations []generated.Conversation
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
 		conversations, err = q.ListConversations(ctx, generated.ListConversationsParams{
+			UserID: userID,
 			Limit:  limit,
 			Offset: offset,
 		})
@@ -301,16 +312,16 @@ func (db *DB) ListConversations(ctx context.Context, limit, offset int64) ([]gen
 }
 
 // SearchConversations searches for conversations containing the given query in their slug
-func (db *DB) SearchConversations(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
-	queryPtr := &query
+func (db *DB) SearchConversations(ctx context.Context, userID *string, query string, limit, offset int64) ([]generated.Conversation, error) {
 	var conversations []generated.Conversation
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
 		conversations, err = q.SearchConversations(ctx, generated.SearchConversationsParams{
-			Column1: queryPtr,
-			Limit:   limit,
-			Offset:  offset,
+			Query:  &query,
+			UserID: userID,
+			Limit:  limit,
+			Offset: offset,
 		})
 		return err
 	})
@@ -318,24 +329,59 @@ func (db *DB) SearchConversations(ctx context.Context, query string, limit, offs
 }
 
 // SearchConversationsWithMessages searches for conversations containing the query in slug or message content
-func (db *DB) SearchConversationsWithMessages(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
-	queryPtr := &query
+func (db *DB) SearchConversationsWithMessages(ctx context.Context, userID *string, query string, limit, offset int64) ([]generated.Conversation, error) {
 	var conversations []generated.Conversation
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
 		conversations, err = q.SearchConversationsWithMessages(ctx, generated.SearchConversationsWithMessagesParams{
-			Column1: queryPtr,
-			Column2: queryPtr,
-			Column3: queryPtr,
-			Limit:   limit,
-			Offset:  offset,
+			UserID: userID,
+			Query:  &query,
+			Limit:  limit,
+			Offset: offset,
 		})
 		return err
 	})
 	return conversations, err
 }
 
+// SearchMessages runs a full-text search over message content. Each word of
+// query must appear in the message; the last word also matches as a prefix so
+// that results can update as the user types. If userID is non-nil, only that
+// user's conversations are searched.
+func (db *DB) SearchMessages(ctx context.Context, userID *string, query string, limit, offset int64) ([]generated.SearchMessagesRow, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return []generated.SearchMessagesRow{}, nil
+	}
+	var results []generated.SearchMessagesRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		results, err = q.SearchMessages(ctx, generated.SearchMessagesParams{
+			Query:  match,
+			UserID: userID,
+			Limit:  limit,
+			Offset: offset,
+		})
+		return err
+	})
+	return results, err
+}
+
+// ftsQuery turns free text into an FTS5 query, quoting each word so that
+// punctuation in the input is not parsed as query syntax.
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
 // UpdateConversationSlug updates the slug of a conversation
 func (db *DB) UpdateConversationSlug(ctx context.Context, conversationID, slug string) (*generated.Conversation, error) {
 	var conversation generated.Conversation
@@ -375,6 +421,97 @@ func (db *DB) UpdateConversationModel(ctx context.Context, conversationID, model
 	})
 }
 
+// UpdateConversationAllowedTools restricts the conversation to the named tools.
+// A nil slice lifts the restriction.
+func (db *DB) UpdateConversationAllowedTools(ctx context.Context, conversationID string, tools []string) error {
+	var allowedTools *string
+	if tools != nil {
+		data, err := json.Marshal(tools)
+		if err != nil {
+			return err
+		}
+		value := string(data)
+		allowedTools = &value
+	}
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationAllowedTools(ctx, generated.UpdateConversationAllowedToolsParams{
+			AllowedTools:   allowedTools,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// ParseAllowedTools decodes a conversation's allowed_tools column. It returns
+// nil if every tool is allowed.
+func ParseAllowedTools(conversation *generated.Conversation) ([]string, error) {
+	if conversation.AllowedTools == nil {
+		return nil, nil
+	}
+	var tools []string
+	if err := json.Unmarshal([]byte(*conversation.AllowedTools), &tools); err != nil {
+		return nil, fmt.Errorf("invalid allowed_tools: %w", err)
+	}
+	if tools == nil {
+		tools = []string{}
+	}
+	return tools, nil
+}
+
+// AddConversationSkill records that a skill was loaded in a conversation.
+// A skill that is already recorded is not added again.
+func (db *DB) AddConversationSkill(ctx context.Context, conversationID, name string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		conversation, err := q.GetConversation(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		skills, err := ParseSkills(&conversation)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(skills, name) {
+			return nil
+		}
+		data, err := json.Marshal(append(skills, name))
+		if err != nil {
+			return err
+		}
+		value := string(data)
+		return q.UpdateConversationSkills(ctx, generated.UpdateConversationSkillsParams{
+			Skills:         &value,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// ParseSkills decodes a conversation's skills column: the names of the skills
+// loaded in it, in the order they were first loaded.
+func ParseSkills(conversation *generated.Conversation) ([]string, error) {
+	if conversation.Skills == nil {
+		return nil, nil
+	}
+	var skills []string
+	if err := json.Unmarshal([]byte(*conversation.Skills), &skills); err != nil {
+		return nil, fmt.Errorf("invalid skills: %w", err)
+	}
+	return skills, nil
+}
+
+// GetConversationDepth returns how deeply a conversation is nested under
+// subagent parents: 0 for a top-level conversation, 1 for its subagents, and so on.
+func (db *DB) GetConversationDepth(ctx context.Context, conversationID string) (int, error) {
+	var depth int64
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		depth, err = q.GetConversationDepth(ctx, conversationID)
+		return err
+	})
+	return int(depth), err
+}
+
 // Message methods (moved from MessageService)
 
 // MessageType represents the type of message
@@ -400,51 +537,48 @@ type CreateMessageParams struct {
 	ExcludedFromContext bool        // If true, message is stored but not sent to LLM
 }
 
-// CreateMessage creates a new message
-func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*generated.Message, error) {
-	messageID := uuid.New().String()
-
-	// Marshal JSON fields
-	var llmDataJSON, userDataJSON, usageDataJSON, displayDataJSON *string
+// messageJSON holds the JSON-encoded fields of a CreateMessageParams.
+type messageJSON struct {
+	llmData, userData, usageData, displayData *string
+}
 
-	if params.LLMData != nil {
-		data, err := json.Marshal(params.LLMData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal LLM data: %w", err)
+// marshal encodes the JSON fields of params.
+func (params CreateMessageParams) marshal() (messageJSON, error) {
+	var m messageJSON
+	for _, field := range []struct {
+		name  string
+		value interface{}
+		dst   **string
+	}{
+		{"LLM data", params.LLMData, &m.llmData},
+		{"user data", params.UserData, &m.userData},
+		{"usage data", params.UsageData, &m.usageData},
+		{"display data", params.DisplayData, &m.displayData},
+	} {
+		if field.value == nil {
+			continue
 		}
-		str := string(data)
-		llmDataJSON = &str
-	}
-
-	if params.UserData != nil {
-		data, err := json.Marshal(params.UserData)
+		data, err := json.Marshal(field.value)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal user data: %w", err)
+			return messageJSON{}, fmt.Errorf("failed to marshal %s: %w", field.name, err)
 		}
 		str := string(data)
-		userDataJSON = &str
+		*field.dst = &str
 	}
+	return m, nil
+}
 
-	if params.UsageData != nil {
-		data, err := json.Marshal(params.UsageData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal usage data: %w", err)
-		}
-		str := string(data)
-		usageDataJSON = &str
-	}
+// CreateMessage creates a new message
+func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*generated.Message, error) {
+	messageID := uuid.New().String()
 
-	if params.DisplayData != nil {
-		data, err := json.Marshal(params.DisplayData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal display data: %w", err)
-		}
-		str := string(data)
-		displayDataJSON = &str
+	fields, err := params.marshal()
+	if err != nil {
+		return nil, err
 	}
 
 	var message generated.Message
-	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
 
 		// Get next sequence_id for this conversation
@@ -453,18 +587,31 @@ func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*g
 			return fmt.Errorf("failed to get next sequence ID: %w", err)
 		}
 
+		// New messages extend the active branch
+		conversation, err := q.GetConversation(ctx, params.ConversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get conversation: %w", err)
+		}
+
 		message, err = q.CreateMessage(ctx, generated.CreateMessageParams{
 			MessageID:           messageID,
 			ConversationID:      params.ConversationID,
 			SequenceID:          sequenceID,
 			Type:                string(params.Type),
-			LlmData:             llmDataJSON,
-			UserData:            userDataJSON,
-			UsageData:           usageDataJSON,
-			DisplayData:         displayDataJSON,
+			LlmData:             fields.llmData,
+			UserData:            fields.userData,
+			UsageData:           fields.usageData,
+			DisplayData:         fields.displayData,
 			ExcludedFromContext: params.ExcludedFromContext,
+			ParentMessageID:     conversation.ActiveMessageID,
+		})
+		if err != nil {
+			return err
+		}
+		return q.SetActiveMessage(ctx, generated.SetActiveMessageParams{
+			ActiveMessageID: &messageID,
+			ConversationID:  params.ConversationID,
 		})
-		return err
 	})
 	return &message, err
 }
@@ -524,6 +671,140 @@ func (db *DB) ListMessagesForContext(ctx context.Context, conversationID string)
 	return messages, err
 }
 
+// ListActiveMessages retrieves the messages on a conversation's active branch
+func (db *DB) ListActiveMessages(ctx context.Context, conversationID string) ([]generated.Message, error) {
+	var messages []generated.Message
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		messages, err = q.ListActiveMessages(ctx, conversationID)
+		return err
+	})
+	return messages, err
+}
+
+// ListBranchingMessages retrieves the messages that have alternates, i.e. where
+// an earlier user message was edited and the conversation branched.
+func (db *DB) ListBranchingMessages(ctx context.Context, conversationID string) ([]generated.ListBranchingMessagesRow, error) {
+	var rows []generated.ListBranchingMessagesRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.ListBranchingMessages(ctx, conversationID)
+		return err
+	})
+	return rows, err
+}
+
+// SetActiveMessage makes messageID the last message of the active branch.
+// A nil messageID empties the active branch.
+func (db *DB) SetActiveMessage(ctx context.Context, conversationID string, messageID *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.SetActiveMessage(ctx, generated.SetActiveMessageParams{
+			ActiveMessageID: messageID,
+			ConversationID:  conversationID,
+		})
+	})
+}
+
+// SwitchBranch activates the most recent branch running through messageID.
+func (db *DB) SwitchBranch(ctx context.Context, conversationID, messageID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		message, err := q.GetMessage(ctx, messageID)
+		if err != nil {
+			return err
+		}
+		if message.ConversationID != conversationID {
+			return fmt.Errorf("message %s is not in conversation %s: %w", messageID, conversationID, sql.ErrNoRows)
+		}
+		tip, err := q.GetLatestDescendant(ctx, messageID)
+		if err != nil {
+			return err
+		}
+		return q.SetActiveMessage(ctx, generated.SetActiveMessageParams{
+			ActiveMessageID: &tip.MessageID,
+			ConversationID:  conversationID,
+		})
+	})
+}
+
+// CompactMessages replaces everything before keepFromID on the active branch
+// with a summary in the LLM context. No message is changed, since the earlier
+// ones may be shared with other branches: the summary starts a new branch beside
+// keepFromID, holding copies of keepFromID and the messages after it, and
+// ListMessagesForContext leaves out what precedes the summary. The copies carry
+// no usage, which stays with the originals.
+func (db *DB) CompactMessages(ctx context.Context, keepFromID string, summary CreateMessageParams) (*generated.Message, error) {
+	fields, err := summary.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var message generated.Message
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		active, err := q.ListActiveMessages(ctx, summary.ConversationID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		keep := slices.IndexFunc(active, func(m generated.Message) bool { return m.MessageID == keepFromID })
+		if keep < 0 {
+			return fmt.Errorf("message %s is not on the active branch of conversation %s", keepFromID, summary.ConversationID)
+		}
+
+		sequenceID, err := q.GetNextSequenceID(ctx, summary.ConversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get next sequence ID: %w", err)
+		}
+		message, err = q.CreateMessage(ctx, generated.CreateMessageParams{
+			MessageID:           uuid.New().String(),
+			ConversationID:      summary.ConversationID,
+			SequenceID:          sequenceID,
+			Type:                string(summary.Type),
+			LlmData:             fields.llmData,
+			UserData:            fields.userData,
+			UsageData:           fields.usageData,
+			DisplayData:         fields.displayData,
+			ExcludedFromContext: summary.ExcludedFromContext,
+			ParentMessageID:     active[keep].ParentMessageID,
+		})
+		if err != nil {
+			return err
+		}
+		parentID := &message.MessageID
+		for _, m := range active[keep:] {
+			sequenceID++
+			copiedID := uuid.New().String()
+			err := q.ImportMessage(ctx, generated.ImportMessageParams{
+				MessageID:           copiedID,
+				ConversationID:      summary.ConversationID,
+				SequenceID:          sequenceID,
+				Type:                m.Type,
+				LlmData:             m.LlmData,
+				UserData:            m.UserData,
+				CreatedAt:           m.CreatedAt,
+				DisplayData:         m.DisplayData,
+				ExcludedFromContext: m.ExcludedFromContext,
+				ParentMessageID:     parentID,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to copy message %d: %w", m.SequenceID, err)
+			}
+			parentID = &copiedID
+		}
+		return q.SetActiveMessage(ctx, generated.SetActiveMessageParams{
+			ActiveMessageID: parentID,
+			ConversationID:  summary.ConversationID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
 // ListMessagesByType retrieves messages of a specific type in a conversation
 func (db *DB) ListMessagesByType(ctx context.Context, conversationID string, messageType MessageType) ([]generated.Message, error) {
 	var messages []generated.Message
@@ -569,6 +850,17 @@ func (db *DB) CountMessagesByType(ctx context.Context, conversationID string, me
 	return count, err
 }
 
+// UpdateMessageLLMData replaces the llm_data JSON field of a message
+func (db *DB) UpdateMessageLLMData(ctx context.Context, messageID string, llmData *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateMessageLLMData(ctx, generated.UpdateMessageLLMDataParams{
+			MessageID: messageID,
+			LlmData:   llmData,
+		})
+	})
+}
+
 // UpdateMessageUserData updates the user_data JSON field of a message
 func (db *DB) UpdateMessageUserData(ctx context.Context, messageID string, userData *string) error {
 	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
@@ -597,12 +889,13 @@ func (db *DB) QueriesTx(ctx context.Context, fn func(*generated.Queries) error)
 }
 
 // ListArchivedConversations retrieves archived conversations with pagination
-func (db *DB) ListArchivedConversations(ctx context.Context, limit, offset int64) ([]generated.Conversation, error) {
+func (db *DB) ListArchivedConversations(ctx context.Context, userID *string, limit, offset int64) ([]generated.Conversation, error) {
 	var conversations []generated.Conversation
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
 		conversations, err = q.ListArchivedConversations(ctx, generated.ListArchivedConversationsParams{
+			UserID: userID,
 			Limit:  limit,
 			Offset: offset,
 		})
@@ -612,16 +905,16 @@ func (db *DB) ListArchivedConversations(ctx context.Context, limit, offset int64
 }
 
 // SearchArchivedConversations searches for archived conversations containing the given query in their slug
-func (db *DB) SearchArchivedConversations(ctx context.Context, query string, limit, offset int64) ([]generated.Conversation, error) {
-	queryPtr := &query
+func (db *DB) SearchArchivedConversations(ctx context.Context, userID *string, query string, limit, offset int64) ([]generated.Conversation, error) {
 	var conversations []generated.Conversation
 	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
 		q := generated.New(rx.Conn())
 		var err error
 		conversations, err = q.SearchArchivedConversations(ctx, generated.SearchArchivedConversationsParams{
-			Column1: queryPtr,
-			Limit:   limit,
-			Offset:  offset,
+			Query:  &query,
+			UserID: userID,
+			Limit:  limit,
+			Offset: offset,
 		})
 		return err
 	})
@@ -664,7 +957,8 @@ func (db *DB) DeleteConversation(ctx context.Context, conversationID string) err
 	})
 }
 
-// CreateSubagentConversation creates a new subagent conversation with a parent
+// CreateSubagentConversation creates a new subagent conversation with a parent.
+// The subagent has the same owner as its parent.
 func (db *DB) CreateSubagentConversation(ctx context.Context, slug, parentID string, cwd *string) (*generated.Conversation, error) {
 	conversationID, err := generateConversationID()
 	if err != nil {
@@ -673,11 +967,16 @@ func (db *DB) CreateSubagentConversation(ctx context.Context, slug, parentID str
 	var conversation generated.Conversation
 	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
+		parent, err := q.GetConversation(ctx, parentID)
+		if err != nil {
+			return err
+		}
 		conversation, err = q.CreateSubagentConversation(ctx, generated.CreateSubagentConversationParams{
 			ConversationID:       conversationID,
 			Slug:                 &slug,
 			Cwd:                  cwd,
 			ParentConversationID: &parentID,
+			UserID:               parent.UserID,
 		})
 		return err
 	})
@@ -714,11 +1013,232 @@ func (db *DB) GetConversationBySlugAndParent(ctx context.Context, slug, parentID
 	return &conversation, err
 }
 
+// ForkConversation creates a new conversation holding verbatim copies of the
+// source conversation's active-branch messages up to and including sequenceID. The fork keeps
+// the source's cwd and model, belongs to userID, and records where it was forked from.
+// If the source has no message with sequenceID, the error wraps sql.ErrNoRows.
+func (db *DB) ForkConversation(ctx context.Context, sourceID string, sequenceID int64, userID *string) (*generated.Conversation, error) {
+	conversationID, err := generateConversationID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate conversation ID: %w", err)
+	}
+	var conversation generated.Conversation
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		source, err := q.GetConversation(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+		active, err := q.ListActiveMessages(ctx, sourceID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		var messages []generated.Message
+		for i, m := range active {
+			if m.SequenceID == sequenceID {
+				messages = active[:i+1]
+				break
+			}
+		}
+		if messages == nil {
+			return fmt.Errorf("message with sequence_id %d not found: %w", sequenceID, sql.ErrNoRows)
+		}
+
+		conversation, err = q.CreateForkConversation(ctx, generated.CreateForkConversationParams{
+			ConversationID:           conversationID,
+			Cwd:                      source.Cwd,
+			Model:                    source.Model,
+			ForkedFromConversationID: &sourceID,
+			ForkedFromSequenceID:     &sequenceID,
+			UserID:                   userID,
+		})
+		if err != nil {
+			return err
+		}
+		var parentID *string
+		for _, m := range messages {
+			copied, err := q.CreateMessage(ctx, generated.CreateMessageParams{
+				MessageID:           uuid.New().String(),
+				ConversationID:      conversationID,
+				SequenceID:          m.SequenceID,
+				Type:                m.Type,
+				LlmData:             m.LlmData,
+				UserData:            m.UserData,
+				UsageData:           m.UsageData,
+				DisplayData:         m.DisplayData,
+				ExcludedFromContext: m.ExcludedFromContext,
+				ParentMessageID:     parentID,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to copy message %d: %w", m.SequenceID, err)
+			}
+			parentID = &copied.MessageID
+		}
+		conversation.ActiveMessageID = parentID
+		return q.SetActiveMessage(ctx, generated.SetActiveMessageParams{
+			ActiveMessageID: parentID,
+			ConversationID:  conversationID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// ConversationImport is a conversation to recreate with ImportConversation,
+// along with its messages and subagents.
+type ConversationImport struct {
+	Conversation generated.Conversation
+	Messages     []generated.Message
+	Subagents    []ConversationImport
+	// UserID owns the imported conversation and its subagents; the owner in
+	// Conversation is ignored.
+	UserID *string
+}
+
+// ImportConversation recreates a conversation tree in one transaction. The
+// conversations and messages get new IDs, with parent and active message links
+// remapped to match; slugs get a numeric suffix if already taken. Fork lineage
+// is dropped since the source conversation may not exist here.
+func (db *DB) ImportConversation(ctx context.Context, imp *ConversationImport) (*generated.Conversation, error) {
+	var conversation generated.Conversation
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		var err error
+		conversation, err = importConversation(ctx, generated.New(tx.Conn()), imp, nil, imp.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func importConversation(ctx context.Context, q *generated.Queries, imp *ConversationImport, parentID, userID *string) (generated.Conversation, error) {
+	conversationID, err := generateConversationID()
+	if err != nil {
+		return generated.Conversation{}, fmt.Errorf("failed to generate conversation ID: %w", err)
+	}
+	source := imp.Conversation
+	slug := source.Slug
+	if slug != nil {
+		available, err := availableSlug(ctx, q, *slug)
+		if err != nil {
+			return generated.Conversation{}, err
+		}
+		slug = &available
+	}
+	conversation, err := q.ImportConversation(ctx, generated.ImportConversationParams{
+		ConversationID:       conversationID,
+		Slug:                 slug,
+		UserInitiated:        source.UserInitiated,
+		CreatedAt:            source.CreatedAt,
+		UpdatedAt:            source.UpdatedAt,
+		Cwd:                  source.Cwd,
+		Archived:             source.Archived,
+		ParentConversationID: parentID,
+		Model:                source.Model,
+		AllowedTools:         source.AllowedTools,
+		UserID:               userID,
+	})
+	if err != nil {
+		return generated.Conversation{}, err
+	}
+
+	messages := slices.Clone(imp.Messages)
+	slices.SortFunc(messages, func(a, b generated.Message) int {
+		return cmp.Compare(a.SequenceID, b.SequenceID)
+	})
+	newIDs := make(map[string]string, len(messages))
+	for _, m := range messages {
+		newIDs[m.MessageID] = uuid.New().String()
+	}
+	remap := func(id *string) *string {
+		if id == nil {
+			return nil
+		}
+		newID, ok := newIDs[*id]
+		if !ok {
+			return nil
+		}
+		return &newID
+	}
+	for _, m := range messages {
+		err := q.ImportMessage(ctx, generated.ImportMessageParams{
+			MessageID:           newIDs[m.MessageID],
+			ConversationID:      conversationID,
+			SequenceID:          m.SequenceID,
+			Type:                m.Type,
+			LlmData:             m.LlmData,
+			UserData:            m.UserData,
+			UsageData:           m.UsageData,
+			CreatedAt:           m.CreatedAt,
+			DisplayData:         m.DisplayData,
+			ExcludedFromContext: m.ExcludedFromContext,
+			ParentMessageID:     remap(m.ParentMessageID),
+		})
+		if err != nil {
+			return generated.Conversation{}, fmt.Errorf("failed to import message %d: %w", m.SequenceID, err)
+		}
+	}
+
+	activeID := remap(source.ActiveMessageID)
+	if activeID == nil && len(messages) > 0 {
+		activeID = remap(&messages[len(messages)-1].MessageID)
+	}
+	conversation.ActiveMessageID = activeID
+	err = q.SetActiveMessage(ctx, generated.SetActiveMessageParams{
+		ActiveMessageID: activeID,
+		ConversationID:  conversationID,
+	})
+	if err != nil {
+		return generated.Conversation{}, err
+	}
+
+	for i := range imp.Subagents {
+		if _, err := importConversation(ctx, q, &imp.Subagents[i], &conversationID, userID); err != nil {
+			return generated.Conversation{}, err
+		}
+	}
+	return conversation, nil
+}
+
+// availableSlug returns slug, or slug with a numeric suffix if it is taken.
+func availableSlug(ctx context.Context, q *generated.Queries, slug string) (string, error) {
+	candidate := slug
+	for attempt := 0; attempt < 100; attempt++ {
+		_, err := q.GetConversationBySlug(ctx, &candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, attempt+2)
+	}
+	return "", fmt.Errorf("no free slug for %q", slug)
+}
+
 // SubagentDBAdapter adapts *DB to the claudetool.SubagentDB interface.
 type SubagentDBAdapter struct {
 	DB *DB
 }
 
+// ListSubagentConversations implements claudetool.SubagentDB.
+func (a *SubagentDBAdapter) ListSubagentConversations(ctx context.Context, parentID string) (map[string]string, error) {
+	subagents, err := a.DB.GetSubagents(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(subagents))
+	for _, conv := range subagents {
+		if conv.Slug != nil {
+			ids[*conv.Slug] = conv.ConversationID
+		}
+	}
+	return ids, nil
+}
+
 // GetOrCreateSubagentConversation implements claudetool.SubagentDB.
 // Returns the conversation ID and the actual slug used (may differ if a suffix was added).
 func (a *SubagentDBAdapter) GetOrCreateSubagentConversation(ctx context.Context, slug, parentID, cwd string) (string, string, error) {
@@ -1099,3 +1619,48 @@ func (db *DB) GetAllSettings(ctx context.Context) (map[string]string, error) {
 	}
 	return settings, nil
 }
+
+// CreateCheckpoint records a working tree snapshot taken before sequenceID.
+func (db *DB) CreateCheckpoint(ctx context.Context, params generated.CreateCheckpointParams) (*generated.Checkpoint, error) {
+	var checkpoint generated.Checkpoint
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		checkpoint, err = q.CreateCheckpoint(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// GetCheckpointAt returns the checkpoint for the turn containing sequenceID.
+func (db *DB) GetCheckpointAt(ctx context.Context, conversationID string, sequenceID int64) (*generated.Checkpoint, error) {
+	var checkpoint generated.Checkpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		checkpoint, err = q.GetCheckpointAt(ctx, generated.GetCheckpointAtParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// ListCheckpointWorktrees returns the worktrees a conversation has checkpoints in.
+func (db *DB) ListCheckpointWorktrees(ctx context.Context, conversationID string) ([]string, error) {
+	var worktrees []string
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		worktrees, err = q.ListCheckpointWorktrees(ctx, conversationID)
+		return err
+	})
+	return worktrees, err
+}
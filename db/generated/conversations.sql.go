@@ -7,13 +7,26 @@ package generated
 
 import (
 	"context"
+	"time"
 )
 
+const adoptUnownedConversations = `-- name: AdoptUnownedConversations :exec
+UPDATE conversations
+SET user_id = ?
+WHERE user_id IS NULL
+`
+
+// Gives conversations that predate users to the first user.
+func (q *Queries) AdoptUnownedConversations(ctx context.Context, userID *string) error {
+	_, err := q.db.ExecContext(ctx, adoptUnownedConversations, userID)
+	return err
+}
+
 const archiveConversation = `-- name: ArchiveConversation :one
 UPDATE conversations
 SET archived = TRUE, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
 `
 
 func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -29,6 +42,12 @@ func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
@@ -56,9 +75,9 @@ func (q *Queries) CountConversations(ctx context.Context) (int64, error) {
 }
 
 const createConversation = `-- name: CreateConversation :one
-INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model)
-VALUES (?, ?, ?, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model, user_id)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
 `
 
 type CreateConversationParams struct {
@@ -67,6 +86,7 @@ type CreateConversationParams struct {
 	UserInitiated  bool    `json:"user_initiated"`
 	Cwd            *string `json:"cwd"`
 	Model          *string `json:"model"`
+	UserID         *string `json:"user_id"`
 }
 
 func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversationParams) (Conversation, error) {
@@ -76,6 +96,54 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		arg.UserInitiated,
 		arg.Cwd,
 		arg.Model,
+		arg.UserID,
+	)
+	var i Conversation
+	err := row.Scan(
+		&i.ConversationID,
+		&i.Slug,
+		&i.UserInitiated,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Cwd,
+		&i.Archived,
+		&i.ParentConversationID,
+		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
+	)
+	return i, err
+}
+
+const createForkConversation = `-- name: CreateForkConversation :one
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model, forked_from_conversation_id, forked_from_sequence_id, user_id)
+VALUES (?, ?, TRUE, ?, ?, ?, ?, ?)
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
+`
+
+type CreateForkConversationParams struct {
+	ConversationID           string  `json:"conversation_id"`
+	Slug                     *string `json:"slug"`
+	Cwd                      *string `json:"cwd"`
+	Model                    *string `json:"model"`
+	ForkedFromConversationID *string `json:"forked_from_conversation_id"`
+	ForkedFromSequenceID     *int64  `json:"forked_from_sequence_id"`
+	UserID                   *string `json:"user_id"`
+}
+
+func (q *Queries) CreateForkConversation(ctx context.Context, arg CreateForkConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createForkConversation,
+		arg.ConversationID,
+		arg.Slug,
+		arg.Cwd,
+		arg.Model,
+		arg.ForkedFromConversationID,
+		arg.ForkedFromSequenceID,
+		arg.UserID,
 	)
 	var i Conversation
 	err := row.Scan(
@@ -88,14 +156,20 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
 
 const createSubagentConversation = `-- name: CreateSubagentConversation :one
-INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, parent_conversation_id)
-VALUES (?, ?, FALSE, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, parent_conversation_id, user_id)
+VALUES (?, ?, FALSE, ?, ?, ?)
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
 `
 
 type CreateSubagentConversationParams struct {
@@ -103,6 +177,7 @@ type CreateSubagentConversationParams struct {
 	Slug                 *string `json:"slug"`
 	Cwd                  *string `json:"cwd"`
 	ParentConversationID *string `json:"parent_conversation_id"`
+	UserID               *string `json:"user_id"`
 }
 
 func (q *Queries) CreateSubagentConversation(ctx context.Context, arg CreateSubagentConversationParams) (Conversation, error) {
@@ -111,6 +186,7 @@ func (q *Queries) CreateSubagentConversation(ctx context.Context, arg CreateSuba
 		arg.Slug,
 		arg.Cwd,
 		arg.ParentConversationID,
+		arg.UserID,
 	)
 	var i Conversation
 	err := row.Scan(
@@ -123,6 +199,12 @@ func (q *Queries) CreateSubagentConversation(ctx context.Context, arg CreateSuba
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
@@ -138,7 +220,7 @@ func (q *Queries) DeleteConversation(ctx context.Context, conversationID string)
 }
 
 const getConversation = `-- name: GetConversation :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE conversation_id = ?
 `
 
@@ -155,12 +237,18 @@ func (q *Queries) GetConversation(ctx context.Context, conversationID string) (C
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
 
 const getConversationBySlug = `-- name: GetConversationBySlug :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE slug = ?
 `
 
@@ -177,12 +265,18 @@ func (q *Queries) GetConversationBySlug(ctx context.Context, slug *string) (Conv
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
 
 const getConversationBySlugAndParent = `-- name: GetConversationBySlugAndParent :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE slug = ? AND parent_conversation_id = ?
 `
 
@@ -204,12 +298,37 @@ func (q *Queries) GetConversationBySlugAndParent(ctx context.Context, arg GetCon
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
 
+const getConversationDepth = `-- name: GetConversationDepth :one
+WITH RECURSIVE ancestors(conversation_id, parent_conversation_id, depth) AS (
+    SELECT c.conversation_id, c.parent_conversation_id, 0 FROM conversations c WHERE c.conversation_id = ?
+    UNION ALL
+    SELECT c.conversation_id, c.parent_conversation_id, a.depth + 1
+    FROM conversations c
+    JOIN ancestors a ON c.conversation_id = a.parent_conversation_id
+)
+SELECT CAST(MAX(depth) AS INTEGER) AS depth FROM ancestors
+`
+
+// How many parent_conversation_id links separate a conversation from its root.
+func (q *Queries) GetConversationDepth(ctx context.Context, conversationID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getConversationDepth, conversationID)
+	var depth int64
+	err := row.Scan(&depth)
+	return depth, err
+}
+
 const getSubagents = `-- name: GetSubagents :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE parent_conversation_id = ?
 ORDER BY created_at ASC
 `
@@ -233,6 +352,12 @@ func (q *Queries) GetSubagents(ctx context.Context, parentConversationID *string
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromSequenceID,
+			&i.ActiveMessageID,
+			&i.AllowedTools,
+			&i.UserID,
+			&i.Skills,
 		); err != nil {
 			return nil, err
 		}
@@ -247,20 +372,78 @@ func (q *Queries) GetSubagents(ctx context.Context, parentConversationID *string
 	return items, nil
 }
 
+const importConversation = `-- name: ImportConversation :one
+INSERT INTO conversations (conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, allowed_tools, user_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
+`
+
+type ImportConversationParams struct {
+	ConversationID       string    `json:"conversation_id"`
+	Slug                 *string   `json:"slug"`
+	UserInitiated        bool      `json:"user_initiated"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	Cwd                  *string   `json:"cwd"`
+	Archived             bool      `json:"archived"`
+	ParentConversationID *string   `json:"parent_conversation_id"`
+	Model                *string   `json:"model"`
+	AllowedTools         *string   `json:"allowed_tools"`
+	UserID               *string   `json:"user_id"`
+}
+
+// Recreates an exported conversation, keeping its timestamps.
+func (q *Queries) ImportConversation(ctx context.Context, arg ImportConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, importConversation,
+		arg.ConversationID,
+		arg.Slug,
+		arg.UserInitiated,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Cwd,
+		arg.Archived,
+		arg.ParentConversationID,
+		arg.Model,
+		arg.AllowedTools,
+		arg.UserID,
+	)
+	var i Conversation
+	err := row.Scan(
+		&i.ConversationID,
+		&i.Slug,
+		&i.UserInitiated,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Cwd,
+		&i.Archived,
+		&i.ParentConversationID,
+		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
+	)
+	return i, err
+}
+
 const listArchivedConversations = `-- name: ListArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE archived = TRUE
+  AND user_id IS COALESCE(?, user_id)
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
 `
 
 type ListArchivedConversationsParams struct {
-	Limit  int64 `json:"limit"`
-	Offset int64 `json:"offset"`
+	UserID *string `json:"user_id"`
+	Limit  int64   `json:"limit"`
+	Offset int64   `json:"offset"`
 }
 
 func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchivedConversationsParams) ([]Conversation, error) {
-	rows, err := q.db.QueryContext(ctx, listArchivedConversations, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listArchivedConversations, arg.UserID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -278,6 +461,12 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromSequenceID,
+			&i.ActiveMessageID,
+			&i.AllowedTools,
+			&i.UserID,
+			&i.Skills,
 		); err != nil {
 			return nil, err
 		}
@@ -293,19 +482,22 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE archived = FALSE AND parent_conversation_id IS NULL
+  AND user_id IS COALESCE(?, user_id)
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
 `
 
 type ListConversationsParams struct {
-	Limit  int64 `json:"limit"`
-	Offset int64 `json:"offset"`
+	UserID *string `json:"user_id"`
+	Limit  int64   `json:"limit"`
+	Offset int64   `json:"offset"`
 }
 
+// A NULL user_id lists every user's conversations.
 func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsParams) ([]Conversation, error) {
-	rows, err := q.db.QueryContext(ctx, listConversations, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listConversations, arg.UserID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -323,6 +515,12 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromSequenceID,
+			&i.ActiveMessageID,
+			&i.AllowedTools,
+			&i.UserID,
+			&i.Skills,
 		); err != nil {
 			return nil, err
 		}
@@ -338,20 +536,27 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 }
 
 const searchArchivedConversations = `-- name: SearchArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
+  AND user_id IS COALESCE(?, user_id)
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
 `
 
 type SearchArchivedConversationsParams struct {
-	Column1 *string `json:"column_1"`
-	Limit   int64   `json:"limit"`
-	Offset  int64   `json:"offset"`
+	Query  *string `json:"query"`
+	UserID *string `json:"user_id"`
+	Limit  int64   `json:"limit"`
+	Offset int64   `json:"offset"`
 }
 
 func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArchivedConversationsParams) ([]Conversation, error) {
-	rows, err := q.db.QueryContext(ctx, searchArchivedConversations, arg.Column1, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, searchArchivedConversations,
+		arg.Query,
+		arg.UserID,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -369,6 +574,12 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromSequenceID,
+			&i.ActiveMessageID,
+			&i.AllowedTools,
+			&i.UserID,
+			&i.Skills,
 		); err != nil {
 			return nil, err
 		}
@@ -384,20 +595,27 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 }
 
 const searchConversations = `-- name: SearchConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = FALSE AND parent_conversation_id IS NULL
+  AND user_id IS COALESCE(?, user_id)
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
 `
 
 type SearchConversationsParams struct {
-	Column1 *string `json:"column_1"`
-	Limit   int64   `json:"limit"`
-	Offset  int64   `json:"offset"`
+	Query  *string `json:"query"`
+	UserID *string `json:"user_id"`
+	Limit  int64   `json:"limit"`
+	Offset int64   `json:"offset"`
 }
 
 func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversationsParams) ([]Conversation, error) {
-	rows, err := q.db.QueryContext(ctx, searchConversations, arg.Column1, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, searchConversations,
+		arg.Query,
+		arg.UserID,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -415,6 +633,12 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromSequenceID,
+			&i.ActiveMessageID,
+			&i.AllowedTools,
+			&i.UserID,
+			&i.Skills,
 		); err != nil {
 			return nil, err
 		}
@@ -430,9 +654,10 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 }
 
 const searchConversationsWithMessages = `-- name: SearchConversationsWithMessages :many
-SELECT DISTINCT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model FROM conversations c
+SELECT DISTINCT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model, c.forked_from_conversation_id, c.forked_from_sequence_id, c.active_message_id, c.allowed_tools, c.user_id, c.skills FROM conversations c
 LEFT JOIN messages m ON c.conversation_id = m.conversation_id AND m.type IN ('user', 'agent')
 WHERE c.archived = FALSE
+  AND c.user_id IS COALESCE(?, c.user_id)
   AND (
     c.slug LIKE '%' || ? || '%'
     OR json_extract(m.user_data, '$.text') LIKE '%' || ? || '%'
@@ -443,20 +668,20 @@ LIMIT ? OFFSET ?
 `
 
 type SearchConversationsWithMessagesParams struct {
-	Column1 *string `json:"column_1"`
-	Column2 *string `json:"column_2"`
-	Column3 *string `json:"column_3"`
-	Limit   int64   `json:"limit"`
-	Offset  int64   `json:"offset"`
+	UserID *string `json:"user_id"`
+	Query  *string `json:"query"`
+	Limit  int64   `json:"limit"`
+	Offset int64   `json:"offset"`
 }
 
 // Search conversations by slug OR message content (user messages and agent responses, not system prompts)
 // Includes both top-level conversations and subagent conversations
 func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg SearchConversationsWithMessagesParams) ([]Conversation, error) {
 	rows, err := q.db.QueryContext(ctx, searchConversationsWithMessages,
-		arg.Column1,
-		arg.Column2,
-		arg.Column3,
+		arg.UserID,
+		arg.Query,
+		arg.Query,
+		arg.Query,
 		arg.Limit,
 		arg.Offset,
 	)
@@ -477,6 +702,12 @@ func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg Searc
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ForkedFromConversationID,
+			&i.ForkedFromSequenceID,
+			&i.ActiveMessageID,
+			&i.AllowedTools,
+			&i.UserID,
+			&i.Skills,
 		); err != nil {
 			return nil, err
 		}
@@ -491,11 +722,27 @@ func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg Searc
 	return items, nil
 }
 
+const setActiveMessage = `-- name: SetActiveMessage :exec
+UPDATE conversations
+SET active_message_id = ?
+WHERE conversation_id = ?
+`
+
+type SetActiveMessageParams struct {
+	ActiveMessageID *string `json:"active_message_id"`
+	ConversationID  string  `json:"conversation_id"`
+}
+
+func (q *Queries) SetActiveMessage(ctx context.Context, arg SetActiveMessageParams) error {
+	_, err := q.db.ExecContext(ctx, setActiveMessage, arg.ActiveMessageID, arg.ConversationID)
+	return err
+}
+
 const unarchiveConversation = `-- name: UnarchiveConversation :one
 UPDATE conversations
 SET archived = FALSE, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
 `
 
 func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -511,15 +758,37 @@ func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID stri
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
 
+const updateConversationAllowedTools = `-- name: UpdateConversationAllowedTools :exec
+UPDATE conversations
+SET allowed_tools = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationAllowedToolsParams struct {
+	AllowedTools   *string `json:"allowed_tools"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationAllowedTools(ctx context.Context, arg UpdateConversationAllowedToolsParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationAllowedTools, arg.AllowedTools, arg.ConversationID)
+	return err
+}
+
 const updateConversationCwd = `-- name: UpdateConversationCwd :one
 UPDATE conversations
 SET cwd = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
 `
 
 type UpdateConversationCwdParams struct {
@@ -540,6 +809,12 @@ func (q *Queries) UpdateConversationCwd(ctx context.Context, arg UpdateConversat
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
@@ -560,11 +835,27 @@ func (q *Queries) UpdateConversationModel(ctx context.Context, arg UpdateConvers
 	return err
 }
 
+const updateConversationSkills = `-- name: UpdateConversationSkills :exec
+UPDATE conversations
+SET skills = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationSkillsParams struct {
+	Skills         *string `json:"skills"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationSkills(ctx context.Context, arg UpdateConversationSkillsParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationSkills, arg.Skills, arg.ConversationID)
+	return err
+}
+
 const updateConversationSlug = `-- name: UpdateConversationSlug :one
 UPDATE conversations
 SET slug = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, forked_from_conversation_id, forked_from_sequence_id, active_message_id, allowed_tools, user_id, skills
 `
 
 type UpdateConversationSlugParams struct {
@@ -585,6 +876,12 @@ func (q *Queries) UpdateConversationSlug(ctx context.Context, arg UpdateConversa
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ForkedFromConversationID,
+		&i.ForkedFromSequenceID,
+		&i.ActiveMessageID,
+		&i.AllowedTools,
+		&i.UserID,
+		&i.Skills,
 	)
 	return i, err
 }
@@ -22,7 +22,7 @@ func (q *Queries) GetLLMRequestBody(ctx context.Context, id int64) (*string, err
 }
 
 const getLLMRequestByID = `-- name: GetLLMRequestByID :one
-SELECT id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length FROM llm_requests WHERE id = ?
+SELECT id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length, backend FROM llm_requests WHERE id = ?
 `
 
 func (q *Queries) GetLLMRequestByID(ctx context.Context, id int64) (LlmRequest, error) {
@@ -42,6 +42,7 @@ func (q *Queries) GetLLMRequestByID(ctx context.Context, id int64) (LlmRequest,
 		&i.CreatedAt,
 		&i.PrefixRequestID,
 		&i.PrefixLength,
+		&i.Backend,
 	)
 	return i, err
 }
@@ -58,7 +59,7 @@ func (q *Queries) GetLLMResponseBody(ctx context.Context, id int64) (*string, er
 }
 
 const getLastRequestForConversation = `-- name: GetLastRequestForConversation :one
-SELECT id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length FROM llm_requests
+SELECT id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length, backend FROM llm_requests
 WHERE conversation_id = ?
 ORDER BY id DESC
 LIMIT 1
@@ -81,6 +82,7 @@ func (q *Queries) GetLastRequestForConversation(ctx context.Context, conversatio
 		&i.CreatedAt,
 		&i.PrefixRequestID,
 		&i.PrefixLength,
+		&i.Backend,
 	)
 	return i, err
 }
@@ -97,9 +99,10 @@ INSERT INTO llm_requests (
     error,
     duration_ms,
     prefix_request_id,
-    prefix_length
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length
+    prefix_length,
+    backend
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length, backend
 `
 
 type InsertLLMRequestParams struct {
@@ -114,6 +117,7 @@ type InsertLLMRequestParams struct {
 	DurationMs      *int64  `json:"duration_ms"`
 	PrefixRequestID *int64  `json:"prefix_request_id"`
 	PrefixLength    *int64  `json:"prefix_length"`
+	Backend         *string `json:"backend"`
 }
 
 func (q *Queries) InsertLLMRequest(ctx context.Context, arg InsertLLMRequestParams) (LlmRequest, error) {
@@ -129,6 +133,7 @@ func (q *Queries) InsertLLMRequest(ctx context.Context, arg InsertLLMRequestPara
 		arg.DurationMs,
 		arg.PrefixRequestID,
 		arg.PrefixLength,
+		arg.Backend,
 	)
 	var i LlmRequest
 	err := row.Scan(
@@ -145,6 +150,7 @@ func (q *Queries) InsertLLMRequest(ctx context.Context, arg InsertLLMRequestPara
 		&i.CreatedAt,
 		&i.PrefixRequestID,
 		&i.PrefixLength,
+		&i.Backend,
 	)
 	return i, err
 }
@@ -164,7 +170,8 @@ SELECT
     r.duration_ms,
     r.created_at,
     r.prefix_request_id,
-    r.prefix_length
+    r.prefix_length,
+    r.backend
 FROM llm_requests r
 LEFT JOIN models m ON r.model = m.model_id
 ORDER BY r.id DESC
@@ -186,6 +193,7 @@ type ListRecentLLMRequestsRow struct {
 	CreatedAt          time.Time `json:"created_at"`
 	PrefixRequestID    *int64    `json:"prefix_request_id"`
 	PrefixLength       *int64    `json:"prefix_length"`
+	Backend            *string   `json:"backend"`
 }
 
 func (q *Queries) ListRecentLLMRequests(ctx context.Context, limit int64) ([]ListRecentLLMRequestsRow, error) {
@@ -212,6 +220,7 @@ func (q *Queries) ListRecentLLMRequests(ctx context.Context, limit int64) ([]Lis
 			&i.CreatedAt,
 			&i.PrefixRequestID,
 			&i.PrefixLength,
+			&i.Backend,
 		); err != nil {
 			return nil, err
 		}
@@ -7,6 +7,7 @@ package generated
 
 import (
 	"context"
+	"time"
 )
 
 const countMessagesByType = `-- name: CountMessagesByType :one
@@ -39,9 +40,9 @@ func (q *Queries) CountMessagesInConversation(ctx context.Context, conversationI
 }
 
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, display_data, excluded_from_context)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context
+INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, display_data, excluded_from_context, parent_message_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id
 `
 
 type CreateMessageParams struct {
@@ -54,6 +55,7 @@ type CreateMessageParams struct {
 	UsageData           *string `json:"usage_data"`
 	DisplayData         *string `json:"display_data"`
 	ExcludedFromContext bool    `json:"excluded_from_context"`
+	ParentMessageID     *string `json:"parent_message_id"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -67,6 +69,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.UsageData,
 		arg.DisplayData,
 		arg.ExcludedFromContext,
+		arg.ParentMessageID,
 	)
 	var i Message
 	err := row.Scan(
@@ -80,6 +83,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.CreatedAt,
 		&i.DisplayData,
 		&i.ExcludedFromContext,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
@@ -104,8 +108,104 @@ func (q *Queries) DeleteMessage(ctx context.Context, messageID string) error {
 	return err
 }
 
+const getConversationTreeCost = `-- name: GetConversationTreeCost :one
+WITH RECURSIVE tree(conversation_id) AS (
+    SELECT conversations.conversation_id FROM conversations WHERE conversations.conversation_id = ?
+    UNION ALL
+    SELECT c.conversation_id FROM conversations c
+    JOIN tree t ON c.parent_conversation_id = t.conversation_id
+)
+SELECT CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages m
+JOIN tree t ON m.conversation_id = t.conversation_id
+`
+
+// Total cost of a conversation and all of its subagents, however deeply nested.
+func (q *Queries) GetConversationTreeCost(ctx context.Context, conversationID string) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getConversationTreeCost, conversationID)
+	var cost_usd float64
+	err := row.Scan(&cost_usd)
+	return cost_usd, err
+}
+
+const getConversationUsageTotals = `-- name: GetConversationUsageTotals :one
+SELECT
+    CAST(COALESCE(SUM(
+        COALESCE(json_extract(usage_data, '$.input_tokens'), 0) +
+        COALESCE(json_extract(usage_data, '$.cache_creation_input_tokens'), 0) +
+        COALESCE(json_extract(usage_data, '$.cache_read_input_tokens'), 0)
+    ), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages
+WHERE conversation_id = ? AND usage_data IS NOT NULL
+`
+
+type GetConversationUsageTotalsRow struct {
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUsd      float64 `json:"cost_usd"`
+}
+
+// Token and cost totals for a single conversation; input tokens include cache reads and writes.
+func (q *Queries) GetConversationUsageTotals(ctx context.Context, conversationID string) (GetConversationUsageTotalsRow, error) {
+	row := q.db.QueryRowContext(ctx, getConversationUsageTotals, conversationID)
+	var i GetConversationUsageTotalsRow
+	err := row.Scan(&i.InputTokens, &i.OutputTokens, &i.CostUsd)
+	return i, err
+}
+
+const getCostSinceStartOfDay = `-- name: GetCostSinceStartOfDay :one
+SELECT CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages
+WHERE created_at >= datetime('now', 'localtime', 'start of day', 'utc')
+`
+
+// Total cost across all conversations since local midnight.
+func (q *Queries) GetCostSinceStartOfDay(ctx context.Context) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getCostSinceStartOfDay)
+	var cost_usd float64
+	err := row.Scan(&cost_usd)
+	return cost_usd, err
+}
+
+const getLatestDescendant = `-- name: GetLatestDescendant :one
+WITH RECURSIVE subtree(message_id) AS (
+    SELECT message_id FROM messages WHERE messages.message_id = ?
+    UNION ALL
+    SELECT m.message_id FROM messages m
+    JOIN subtree s ON m.parent_message_id = s.message_id
+)
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
+WHERE message_id IN (SELECT message_id FROM subtree)
+    AND NOT EXISTS (SELECT 1 FROM messages c WHERE c.parent_message_id = messages.message_id)
+ORDER BY sequence_id DESC
+LIMIT 1
+`
+
+// The newest leaf in the subtree under (and including) the given message,
+// which ends the most recent branch through that message.
+func (q *Queries) GetLatestDescendant(ctx context.Context, messageID string) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getLatestDescendant, messageID)
+	var i Message
+	err := row.Scan(
+		&i.MessageID,
+		&i.ConversationID,
+		&i.SequenceID,
+		&i.Type,
+		&i.LlmData,
+		&i.UserData,
+		&i.UsageData,
+		&i.CreatedAt,
+		&i.DisplayData,
+		&i.ExcludedFromContext,
+		&i.ParentMessageID,
+	)
+	return i, err
+}
+
 const getLatestMessage = `-- name: GetLatestMessage :one
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
 WHERE conversation_id = ?
 ORDER BY sequence_id DESC
 LIMIT 1
@@ -125,12 +225,13 @@ func (q *Queries) GetLatestMessage(ctx context.Context, conversationID string) (
 		&i.CreatedAt,
 		&i.DisplayData,
 		&i.ExcludedFromContext,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
 WHERE message_id = ?
 `
 
@@ -148,6 +249,7 @@ func (q *Queries) GetMessage(ctx context.Context, messageID string) (Message, er
 		&i.CreatedAt,
 		&i.DisplayData,
 		&i.ExcludedFromContext,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
@@ -165,8 +267,188 @@ func (q *Queries) GetNextSequenceID(ctx context.Context, conversationID string)
 	return column_1, err
 }
 
+const importMessage = `-- name: ImportMessage :exec
+INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type ImportMessageParams struct {
+	MessageID           string    `json:"message_id"`
+	ConversationID      string    `json:"conversation_id"`
+	SequenceID          int64     `json:"sequence_id"`
+	Type                string    `json:"type"`
+	LlmData             *string   `json:"llm_data"`
+	UserData            *string   `json:"user_data"`
+	UsageData           *string   `json:"usage_data"`
+	CreatedAt           time.Time `json:"created_at"`
+	DisplayData         *string   `json:"display_data"`
+	ExcludedFromContext bool      `json:"excluded_from_context"`
+	ParentMessageID     *string   `json:"parent_message_id"`
+}
+
+// Recreates an exported message, keeping its timestamp.
+func (q *Queries) ImportMessage(ctx context.Context, arg ImportMessageParams) error {
+	_, err := q.db.ExecContext(ctx, importMessage,
+		arg.MessageID,
+		arg.ConversationID,
+		arg.SequenceID,
+		arg.Type,
+		arg.LlmData,
+		arg.UserData,
+		arg.UsageData,
+		arg.CreatedAt,
+		arg.DisplayData,
+		arg.ExcludedFromContext,
+		arg.ParentMessageID,
+	)
+	return err
+}
+
+const listActiveMessages = `-- name: ListActiveMessages :many
+WITH RECURSIVE active_path(message_id, depth) AS (
+    SELECT active_message_id, 0 FROM conversations WHERE conversation_id = ?
+    UNION ALL
+    SELECT m.parent_message_id, p.depth + 1 FROM messages m
+    JOIN active_path p ON m.message_id = p.message_id
+    WHERE m.parent_message_id IS NOT NULL
+)
+SELECT messages.message_id, messages.conversation_id, messages.sequence_id, messages.type, messages.llm_data, messages.user_data, messages.usage_data, messages.created_at, messages.display_data, messages.excluded_from_context, messages.parent_message_id FROM messages
+JOIN active_path ON active_path.message_id = messages.message_id
+ORDER BY active_path.depth DESC
+`
+
+// Messages on the active branch: the parent chain from the conversation's
+// active_message_id back to the first message, in chain order.
+func (q *Queries) ListActiveMessages(ctx context.Context, conversationID string) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveMessages, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ConversationID,
+			&i.SequenceID,
+			&i.Type,
+			&i.LlmData,
+			&i.UserData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.DisplayData,
+			&i.ExcludedFromContext,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActiveMessagesSince = `-- name: ListActiveMessagesSince :many
+WITH RECURSIVE active_path(message_id, depth) AS (
+    SELECT active_message_id, 0 FROM conversations WHERE conversation_id = ?
+    UNION ALL
+    SELECT m.parent_message_id, p.depth + 1 FROM messages m
+    JOIN active_path p ON m.message_id = p.message_id
+    WHERE m.parent_message_id IS NOT NULL
+)
+SELECT messages.message_id, messages.conversation_id, messages.sequence_id, messages.type, messages.llm_data, messages.user_data, messages.usage_data, messages.created_at, messages.display_data, messages.excluded_from_context, messages.parent_message_id FROM messages
+JOIN active_path ON active_path.message_id = messages.message_id
+WHERE messages.sequence_id > ?
+ORDER BY active_path.depth DESC
+`
+
+type ListActiveMessagesSinceParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+}
+
+func (q *Queries) ListActiveMessagesSince(ctx context.Context, arg ListActiveMessagesSinceParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveMessagesSince, arg.ConversationID, arg.SequenceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ConversationID,
+			&i.SequenceID,
+			&i.Type,
+			&i.LlmData,
+			&i.UserData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.DisplayData,
+			&i.ExcludedFromContext,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBranchingMessages = `-- name: ListBranchingMessages :many
+SELECT m.message_id, m.parent_message_id, m.sequence_id FROM messages m
+WHERE m.conversation_id = ? AND m.parent_message_id IS NOT NULL AND EXISTS (
+    SELECT 1 FROM messages s
+    WHERE s.parent_message_id = m.parent_message_id AND s.message_id != m.message_id
+)
+ORDER BY m.sequence_id ASC
+`
+
+type ListBranchingMessagesRow struct {
+	MessageID       string  `json:"message_id"`
+	ParentMessageID *string `json:"parent_message_id"`
+	SequenceID      int64   `json:"sequence_id"`
+}
+
+// Messages that have siblings, i.e. the points where the conversation was edited.
+func (q *Queries) ListBranchingMessages(ctx context.Context, conversationID string) ([]ListBranchingMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBranchingMessages, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListBranchingMessagesRow{}
+	for rows.Next() {
+		var i ListBranchingMessagesRow
+		if err := rows.Scan(&i.MessageID, &i.ParentMessageID, &i.SequenceID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessages = `-- name: ListMessages :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
 WHERE conversation_id = ?
 ORDER BY sequence_id ASC
 `
@@ -191,6 +473,7 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID string) ([]Me
 			&i.CreatedAt,
 			&i.DisplayData,
 			&i.ExcludedFromContext,
+			&i.ParentMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -206,7 +489,7 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID string) ([]Me
 }
 
 const listMessagesByType = `-- name: ListMessagesByType :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
 WHERE conversation_id = ? AND type = ?
 ORDER BY sequence_id ASC
 `
@@ -236,6 +519,7 @@ func (q *Queries) ListMessagesByType(ctx context.Context, arg ListMessagesByType
 			&i.CreatedAt,
 			&i.DisplayData,
 			&i.ExcludedFromContext,
+			&i.ParentMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -251,11 +535,27 @@ func (q *Queries) ListMessagesByType(ctx context.Context, arg ListMessagesByType
 }
 
 const listMessagesForContext = `-- name: ListMessagesForContext :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
-WHERE conversation_id = ? AND excluded_from_context = FALSE
-ORDER BY sequence_id ASC
+WITH RECURSIVE active_path(message_id, depth) AS (
+    SELECT active_message_id, 0 FROM conversations WHERE conversation_id = ?
+    UNION ALL
+    SELECT m.parent_message_id, p.depth + 1 FROM messages m
+    JOIN active_path p ON m.message_id = p.message_id
+    WHERE m.parent_message_id IS NOT NULL
+)
+SELECT messages.message_id, messages.conversation_id, messages.sequence_id, messages.type, messages.llm_data, messages.user_data, messages.usage_data, messages.created_at, messages.display_data, messages.excluded_from_context, messages.parent_message_id FROM messages
+JOIN active_path ON active_path.message_id = messages.message_id
+WHERE messages.excluded_from_context = FALSE
+  AND (messages.type = 'system' OR active_path.depth <= COALESCE((
+      SELECT MIN(p.depth) FROM active_path p
+      JOIN messages s ON s.message_id = p.message_id
+      WHERE json_extract(s.user_data, '$.compaction') IS TRUE
+  ), active_path.depth))
+ORDER BY active_path.depth DESC
 `
 
+// Messages on the active branch that are sent to the LLM: those not excluded
+// from context and, once the branch has been compacted, only the system
+// prompt ahead of its latest compaction summary.
 func (q *Queries) ListMessagesForContext(ctx context.Context, conversationID string) ([]Message, error) {
 	rows, err := q.db.QueryContext(ctx, listMessagesForContext, conversationID)
 	if err != nil {
@@ -276,6 +576,7 @@ func (q *Queries) ListMessagesForContext(ctx context.Context, conversationID str
 			&i.CreatedAt,
 			&i.DisplayData,
 			&i.ExcludedFromContext,
+			&i.ParentMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -291,7 +592,7 @@ func (q *Queries) ListMessagesForContext(ctx context.Context, conversationID str
 }
 
 const listMessagesPaginated = `-- name: ListMessagesPaginated :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
 WHERE conversation_id = ?
 ORDER BY sequence_id ASC
 LIMIT ? OFFSET ?
@@ -323,6 +624,7 @@ func (q *Queries) ListMessagesPaginated(ctx context.Context, arg ListMessagesPag
 			&i.CreatedAt,
 			&i.DisplayData,
 			&i.ExcludedFromContext,
+			&i.ParentMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -338,7 +640,7 @@ func (q *Queries) ListMessagesPaginated(ctx context.Context, arg ListMessagesPag
 }
 
 const listMessagesSince = `-- name: ListMessagesSince :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id FROM messages
 WHERE conversation_id = ? AND sequence_id > ?
 ORDER BY sequence_id ASC
 `
@@ -368,6 +670,7 @@ func (q *Queries) ListMessagesSince(ctx context.Context, arg ListMessagesSincePa
 			&i.CreatedAt,
 			&i.DisplayData,
 			&i.ExcludedFromContext,
+			&i.ParentMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -382,6 +685,20 @@ func (q *Queries) ListMessagesSince(ctx context.Context, arg ListMessagesSincePa
 	return items, nil
 }
 
+const updateMessageLLMData = `-- name: UpdateMessageLLMData :exec
+UPDATE messages SET llm_data = ? WHERE message_id = ?
+`
+
+type UpdateMessageLLMDataParams struct {
+	LlmData   *string `json:"llm_data"`
+	MessageID string  `json:"message_id"`
+}
+
+func (q *Queries) UpdateMessageLLMData(ctx context.Context, arg UpdateMessageLLMDataParams) error {
+	_, err := q.db.ExecContext(ctx, updateMessageLLMData, arg.LlmData, arg.MessageID)
+	return err
+}
+
 const updateMessageUserData = `-- name: UpdateMessageUserData :exec
 UPDATE messages SET user_data = ? WHERE message_id = ?
 `
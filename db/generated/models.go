@@ -8,16 +8,47 @@ import (
 	"time"
 )
 
+type ApiToken struct {
+	TokenID    string     `json:"token_id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	TokenHash  string     `json:"token_hash"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type Checkpoint struct {
+	ConversationID string    `json:"conversation_id"`
+	SequenceID     int64     `json:"sequence_id"`
+	Worktree       string    `json:"worktree"`
+	CommitHash     string    `json:"commit_hash"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 type Conversation struct {
-	ConversationID       string    `json:"conversation_id"`
-	Slug                 *string   `json:"slug"`
-	UserInitiated        bool      `json:"user_initiated"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
-	Cwd                  *string   `json:"cwd"`
-	Archived             bool      `json:"archived"`
-	ParentConversationID *string   `json:"parent_conversation_id"`
-	Model                *string   `json:"model"`
+	ConversationID           string    `json:"conversation_id"`
+	Slug                     *string   `json:"slug"`
+	UserInitiated            bool      `json:"user_initiated"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+	Cwd                      *string   `json:"cwd"`
+	Archived                 bool      `json:"archived"`
+	ParentConversationID     *string   `json:"parent_conversation_id"`
+	Model                    *string   `json:"model"`
+	ForkedFromConversationID *string   `json:"forked_from_conversation_id"`
+	ForkedFromSequenceID     *int64    `json:"forked_from_sequence_id"`
+	ActiveMessageID          *string   `json:"active_message_id"`
+	AllowedTools             *string   `json:"allowed_tools"`
+	UserID                   *string   `json:"user_id"`
+	Skills                   *string   `json:"skills"`
+}
+
+type ConversationShare struct {
+	ShareID        string    `json:"share_id"`
+	ConversationID string    `json:"conversation_id"`
+	CreatedBy      *string   `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type LlmRequest struct {
@@ -34,6 +65,7 @@ type LlmRequest struct {
 	CreatedAt       time.Time `json:"created_at"`
 	PrefixRequestID *int64    `json:"prefix_request_id"`
 	PrefixLength    *int64    `json:"prefix_length"`
+	Backend         *string   `json:"backend"`
 }
 
 type Message struct {
@@ -47,6 +79,7 @@ type Message struct {
 	CreatedAt           time.Time `json:"created_at"`
 	DisplayData         *string   `json:"display_data"`
 	ExcludedFromContext bool      `json:"excluded_from_context"`
+	ParentMessageID     *string   `json:"parent_message_id"`
 }
 
 type Migration struct {
@@ -66,6 +99,7 @@ type Model struct {
 	Tags         string    `json:"tags"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	UserID       *string   `json:"user_id"`
 }
 
 type NotificationChannel struct {
@@ -76,6 +110,7 @@ type NotificationChannel struct {
 	Config      string    `json:"config"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      *string   `json:"user_id"`
 }
 
 type Setting struct {
@@ -83,3 +118,18 @@ type Setting struct {
 	Value     string    `json:"value"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type User struct {
+	UserID       string    `json:"user_id"`
+	Username     string    `json:"username"`
+	PasswordHash *string   `json:"password_hash"`
+	IsAdmin      bool      `json:"is_admin"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type UserSession struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
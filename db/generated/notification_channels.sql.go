@@ -10,17 +10,18 @@ import (
 )
 
 const createNotificationChannel = `-- name: CreateNotificationChannel :one
-INSERT INTO notification_channels (channel_id, channel_type, display_name, enabled, config)
-VALUES (?, ?, ?, ?, ?)
-RETURNING channel_id, channel_type, display_name, enabled, config, created_at, updated_at
+INSERT INTO notification_channels (channel_id, channel_type, display_name, enabled, config, user_id)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING channel_id, channel_type, display_name, enabled, config, created_at, updated_at, user_id
 `
 
 type CreateNotificationChannelParams struct {
-	ChannelID   string `json:"channel_id"`
-	ChannelType string `json:"channel_type"`
-	DisplayName string `json:"display_name"`
-	Enabled     int64  `json:"enabled"`
-	Config      string `json:"config"`
+	ChannelID   string  `json:"channel_id"`
+	ChannelType string  `json:"channel_type"`
+	DisplayName string  `json:"display_name"`
+	Enabled     int64   `json:"enabled"`
+	Config      string  `json:"config"`
+	UserID      *string `json:"user_id"`
 }
 
 func (q *Queries) CreateNotificationChannel(ctx context.Context, arg CreateNotificationChannelParams) (NotificationChannel, error) {
@@ -30,6 +31,7 @@ func (q *Queries) CreateNotificationChannel(ctx context.Context, arg CreateNotif
 		arg.DisplayName,
 		arg.Enabled,
 		arg.Config,
+		arg.UserID,
 	)
 	var i NotificationChannel
 	err := row.Scan(
@@ -40,6 +42,7 @@ func (q *Queries) CreateNotificationChannel(ctx context.Context, arg CreateNotif
 		&i.Config,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.UserID,
 	)
 	return i, err
 }
@@ -54,7 +57,7 @@ func (q *Queries) DeleteNotificationChannel(ctx context.Context, channelID strin
 }
 
 const getEnabledNotificationChannels = `-- name: GetEnabledNotificationChannels :many
-SELECT channel_id, channel_type, display_name, enabled, config, created_at, updated_at FROM notification_channels WHERE enabled = 1 ORDER BY created_at ASC
+SELECT channel_id, channel_type, display_name, enabled, config, created_at, updated_at, user_id FROM notification_channels WHERE enabled = 1 ORDER BY created_at ASC
 `
 
 func (q *Queries) GetEnabledNotificationChannels(ctx context.Context) ([]NotificationChannel, error) {
@@ -74,6 +77,7 @@ func (q *Queries) GetEnabledNotificationChannels(ctx context.Context) ([]Notific
 			&i.Config,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.UserID,
 		); err != nil {
 			return nil, err
 		}
@@ -89,7 +93,7 @@ func (q *Queries) GetEnabledNotificationChannels(ctx context.Context) ([]Notific
 }
 
 const getNotificationChannel = `-- name: GetNotificationChannel :one
-SELECT channel_id, channel_type, display_name, enabled, config, created_at, updated_at FROM notification_channels WHERE channel_id = ?
+SELECT channel_id, channel_type, display_name, enabled, config, created_at, updated_at, user_id FROM notification_channels WHERE channel_id = ?
 `
 
 func (q *Queries) GetNotificationChannel(ctx context.Context, channelID string) (NotificationChannel, error) {
@@ -103,12 +107,13 @@ func (q *Queries) GetNotificationChannel(ctx context.Context, channelID string)
 		&i.Config,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.UserID,
 	)
 	return i, err
 }
 
 const getNotificationChannels = `-- name: GetNotificationChannels :many
-SELECT channel_id, channel_type, display_name, enabled, config, created_at, updated_at FROM notification_channels ORDER BY created_at ASC
+SELECT channel_id, channel_type, display_name, enabled, config, created_at, updated_at, user_id FROM notification_channels ORDER BY created_at ASC
 `
 
 func (q *Queries) GetNotificationChannels(ctx context.Context) ([]NotificationChannel, error) {
@@ -128,6 +133,7 @@ func (q *Queries) GetNotificationChannels(ctx context.Context) ([]NotificationCh
 			&i.Config,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.UserID,
 		); err != nil {
 			return nil, err
 		}
@@ -149,7 +155,7 @@ SET display_name = ?,
     config = ?,
     updated_at = CURRENT_TIMESTAMP
 WHERE channel_id = ?
-RETURNING channel_id, channel_type, display_name, enabled, config, created_at, updated_at
+RETURNING channel_id, channel_type, display_name, enabled, config, created_at, updated_at, user_id
 `
 
 type UpdateNotificationChannelParams struct {
@@ -175,6 +181,7 @@ func (q *Queries) UpdateNotificationChannel(ctx context.Context, arg UpdateNotif
 		&i.Config,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.UserID,
 	)
 	return i, err
 }
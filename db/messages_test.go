@@ -518,3 +518,294 @@ func TestMessageService_ListMessagesByConversationPaginated(t *testing.T) {
 		messageIDs[msg.MessageID] = true
 	}
 }
+
+func TestMessageService_Branches(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	create := func(text string) *generated.Message {
+		t.Helper()
+		msg, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           MessageTypeUser,
+			UserData:       map[string]string{"text": text},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		return msg
+	}
+	texts := func(messages []generated.Message) string {
+		var parts []string
+		for _, m := range messages {
+			var data map[string]string
+			json.Unmarshal([]byte(*m.UserData), &data)
+			parts = append(parts, data["text"])
+		}
+		return strings.Join(parts, ",")
+	}
+
+	first := create("first")
+	original := create("original")
+	create("reply")
+	if original.ParentMessageID == nil || *original.ParentMessageID != first.MessageID {
+		t.Fatalf("Expected parent %s, got %v", first.MessageID, original.ParentMessageID)
+	}
+
+	// Edit "original": rewind to its parent and append a sibling.
+	if err := db.SetActiveMessage(ctx, conv.ConversationID, &first.MessageID); err != nil {
+		t.Fatalf("SetActiveMessage() error = %v", err)
+	}
+	edited := create("edited")
+
+	active, err := db.ListActiveMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListActiveMessages() error = %v", err)
+	}
+	if got := texts(active); got != "first,edited" {
+		t.Errorf("Expected active branch first,edited, got %s", got)
+	}
+	all, err := db.ListMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListMessages() error = %v", err)
+	}
+	if len(all) != 4 {
+		t.Errorf("Expected the old tail to be kept, got %d messages", len(all))
+	}
+
+	branching, err := db.ListBranchingMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListBranchingMessages() error = %v", err)
+	}
+	if len(branching) != 2 || branching[0].MessageID != original.MessageID || branching[1].MessageID != edited.MessageID {
+		t.Errorf("Expected original and edited as siblings, got %+v", branching)
+	}
+
+	// Switching back to the original restores its whole tail.
+	if err := db.SwitchBranch(ctx, conv.ConversationID, original.MessageID); err != nil {
+		t.Fatalf("SwitchBranch() error = %v", err)
+	}
+	active, err = db.ListActiveMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListActiveMessages() error = %v", err)
+	}
+	if got := texts(active); got != "first,original,reply" {
+		t.Errorf("Expected active branch first,original,reply, got %s", got)
+	}
+}
+
+func TestMessageService_CompactMessages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation"), true, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	create := func(text string) *generated.Message {
+		t.Helper()
+		msg, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           MessageTypeUser,
+			UserData:       map[string]string{"text": text},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		return msg
+	}
+	texts := func(messages []generated.Message) string {
+		var parts []string
+		for _, m := range messages {
+			var data map[string]string
+			json.Unmarshal([]byte(*m.UserData), &data)
+			parts = append(parts, data["text"])
+		}
+		return strings.Join(parts, ",")
+	}
+
+	old1 := create("old1")
+	old2 := create("old2")
+	current := create("current")
+	reply := create("reply")
+
+	summary, err := db.CompactMessages(ctx, current.MessageID, CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           MessageTypeUser,
+		UserData:       map[string]any{"text": "summary", "compaction": true},
+	})
+	if err != nil {
+		t.Fatalf("CompactMessages() error = %v", err)
+	}
+	if summary.SequenceID != 5 || *summary.ParentMessageID != old2.MessageID {
+		t.Errorf("Expected the summary to get the next sequence_id and follow old2, got %d after %s", summary.SequenceID, *summary.ParentMessageID)
+	}
+
+	// The summary starts a new branch, followed by copies of the kept messages.
+	active, err := db.ListActiveMessages(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListActiveMessages() error = %v", err)
+	}
+	if got := texts(active); got != "old1,old2,summary,current,reply" {
+		t.Errorf("Expected active branch old1,old2,summary,current,reply, got %s", got)
+	}
+	if active[3].MessageID == current.MessageID || active[3].SequenceID != 6 || !active[3].CreatedAt.Equal(current.CreatedAt) {
+		t.Errorf("Expected a copy of current with its timestamp, got %+v", active[3])
+	}
+	forContext, err := db.ListMessagesForContext(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListMessagesForContext() error = %v", err)
+	}
+	if got := texts(forContext); got != "summary,current,reply" {
+		t.Errorf("Expected context summary,current,reply, got %s", got)
+	}
+
+	// The original messages are untouched, so the branch they are on still
+	// sends all of them.
+	parentOf := func(m *generated.Message) string {
+		if m.ParentMessageID == nil {
+			return ""
+		}
+		return *m.ParentMessageID
+	}
+	for _, m := range []*generated.Message{old1, old2, current, reply} {
+		stored, err := db.GetMessageByID(ctx, m.MessageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.ExcludedFromContext || parentOf(stored) != parentOf(m) {
+			t.Errorf("Expected %s to be unchanged, got %+v", m.MessageID, stored)
+		}
+	}
+	if err := db.SwitchBranch(ctx, conv.ConversationID, current.MessageID); err != nil {
+		t.Fatalf("SwitchBranch() error = %v", err)
+	}
+	forContext, err = db.ListMessagesForContext(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("ListMessagesForContext() error = %v", err)
+	}
+	if got := texts(forContext); got != "old1,old2,current,reply" {
+		t.Errorf("Expected the original branch's full context, got %s", got)
+	}
+
+	if _, err := db.CompactMessages(ctx, summary.MessageID, CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           MessageTypeUser,
+	}); err == nil {
+		t.Error("Expected an error compacting before a message that is not on the active branch")
+	}
+}
+
+func TestMessageService_Search(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	textMessage := func(conversationID string, msgType MessageType, texts ...string) {
+		t.Helper()
+		var content []map[string]any
+		for _, text := range texts {
+			content = append(content, map[string]any{"Type": 2, "Text": text})
+		}
+		_, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conversationID,
+			Type:           msgType,
+			LLMData:        map[string]any{"Role": 0, "Content": content},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := db.CreateConversation(ctx, stringPtr("first"), true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.CreateConversation(ctx, stringPtr("second"), true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	textMessage(first.ConversationID, MessageTypeSystem, "You are a helpful deployment assistant")
+	textMessage(first.ConversationID, MessageTypeUser, "How do I configure the deployment pipeline?")
+	textMessage(first.ConversationID, MessageTypeAgent, "Edit deploy.yaml,", "then rerun the pipeline.")
+	textMessage(second.ConversationID, MessageTypeUser, "Deployment failed: deployment deployment")
+
+	results, err := db.SearchMessages(ctx, nil, "deployment", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 hits (system prompts are not indexed), got %+v", results)
+	}
+	// The message that mentions the term most ranks first.
+	if *results[0].Slug != "second" || results[0].Score < results[1].Score {
+		t.Errorf("expected second to rank first, got %+v", results)
+	}
+	if !strings.Contains(results[1].Snippet, "\x02deployment\x03") {
+		t.Errorf("expected a highlighted snippet, got %q", results[1].Snippet)
+	}
+
+	// Words are ANDed, stemmed, and the last one matches as a prefix.
+	results, err = db.SearchMessages(ctx, nil, "rerunning deploy.yam", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Type != string(MessageTypeAgent) || results[0].SequenceID != 3 {
+		t.Errorf("expected the agent message, got %+v", results)
+	}
+
+	// Query syntax in the input is treated as text.
+	if _, err := db.SearchMessages(ctx, nil, `"unbalanced AND (`, 10, 0); err != nil {
+		t.Errorf("expected punctuation to be quoted, got %v", err)
+	}
+
+	// Archived and deleted conversations drop out of the results.
+	if _, err := db.ArchiveConversation(ctx, second.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteConversation(ctx, first.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	results, err = db.SearchMessages(ctx, nil, "deployment", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no hits, got %+v", results)
+	}
+	var indexed int
+	if err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		return rx.QueryRow("SELECT COUNT(*) FROM messages_fts").Scan(&indexed)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 1 {
+		t.Errorf("expected only the archived conversation's message to remain indexed, got %d", indexed)
+	}
+
+	// Hits still point at the right message after its rowid changes, as it
+	// may in a VACUUM or a table rebuild.
+	if err := db.pool.Exec(ctx, "UPDATE messages SET rowid = rowid + 100"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UnarchiveConversation(ctx, second.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	results, err = db.SearchMessages(ctx, nil, "deployment", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ConversationID != second.ConversationID || !strings.Contains(results[0].Snippet, "failed") {
+		t.Errorf("expected the second conversation's message, got %+v", results)
+	}
+}
@@ -0,0 +1,22 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (token_id, user_id, name, scope, token_hash)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetAPIToken :one
+SELECT * FROM api_tokens WHERE token_id = ?;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash = ?;
+
+-- name: ListAPITokens :many
+-- A NULL user_id lists every user's tokens.
+SELECT * FROM api_tokens
+WHERE user_id IS COALESCE(sqlc.narg(user_id), user_id)
+ORDER BY created_at DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE token_id = ?;
+
+-- name: DeleteAPIToken :exec
+DELETE FROM api_tokens WHERE token_id = ?;
@@ -0,0 +1,18 @@
+-- name: CreateCheckpoint :one
+INSERT INTO checkpoints (conversation_id, sequence_id, worktree, commit_hash)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(conversation_id, sequence_id) DO UPDATE SET
+    worktree = excluded.worktree,
+    commit_hash = excluded.commit_hash
+RETURNING *;
+
+-- name: GetCheckpointAt :one
+-- The checkpoint for the turn containing sequence_id: the latest one taken at or before it.
+SELECT * FROM checkpoints
+WHERE conversation_id = ? AND sequence_id <= ?
+ORDER BY sequence_id DESC
+LIMIT 1;
+
+-- name: ListCheckpointWorktrees :many
+SELECT DISTINCT worktree FROM checkpoints
+WHERE conversation_id = ?;
@@ -1,6 +1,6 @@
 -- name: CreateConversation :one
-INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model)
-VALUES (?, ?, ?, ?, ?)
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model, user_id)
+VALUES (?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetConversation :one
@@ -12,22 +12,26 @@ SELECT * FROM conversations
 WHERE slug = ?;
 
 -- name: ListConversations :many
+-- A NULL user_id lists every user's conversations.
 SELECT * FROM conversations
 WHERE archived = FALSE AND parent_conversation_id IS NULL
+  AND user_id IS COALESCE(sqlc.narg(user_id), user_id)
 ORDER BY updated_at DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: ListArchivedConversations :many
 SELECT * FROM conversations
 WHERE archived = TRUE
+  AND user_id IS COALESCE(sqlc.narg(user_id), user_id)
 ORDER BY updated_at DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: SearchConversations :many
 SELECT * FROM conversations
-WHERE slug LIKE '%' || ? || '%' AND archived = FALSE AND parent_conversation_id IS NULL
+WHERE slug LIKE '%' || sqlc.arg(query) || '%' AND archived = FALSE AND parent_conversation_id IS NULL
+  AND user_id IS COALESCE(sqlc.narg(user_id), user_id)
 ORDER BY updated_at DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: SearchConversationsWithMessages :many
 -- Search conversations by slug OR message content (user messages and agent responses, not system prompts)
@@ -35,19 +39,21 @@ LIMIT ? OFFSET ?;
 SELECT DISTINCT c.* FROM conversations c
 LEFT JOIN messages m ON c.conversation_id = m.conversation_id AND m.type IN ('user', 'agent')
 WHERE c.archived = FALSE
+  AND c.user_id IS COALESCE(sqlc.narg(user_id), c.user_id)
   AND (
-    c.slug LIKE '%' || ? || '%'
-    OR json_extract(m.user_data, '$.text') LIKE '%' || ? || '%'
-    OR m.llm_data LIKE '%' || ? || '%'
+    c.slug LIKE '%' || sqlc.arg(query) || '%'
+    OR json_extract(m.user_data, '$.text') LIKE '%' || sqlc.arg(query) || '%'
+    OR m.llm_data LIKE '%' || sqlc.arg(query) || '%'
   )
 ORDER BY c.updated_at DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: SearchArchivedConversations :many
 SELECT * FROM conversations
-WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
+WHERE slug LIKE '%' || sqlc.arg(query) || '%' AND archived = TRUE
+  AND user_id IS COALESCE(sqlc.narg(user_id), user_id)
 ORDER BY updated_at DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: UpdateConversationSlug :one
 UPDATE conversations
@@ -90,8 +96,13 @@ RETURNING *;
 
 
 -- name: CreateSubagentConversation :one
-INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, parent_conversation_id)
-VALUES (?, ?, FALSE, ?, ?)
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, parent_conversation_id, user_id)
+VALUES (?, ?, FALSE, ?, ?, ?)
+RETURNING *;
+
+-- name: CreateForkConversation :one
+INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model, forked_from_conversation_id, forked_from_sequence_id, user_id)
+VALUES (?, ?, TRUE, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetSubagents :many
@@ -103,7 +114,45 @@ ORDER BY created_at ASC;
 SELECT * FROM conversations
 WHERE slug = ? AND parent_conversation_id = ?;
 
+-- name: SetActiveMessage :exec
+UPDATE conversations
+SET active_message_id = ?
+WHERE conversation_id = ?;
+
 -- name: UpdateConversationModel :exec
 UPDATE conversations
 SET model = ?
 WHERE conversation_id = ? AND model IS NULL;
+
+-- name: UpdateConversationAllowedTools :exec
+UPDATE conversations
+SET allowed_tools = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationSkills :exec
+UPDATE conversations
+SET skills = ?
+WHERE conversation_id = ?;
+
+-- name: GetConversationDepth :one
+-- How many parent_conversation_id links separate a conversation from its root.
+WITH RECURSIVE ancestors(conversation_id, parent_conversation_id, depth) AS (
+    SELECT c.conversation_id, c.parent_conversation_id, 0 FROM conversations c WHERE c.conversation_id = ?
+    UNION ALL
+    SELECT c.conversation_id, c.parent_conversation_id, a.depth + 1
+    FROM conversations c
+    JOIN ancestors a ON c.conversation_id = a.parent_conversation_id
+)
+SELECT CAST(MAX(depth) AS INTEGER) AS depth FROM ancestors;
+
+-- name: ImportConversation :one
+-- Recreates an exported conversation, keeping its timestamps.
+INSERT INTO conversations (conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, allowed_tools, user_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: AdoptUnownedConversations :exec
+-- Gives conversations that predate users to the first user.
+UPDATE conversations
+SET user_id = ?
+WHERE user_id IS NULL;
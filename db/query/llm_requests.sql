@@ -10,8 +10,9 @@ INSERT INTO llm_requests (
     error,
     duration_ms,
     prefix_request_id,
-    prefix_length
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    prefix_length,
+    backend
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetLastRequestForConversation :one
@@ -38,7 +39,8 @@ SELECT
     r.duration_ms,
     r.created_at,
     r.prefix_request_id,
-    r.prefix_length
+    r.prefix_length,
+    r.backend
 FROM llm_requests r
 LEFT JOIN models m ON r.model = m.model_id
 ORDER BY r.id DESC
@@ -1,6 +1,6 @@
 -- name: CreateMessage :one
-INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, display_data, excluded_from_context)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, display_data, excluded_from_context, parent_message_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetNextSequenceID :one
@@ -8,6 +8,11 @@ SELECT COALESCE(MAX(sequence_id), 0) + 1
 FROM messages 
 WHERE conversation_id = ?;
 
+-- name: ImportMessage :exec
+-- Recreates an exported message, keeping its timestamp.
+INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context, parent_message_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
 -- name: GetMessage :one
 SELECT * FROM messages
 WHERE message_id = ?;
@@ -18,9 +23,76 @@ WHERE conversation_id = ?
 ORDER BY sequence_id ASC;
 
 -- name: ListMessagesForContext :many
+-- Messages on the active branch that are sent to the LLM: those not excluded
+-- from context and, once the branch has been compacted, only the system
+-- prompt ahead of its latest compaction summary.
+WITH RECURSIVE active_path(message_id, depth) AS (
+    SELECT active_message_id, 0 FROM conversations WHERE conversation_id = ?
+    UNION ALL
+    SELECT m.parent_message_id, p.depth + 1 FROM messages m
+    JOIN active_path p ON m.message_id = p.message_id
+    WHERE m.parent_message_id IS NOT NULL
+)
+SELECT messages.* FROM messages
+JOIN active_path ON active_path.message_id = messages.message_id
+WHERE messages.excluded_from_context = FALSE
+  AND (messages.type = 'system' OR active_path.depth <= COALESCE((
+      SELECT MIN(p.depth) FROM active_path p
+      JOIN messages s ON s.message_id = p.message_id
+      WHERE json_extract(s.user_data, '$.compaction') IS TRUE
+  ), active_path.depth))
+ORDER BY active_path.depth DESC;
+
+-- name: ListActiveMessages :many
+-- Messages on the active branch: the parent chain from the conversation's
+-- active_message_id back to the first message, in chain order.
+WITH RECURSIVE active_path(message_id, depth) AS (
+    SELECT active_message_id, 0 FROM conversations WHERE conversation_id = ?
+    UNION ALL
+    SELECT m.parent_message_id, p.depth + 1 FROM messages m
+    JOIN active_path p ON m.message_id = p.message_id
+    WHERE m.parent_message_id IS NOT NULL
+)
+SELECT messages.* FROM messages
+JOIN active_path ON active_path.message_id = messages.message_id
+ORDER BY active_path.depth DESC;
+
+-- name: ListActiveMessagesSince :many
+WITH RECURSIVE active_path(message_id, depth) AS (
+    SELECT active_message_id, 0 FROM conversations WHERE conversation_id = ?
+    UNION ALL
+    SELECT m.parent_message_id, p.depth + 1 FROM messages m
+    JOIN active_path p ON m.message_id = p.message_id
+    WHERE m.parent_message_id IS NOT NULL
+)
+SELECT messages.* FROM messages
+JOIN active_path ON active_path.message_id = messages.message_id
+WHERE messages.sequence_id > ?
+ORDER BY active_path.depth DESC;
+
+-- name: ListBranchingMessages :many
+-- Messages that have siblings, i.e. the points where the conversation was edited.
+SELECT m.message_id, m.parent_message_id, m.sequence_id FROM messages m
+WHERE m.conversation_id = ? AND m.parent_message_id IS NOT NULL AND EXISTS (
+    SELECT 1 FROM messages s
+    WHERE s.parent_message_id = m.parent_message_id AND s.message_id != m.message_id
+)
+ORDER BY m.sequence_id ASC;
+
+-- name: GetLatestDescendant :one
+-- The newest leaf in the subtree under (and including) the given message,
+-- which ends the most recent branch through that message.
+WITH RECURSIVE subtree(message_id) AS (
+    SELECT message_id FROM messages WHERE messages.message_id = ?
+    UNION ALL
+    SELECT m.message_id FROM messages m
+    JOIN subtree s ON m.parent_message_id = s.message_id
+)
 SELECT * FROM messages
-WHERE conversation_id = ? AND excluded_from_context = FALSE
-ORDER BY sequence_id ASC;
+WHERE message_id IN (SELECT message_id FROM subtree)
+    AND NOT EXISTS (SELECT 1 FROM messages c WHERE c.parent_message_id = messages.message_id)
+ORDER BY sequence_id DESC
+LIMIT 1;
 
 -- name: ListMessagesPaginated :many
 SELECT * FROM messages
@@ -62,3 +134,37 @@ ORDER BY sequence_id ASC;
 
 -- name: UpdateMessageUserData :exec
 UPDATE messages SET user_data = ? WHERE message_id = ?;
+
+-- name: UpdateMessageLLMData :exec
+UPDATE messages SET llm_data = ? WHERE message_id = ?;
+
+-- name: GetConversationTreeCost :one
+-- Total cost of a conversation and all of its subagents, however deeply nested.
+WITH RECURSIVE tree(conversation_id) AS (
+    SELECT conversations.conversation_id FROM conversations WHERE conversations.conversation_id = ?
+    UNION ALL
+    SELECT c.conversation_id FROM conversations c
+    JOIN tree t ON c.parent_conversation_id = t.conversation_id
+)
+SELECT CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages m
+JOIN tree t ON m.conversation_id = t.conversation_id;
+
+-- name: GetConversationUsageTotals :one
+-- Token and cost totals for a single conversation; input tokens include cache reads and writes.
+SELECT
+    CAST(COALESCE(SUM(
+        COALESCE(json_extract(usage_data, '$.input_tokens'), 0) +
+        COALESCE(json_extract(usage_data, '$.cache_creation_input_tokens'), 0) +
+        COALESCE(json_extract(usage_data, '$.cache_read_input_tokens'), 0)
+    ), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages
+WHERE conversation_id = ? AND usage_data IS NOT NULL;
+
+-- name: GetCostSinceStartOfDay :one
+-- Total cost across all conversations since local midnight.
+SELECT CAST(COALESCE(SUM(json_extract(usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages
+WHERE created_at >= datetime('now', 'localtime', 'start of day', 'utc');
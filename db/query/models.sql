@@ -5,8 +5,8 @@ SELECT * FROM models ORDER BY created_at ASC;
 SELECT * FROM models WHERE model_id = ?;
 
 -- name: CreateModel :one
-INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO models (model_id, display_name, provider_type, endpoint, api_key, model_name, max_tokens, tags, user_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateModel :one
@@ -8,8 +8,8 @@ SELECT * FROM notification_channels WHERE channel_id = ?;
 SELECT * FROM notification_channels WHERE enabled = 1 ORDER BY created_at ASC;
 
 -- name: CreateNotificationChannel :one
-INSERT INTO notification_channels (channel_id, channel_type, display_name, enabled, config)
-VALUES (?, ?, ?, ?, ?)
+INSERT INTO notification_channels (channel_id, channel_type, display_name, enabled, config, user_id)
+VALUES (?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateNotificationChannel :one
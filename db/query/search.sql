@@ -0,0 +1,19 @@
+-- name: SearchMessages :many
+-- Full-text search over user and agent messages in unarchived conversations, best match first.
+-- A NULL user_id searches every user's conversations.
+-- The snippet marks each match with char(2) before it and char(3) after it.
+SELECT
+    m.conversation_id,
+    c.slug,
+    m.sequence_id,
+    m.type,
+    CAST(snippet(messages_fts, 0, char(2), char(3), '…', 16) AS TEXT) AS snippet,
+    CAST(-messages_fts.rank AS REAL) AS score
+FROM messages_fts
+JOIN message_search s ON s.id = messages_fts.rowid
+JOIN messages m ON m.message_id = s.message_id
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE messages_fts MATCH sqlc.arg(query) AND c.archived = FALSE
+  AND c.user_id IS COALESCE(sqlc.narg(user_id), c.user_id)
+ORDER BY messages_fts.rank
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
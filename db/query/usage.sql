@@ -0,0 +1,35 @@
+-- name: ListUsageByConversationDay :many
+-- Token and cost totals for each conversation, UTC day and model, over messages created in [since, until).
+-- The model is the one that served each request, or the conversation's for messages recorded before that was kept.
+-- A NULL user_id covers every user's conversations.
+SELECT
+    m.conversation_id,
+    CAST(COALESCE(NULLIF(json_extract(m.usage_data, '$.model_id'), ''), c.model, '') AS TEXT) AS model,
+    CAST(date(m.created_at) AS TEXT) AS day,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.input_tokens')), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cache_creation_input_tokens')), 0) AS INTEGER) AS cache_creation_input_tokens,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cache_read_input_tokens')), 0) AS INTEGER) AS cache_read_input_tokens,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.output_tokens')), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages m
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE m.usage_data IS NOT NULL
+  AND m.created_at >= sqlc.arg(since) AND m.created_at < sqlc.arg(until)
+  AND c.user_id IS COALESCE(sqlc.narg(user_id), c.user_id)
+GROUP BY m.conversation_id, day, json_extract(m.usage_data, '$.model_id')
+ORDER BY day, m.conversation_id, model;
+
+-- name: ListLLMRequestLatencies :many
+-- Durations of the successful LLM requests made in [since, until).
+-- A NULL user_id covers every request, including those made outside a conversation.
+SELECT
+    COALESCE(r.conversation_id, '') AS conversation_id,
+    r.model,
+    CAST(date(r.created_at) AS TEXT) AS day,
+    CAST(r.duration_ms AS INTEGER) AS duration_ms
+FROM llm_requests r
+LEFT JOIN conversations c ON c.conversation_id = r.conversation_id
+WHERE r.error IS NULL AND r.duration_ms IS NOT NULL
+  AND r.created_at >= sqlc.arg(since) AND r.created_at < sqlc.arg(until)
+  AND c.user_id IS COALESCE(sqlc.narg(user_id), c.user_id)
+ORDER BY r.id;
@@ -0,0 +1,52 @@
+-- name: CreateUser :one
+INSERT INTO users (user_id, username, password_hash, is_admin)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE user_id = ?;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users WHERE username = ?;
+
+-- name: ListUsers :many
+SELECT * FROM users ORDER BY username ASC;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = ? WHERE user_id = ?;
+
+-- name: UpdateUserAdmin :exec
+UPDATE users SET is_admin = ? WHERE user_id = ?;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE user_id = ?;
+
+-- name: CreateUserSession :exec
+INSERT INTO user_sessions (token_hash, user_id, expires_at)
+VALUES (?, ?, ?);
+
+-- name: GetUserSession :one
+SELECT * FROM user_sessions WHERE token_hash = ?;
+
+-- name: DeleteUserSession :exec
+DELETE FROM user_sessions WHERE token_hash = ?;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM user_sessions WHERE user_id = ?;
+
+-- name: CreateConversationShare :one
+INSERT INTO conversation_shares (share_id, conversation_id, created_by)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: GetConversationShare :one
+SELECT * FROM conversation_shares WHERE share_id = ?;
+
+-- name: ListConversationShares :many
+SELECT * FROM conversation_shares WHERE conversation_id = ? ORDER BY created_at ASC;
+
+-- name: DeleteConversationShares :exec
+DELETE FROM conversation_shares WHERE conversation_id = ?;
@@ -0,0 +1,8 @@
+-- Record where a forked conversation came from.
+-- A fork copies the source conversation's messages up to and including
+-- forked_from_sequence_id; the source may later be deleted, so the link is
+-- cleared rather than blocking the delete.
+ALTER TABLE conversations ADD COLUMN forked_from_conversation_id TEXT REFERENCES conversations(conversation_id) ON DELETE SET NULL;
+ALTER TABLE conversations ADD COLUMN forked_from_sequence_id INTEGER;
+
+CREATE INDEX idx_conversations_forked_from ON conversations(forked_from_conversation_id) WHERE forked_from_conversation_id IS NOT NULL;
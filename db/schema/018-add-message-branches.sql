@@ -0,0 +1,27 @@
+-- Conversations become trees of messages so that an earlier user message can be
+-- edited and regenerated without losing the original tail.
+--
+-- parent_message_id points at the previous message on the same branch. Editing a
+-- user message adds a sibling (same parent), leaving the old tail as an alternate
+-- branch. active_message_id is the last message of the branch currently shown and
+-- sent to the LLM; following parents from it back to the root gives that branch.
+
+ALTER TABLE messages ADD COLUMN parent_message_id TEXT;
+ALTER TABLE conversations ADD COLUMN active_message_id TEXT;
+
+-- Existing conversations are linear: each message's parent is the one before it.
+UPDATE messages SET parent_message_id = (
+    SELECT p.message_id FROM messages p
+    WHERE p.conversation_id = messages.conversation_id AND p.sequence_id < messages.sequence_id
+    ORDER BY p.sequence_id DESC
+    LIMIT 1
+);
+
+UPDATE conversations SET active_message_id = (
+    SELECT m.message_id FROM messages m
+    WHERE m.conversation_id = conversations.conversation_id
+    ORDER BY m.sequence_id DESC
+    LIMIT 1
+);
+
+CREATE INDEX idx_messages_parent_id ON messages(parent_message_id) WHERE parent_message_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Checkpoints are snapshots of a conversation's git working tree, taken at the
+-- start of each user turn so that files can be rolled back if the agent makes a
+-- mess. The snapshot itself is a commit kept alive by a ref under refs/shelley/;
+-- this table records where it is.
+
+CREATE TABLE checkpoints (
+    conversation_id TEXT NOT NULL REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    sequence_id INTEGER NOT NULL,
+    worktree TEXT NOT NULL,
+    commit_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, sequence_id)
+);
@@ -0,0 +1,2 @@
+-- The daily budget sums cost across all conversations since midnight.
+CREATE INDEX idx_messages_created_at ON messages(created_at);
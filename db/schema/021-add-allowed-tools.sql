@@ -0,0 +1,4 @@
+-- Restrict the tools available to a conversation. allowed_tools is a JSON array
+-- of tool names; NULL means every tool is available. It is set when a subagent
+-- is spawned with a tool allow-list.
+ALTER TABLE conversations ADD COLUMN allowed_tools TEXT;
@@ -0,0 +1,57 @@
+-- Full-text search over message content. message_search holds the text of each
+-- user and agent message under a stable integer id, messages_fts indexes it,
+-- and triggers keep both in sync. System prompts and tool results are not
+-- indexed. Messages are keyed by message_id rather than their implicit rowid,
+-- which VACUUM may renumber.
+
+-- message_search_text pulls the text parts out of llm_data.
+CREATE VIEW message_search_text AS
+SELECT m.message_id, (
+    SELECT group_concat(json_extract(part.value, '$.Text'), char(10))
+    FROM json_each(m.llm_data, '$.Content') AS part
+    WHERE json_extract(part.value, '$.Text') <> ''
+) AS text
+FROM messages m
+WHERE m.type IN ('user', 'agent') AND json_valid(m.llm_data);
+
+CREATE TABLE message_search (
+    id INTEGER PRIMARY KEY,
+    message_id TEXT NOT NULL UNIQUE,
+    text TEXT NOT NULL
+);
+
+CREATE VIRTUAL TABLE messages_fts USING fts5(
+    text,
+    content = 'message_search',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER message_search_insert AFTER INSERT ON message_search BEGIN
+    INSERT INTO messages_fts(rowid, text) VALUES (NEW.id, NEW.text);
+END;
+
+CREATE TRIGGER message_search_delete AFTER DELETE ON message_search BEGIN
+    INSERT INTO messages_fts(messages_fts, rowid, text) VALUES ('delete', OLD.id, OLD.text);
+END;
+
+CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO message_search(message_id, text)
+    SELECT message_id, text FROM message_search_text
+    WHERE message_id = NEW.message_id AND text IS NOT NULL;
+END;
+
+CREATE TRIGGER messages_fts_update AFTER UPDATE OF type, llm_data ON messages BEGIN
+    DELETE FROM message_search WHERE message_id = OLD.message_id;
+    INSERT INTO message_search(message_id, text)
+    SELECT message_id, text FROM message_search_text
+    WHERE message_id = NEW.message_id AND text IS NOT NULL;
+END;
+
+CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+    DELETE FROM message_search WHERE message_id = OLD.message_id;
+END;
+
+-- Backfill existing messages.
+INSERT INTO message_search(message_id, text)
+SELECT message_id, text FROM message_search_text WHERE text IS NOT NULL;
@@ -0,0 +1,40 @@
+-- Users and per-user ownership.
+-- When authentication is enabled, each request is mapped to a user: by the
+-- value of a header set by an authenticating proxy, or by a session created
+-- with the built-in password login.
+
+CREATE TABLE users (
+    user_id TEXT PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE, -- the header value, or the login name
+    password_hash TEXT, -- NULL for users who cannot log in with a password
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE, -- admins can see and manage every user's conversations
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_sessions (
+    token_hash TEXT PRIMARY KEY, -- hex SHA-256 of the session cookie
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
+
+-- A NULL owner means the row predates users. Unowned conversations are visible
+-- only to admins; unowned models and notification channels are shared.
+ALTER TABLE conversations ADD COLUMN user_id TEXT REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE models ADD COLUMN user_id TEXT REFERENCES users(user_id) ON DELETE CASCADE;
+ALTER TABLE notification_channels ADD COLUMN user_id TEXT REFERENCES users(user_id) ON DELETE CASCADE;
+
+CREATE INDEX idx_conversations_user_id ON conversations(user_id, updated_at DESC);
+
+-- Read-only links to a conversation. Anyone signed in who has the link can
+-- view the conversation.
+CREATE TABLE conversation_shares (
+    share_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    created_by TEXT REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_conversation_shares_conversation_id ON conversation_shares(conversation_id);
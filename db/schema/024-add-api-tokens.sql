@@ -0,0 +1,15 @@
+-- API tokens for programmatic access to the HTTP API. A token acts as the
+-- user that owns it, limited to its scope. Only a hash of the token is kept;
+-- the token itself is shown once, when it is created.
+
+CREATE TABLE api_tokens (
+    token_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL, -- what the token is for, e.g. "ci"
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'chat', 'admin')),
+    token_hash TEXT NOT NULL UNIQUE, -- hex SHA-256 of the token
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
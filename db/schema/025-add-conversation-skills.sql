@@ -0,0 +1,3 @@
+-- Record the skills the agent loaded in a conversation. skills is a JSON array
+-- of skill names in the order they were first loaded; NULL means none were.
+ALTER TABLE conversations ADD COLUMN skills TEXT;
@@ -0,0 +1,3 @@
+-- Record which backend served an LLM request: for a model group, the member
+-- model and endpoint that answered. NULL for models that aren't in a group.
+ALTER TABLE llm_requests ADD COLUMN backend TEXT;
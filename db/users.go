@@ -0,0 +1,348 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// ErrUserExists is returned by CreateUser when the username is taken.
+var ErrUserExists = errors.New("user already exists")
+
+// CreateUser creates a user. The first user created is an admin and takes
+// ownership of the conversations that were created before there were users,
+// so that turning on authentication does not hide an existing history.
+func (db *DB) CreateUser(ctx context.Context, username string, passwordHash *string, isAdmin bool) (*generated.User, error) {
+	var user generated.User
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		user, err = createUser(ctx, q, username, passwordHash, isAdmin)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetOrCreateUser returns the user with the given username, creating one
+// without a password if there is none. It is used for users identified by a
+// header set by an authenticating proxy.
+func (db *DB) GetOrCreateUser(ctx context.Context, username string) (*generated.User, error) {
+	user, err := db.GetUserByUsername(ctx, username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return user, err
+	}
+	var created generated.User
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		// Another request may have created the user since we looked.
+		existing, err := q.GetUserByUsername(ctx, username)
+		if err == nil {
+			created = existing
+			return nil
+		}
+		created, err = createUser(ctx, q, username, nil, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func createUser(ctx context.Context, q *generated.Queries, username string, passwordHash *string, isAdmin bool) (generated.User, error) {
+	if username == "" {
+		return generated.User{}, fmt.Errorf("username cannot be empty")
+	}
+	if _, err := q.GetUserByUsername(ctx, username); err == nil {
+		return generated.User{}, fmt.Errorf("%w: %s", ErrUserExists, username)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return generated.User{}, err
+	}
+	count, err := q.CountUsers(ctx)
+	if err != nil {
+		return generated.User{}, err
+	}
+	user, err := q.CreateUser(ctx, generated.CreateUserParams{
+		UserID:       "u" + rand.Text()[:10],
+		Username:     username,
+		PasswordHash: passwordHash,
+		IsAdmin:      isAdmin || count == 0,
+	})
+	if err != nil {
+		return generated.User{}, err
+	}
+	if count == 0 {
+		if err := q.AdoptUnownedConversations(ctx, &user.UserID); err != nil {
+			return generated.User{}, fmt.Errorf("failed to adopt existing conversations: %w", err)
+		}
+	}
+	return user, nil
+}
+
+// GetUser returns a user by ID. If there is none, the error wraps sql.ErrNoRows.
+func (db *DB) GetUser(ctx context.Context, userID string) (*generated.User, error) {
+	var user generated.User
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		user, err = q.GetUser(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByUsername returns a user by username. If there is none, the error
+// wraps sql.ErrNoRows.
+func (db *DB) GetUserByUsername(ctx context.Context, username string) (*generated.User, error) {
+	var user generated.User
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		user, err = q.GetUserByUsername(ctx, username)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns all users ordered by username.
+func (db *DB) ListUsers(ctx context.Context) ([]generated.User, error) {
+	var users []generated.User
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		users, err = q.ListUsers(ctx)
+		return err
+	})
+	return users, err
+}
+
+// SetUserPassword sets or, with a nil hash, clears a user's password hash.
+// The user's sessions are ended.
+func (db *DB) SetUserPassword(ctx context.Context, userID string, passwordHash *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.UpdateUserPassword(ctx, generated.UpdateUserPasswordParams{
+			PasswordHash: passwordHash,
+			UserID:       userID,
+		}); err != nil {
+			return err
+		}
+		return q.DeleteUserSessions(ctx, userID)
+	})
+}
+
+// SetUserAdmin grants or revokes a user's admin rights.
+func (db *DB) SetUserAdmin(ctx context.Context, userID string, isAdmin bool) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateUserAdmin(ctx, generated.UpdateUserAdminParams{
+			IsAdmin: isAdmin,
+			UserID:  userID,
+		})
+	})
+}
+
+// DeleteUser deletes a user along with their sessions, custom models and
+// notification channels. Their conversations are kept but become unowned.
+func (db *DB) DeleteUser(ctx context.Context, userID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteUser(ctx, userID)
+	})
+}
+
+// CreateUserSession records a login session that lasts until expiresAt.
+func (db *DB) CreateUserSession(ctx context.Context, tokenHash, userID string, expiresAt time.Time) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.CreateUserSession(ctx, generated.CreateUserSessionParams{
+			TokenHash: tokenHash,
+			UserID:    userID,
+			ExpiresAt: expiresAt,
+		})
+	})
+}
+
+// GetSessionUser returns the user a session belongs to. If there is no such
+// session, or it has expired, the error wraps sql.ErrNoRows.
+func (db *DB) GetSessionUser(ctx context.Context, tokenHash string) (*generated.User, error) {
+	var user generated.User
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		session, err := q.GetUserSession(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		if time.Now().After(session.ExpiresAt) {
+			return fmt.Errorf("session expired: %w", sql.ErrNoRows)
+		}
+		user, err = q.GetUser(ctx, session.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUserSession ends a login session.
+func (db *DB) DeleteUserSession(ctx context.Context, tokenHash string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteUserSession(ctx, tokenHash)
+	})
+}
+
+// CreateConversationShare creates a read-only link to a conversation. The
+// share ID is the secret part of the link.
+func (db *DB) CreateConversationShare(ctx context.Context, conversationID string, createdBy *string) (*generated.ConversationShare, error) {
+	var share generated.ConversationShare
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		share, err = q.CreateConversationShare(ctx, generated.CreateConversationShareParams{
+			ShareID:        rand.Text(),
+			ConversationID: conversationID,
+			CreatedBy:      createdBy,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// GetConversationShare returns a share by ID. If there is none, the error
+// wraps sql.ErrNoRows.
+func (db *DB) GetConversationShare(ctx context.Context, shareID string) (*generated.ConversationShare, error) {
+	var share generated.ConversationShare
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		share, err = q.GetConversationShare(ctx, shareID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// ListConversationShares returns the read-only links to a conversation.
+func (db *DB) ListConversationShares(ctx context.Context, conversationID string) ([]generated.ConversationShare, error) {
+	var shares []generated.ConversationShare
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		shares, err = q.ListConversationShares(ctx, conversationID)
+		return err
+	})
+	return shares, err
+}
+
+// DeleteConversationShares revokes every read-only link to a conversation.
+func (db *DB) DeleteConversationShares(ctx context.Context, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteConversationShares(ctx, conversationID)
+	})
+}
+
+// CreateAPIToken records an API token for a user. The token ID identifies the
+// token when listing and revoking it; the token itself is not stored.
+func (db *DB) CreateAPIToken(ctx context.Context, userID, name, scope, tokenHash string) (*generated.ApiToken, error) {
+	var token generated.ApiToken
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		token, err = q.CreateAPIToken(ctx, generated.CreateAPITokenParams{
+			TokenID:   "tok" + rand.Text()[:10],
+			UserID:    userID,
+			Name:      name,
+			Scope:     scope,
+			TokenHash: tokenHash,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetAPIToken returns an API token by ID. If there is none, the error wraps
+// sql.ErrNoRows.
+func (db *DB) GetAPIToken(ctx context.Context, tokenID string) (*generated.ApiToken, error) {
+	var token generated.ApiToken
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		token, err = q.GetAPIToken(ctx, tokenID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetAPITokenUser returns the API token with the given hash and the user it
+// belongs to, and records that the token was used. If there is no such token,
+// the error wraps sql.ErrNoRows.
+func (db *DB) GetAPITokenUser(ctx context.Context, tokenHash string) (*generated.ApiToken, *generated.User, error) {
+	var token generated.ApiToken
+	var user generated.User
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		token, err = q.GetAPITokenByHash(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		user, err = q.GetUser(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		return q.TouchAPIToken(ctx, token.TokenID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &token, &user, nil
+}
+
+// ListAPITokens returns a user's API tokens, newest first. A nil userID lists
+// every user's tokens.
+func (db *DB) ListAPITokens(ctx context.Context, userID *string) ([]generated.ApiToken, error) {
+	var tokens []generated.ApiToken
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		tokens, err = q.ListAPITokens(ctx, userID)
+		return err
+	})
+	return tokens, err
+}
+
+// DeleteAPIToken revokes an API token.
+func (db *DB) DeleteAPIToken(ctx context.Context, tokenID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteAPIToken(ctx, tokenID)
+	})
+}
@@ -0,0 +1,175 @@
+package gitstate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshots are ordinary commits built from a scratch index, so taking one never
+// touches HEAD, the real index, or the stash. They are kept alive by a ref
+// (typically under refs/shelley/) rather than by any branch.
+
+// snapshotIdentity lets commit-tree work in repos without user.name/user.email.
+var snapshotIdentity = []string{
+	"GIT_AUTHOR_NAME=Shelley",
+	"GIT_AUTHOR_EMAIL=shelley@localhost",
+	"GIT_COMMITTER_NAME=Shelley",
+	"GIT_COMMITTER_EMAIL=shelley@localhost",
+}
+
+// git runs a git command in dir with extra environment variables and returns its
+// trimmed standard output.
+func git(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// withScratchIndex calls fn with an environment pointing git at a temporary copy
+// of the repository's index, so staging files does not disturb the user's index.
+func withScratchIndex(root string, fn func(env []string) error) error {
+	scratchDir, err := os.MkdirTemp("", "shelley-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratchDir)
+	scratchPath := filepath.Join(scratchDir, "index")
+
+	// Starting from the real index lets git reuse its cached stat data instead of
+	// rehashing every file. A repository with nothing staged yet has no index.
+	if indexPath, err := git(root, nil, "rev-parse", "--path-format=absolute", "--git-path", "index"); err == nil {
+		if err := copyFile(indexPath, scratchPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return fn([]string{"GIT_INDEX_FILE=" + scratchPath})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Snapshot records the working tree containing dir, including uncommitted and
+// untracked (but not ignored) files, as a commit whose parent is HEAD, and points
+// ref at it. It returns the worktree root and the snapshot's commit hash.
+func Snapshot(dir, ref, message string) (worktree, commit string, err error) {
+	worktree, err = git(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", err
+	}
+
+	var tree string
+	err = withScratchIndex(worktree, func(env []string) error {
+		if _, err := git(worktree, env, "add", "-A"); err != nil {
+			return err
+		}
+		tree, err = git(worktree, env, "write-tree")
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	args := []string{"commit-tree", tree, "-m", message}
+	if head, err := git(worktree, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		args = append(args, "-p", head)
+	}
+	commit, err = git(worktree, snapshotIdentity, args...)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := git(worktree, nil, "update-ref", ref, commit); err != nil {
+		return "", "", err
+	}
+	return worktree, commit, nil
+}
+
+// DeleteRefs deletes every ref under prefix (which should end in "/") in the
+// repository containing dir, letting git collect the snapshots they kept alive.
+func DeleteRefs(dir, prefix string) error {
+	refs, err := git(dir, nil, "for-each-ref", "--format=%(refname)", prefix)
+	if err != nil || refs == "" {
+		return err
+	}
+	var stdin strings.Builder
+	for _, ref := range strings.Split(refs, "\n") {
+		fmt.Fprintf(&stdin, "delete %s\n", ref)
+	}
+	cmd := exec.Command("git", "update-ref", "--stdin")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin.String())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git update-ref: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Restore makes the files in worktree match the snapshot commit: files changed or
+// deleted since are rewritten, and files created since are removed. HEAD, the
+// index, and ignored files are left alone.
+func Restore(worktree, commit string) error {
+	return withScratchIndex(worktree, func(env []string) error {
+		// Capture the current state in the scratch index to diff against.
+		if _, err := git(worktree, env, "add", "-A"); err != nil {
+			return err
+		}
+		current, err := git(worktree, env, "write-tree")
+		if err != nil {
+			return err
+		}
+		diff, err := git(worktree, nil, "diff-tree", "-r", "-z", "--name-status", "--no-renames", commit, current)
+		if err != nil {
+			return err
+		}
+
+		var restore []string
+		fields := strings.Split(diff, "\x00")
+		for i := 0; i+1 < len(fields); i += 2 {
+			status, path := fields[i], fields[i+1]
+			switch status {
+			case "A":
+				// Created after the snapshot.
+				if err := os.Remove(filepath.Join(worktree, path)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			default:
+				restore = append(restore, path)
+			}
+		}
+		if len(restore) == 0 {
+			return nil
+		}
+
+		if _, err := git(worktree, env, "read-tree", commit); err != nil {
+			return err
+		}
+		args := append([]string{"checkout-index", "-f", "--"}, restore...)
+		_, err = git(worktree, env, args...)
+		return err
+	})
+}
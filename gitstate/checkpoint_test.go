@@ -0,0 +1,139 @@
+package gitstate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+	runGit(t, tmpDir, "config", "user.email", "test@test.com")
+	runGit(t, tmpDir, "config", "user.name", "Test")
+
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if os.IsNotExist(err) {
+			return "<missing>"
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	write(".gitignore", "*.log\n")
+	write("committed.txt", "v1")
+	write("deleted.txt", "keep me")
+	runGit(t, tmpDir, "add", ".")
+	runGit(t, tmpDir, "commit", "-m", "initial")
+
+	// Uncommitted, staged, and untracked changes all belong in the snapshot.
+	write("committed.txt", "v2 uncommitted")
+	write("staged.txt", "staged")
+	runGit(t, tmpDir, "add", "staged.txt")
+	write("dir/untracked.txt", "untracked")
+	indexBefore := runGitOutput(t, tmpDir, "diff", "--cached", "--name-only")
+	headBefore := runGitOutput(t, tmpDir, "rev-parse", "HEAD")
+
+	worktree, commit, err := Snapshot(filepath.Join(tmpDir, "dir"), "refs/shelley/test/1", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worktree != tmpDir {
+		t.Errorf("expected worktree %q, got %q", tmpDir, worktree)
+	}
+	if got := strings.TrimSpace(runGitOutput(t, tmpDir, "rev-parse", "refs/shelley/test/1")); got != commit {
+		t.Errorf("expected ref to point at %s, got %s", commit, got)
+	}
+	if got := runGitOutput(t, tmpDir, "diff", "--cached", "--name-only"); got != indexBefore {
+		t.Errorf("snapshot changed the index: %q -> %q", indexBefore, got)
+	}
+	if got := runGitOutput(t, tmpDir, "rev-parse", "HEAD"); got != headBefore {
+		t.Error("snapshot moved HEAD")
+	}
+	if got := runGitOutput(t, tmpDir, "stash", "list"); got != "" {
+		t.Errorf("snapshot touched the stash: %q", got)
+	}
+
+	// Make a mess.
+	write("committed.txt", "v3 broken")
+	write("dir/untracked.txt", "clobbered")
+	write("new.txt", "created later")
+	write("debug.log", "ignored")
+	if err := os.Remove(filepath.Join(tmpDir, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(worktree, commit); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"committed.txt":     "v2 uncommitted",
+		"staged.txt":        "staged",
+		"dir/untracked.txt": "untracked",
+		"deleted.txt":       "keep me",
+		"new.txt":           "<missing>",
+		"debug.log":         "ignored",
+	} {
+		if got := read(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	if got := runGitOutput(t, tmpDir, "diff", "--cached", "--name-only"); got != indexBefore {
+		t.Errorf("restore changed the index: %q -> %q", indexBefore, got)
+	}
+}
+
+func TestSnapshot_NoCommits(t *testing.T) {
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Snapshot(tmpDir, "refs/shelley/test/1", "checkpoint"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshot_NotARepo(t *testing.T) {
+	if _, _, err := Snapshot(t.TempDir(), "refs/shelley/test/1", "checkpoint"); err == nil {
+		t.Error("expected an error outside a git repository")
+	}
+}
+
+func TestDeleteRefs(t *testing.T) {
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"refs/shelley/c1/1", "refs/shelley/c1/rollback-1", "refs/shelley/c10/1"} {
+		if _, _, err := Snapshot(tmpDir, ref, "checkpoint"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DeleteRefs(tmpDir, "refs/shelley/c1/"); err != nil {
+		t.Fatal(err)
+	}
+	if got := runGitOutput(t, tmpDir, "for-each-ref", "--format=%(refname)", "refs/shelley/"); strings.TrimSpace(got) != "refs/shelley/c10/1" {
+		t.Errorf("expected only the other conversation's ref to remain, got %q", got)
+	}
+	if err := DeleteRefs(tmpDir, "refs/shelley/c1/"); err != nil {
+		t.Errorf("deleting no refs should succeed, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ package gitstate
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -32,25 +33,19 @@ type GitState struct {
 func GetGitState(dir string) *GitState {
 	state := &GitState{}
 
-	// Get the worktree root (this works for both regular repos and worktrees)
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	output, err := cmd.Output()
-	if err != nil {
+	state.Worktree = Root(dir)
+	if state.Worktree == "" {
 		// Not in a git repository
 		return state
 	}
 	state.IsRepo = true
-	state.Worktree = strings.TrimSpace(string(output))
 
 	// Get the current commit hash (short form)
-	cmd = exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
 	if dir != "" {
 		cmd.Dir = dir
 	}
-	output, err = cmd.Output()
+	output, err := cmd.Output()
 	if err == nil {
 		state.Commit = strings.TrimSpace(string(output))
 	}
@@ -80,6 +75,46 @@ func GetGitState(dir string) *GitState {
 	return state
 }
 
+// Root returns the root of the worktree containing dir, or "" if dir is not
+// inside a git repository. If dir is empty, uses the current working directory.
+func Root(dir string) string {
+	// This works for both regular repos and worktrees
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ProjectDirs returns dir and each directory above it, nearest first, up to
+// gitRoot, or up to the filesystem root if gitRoot is empty. Per-project
+// configuration such as .skills and .shelley/hooks.json is looked up in them.
+func ProjectDirs(dir, gitRoot string) []string {
+	stopAt := gitRoot
+	if stopAt == "" {
+		stopAt = "/"
+	}
+
+	var dirs []string
+	for current := dir; current != ""; {
+		dirs = append(dirs, current)
+		// Stop if we've reached the git root or filesystem root
+		if current == stopAt || current == "/" {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	return dirs
+}
+
 // Equal reports whether g and other represent the same git state.
 func (g *GitState) Equal(other *GitState) bool {
 	if g == nil && other == nil {
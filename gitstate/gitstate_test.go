@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -224,6 +225,28 @@ func TestTildeReplace(t *testing.T) {
 	}
 }
 
+func TestProjectDirs(t *testing.T) {
+	tests := []struct {
+		name     string
+		dir      string
+		gitRoot  string
+		expected []string
+	}{
+		{"stops at git root", "/repo/pkg/sub", "/repo", []string{"/repo/pkg/sub", "/repo/pkg", "/repo"}},
+		{"at git root", "/repo", "/repo", []string{"/repo"}},
+		{"no git root", "/a/b", "", []string{"/a/b", "/a", "/"}},
+		{"no dir", "", "/repo", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProjectDirs(tt.dir, tt.gitRoot); !slices.Equal(got, tt.expected) {
+				t.Errorf("ProjectDirs(%q, %q) = %q, want %q", tt.dir, tt.gitRoot, got, tt.expected)
+			}
+		})
+	}
+}
+
 func runGit(t *testing.T, dir string, args ...string) {
 	t.Helper()
 	// For commits, use --no-verify to skip hooks
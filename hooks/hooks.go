@@ -0,0 +1,254 @@
+// Package hooks runs project-configured shell commands before and after tool
+// calls, in the spirit of git hooks.
+//
+// Hooks are configured in .shelley/hooks.json:
+//
+//	{
+//	  "pre_tool_use": [
+//	    {"tools": ["bash"], "command": "./scripts/check-command.sh"}
+//	  ],
+//	  "post_tool_use": [
+//	    {"tools": ["patch"], "command": "gofmt -l .", "timeout_seconds": 30}
+//	  ]
+//	}
+//
+// Each hook is run with sh -c in the directory that contains its .shelley
+// directory and gets an Input as JSON on stdin. A pre_tool_use hook that exits
+// non-zero vetoes the call, and its output is returned to the LLM instead of
+// the tool's result. The output of a post_tool_use hook, if any, is appended to
+// the tool's result.
+//
+// The agent can edit hooks.json like any other file, so callers should vet
+// each command with Config.Check before it runs.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"shelley.exe.dev/gitstate"
+)
+
+// ConfigPath is where hooks are configured, relative to a project directory.
+const ConfigPath = ".shelley/hooks.json"
+
+const (
+	// DefaultTimeout applies to hooks that do not set timeout_seconds.
+	DefaultTimeout = 60 * time.Second
+	// maxOutput is how much of a hook's output is passed on to the LLM.
+	maxOutput = 16 * 1024
+)
+
+// Event names, as passed to hooks in Input.Event.
+const (
+	PreToolUse  = "pre_tool_use"
+	PostToolUse = "post_tool_use"
+)
+
+// Hook is a command to run around tool calls.
+type Hook struct {
+	// Tools limits the hook to calls of these tools. Empty means every tool.
+	Tools          []string `json:"tools,omitempty"`
+	Command        string   `json:"command"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+
+	// dir is the project directory whose hooks.json configured the hook, set by Load.
+	dir string
+}
+
+// Config is the contents of one or more hooks.json files.
+type Config struct {
+	PreToolUse  []Hook `json:"pre_tool_use,omitempty"`
+	PostToolUse []Hook `json:"post_tool_use,omitempty"`
+
+	// Check, if set, is called with each hook's command before it runs. A
+	// hook it returns an error for is not run, and counts as failing.
+	Check func(ctx context.Context, command string) error `json:"-"`
+}
+
+// Input is what a hook reads from stdin.
+type Input struct {
+	Event      string          `json:"event"`
+	ToolName   string          `json:"tool_name"`
+	ToolInput  json.RawMessage `json:"tool_input"`
+	ToolResult string          `json:"tool_result,omitempty"` // post_tool_use only
+	ToolError  bool            `json:"tool_error,omitempty"`  // post_tool_use only
+	WorkingDir string          `json:"working_dir"`
+}
+
+// ConfigFiles returns all hooks.json files found by walking up from the
+// working directory to the git root (or filesystem root if no git root),
+// nearest first.
+func ConfigFiles(workingDir, gitRoot string) []string {
+	var files []string
+	for _, dir := range gitstate.ProjectDirs(workingDir, gitRoot) {
+		path := filepath.Join(dir, ConfigPath)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Load reads and merges the hooks configured for workingDir. Hooks from files
+// nearer the working directory run first. It returns nil if no hooks are
+// configured.
+func Load(workingDir string) (*Config, error) {
+	if workingDir == "" {
+		return nil, nil
+	}
+	var merged *Config
+	for _, path := range ConfigFiles(workingDir, gitstate.Root(workingDir)) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var config Config
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for _, hook := range slices.Concat(config.PreToolUse, config.PostToolUse) {
+			if strings.TrimSpace(hook.Command) == "" {
+				return nil, fmt.Errorf("%s: hook has no command", path)
+			}
+		}
+		dir := filepath.Clean(strings.TrimSuffix(path, ConfigPath))
+		for i := range config.PreToolUse {
+			config.PreToolUse[i].dir = dir
+		}
+		for i := range config.PostToolUse {
+			config.PostToolUse[i].dir = dir
+		}
+		if merged == nil {
+			merged = &Config{}
+		}
+		merged.PreToolUse = append(merged.PreToolUse, config.PreToolUse...)
+		merged.PostToolUse = append(merged.PostToolUse, config.PostToolUse...)
+	}
+	return merged, nil
+}
+
+func (h Hook) matches(toolName string) bool {
+	return len(h.Tools) == 0 || slices.Contains(h.Tools, toolName)
+}
+
+// RunPre runs the pre_tool_use hooks that match the call, stopping at the
+// first that exits non-zero. If one does, RunPre returns the message to give
+// the LLM in place of the tool's result; otherwise it returns "".
+func (c *Config) RunPre(ctx context.Context, workingDir, toolName string, toolInput json.RawMessage) string {
+	if c == nil {
+		return ""
+	}
+	input := Input{Event: PreToolUse, ToolName: toolName, ToolInput: toolInput, WorkingDir: workingDir}
+	for _, hook := range c.PreToolUse {
+		if !hook.matches(toolName) {
+			continue
+		}
+		output, err := c.run(ctx, hook, input)
+		if err != nil {
+			msg := fmt.Sprintf("Tool call blocked by %s hook `%s` (%v)", PreToolUse, hook.Command, err)
+			if output != "" {
+				msg += ":\n" + output
+			}
+			return msg
+		}
+	}
+	return ""
+}
+
+// RunPost runs the post_tool_use hooks that match the call and returns their
+// feedback for the LLM: one entry for each hook that printed something or
+// exited non-zero.
+func (c *Config) RunPost(ctx context.Context, workingDir, toolName string, toolInput json.RawMessage, result string, isError bool) []string {
+	if c == nil {
+		return nil
+	}
+	input := Input{
+		Event:      PostToolUse,
+		ToolName:   toolName,
+		ToolInput:  toolInput,
+		ToolResult: result,
+		ToolError:  isError,
+		WorkingDir: workingDir,
+	}
+	var feedback []string
+	for _, hook := range c.PostToolUse {
+		if !hook.matches(toolName) {
+			continue
+		}
+		output, err := c.run(ctx, hook, input)
+		switch {
+		case err != nil:
+			msg := fmt.Sprintf("%s hook `%s` failed (%v)", PostToolUse, hook.Command, err)
+			if output != "" {
+				msg += ":\n" + output
+			}
+			feedback = append(feedback, msg)
+		case output != "":
+			feedback = append(feedback, fmt.Sprintf("%s hook `%s`:\n%s", PostToolUse, hook.Command, output))
+		}
+	}
+	return feedback
+}
+
+// run runs the hook with input on stdin, if c.Check allows it, and returns its
+// combined output, trimmed and truncated. The error describes a refusal,
+// non-zero exit or timeout.
+func (c *Config) run(ctx context.Context, h Hook, input Input) (string, error) {
+	if c.Check != nil {
+		if err := c.Check(ctx, h.Command); err != nil {
+			return "", fmt.Errorf("not run: %w", err)
+		}
+	}
+	dir := h.dir
+	if dir == "" {
+		dir = input.WorkingDir
+	}
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	timeout := DefaultTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env = append(os.Environ(), "SHELLEY_HOOK_EVENT="+input.Event, "SHELLEY_TOOL_NAME="+input.ToolName)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // kill the hook's children too
+	}
+	cmd.WaitDelay = 5 * time.Second
+	err = cmd.Run()
+
+	output := strings.TrimSpace(out.String())
+	if len(output) > maxOutput {
+		output = output[:maxOutput] + "\n[output truncated]"
+	}
+	if ctx.Err() != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("timed out after %s", timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output, fmt.Errorf("exit status %d", exitErr.ExitCode())
+	}
+	return output, err
+}
@@ -0,0 +1,167 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, ".shelley"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigPath), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	outside := t.TempDir()
+	root := filepath.Join(outside, "repo")
+	sub := filepath.Join(root, "pkg")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "init", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	// Hooks above the git root are not picked up.
+	writeConfig(t, outside, `{"pre_tool_use": [{"command": "outside"}]}`)
+	writeConfig(t, root, `{"pre_tool_use": [{"command": "root"}], "post_tool_use": [{"tools": ["patch"], "command": "gofmt -l ."}]}`)
+	writeConfig(t, sub, `{"pre_tool_use": [{"command": "sub"}]}`)
+
+	config, err := Load(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pre []string
+	for _, h := range config.PreToolUse {
+		pre = append(pre, h.Command)
+	}
+	if strings.Join(pre, ",") != "sub,root" {
+		t.Errorf("expected nearest hooks first, got %v", pre)
+	}
+	if len(config.PostToolUse) != 1 || config.PostToolUse[0].Tools[0] != "patch" {
+		t.Errorf("unexpected post hooks: %+v", config.PostToolUse)
+	}
+	if config.PreToolUse[0].dir != sub || config.PreToolUse[1].dir != root {
+		t.Errorf("expected hooks to run from their project directories, got %q and %q", config.PreToolUse[0].dir, config.PreToolUse[1].dir)
+	}
+
+	empty := t.TempDir()
+	if config, err := Load(empty); err != nil || config != nil {
+		t.Errorf("expected no hooks, got %+v, %v", config, err)
+	}
+
+	writeConfig(t, sub, `{"pre_tool_use": [{"command": ""}]}`)
+	if _, err := Load(sub); err == nil {
+		t.Error("expected an error for a hook without a command")
+	}
+	writeConfig(t, sub, `{`)
+	if _, err := Load(sub); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestRunPre(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := &Config{PreToolUse: []Hook{
+		{Tools: []string{"bash"}, Command: `if grep -q '"rm -rf /"'; then echo "refusing $SHELLEY_TOOL_NAME"; exit 2; fi`},
+		{Tools: []string{"slow"}, Command: "sleep 10", TimeoutSeconds: 1},
+	}}
+
+	if veto := config.RunPre(ctx, dir, "bash", json.RawMessage(`{"command":"ls"}`)); veto != "" {
+		t.Errorf("expected the call to be allowed, got %q", veto)
+	}
+	veto := config.RunPre(ctx, dir, "bash", json.RawMessage(`{"command":"rm -rf /"}`))
+	if !strings.Contains(veto, "exit status 2") || !strings.Contains(veto, "refusing bash") {
+		t.Errorf("expected a veto with the hook's output, got %q", veto)
+	}
+	if veto := config.RunPre(ctx, dir, "patch", json.RawMessage(`{"command":"rm -rf /"}`)); veto != "" {
+		t.Errorf("expected hooks for other tools not to run, got %q", veto)
+	}
+	if veto := config.RunPre(ctx, dir, "slow", nil); !strings.Contains(veto, "timed out") {
+		t.Errorf("expected a timeout to veto the call, got %q", veto)
+	}
+
+	// Commands the check refuses are not run, and veto the call.
+	var checked []string
+	config.Check = func(ctx context.Context, command string) error {
+		checked = append(checked, command)
+		return errors.New("permission denied")
+	}
+	veto = config.RunPre(ctx, dir, "bash", json.RawMessage(`{"command":"ls"}`))
+	if !strings.Contains(veto, "not run: permission denied") || len(checked) != 1 {
+		t.Errorf("expected the check to veto the call, got %q after checking %q", veto, checked)
+	}
+
+	var none *Config
+	if veto := none.RunPre(ctx, dir, "bash", nil); veto != "" {
+		t.Errorf("expected a nil config to allow everything, got %q", veto)
+	}
+}
+
+func TestRunPost(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := &Config{PostToolUse: []Hook{
+		{Command: "true"},
+		{Command: `cat > input.json; echo "$SHELLEY_HOOK_EVENT"`},
+		{Command: "echo lint failed; exit 1"},
+	}}
+
+	feedback := config.RunPost(ctx, dir, "patch", json.RawMessage(`{"path":"a.go"}`), "applied", false)
+	if len(feedback) != 2 {
+		t.Fatalf("expected feedback from the two hooks with output, got %q", feedback)
+	}
+	if !strings.HasSuffix(feedback[0], "\npost_tool_use") {
+		t.Errorf("unexpected feedback %q", feedback[0])
+	}
+	if !strings.Contains(feedback[1], "exit status 1") || !strings.Contains(feedback[1], "lint failed") {
+		t.Errorf("unexpected feedback %q", feedback[1])
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input Input
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatal(err)
+	}
+	if input.Event != PostToolUse || input.ToolName != "patch" || input.ToolResult != "applied" || string(input.ToolInput) != `{"path":"a.go"}` || input.WorkingDir != dir {
+		t.Errorf("unexpected hook input: %+v", input)
+	}
+}
+
+func TestHookDir(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "pkg")
+	if err := os.MkdirAll(filepath.Join(root, "scripts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "scripts", "where.sh"), []byte("#!/bin/sh\npwd\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, root, `{"post_tool_use": [{"command": "./scripts/where.sh"}]}`)
+
+	// Relative paths in a hook's command work from anywhere in the project.
+	config, err := Load(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feedback := config.RunPost(context.Background(), sub, "bash", nil, "", false)
+	if len(feedback) != 1 || !strings.HasSuffix(feedback[0], "\n"+root) {
+		t.Errorf("expected the hook to run in %s, got %q", root, feedback)
+	}
+}
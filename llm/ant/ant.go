@@ -554,7 +554,8 @@ type streamDelta struct {
 }
 
 // parseSSEStream reads an SSE stream and assembles the complete response.
-func parseSSEStream(r io.Reader) (*response, error) {
+// If onDelta is non-nil, it is called with each text and thinking delta.
+func parseSSEStream(r io.Reader, onDelta func(llm.StreamDelta)) (*response, error) {
 	var (
 		resp     *response
 		contents []content // indexed by content block index
@@ -619,8 +620,14 @@ func parseSSEStream(r io.Reader) (*response, error) {
 					c.Text = new(string)
 				}
 				*c.Text += delta.Text
+				if onDelta != nil && delta.Text != "" {
+					onDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: delta.Text})
+				}
 			case "thinking_delta":
 				c.Thinking += delta.Thinking
+				if onDelta != nil && delta.Thinking != "" {
+					onDelta(llm.StreamDelta{Type: llm.ContentTypeThinking, Text: delta.Thinking})
+				}
 			case "input_json_delta":
 				// Accumulate raw JSON for tool_use input
 				c.ToolInput = append(c.ToolInput, []byte(delta.PartialJSON)...)
@@ -681,6 +688,11 @@ func parseSSEStream(r io.Reader) (*response, error) {
 
 // Do sends a streaming request to Anthropic and collects the full response.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.DoStream(ctx, ir, nil)
+}
+
+// DoStream is like Do, but also passes text and thinking deltas to onDelta as they arrive.
+func (s *Service) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	startTime := time.Now()
 	request := s.fromLLMRequest(ir)
 	request.Stream = true
@@ -698,13 +710,16 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	// retry loop
 	var errs error // accumulated errors across all attempts
 	for attempts := 0; ; attempts++ {
-		if attempts > 10 {
+		if attempts > 10 || (attempts > 0 && llm.RetriesDisabled(ctx)) {
 			return nil, fmt.Errorf("anthropic request failed after %d attempts: %w", attempts, errs)
 		}
 		if attempts > 0 {
 			sleep := backoff[min(attempts, len(backoff)-1)] + time.Duration(rand.Int64N(int64(time.Second)))
 			slog.WarnContext(ctx, "anthropic request sleep before retry", "sleep", sleep, "attempts", attempts)
 			time.Sleep(sleep)
+			if onDelta != nil {
+				onDelta(llm.StreamDelta{Restart: true})
+			}
 		}
 		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
 		if err != nil {
@@ -727,7 +742,7 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 		switch {
 		case resp.StatusCode == http.StatusOK:
-			response, err := parseSSEStream(resp.Body)
+			response, err := parseSSEStream(resp.Body, onDelta)
 			resp.Body.Close()
 			if err != nil {
 				// Stream parse errors might be transient (connection reset, etc.)
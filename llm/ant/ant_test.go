@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -1172,7 +1173,7 @@ func TestToLLMContentWithNestedToolResults(t *testing.T) {
 
 func TestParseSSEStreamText(t *testing.T) {
 	stream := mockSSEResponse("msg_abc", Claude45Sonnet, "Hello!", 10, 5)
-	resp, err := parseSSEStream(strings.NewReader(stream))
+	resp, err := parseSSEStream(strings.NewReader(stream), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1213,7 +1214,7 @@ func TestParseSSEStreamMultipleDeltas(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1225,6 +1226,37 @@ func TestParseSSEStreamMultipleDeltas(t *testing.T) {
 	}
 }
 
+func TestParseSSEStreamDeltas(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_deltas\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"test\",\"content\":[],\"stop_reason\":null,\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n")
+	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"Hmm.\"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"signature_delta\",\"signature\":\"sig\"}}\n\n")
+	b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	b.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello, \"}}\n\n")
+	b.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"world!\"}}\n\n")
+	b.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n")
+	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":3}}\n\n")
+	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+
+	var deltas []llm.StreamDelta
+	_, err := parseSSEStream(strings.NewReader(b.String()), func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("parseSSEStream() error = %v", err)
+	}
+	want := []llm.StreamDelta{
+		{Type: llm.ContentTypeThinking, Text: "Hmm."},
+		{Type: llm.ContentTypeText, Text: "Hello, "},
+		{Type: llm.ContentTypeText, Text: "world!"},
+	}
+	if !reflect.DeepEqual(deltas, want) {
+		t.Errorf("deltas = %+v, want %+v", deltas, want)
+	}
+}
+
 func TestParseSSEStreamToolUse(t *testing.T) {
 	var b strings.Builder
 	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_tool\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"test\",\"content\":[],\"stop_reason\":null,\"usage\":{\"input_tokens\":50,\"output_tokens\":0}}}\n\n")
@@ -1240,7 +1272,7 @@ func TestParseSSEStreamToolUse(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":25}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1291,7 +1323,7 @@ func TestParseSSEStreamToolUseEmptyInput(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":10}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1329,7 +1361,7 @@ func TestParseSSEStreamThinking(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":15}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1364,7 +1396,7 @@ func TestParseSSEStreamPing(t *testing.T) {
 	b.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":1}}\n\n")
 	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
 
-	resp, err := parseSSEStream(strings.NewReader(b.String()))
+	resp, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err != nil {
 		t.Fatalf("parseSSEStream() error = %v", err)
 	}
@@ -1375,7 +1407,7 @@ func TestParseSSEStreamPing(t *testing.T) {
 
 func TestParseSSEStreamNoMessageStart(t *testing.T) {
 	stream := "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"
-	_, err := parseSSEStream(strings.NewReader(stream))
+	_, err := parseSSEStream(strings.NewReader(stream), nil)
 	if err == nil {
 		t.Fatal("expected error for missing message_start")
 	}
@@ -1389,7 +1421,7 @@ func TestParseSSEStreamError(t *testing.T) {
 	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_err\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"test\",\"content\":[],\"stop_reason\":null,\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n")
 	b.WriteString(`event: error` + "\n" + `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n")
 
-	_, err := parseSSEStream(strings.NewReader(b.String()))
+	_, err := parseSSEStream(strings.NewReader(b.String()), nil)
 	if err == nil {
 		t.Fatal("expected error for stream error event")
 	}
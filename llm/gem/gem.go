@@ -477,6 +477,12 @@ func (s *Service) MaxImageDimension() int {
 
 // Do sends a request to Gemini.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.DoStream(ctx, ir, nil)
+}
+
+// DoStream is like Do, but streams the response and passes text deltas to
+// onDelta as they arrive. With a nil onDelta, the response is not streamed.
+func (s *Service) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	// Log the incoming request for debugging
 	slog.DebugContext(ctx, "gemini_request",
 		"message_count", len(ir.Messages),
@@ -547,7 +553,23 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	backoff := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second, 10 * time.Second}
 	for attempts := 0; attempts <= len(backoff); attempts++ {
 		gemApiErr := error(nil)
-		gemRes, gemApiErr = model.GenerateContent(ctx, gemReq)
+		if onDelta != nil {
+			if attempts > 0 {
+				onDelta(llm.StreamDelta{Restart: true})
+			}
+			gemRes, gemApiErr = model.StreamGenerateContent(ctx, gemReq, func(chunk *gemini.Response) {
+				if len(chunk.Candidates) == 0 {
+					return
+				}
+				for _, part := range chunk.Candidates[0].Content.Parts {
+					if part.Text != "" {
+						onDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: part.Text})
+					}
+				}
+			})
+		} else {
+			gemRes, gemApiErr = model.GenerateContent(ctx, gemReq)
+		}
 		endTime = time.Now()
 
 		if gemApiErr == nil {
@@ -559,9 +581,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			break
 		}
 
-		if attempts == len(backoff) {
+		if attempts == len(backoff) || llm.RetriesDisabled(ctx) {
 			// We've exhausted all retry attempts
-			return nil, fmt.Errorf("gemini: API error after %d attempts: %w", attempts, gemApiErr)
+			return nil, fmt.Errorf("gemini: API error after %d attempts: %w", attempts+1, gemApiErr)
 		}
 
 		// Check if the error is retryable (e.g., server error or rate limiting)
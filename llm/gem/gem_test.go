@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"shelley.exe.dev/llm"
@@ -781,3 +782,61 @@ func TestCalculateUsageWithComplexFunctionCall(t *testing.T) {
 		t.Errorf("Expected output tokens with complex function call to be greater than 0, got %d", usage.OutputTokens)
 	}
 }
+
+func TestService_DoStream(t *testing.T) {
+	stream := `data: {"candidates": [{"content": {"role": "model", "parts": [{"text": "Let me "}]}}]}
+
+data: {"candidates": [{"content": {"role": "model", "parts": [{"text": "check."}]}}]}
+
+data: {"candidates": [{"content": {"role": "model", "parts": [{"functionCall": {"name": "bash", "args": {"command": "ls"}}, "thoughtSignature": "sig"}]}}]}
+
+`
+	var gotURL string
+	mockClient := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			gotURL = req.URL.String()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+				Body:       io.NopCloser(bytes.NewBufferString(stream)),
+			}, nil
+		}),
+	}
+	svc := &Service{
+		APIKey: "test-key",
+		Model:  "gemini-test",
+		URL:    "https://test.googleapis.com",
+		HTTPC:  mockClient,
+	}
+
+	var deltas []llm.StreamDelta
+	res, err := svc.DoStream(context.Background(), &llm.Request{
+		Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "List files"}}}},
+	}, func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("DoStream() error = %v", err)
+	}
+	if !strings.Contains(gotURL, ":streamGenerateContent?alt=sse") {
+		t.Errorf("expected a streaming request, got %s", gotURL)
+	}
+	if len(deltas) != 2 || deltas[0].Text != "Let me " || deltas[1].Text != "check." {
+		t.Errorf("unexpected deltas: %+v", deltas)
+	}
+	if len(res.Content) != 2 {
+		t.Fatalf("expected text and tool use, got %+v", res.Content)
+	}
+	if res.Content[0].Text != "Let me check." {
+		t.Errorf("expected merged text, got %q", res.Content[0].Text)
+	}
+	if res.Content[1].ToolName != "bash" || res.Content[1].Signature != "sig" || res.StopReason != llm.StopReasonToolUse {
+		t.Errorf("unexpected tool use: %+v (stop reason %v)", res.Content[1], res.StopReason)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
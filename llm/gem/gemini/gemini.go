@@ -1,12 +1,14 @@
 package gemini
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 // https://ai.google.dev/api/generate-content#request-body
@@ -178,6 +180,82 @@ func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, er
 	return &res, nil
 }
 
+// StreamGenerateContent is like GenerateContent, but streams the response,
+// calling onChunk with each partial response as it arrives.
+// It returns the chunks merged into a single response.
+func (m Model) StreamGenerateContent(ctx context.Context, req *Request, onChunk func(*Response)) (*Response, error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse&key=%s", m.endpoint(), m.Model, m.APIKey), bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP request: %w", err)
+	}
+	httpReq.Header.Add("Content-Type", "application/json")
+	httpResp, err := m.httpc().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("StreamGenerateContent: do: %w", err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResp.Body)
+		return nil, fmt.Errorf("StreamGenerateContent: HTTP status: %d, %s", httpResp.StatusCode, string(body))
+	}
+
+	res := Response{headers: httpResp.Header}
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var chunk Response
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("StreamGenerateContent: unmarshaling chunk: %w, %s", err, data)
+		}
+		if onChunk != nil {
+			onChunk(&chunk)
+		}
+		res.merge(&chunk)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("StreamGenerateContent: reading stream: %w", err)
+	}
+	return &res, nil
+}
+
+// merge appends the first candidate of a streamed chunk to r.
+// Consecutive text is joined into one part, and a bare thought signature
+// is attached to the part before it.
+func (r *Response) merge(chunk *Response) {
+	if len(chunk.Candidates) == 0 {
+		return
+	}
+	if len(r.Candidates) == 0 {
+		r.Candidates = []Candidate{{Content: Content{Role: chunk.Candidates[0].Content.Role}}}
+	}
+	content := &r.Candidates[0].Content
+	for _, part := range chunk.Candidates[0].Content.Parts {
+		var last *Part
+		if n := len(content.Parts); n > 0 {
+			last = &content.Parts[n-1]
+		}
+		switch {
+		case last != nil && part.Text == "" && part.FunctionCall == nil && part.ThoughtSignature != "":
+			last.ThoughtSignature = part.ThoughtSignature
+		case last != nil && part.Text != "" && part.FunctionCall == nil && last.Text != "" && last.FunctionCall == nil:
+			last.Text += part.Text
+			if part.ThoughtSignature != "" {
+				last.ThoughtSignature = part.ThoughtSignature
+			}
+		default:
+			content.Parts = append(content.Parts, part)
+		}
+	}
+}
+
 func (m Model) endpoint() string {
 	if m.Endpoint != "" {
 		return m.Endpoint
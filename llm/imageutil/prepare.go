@@ -0,0 +1,55 @@
+package imageutil
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Prepared is an image ready to send to an LLM.
+type Prepared struct {
+	Data      []byte
+	MediaType string
+	// Converted is set if the image was converted from HEIC.
+	Converted bool
+	// Resized is set if the image was scaled down to fit maxDimension.
+	Resized bool
+}
+
+// IsImage reports whether data looks like an image we can send to an LLM,
+// including HEIC images, which Prepare converts.
+func IsImage(data []byte) bool {
+	return IsHEIC(data) || strings.HasPrefix(http.DetectContentType(data), "image/")
+}
+
+// Prepare converts HEIC images to PNG and scales down images larger than
+// maxDimension in either direction. A maxDimension of 0 means no limit.
+func Prepare(data []byte, maxDimension int) (*Prepared, error) {
+	p := &Prepared{Data: data}
+	// Go's image library doesn't support HEIC.
+	if IsHEIC(data) {
+		converted, err := ConvertHEICToPNG(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert HEIC image: %w", err)
+		}
+		p.Data = converted
+		p.Converted = true
+	}
+
+	detectedType := http.DetectContentType(p.Data)
+	if !strings.HasPrefix(detectedType, "image/") {
+		return nil, fmt.Errorf("file is not an image: %s", detectedType)
+	}
+	p.MediaType = detectedType
+
+	if maxDimension > 0 {
+		resized, format, didResize, err := ResizeImage(p.Data, maxDimension)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resize image: %w", err)
+		}
+		p.Data = resized
+		p.MediaType = "image/" + format
+		p.Resized = didResize
+	}
+	return p, nil
+}
@@ -0,0 +1,30 @@
+package imageutil
+
+import "testing"
+
+func TestPrepare(t *testing.T) {
+	data := createTestPNG(t, 300, 100)
+
+	p, err := Prepare(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MediaType != "image/png" || p.Resized || p.Converted || len(p.Data) != len(data) {
+		t.Errorf("expected the image unchanged, got %s resized=%v converted=%v", p.MediaType, p.Resized, p.Converted)
+	}
+
+	p, err = Prepare(data, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Resized || p.MediaType != "image/png" {
+		t.Errorf("expected a resized PNG, got %s resized=%v", p.MediaType, p.Resized)
+	}
+
+	if _, err := Prepare([]byte("not an image"), 0); err == nil {
+		t.Error("expected an error for text")
+	}
+	if IsImage([]byte("not an image")) || !IsImage(data) {
+		t.Error("IsImage misclassified its input")
+	}
+}
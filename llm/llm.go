@@ -37,6 +37,48 @@ func UseSimplifiedPatch(svc Service) bool {
 	return false
 }
 
+// StreamDelta is a piece of a response that is still being generated.
+type StreamDelta struct {
+	// Type is ContentTypeText or ContentTypeThinking.
+	Type ContentType
+	Text string
+	// Restart reports that the service is retrying the request,
+	// so any deltas received so far should be discarded.
+	Restart bool
+}
+
+// StreamingService is a Service that can report partial output as it arrives.
+type StreamingService interface {
+	Service
+	// DoStream is like Do, but calls onDelta with text and thinking as they are generated.
+	// onDelta is called synchronously from the goroutine calling DoStream.
+	DoStream(ctx context.Context, req *Request, onDelta func(StreamDelta)) (*Response, error)
+}
+
+// DoStream sends req to svc, streaming deltas to onDelta if svc supports it.
+// Otherwise it falls back to svc.Do and onDelta is never called.
+func DoStream(ctx context.Context, svc Service, req *Request, onDelta func(StreamDelta)) (*Response, error) {
+	if ss, ok := svc.(StreamingService); ok && onDelta != nil {
+		return ss.DoStream(ctx, req, onDelta)
+	}
+	return svc.Do(ctx, req)
+}
+
+type noRetriesKey struct{}
+
+// WithoutRetries returns a context asking services to return errors they would
+// otherwise retry, such as rate limits and overloaded or failing servers,
+// after the first attempt, so that the caller can fail over to another service.
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetriesKey{}, true)
+}
+
+// RetriesDisabled reports whether ctx came from WithoutRetries.
+func RetriesDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetriesKey{}).(bool)
+	return disabled
+}
+
 // MustSchema validates that schema is a valid JSON schema and returns it as a json.RawMessage.
 // It panics if the schema is invalid.
 // The schema must have at least type="object" and a properties key.
@@ -67,6 +109,7 @@ const (
 	ErrorTypeNone       ErrorType = ""            // Not an error
 	ErrorTypeTruncation ErrorType = "truncation"  // Response truncated due to max tokens
 	ErrorTypeLLMRequest ErrorType = "llm_request" // LLM request failed
+	ErrorTypeBudget     ErrorType = "budget"      // Spending limit reached
 )
 
 type Request struct {
@@ -121,6 +164,10 @@ type Tool struct {
 	EndsTurn bool
 	// Cache indicates whether to use prompt caching for this tool
 	Cache bool
+	// Parallel indicates that calls to this tool have no side effects that
+	// other tool calls could observe, so the loop may run them concurrently
+	// with other Parallel tool calls from the same response.
+	Parallel bool
 
 	// The Run function is automatically called when the tool is used.
 	// Run functions may be called concurrently with each other and themselves.
@@ -329,6 +376,9 @@ type Usage struct {
 	Model                    string     `json:"model,omitempty"`
 	StartTime                *time.Time `json:"start_time,omitempty"`
 	EndTime                  *time.Time `json:"end_time,omitempty"`
+	// ModelID is the Shelley model (or model group) that served the request;
+	// Model is the provider's name for it.
+	ModelID string `json:"model_id,omitempty"`
 }
 
 func (u *Usage) Add(other Usage) {
@@ -19,6 +19,7 @@ const (
 	conversationIDKey contextKey = iota
 	modelIDKey
 	providerKey
+	backendKey
 )
 
 // WithConversationID returns a context with the conversation ID attached.
@@ -60,6 +61,20 @@ func ProviderFromContext(ctx context.Context) string {
 	return ""
 }
 
+// WithBackend returns a context with the backend attached: for a model group,
+// the member model and endpoint a request is sent to.
+func WithBackend(ctx context.Context, backend string) context.Context {
+	return context.WithValue(ctx, backendKey, backend)
+}
+
+// BackendFromContext returns the backend from the context, if any.
+func BackendFromContext(ctx context.Context) string {
+	if v := ctx.Value(backendKey); v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
 // Recorder is called after each LLM HTTP request with the request/response details.
 type Recorder func(ctx context.Context, url string, requestBody, responseBody []byte, statusCode int, err error, duration time.Duration)
 
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
@@ -754,6 +755,13 @@ func (s *Service) MaxImageDimension() int {
 
 // Do sends a request to OpenAI using the go-openai package.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.DoStream(ctx, ir, nil)
+}
+
+// DoStream is like Do, but streams the completion and passes text and
+// reasoning deltas to onDelta as they arrive. With a nil onDelta, the
+// completion is requested without streaming.
+func (s *Service) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	// Configure the OpenAI client
 	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
 	model := cmp.Or(s.Model, DefaultModel)
@@ -807,16 +815,25 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	// retry loop
 	var errs error // accumulated errors across all attempts
 	for attempts := 0; ; attempts++ {
-		if attempts > 10 {
+		if attempts > 10 || (attempts > 0 && llm.RetriesDisabled(ctx)) {
 			return nil, fmt.Errorf("openai request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)
 		}
 		if attempts > 0 {
 			sleep := backoff[min(attempts, len(backoff)-1)] + time.Duration(rand.Int64N(int64(time.Second)))
 			slog.WarnContext(ctx, "openai request sleep before retry", "sleep", sleep, "attempts", attempts)
 			time.Sleep(sleep)
+			if onDelta != nil {
+				onDelta(llm.StreamDelta{Restart: true})
+			}
 		}
 
-		resp, err := client.CreateChatCompletion(ctx, req)
+		var resp openai.ChatCompletionResponse
+		var err error
+		if onDelta != nil {
+			resp, err = createChatCompletionStream(ctx, client, req, onDelta)
+		} else {
+			resp, err = client.CreateChatCompletion(ctx, req)
+		}
 
 		// Handle successful response
 		if err == nil {
@@ -864,6 +881,80 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	}
 }
 
+// createChatCompletionStream streams a chat completion, passing deltas to onDelta,
+// and assembles the chunks into the response CreateChatCompletion would have returned.
+func createChatCompletionStream(ctx context.Context, client *openai.Client, req openai.ChatCompletionRequest, onDelta func(llm.StreamDelta)) (openai.ChatCompletionResponse, error) {
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	var resp openai.ChatCompletionResponse
+	resp.SetHeader(stream.Header())
+	var (
+		msg       openai.ChatCompletionMessage
+		content   strings.Builder
+		reasoning strings.Builder
+		finish    openai.FinishReason
+		toolCalls []openai.ToolCall
+	)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+		resp.ID = cmp.Or(resp.ID, chunk.ID)
+		resp.Model = cmp.Or(resp.Model, chunk.Model)
+		resp.Created = cmp.Or(resp.Created, chunk.Created)
+		if chunk.Usage != nil {
+			resp.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		delta := choice.Delta
+		msg.Role = cmp.Or(msg.Role, delta.Role)
+		finish = cmp.Or(choice.FinishReason, finish)
+		if delta.ReasoningContent != "" {
+			reasoning.WriteString(delta.ReasoningContent)
+			onDelta(llm.StreamDelta{Type: llm.ContentTypeThinking, Text: delta.ReasoningContent})
+		}
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			onDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: delta.Content})
+		}
+		for _, tc := range delta.ToolCalls {
+			// Each tool call arrives in pieces that share an index;
+			// only the first piece carries the ID and name.
+			i := len(toolCalls)
+			if tc.Index != nil {
+				i = *tc.Index
+			}
+			for len(toolCalls) <= i {
+				toolCalls = append(toolCalls, openai.ToolCall{Type: openai.ToolTypeFunction})
+			}
+			call := &toolCalls[i]
+			call.ID = cmp.Or(call.ID, tc.ID)
+			call.Type = cmp.Or(tc.Type, call.Type)
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+	}
+
+	msg.Role = cmp.Or(msg.Role, openai.ChatMessageRoleAssistant)
+	msg.Content = content.String()
+	msg.ReasoningContent = reasoning.String()
+	msg.ToolCalls = toolCalls
+	resp.Choices = []openai.ChatCompletionChoice{{Message: msg, FinishReason: finish}}
+	return resp, nil
+}
+
 func (s *Service) UseSimplifiedPatch() bool {
 	return s.Model.UseSimplifiedPatch
 }
@@ -1,6 +1,7 @@
 package oai
 
 import (
+	"bufio"
 	"bytes"
 	"cmp"
 	"context"
@@ -42,6 +43,7 @@ type responsesRequest struct {
 	ToolChoice      any                  `json:"tool_choice,omitempty"`
 	MaxOutputTokens int                  `json:"max_output_tokens,omitempty"`
 	Reasoning       *responsesReasoning  `json:"reasoning,omitempty"`
+	Stream          bool                 `json:"stream,omitempty"`
 }
 
 type responsesReasoning struct {
@@ -116,6 +118,50 @@ type responsesError struct {
 	Code    string `json:"code"`
 }
 
+// responsesStreamEvent is a server-sent event from a streaming Responses request.
+// Only the fields of the events we use are included.
+type responsesStreamEvent struct {
+	Type     string             `json:"type"`
+	Delta    string             `json:"delta,omitempty"`    // response.*.delta
+	Response *responsesResponse `json:"response,omitempty"` // response.completed, response.incomplete, response.failed
+	Message  string             `json:"message,omitempty"`  // error
+}
+
+// parseResponsesStream reads a Responses API event stream, passing text and
+// reasoning summary deltas to onDelta, and returns the final response.
+func parseResponsesStream(r io.Reader, onDelta func(llm.StreamDelta)) (*responsesResponse, error) {
+	scanner := bufio.NewScanner(r)
+	// Completed events carry the whole response, so lines can be large.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event responsesStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("parsing responses stream event: %w", err)
+		}
+		switch event.Type {
+		case "response.output_text.delta":
+			onDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: event.Delta})
+		case "response.reasoning_summary_text.delta":
+			onDelta(llm.StreamDelta{Type: llm.ContentTypeThinking, Text: event.Delta})
+		case "response.completed", "response.incomplete", "response.failed":
+			if event.Response == nil {
+				return nil, fmt.Errorf("%s event has no response", event.Type)
+			}
+			return event.Response, nil
+		case "error":
+			return nil, fmt.Errorf("stream error event: %s", event.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading responses stream: %w", err)
+	}
+	return nil, fmt.Errorf("responses stream ended without a final response")
+}
+
 // fromLLMMessageResponses converts llm.Message to Responses API input items
 func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 	var items []responsesInputItem
@@ -364,6 +410,13 @@ func (s *ResponsesService) MaxImageDimension() int {
 
 // Do sends a request to OpenAI using the Responses API.
 func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	return s.DoStream(ctx, ir, nil)
+}
+
+// DoStream is like Do, but streams the response and passes output text and
+// reasoning summary deltas to onDelta as they arrive. With a nil onDelta,
+// the response is requested without streaming.
+func (s *ResponsesService) DoStream(ctx context.Context, ir *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
 	model := cmp.Or(s.Model, DefaultModel)
 
@@ -392,6 +445,7 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		Input:           allInput,
 		Tools:           tools,
 		MaxOutputTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
+		Stream:          onDelta != nil,
 	}
 
 	// Add reasoning if thinking is enabled
@@ -432,13 +486,16 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	// retry loop
 	var errs error // accumulated errors across all attempts
 	for attempts := 0; ; attempts++ {
-		if attempts > 10 {
+		if attempts > 10 || (attempts > 0 && llm.RetriesDisabled(ctx)) {
 			return nil, fmt.Errorf("responses request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)
 		}
 		if attempts > 0 {
 			sleep := backoff[min(attempts, len(backoff)-1)] + time.Duration(rand.Int64N(int64(time.Second)))
 			slog.WarnContext(ctx, "responses request sleep before retry", "sleep", sleep, "attempts", attempts)
 			time.Sleep(sleep)
+			if onDelta != nil {
+				onDelta(llm.StreamDelta{Restart: true})
+			}
 		}
 
 		// Create HTTP request
@@ -461,6 +518,17 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		}
 		defer httpResp.Body.Close()
 
+		var resp responsesResponse
+		if req.Stream && httpResp.StatusCode == http.StatusOK {
+			streamed, err := parseResponsesStream(httpResp.Body, onDelta)
+			if err != nil {
+				// Stream errors might be transient (connection reset, etc.)
+				errs = errors.Join(errs, fmt.Errorf("attempt %d: %w", attempts+1, err))
+				continue
+			}
+			return s.finishResponses(ctx, streamed, httpResp.Header)
+		}
+
 		// Read response body
 		body, err := io.ReadAll(httpResp.Body)
 		if err != nil {
@@ -500,27 +568,30 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		}
 
 		// Parse successful response
-		var resp responsesResponse
 		if err := json.Unmarshal(body, &resp); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
+		return s.finishResponses(ctx, &resp, httpResp.Header)
+	}
+}
 
-		// Check for errors in the response
-		if resp.Error != nil {
-			return nil, fmt.Errorf("response contains error: %s", resp.Error.Message)
-		}
+// finishResponses converts a successful HTTP response to an llm.Response.
+func (s *ResponsesService) finishResponses(ctx context.Context, resp *responsesResponse, header http.Header) (*llm.Response, error) {
+	// Check for errors in the response
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response contains error: %s", resp.Error.Message)
+	}
 
-		// Dump response if enabled
-		if s.DumpLLM {
-			if respJSON, err := json.MarshalIndent(resp, "", "  "); err == nil {
-				if err := llm.DumpToFile("response", "", respJSON); err != nil {
-					slog.WarnContext(ctx, "failed to dump responses response to file", "error", err)
-				}
+	// Dump response if enabled
+	if s.DumpLLM {
+		if respJSON, err := json.MarshalIndent(resp, "", "  "); err == nil {
+			if err := llm.DumpToFile("response", "", respJSON); err != nil {
+				slog.WarnContext(ctx, "failed to dump responses response to file", "error", err)
 			}
 		}
-
-		return s.toLLMResponseFromResponses(&resp, httpResp.Header), nil
 	}
+
+	return s.toLLMResponseFromResponses(resp, header), nil
 }
 
 func (s *ResponsesService) UseSimplifiedPatch() bool {
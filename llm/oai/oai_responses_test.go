@@ -3,9 +3,11 @@ package oai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
 
 	"shelley.exe.dev/llm"
@@ -518,3 +520,73 @@ func TestResponsesServiceDo(t *testing.T) {
 		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
 	}
 }
+
+func TestResponsesServiceDoStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req responsesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if !req.Stream {
+			t.Error("expected a streaming request")
+		}
+		completed, _ := json.Marshal(responsesStreamEvent{
+			Type: "response.completed",
+			Response: &responsesResponse{
+				ID:     "responses-stream",
+				Model:  "test-model",
+				Status: "completed",
+				Output: []responsesOutputItem{{
+					Type:    "message",
+					Role:    "assistant",
+					Content: []responsesContent{{Type: "output_text", Text: "Hello there!"}},
+				}},
+				Usage: responsesUsage{InputTokens: 10, OutputTokens: 20},
+			},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"response.created","response":{"id":"responses-stream","status":"in_progress"}}`,
+			`{"type":"response.reasoning_summary_text.delta","delta":"Greeting."}`,
+			`{"type":"response.output_text.delta","delta":"Hello "}`,
+			`{"type":"response.output_text.delta","delta":"there!"}`,
+			string(completed),
+		} {
+			var e responsesStreamEvent
+			json.Unmarshal([]byte(event), &e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	svc := &ResponsesService{
+		APIKey:   "test-api-key",
+		Model:    GPT41,
+		ModelURL: server.URL,
+	}
+	req := &llm.Request{
+		Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello!"}}}},
+	}
+
+	var deltas []llm.StreamDelta
+	resp, err := svc.DoStream(context.Background(), req, func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("DoStream() error = %v", err)
+	}
+	wantDeltas := []llm.StreamDelta{
+		{Type: llm.ContentTypeThinking, Text: "Greeting."},
+		{Type: llm.ContentTypeText, Text: "Hello "},
+		{Type: llm.ContentTypeText, Text: "there!"},
+	}
+	if !reflect.DeepEqual(deltas, wantDeltas) {
+		t.Errorf("deltas = %+v, want %+v", deltas, wantDeltas)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "Hello there!" {
+		t.Errorf("unexpected content: %+v", resp.Content)
+	}
+	if resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 20 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
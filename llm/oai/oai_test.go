@@ -3,8 +3,10 @@ package oai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
@@ -1315,3 +1317,73 @@ func TestServiceDo(t *testing.T) {
 		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
 	}
 }
+
+func TestServiceDoStream(t *testing.T) {
+	chunks := []string{
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Thinking."}}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{"content":"Let me "}}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{"content":"check."}}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"bash","arguments":""}}]}}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"command\":"}}]}}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"ls\"}"}}]}}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"id":"chatcmpl-stream","model":"gpt-4.1-2025-04-14","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":20,"total_tokens":30}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["stream"] != true {
+			t.Errorf("expected a streaming request, got %v", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	svc := &Service{
+		APIKey:   "test-api-key",
+		Model:    GPT41,
+		ModelURL: server.URL + "/v1",
+	}
+	req := &llm.Request{
+		Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "List files"}}}},
+	}
+
+	var deltas []llm.StreamDelta
+	resp, err := svc.DoStream(context.Background(), req, func(d llm.StreamDelta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("DoStream() error = %v", err)
+	}
+
+	wantDeltas := []llm.StreamDelta{
+		{Type: llm.ContentTypeThinking, Text: "Thinking."},
+		{Type: llm.ContentTypeText, Text: "Let me "},
+		{Type: llm.ContentTypeText, Text: "check."},
+	}
+	if !reflect.DeepEqual(deltas, wantDeltas) {
+		t.Errorf("deltas = %+v, want %+v", deltas, wantDeltas)
+	}
+	if resp.ID != "chatcmpl-stream" || resp.StopReason != llm.StopReasonToolUse {
+		t.Errorf("unexpected response: id=%q stop=%v", resp.ID, resp.StopReason)
+	}
+	if len(resp.Content) != 2 {
+		t.Fatalf("resp.Content length = %d, expected 2", len(resp.Content))
+	}
+	if resp.Content[0].Text != "Let me check." {
+		t.Errorf("resp.Content[0].Text = %q, expected %q", resp.Content[0].Text, "Let me check.")
+	}
+	call := resp.Content[1]
+	if call.Type != llm.ContentTypeToolUse || call.ID != "call_1" || call.ToolName != "bash" || string(call.ToolInput) != `{"command":"ls"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 20 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
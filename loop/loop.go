@@ -9,8 +9,11 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/hooks"
 	"shelley.exe.dev/llm"
 )
 
@@ -21,6 +24,25 @@ type MessageRecordFunc func(ctx context.Context, message llm.Message, usage llm.
 // This is used to record user-visible notifications about git changes.
 type GitStateChangeFunc func(ctx context.Context, state *gitstate.GitState)
 
+// CompactHistoryFunc is called before each LLM request with the current history.
+// If it compacts the conversation it returns the history to use from then on;
+// otherwise it returns nil and the history is left unchanged.
+type CompactHistoryFunc func(ctx context.Context, history []llm.Message) ([]llm.Message, error)
+
+// BudgetCheckFunc is called before each LLM request. A non-nil error means a
+// spending limit has been reached: the turn ends and the error is shown to the user.
+type BudgetCheckFunc func(ctx context.Context) error
+
+// StreamDeltaFunc is called with partial LLM output as it is generated.
+// A delta with Restart set means the request is being retried and earlier
+// deltas should be discarded.
+type StreamDeltaFunc func(delta llm.StreamDelta)
+
+// LoadHooksFunc returns the tool hooks configured for a working directory,
+// or nil if there are none. If it returns an error, the tool calls are not run
+// and the error is returned to the LLM as their result.
+type LoadHooksFunc func(workingDir string) (*hooks.Config, error)
+
 // Config contains all configuration needed to create a Loop.
 type Config struct {
 	LLM              llm.Service
@@ -35,6 +57,22 @@ type Config struct {
 	// If set, this is called at end of turn to check for git state changes.
 	// If nil, Config.WorkingDir is used as a static value.
 	GetWorkingDir func() string
+	// CompactHistory, if set, may shrink the history before each LLM request.
+	CompactHistory CompactHistoryFunc
+	// CheckBudget, if set, can stop the turn before each LLM request.
+	CheckBudget BudgetCheckFunc
+	// OnStreamDelta, if set, receives text and thinking as the LLM generates them,
+	// for services that support streaming.
+	OnStreamDelta StreamDeltaFunc
+	// LoadHooks, if set, is called before each batch of tool calls to get the
+	// hooks to run before and after each call.
+	LoadHooks LoadHooksFunc
+	// GetTools, if set, returns the tools available now, so that they can
+	// change during the conversation. It is used instead of Tools.
+	GetTools func() []*llm.Tool
+	// OnTurnStart, if set, is called when queued user messages start a new turn,
+	// before the first LLM request.
+	OnTurnStart func(ctx context.Context)
 }
 
 // Loop manages a conversation turn with an LLM including tool execution and message recording.
@@ -53,6 +91,12 @@ type Loop struct {
 	onGitStateChange GitStateChangeFunc
 	getWorkingDir    func() string
 	lastGitState     *gitstate.GitState
+	compactHistory   CompactHistoryFunc
+	checkBudget      BudgetCheckFunc
+	onStreamDelta    StreamDeltaFunc
+	loadHooks        LoadHooksFunc
+	getTools         func() []*llm.Tool
+	onTurnStart      func(ctx context.Context)
 }
 
 // NewLoop creates a new Loop instance with the provided configuration
@@ -81,6 +125,12 @@ func NewLoop(config Config) *Loop {
 		onGitStateChange: config.OnGitStateChange,
 		getWorkingDir:    config.GetWorkingDir,
 		lastGitState:     initialGitState,
+		compactHistory:   config.CompactHistory,
+		checkBudget:      config.CheckBudget,
+		onStreamDelta:    config.OnStreamDelta,
+		loadHooks:        config.LoadHooks,
+		getTools:         config.GetTools,
+		onTurnStart:      config.OnTurnStart,
 	}
 }
 
@@ -124,7 +174,7 @@ func (l *Loop) Go(ctx context.Context) error {
 		return fmt.Errorf("no LLM service configured")
 	}
 
-	l.logger.Info("starting conversation loop", "tools", len(l.tools))
+	l.logger.Info("starting conversation loop", "tools", len(l.currentTools()))
 
 	for {
 		select {
@@ -147,6 +197,9 @@ func (l *Loop) Go(ctx context.Context) error {
 		l.mu.Unlock()
 
 		if hasQueuedMessages {
+			if l.onTurnStart != nil {
+				l.onTurnStart(ctx)
+			}
 			// Send request to LLM
 			l.logger.Debug("processing queued messages", "count", 1)
 			if err := l.processLLMRequest(ctx); err != nil {
@@ -176,7 +229,8 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 
 	// Process any queued messages first
 	l.mu.Lock()
-	if len(l.messageQueue) > 0 {
+	hasQueuedMessages := len(l.messageQueue) > 0
+	if hasQueuedMessages {
 		// Add queued messages to history (they are already recorded to DB by ConversationManager)
 		for _, msg := range l.messageQueue {
 			l.history = append(l.history, msg)
@@ -184,6 +238,9 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 		l.messageQueue = nil
 	}
 	l.mu.Unlock()
+	if hasQueuedMessages && l.onTurnStart != nil {
+		l.onTurnStart(ctx)
+	}
 
 	// Process one LLM request and response
 	return l.processLLMRequest(ctx)
@@ -191,12 +248,17 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 
 // processLLMRequest sends a request to the LLM and handles the response
 func (l *Loop) processLLMRequest(ctx context.Context) error {
+	if err := l.checkOverBudget(ctx); err != nil {
+		return err
+	}
+	l.maybeCompactHistory(ctx)
+
 	l.mu.Lock()
 	messages := append([]llm.Message(nil), l.history...)
-	tools := l.tools
 	system := l.system
 	llmService := l.llm
 	l.mu.Unlock()
+	tools := l.currentTools()
 
 	// Enable prompt caching: set cache flag on last tool and last user message content
 	// See https://docs.anthropic.com/en/docs/build-with-claude/prompt-caching
@@ -249,7 +311,10 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	var resp *llm.Response
 	var err error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		resp, err = llmService.Do(llmCtx, req)
+		if attempt > 1 && l.onStreamDelta != nil {
+			l.onStreamDelta(llm.StreamDelta{Restart: true})
+		}
+		resp, err = llm.DoStream(llmCtx, llmService, req, l.onStreamDelta)
 		if err == nil {
 			break
 		}
@@ -323,6 +388,57 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	return nil
 }
 
+// checkOverBudget ends the turn with an error message if the CheckBudget callback
+// reports that a spending limit has been reached.
+func (l *Loop) checkOverBudget(ctx context.Context) error {
+	if l.checkBudget == nil {
+		return nil
+	}
+	err := l.checkBudget(ctx)
+	if err == nil {
+		return nil
+	}
+	errorMessage := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{
+			{
+				Type: llm.ContentTypeText,
+				Text: err.Error(),
+			},
+		},
+		EndOfTurn: true,
+		ErrorType: llm.ErrorTypeBudget,
+	}
+	if recordErr := l.recordMessage(ctx, errorMessage, llm.Usage{}); recordErr != nil {
+		l.logger.Error("failed to record budget message", "error", recordErr)
+	}
+	return fmt.Errorf("over budget: %w", err)
+}
+
+// maybeCompactHistory gives the CompactHistory callback a chance to shrink the
+// history. Failures are logged and the request goes ahead with the full history.
+func (l *Loop) maybeCompactHistory(ctx context.Context) {
+	if l.compactHistory == nil {
+		return
+	}
+	l.mu.Lock()
+	history := append([]llm.Message(nil), l.history...)
+	l.mu.Unlock()
+
+	compacted, err := l.compactHistory(ctx, history)
+	if err != nil {
+		l.logger.Warn("failed to compact history", "error", err)
+		return
+	}
+	if compacted == nil {
+		return
+	}
+	l.logger.Info("compacted history", "before", len(history), "after", len(compacted))
+	l.mu.Lock()
+	l.history = compacted
+	l.mu.Unlock()
+}
+
 // checkGitStateChange checks if the git state has changed and calls the callback if so.
 // This is called at the end of each turn.
 func (l *Loop) checkGitStateChange(ctx context.Context) {
@@ -408,68 +524,59 @@ func (l *Loop) handleMaxTokensTruncation(ctx context.Context, resp *llm.Response
 	return nil
 }
 
-// handleToolCalls processes tool calls from the LLM response
-func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error {
-	var toolResults []llm.Content
+// maxParallelToolCalls bounds how many Parallel tool calls run at once.
+const maxParallelToolCalls = 8
 
+// handleToolCalls processes tool calls from the LLM response.
+// Consecutive calls to tools marked Parallel are run concurrently (bounded by
+// maxParallelToolCalls); any other call runs on its own, after everything before
+// it has finished. Results are always returned in the order the calls were made.
+func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error {
+	var toolUses []llm.Content
 	for _, c := range content {
-		if c.Type != llm.ContentTypeToolUse {
-			continue
+		if c.Type == llm.ContentTypeToolUse {
+			toolUses = append(toolUses, c)
 		}
+	}
 
-		l.logger.Debug("executing tool", "name", c.ToolName, "id", c.ID)
-
-		// Find the tool
-		var tool *llm.Tool
-		for _, t := range l.tools {
-			if t.Name == c.ToolName {
-				tool = t
-				break
+	toolResults := make([]llm.Content, len(toolUses))
+	var toolHooks *hooks.Config
+	if l.loadHooks != nil && len(toolUses) > 0 {
+		var err error
+		toolHooks, err = l.loadHooks(l.currentWorkingDir())
+		if err != nil {
+			// Fail closed: a broken hooks.json must not let calls through unchecked.
+			l.logger.Warn("failed to load tool hooks, blocking tool calls", "error", err)
+			for i, c := range toolUses {
+				toolResults[i] = blockedToolResult(c, fmt.Sprintf("Tool call blocked: could not load tool hooks: %v", err))
 			}
+			toolUses = nil // nothing left to run
 		}
+	}
 
-		if tool == nil {
-			l.logger.Error("tool not found", "name", c.ToolName)
-			toolResults = append(toolResults, llm.Content{
-				Type:      llm.ContentTypeToolResult,
-				ToolUseID: c.ID,
-				ToolError: true,
-				ToolResult: []llm.Content{
-					{Type: llm.ContentTypeText, Text: fmt.Sprintf("Tool '%s' not found", c.ToolName)},
-				},
-			})
+	for i := 0; i < len(toolUses); {
+		// Gather the run of consecutive parallel-safe calls starting at i.
+		j := i
+		for j < len(toolUses) && l.isParallelTool(toolUses[j].ToolName) {
+			j++
+		}
+		if j-i <= 1 {
+			toolResults[i] = l.runToolCall(ctx, toolUses[i], toolHooks)
+			i++
 			continue
 		}
 
-		// Execute the tool with working directory set in context
-		toolCtx := ctx
-		if l.workingDir != "" {
-			toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
-		}
-		startTime := time.Now()
-		result := tool.Run(toolCtx, c.ToolInput)
-		endTime := time.Now()
-
-		var toolResultContent []llm.Content
-		if result.Error != nil {
-			l.logger.Error("tool execution failed", "name", c.ToolName, "error", result.Error)
-			toolResultContent = []llm.Content{
-				{Type: llm.ContentTypeText, Text: result.Error.Error()},
-			}
-		} else {
-			toolResultContent = result.LLMContent
-			l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
+		l.logger.Debug("executing tool calls in parallel", "count", j-i)
+		var eg errgroup.Group
+		eg.SetLimit(maxParallelToolCalls)
+		for k := i; k < j; k++ {
+			eg.Go(func() error {
+				toolResults[k] = l.runToolCall(ctx, toolUses[k], toolHooks)
+				return nil
+			})
 		}
-
-		toolResults = append(toolResults, llm.Content{
-			Type:             llm.ContentTypeToolResult,
-			ToolUseID:        c.ID,
-			ToolError:        result.Error != nil,
-			ToolResult:       toolResultContent,
-			ToolUseStartTime: &startTime,
-			ToolUseEndTime:   &endTime,
-			Display:          result.Display,
-		})
+		eg.Wait()
+		i = j
 	}
 
 	if len(toolResults) > 0 {
@@ -504,6 +611,117 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 	return nil
 }
 
+// findTool returns the tool with the given name, or nil if there is none.
+func (l *Loop) findTool(name string) *llm.Tool {
+	for _, t := range l.currentTools() {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// currentTools returns the tools available now.
+func (l *Loop) currentTools() []*llm.Tool {
+	if l.getTools != nil {
+		return l.getTools()
+	}
+	return l.tools
+}
+
+// isParallelTool reports whether calls to the named tool may run concurrently.
+func (l *Loop) isParallelTool(name string) bool {
+	tool := l.findTool(name)
+	return tool != nil && tool.Parallel
+}
+
+// currentWorkingDir returns the working directory tools are running in now.
+func (l *Loop) currentWorkingDir() string {
+	if l.getWorkingDir != nil {
+		return l.getWorkingDir()
+	}
+	return l.workingDir
+}
+
+// blockedToolResult is the tool_result for a call that was not run, with msg
+// explaining why.
+func blockedToolResult(c llm.Content, msg string) llm.Content {
+	return llm.Content{
+		Type:       llm.ContentTypeToolResult,
+		ToolUseID:  c.ID,
+		ToolError:  true,
+		ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: msg}},
+	}
+}
+
+// runToolCall executes a single tool_use block and returns its tool_result,
+// running toolHooks (which may be nil) before and after it.
+// It is safe to call concurrently for tools marked Parallel.
+func (l *Loop) runToolCall(ctx context.Context, c llm.Content, toolHooks *hooks.Config) llm.Content {
+	l.logger.Debug("executing tool", "name", c.ToolName, "id", c.ID)
+
+	tool := l.findTool(c.ToolName)
+	if tool == nil {
+		l.logger.Error("tool not found", "name", c.ToolName)
+		return llm.Content{
+			Type:      llm.ContentTypeToolResult,
+			ToolUseID: c.ID,
+			ToolError: true,
+			ToolResult: []llm.Content{
+				{Type: llm.ContentTypeText, Text: fmt.Sprintf("Tool '%s' not found", c.ToolName)},
+			},
+		}
+	}
+
+	hookDir := l.currentWorkingDir()
+	if veto := toolHooks.RunPre(ctx, hookDir, c.ToolName, c.ToolInput); veto != "" {
+		l.logger.Info("tool call vetoed by hook", "name", c.ToolName)
+		return blockedToolResult(c, veto)
+	}
+
+	// Execute the tool with working directory set in context
+	toolCtx := ctx
+	if l.workingDir != "" {
+		toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
+	}
+	startTime := time.Now()
+	result := tool.Run(toolCtx, c.ToolInput)
+	endTime := time.Now()
+
+	var toolResultContent []llm.Content
+	if result.Error != nil {
+		l.logger.Error("tool execution failed", "name", c.ToolName, "error", result.Error)
+		toolResultContent = []llm.Content{
+			{Type: llm.ContentTypeText, Text: result.Error.Error()},
+		}
+	} else {
+		toolResultContent = result.LLMContent
+		l.logger.Debug("tool executed successfully", "name", c.ToolName, "duration", endTime.Sub(startTime))
+	}
+
+	if toolHooks != nil {
+		var resultText []string
+		for _, rc := range toolResultContent {
+			if rc.Type == llm.ContentTypeText {
+				resultText = append(resultText, rc.Text)
+			}
+		}
+		for _, feedback := range toolHooks.RunPost(ctx, hookDir, c.ToolName, c.ToolInput, strings.Join(resultText, "\n"), result.Error != nil) {
+			toolResultContent = append(toolResultContent, llm.Content{Type: llm.ContentTypeText, Text: feedback})
+		}
+	}
+
+	return llm.Content{
+		Type:             llm.ContentTypeToolResult,
+		ToolUseID:        c.ID,
+		ToolError:        result.Error != nil,
+		ToolResult:       toolResultContent,
+		ToolUseStartTime: &startTime,
+		ToolUseEndTime:   &endTime,
+		Display:          result.Display,
+	}
+}
+
 // insertMissingToolResults fixes tool_result issues in the conversation history:
 //  1. Adds error results for tool_uses that were requested but not included in the next message.
 //     This can happen when a request is cancelled or fails after the LLM responds with tool_use
@@ -3,6 +3,7 @@ package loop
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +16,7 @@ import (
 
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/hooks"
 	"shelley.exe.dev/llm"
 )
 
@@ -1952,6 +1954,109 @@ func TestHandleToolCallsWithErrorTool(t *testing.T) {
 	}
 }
 
+func TestHandleToolCallsWithHooks(t *testing.T) {
+	var recordedMessages []llm.Message
+	recordFunc := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		recordedMessages = append(recordedMessages, message)
+		return nil
+	}
+
+	var removed bool
+	tools := []*llm.Tool{
+		{
+			Name:        "greet",
+			InputSchema: llm.MustSchema(`{"type": "object", "properties": {}}`),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				return llm.ToolOut{LLMContent: llm.TextContent("hello")}
+			},
+		},
+		{
+			Name:        "remove",
+			InputSchema: llm.MustSchema(`{"type": "object", "properties": {}}`),
+			Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+				removed = true
+				return llm.ToolOut{LLMContent: llm.TextContent("removed")}
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	var hooksDir string
+	loop := NewLoop(Config{
+		LLM:           NewPredictableService(),
+		Tools:         tools,
+		RecordMessage: recordFunc,
+		WorkingDir:    dir,
+		LoadHooks: func(workingDir string) (*hooks.Config, error) {
+			hooksDir = workingDir
+			return &hooks.Config{
+				PreToolUse: []hooks.Hook{
+					{Tools: []string{"remove"}, Command: "echo removing is not allowed; exit 1"},
+				},
+				PostToolUse: []hooks.Hook{
+					{Command: `grep -o '"tool_result":"[a-z]*"'`},
+				},
+			}, nil
+		},
+	})
+
+	content := []llm.Content{
+		{ID: "greet_1", Type: llm.ContentTypeToolUse, ToolName: "greet", ToolInput: json.RawMessage(`{}`)},
+		{ID: "remove_1", Type: llm.ContentTypeToolUse, ToolName: "remove", ToolInput: json.RawMessage(`{}`)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := loop.handleToolCalls(ctx, content); err != nil {
+		t.Fatalf("handleToolCalls failed: %v", err)
+	}
+	if hooksDir != dir {
+		t.Errorf("expected hooks to be loaded for %s, got %s", dir, hooksDir)
+	}
+	if removed {
+		t.Error("expected the pre hook to veto the remove call")
+	}
+
+	results := recordedMessages[0].Content
+	if len(results) != 2 {
+		t.Fatalf("expected 2 tool results, got %d", len(results))
+	}
+
+	// The post hook saw the result on stdin and its output was appended.
+	greet := results[0]
+	if greet.ToolError || len(greet.ToolResult) != 2 || greet.ToolResult[0].Text != "hello" {
+		t.Fatalf("unexpected greet result: %+v", greet)
+	}
+	if !strings.Contains(greet.ToolResult[1].Text, `"tool_result":"hello"`) {
+		t.Errorf("expected post hook feedback, got %q", greet.ToolResult[1].Text)
+	}
+
+	remove := results[1]
+	if !remove.ToolError || !strings.Contains(remove.ToolResult[0].Text, "removing is not allowed") {
+		t.Errorf("expected a veto with the hook's message, got %+v", remove)
+	}
+	if len(remove.ToolResult) != 1 {
+		t.Errorf("expected no post hook to run for a vetoed call, got %+v", remove.ToolResult)
+	}
+
+	// Hooks that cannot be loaded block every call.
+	recordedMessages = nil
+	loop.loadHooks = func(workingDir string) (*hooks.Config, error) {
+		return nil, errors.New("parsing .shelley/hooks.json: unexpected end of JSON input")
+	}
+	content = content[1:]
+	if err := loop.handleToolCalls(ctx, content); err != nil {
+		t.Fatalf("handleToolCalls failed: %v", err)
+	}
+	blocked := recordedMessages[0].Content[0]
+	if !blocked.ToolError || !strings.Contains(blocked.ToolResult[0].Text, "unexpected end of JSON input") {
+		t.Errorf("expected the load error as the tool result, got %+v", blocked)
+	}
+	if removed {
+		t.Error("expected no tool to run when hooks cannot be loaded")
+	}
+}
+
 func TestMaxTokensTruncation(t *testing.T) {
 	var mu sync.Mutex
 	var recordedMessages []llm.Message
@@ -2136,3 +2241,223 @@ func TestMaxTokensTruncation(t *testing.T) {
 //		t.Error("expected to find tool2 result in message 3")
 //	}
 //}
+
+func TestHandleToolCallsParallel(t *testing.T) {
+	var recordedMessages []llm.Message
+	recordFunc := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		recordedMessages = append(recordedMessages, message)
+		return nil
+	}
+
+	// Each call to the parallel tool waits until both calls have started,
+	// so the test deadlocks (and times out) if they run sequentially.
+	var started sync.WaitGroup
+	started.Add(2)
+	parallelTool := &llm.Tool{
+		Name:        "parallel_tool",
+		InputSchema: llm.EmptySchema(),
+		Parallel:    true,
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			started.Done()
+			done := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return llm.ErrorToolOut(ctx.Err())
+			}
+			var in struct{ N int }
+			json.Unmarshal(input, &in)
+			return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("parallel %d", in.N))}
+		},
+	}
+
+	var serialRan bool
+	serialTool := &llm.Tool{
+		Name:        "serial_tool",
+		InputSchema: llm.EmptySchema(),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			serialRan = true
+			return llm.ToolOut{LLMContent: llm.TextContent("serial")}
+		},
+	}
+
+	loop := NewLoop(Config{
+		LLM:           NewPredictableService(),
+		History:       []llm.Message{},
+		Tools:         []*llm.Tool{parallelTool, serialTool},
+		RecordMessage: recordFunc,
+	})
+
+	content := []llm.Content{
+		{ID: "call_1", Type: llm.ContentTypeToolUse, ToolName: "parallel_tool", ToolInput: json.RawMessage(`{"N": 1}`)},
+		{ID: "call_2", Type: llm.ContentTypeToolUse, ToolName: "parallel_tool", ToolInput: json.RawMessage(`{"N": 2}`)},
+		{ID: "call_3", Type: llm.ContentTypeToolUse, ToolName: "serial_tool", ToolInput: json.RawMessage(`{}`)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := loop.handleToolCalls(ctx, content); err != nil {
+		t.Fatalf("handleToolCalls failed: %v", err)
+	}
+
+	if len(recordedMessages) < 1 {
+		t.Fatalf("expected at least 1 recorded message, got %d", len(recordedMessages))
+	}
+	if !serialRan {
+		t.Error("expected serial tool to run")
+	}
+
+	results := recordedMessages[0].Content
+	if len(results) != 3 {
+		t.Fatalf("expected 3 tool results, got %d", len(results))
+	}
+	want := []struct{ id, text string }{
+		{"call_1", "parallel 1"},
+		{"call_2", "parallel 2"},
+		{"call_3", "serial"},
+	}
+	for i, w := range want {
+		if results[i].ToolUseID != w.id {
+			t.Errorf("result %d: expected tool use ID %q, got %q", i, w.id, results[i].ToolUseID)
+		}
+		if results[i].ToolError {
+			t.Errorf("result %d: unexpected tool error: %v", i, results[i].ToolResult)
+			continue
+		}
+		if got := results[i].ToolResult[0].Text; got != w.text {
+			t.Errorf("result %d: expected %q, got %q", i, w.text, got)
+		}
+	}
+}
+
+func TestCompactHistory(t *testing.T) {
+	userText := func(text string) llm.Message {
+		return llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}}}
+	}
+	summary := userText("summary of earlier turns")
+
+	var seen [][]llm.Message
+	service := NewPredictableService()
+	loop := NewLoop(Config{
+		LLM:           service,
+		History:       []llm.Message{userText("old question"), {Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "old answer"}}}},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+		CompactHistory: func(ctx context.Context, history []llm.Message) ([]llm.Message, error) {
+			seen = append(seen, history)
+			if len(seen) > 1 {
+				return nil, nil
+			}
+			return append([]llm.Message{summary}, history[len(history)-1]), nil
+		},
+	})
+
+	loop.QueueUserMessage(userText("echo: first"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	req := service.GetLastRequest()
+	if len(req.Messages) != 2 || req.Messages[0].Content[0].Text != summary.Content[0].Text {
+		t.Fatalf("expected the compacted history to be sent, got %+v", req.Messages)
+	}
+
+	loop.QueueUserMessage(userText("echo: second"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(seen[1]); got != 4 {
+		t.Errorf("expected the compacted history to be kept, got %d messages", got)
+	}
+	if got := len(service.GetLastRequest().Messages); got != 4 {
+		t.Errorf("expected 4 messages when not compacting, got %d", got)
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	var recorded []llm.Message
+	overBudget := false
+	service := NewPredictableService()
+	loop := NewLoop(Config{
+		LLM: service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			recorded = append(recorded, message)
+			return nil
+		},
+		CheckBudget: func(ctx context.Context) error {
+			if overBudget {
+				return errors.New("budget reached")
+			}
+			return nil
+		},
+	})
+
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: first"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	requests := len(service.GetRecentRequests())
+
+	overBudget = true
+	recorded = nil
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: second"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err == nil {
+		t.Fatal("expected an error when over budget")
+	}
+	if got := len(service.GetRecentRequests()); got != requests {
+		t.Errorf("expected no LLM request when over budget, got %d more", got-requests)
+	}
+	if len(recorded) != 1 || recorded[0].ErrorType != llm.ErrorTypeBudget || !recorded[0].EndOfTurn || recorded[0].Content[0].Text != "budget reached" {
+		t.Errorf("expected an end-of-turn budget error message, got %+v", recorded)
+	}
+}
+
+func TestStreamDeltas(t *testing.T) {
+	var text strings.Builder
+	loop := NewLoop(Config{
+		LLM: NewPredictableService(),
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			return nil
+		},
+		OnStreamDelta: func(delta llm.StreamDelta) {
+			if delta.Type == llm.ContentTypeText {
+				text.WriteString(delta.Text)
+			}
+		},
+	})
+
+	loop.QueueUserMessage(llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: streamed reply"}}})
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := text.String(); got != "streamed reply" {
+		t.Errorf("expected the reply to be streamed, got %q", got)
+	}
+}
+
+func TestOnTurnStart(t *testing.T) {
+	var turns int
+	var requestsAtStart int
+	service := NewPredictableService()
+	loop := NewLoop(Config{
+		LLM:           service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+		OnTurnStart: func(ctx context.Context) {
+			turns++
+			requestsAtStart = len(service.GetRecentRequests())
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	loop.QueueUserMessage(llm.UserStringMessage("echo: hi"))
+	if err := loop.ProcessOneTurn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if turns != 1 || requestsAtStart != 0 {
+		t.Errorf("expected one turn start before the LLM request, got %d turns after %d requests", turns, requestsAtStart)
+	}
+}
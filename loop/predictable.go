@@ -59,6 +59,29 @@ func (s *PredictableService) MaxImageDimension() int {
 	return 2000
 }
 
+// DoStream is like Do, but replays the response's thinking and text to onDelta
+// word by word before returning it.
+func (s *PredictableService) DoStream(ctx context.Context, req *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	resp, err := s.Do(ctx, req)
+	if err != nil || onDelta == nil {
+		return resp, err
+	}
+	for _, c := range resp.Content {
+		text := c.Text
+		if c.Type == llm.ContentTypeThinking {
+			text = c.Thinking
+		} else if c.Type != llm.ContentTypeText {
+			continue
+		}
+		for _, word := range strings.SplitAfter(text, " ") {
+			if word != "" {
+				onDelta(llm.StreamDelta{Type: c.Type, Text: word})
+			}
+		}
+	}
+	return resp, nil
+}
+
 // Do processes a request and returns a predictable response based on the input text
 func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
 	// Store request for testing inspection
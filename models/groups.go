@@ -0,0 +1,550 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/llmhttp"
+)
+
+// Defaults for a group's circuit breaker.
+const (
+	defaultGroupFailureThreshold = 3
+	defaultGroupCooldown         = time.Minute
+)
+
+// GroupConfig configures a model group, which is offered like a model under
+// its ID. Requests go to the group's first model, failing over to the next
+// when one is rate limited, overloaded or unreachable.
+type GroupConfig struct {
+	// ID identifies the group in the model picker and in default_model.
+	ID string `json:"id"`
+
+	// DisplayName is shown in the model picker (optional, defaults to ID)
+	DisplayName string `json:"display_name,omitempty"`
+
+	// Models are tried in order.
+	Models []GroupMember `json:"models"`
+
+	// FailureThreshold is how many consecutive failures take an endpoint out
+	// of rotation (optional, defaults to 3)
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+
+	// Cooldown is how long an endpoint stays out of rotation before it is
+	// tried again, e.g. "30s" (optional, defaults to one minute)
+	Cooldown string `json:"cooldown,omitempty"`
+}
+
+// GroupMember is a built-in or custom model in a group. In shelley.json a
+// member may be just the model ID.
+type GroupMember struct {
+	Model string `json:"model"`
+
+	// Endpoints, if set, replace the model's API key or gateway with several,
+	// chosen by weighted round-robin.
+	Endpoints []GroupEndpoint `json:"endpoints,omitempty"`
+}
+
+// GroupEndpoint is an API key and gateway or URL a member's requests can go to.
+type GroupEndpoint struct {
+	// Name identifies the endpoint in logs and recorded LLM requests (optional)
+	Name string `json:"name,omitempty"`
+
+	// APIKey, or the environment variable APIKeyEnv names, is the API key.
+	APIKey    string `json:"api_key,omitempty"`
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+
+	// Gateway is the base URL of an LLM gateway, for built-in models.
+	Gateway string `json:"gateway,omitempty"`
+
+	// URL is the API endpoint, for custom models.
+	URL string `json:"url,omitempty"`
+
+	// Weight is the endpoint's share of requests relative to the member's
+	// other endpoints (optional, defaults to 1)
+	Weight int `json:"weight,omitempty"`
+}
+
+// UnmarshalJSON accepts a model ID as well as an object.
+func (m *GroupMember) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*m = GroupMember{Model: id}
+		return nil
+	}
+	type member GroupMember
+	return json.Unmarshal(data, (*member)(m))
+}
+
+// Validate checks that c names a group and its models.
+func (c GroupConfig) Validate() error {
+	if c.ID == "" {
+		return errors.New("model group config: id is required")
+	}
+	if len(c.Models) == 0 {
+		return fmt.Errorf("model group %q: models is required", c.ID)
+	}
+	for _, member := range c.Models {
+		if member.Model == "" {
+			return fmt.Errorf("model group %q: every member needs a model", c.ID)
+		}
+		for _, e := range member.Endpoints {
+			if e.Gateway != "" && e.URL != "" {
+				return fmt.Errorf("model group %q: endpoint for %s sets both gateway and url", c.ID, member.Model)
+			}
+			if e.Weight < 0 {
+				return fmt.Errorf("model group %q: endpoint for %s has a negative weight", c.ID, member.Model)
+			}
+		}
+	}
+	if c.FailureThreshold < 0 {
+		return fmt.Errorf("model group %q: failure_threshold must not be negative", c.ID)
+	}
+	if c.Cooldown != "" {
+		if _, err := time.ParseDuration(c.Cooldown); err != nil {
+			return fmt.Errorf("model group %q: invalid cooldown: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// ParseGroupConfigs parses and validates a JSON array of GroupConfig.
+func ParseGroupConfigs(data string) ([]GroupConfig, error) {
+	var configs []GroupConfig
+	if err := json.Unmarshal([]byte(data), &configs); err != nil {
+		return nil, fmt.Errorf("invalid model group config: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("model group %q configured more than once", c.ID)
+		}
+		seen[c.ID] = true
+	}
+	return configs, nil
+}
+
+// apiKey returns the endpoint's API key, or "" to keep the model's own.
+func (e GroupEndpoint) apiKey() string {
+	if e.APIKey != "" {
+		return e.APIKey
+	}
+	if e.APIKeyEnv != "" {
+		return os.Getenv(e.APIKeyEnv)
+	}
+	return ""
+}
+
+// backend names the i'th endpoint of a model for logs and llm_requests,
+// without revealing its API key.
+func (e GroupEndpoint) backend(modelID string, i int) string {
+	var name string
+	switch {
+	case e.Name != "":
+		name = e.Name
+	case e.APIKeyEnv != "":
+		name = "$" + e.APIKeyEnv
+	case e.Gateway != "":
+		name = e.Gateway
+	case e.URL != "":
+		name = e.URL
+	default:
+		name = fmt.Sprintf("endpoint %d", i+1)
+	}
+	return fmt.Sprintf("%s (%s)", modelID, name)
+}
+
+// groupEndpoint is a service a group can send a request to, with its
+// round-robin and circuit breaker state.
+type groupEndpoint struct {
+	backend  string
+	provider Provider
+	service  llm.Service
+	weight   int
+
+	// Guarded by groupService.mu.
+	current   int       // smooth weighted round-robin counter
+	failures  int       // consecutive failures
+	openUntil time.Time // the endpoint sits out until then
+}
+
+// groupService is an llm.Service that sends each request to the first healthy
+// endpoint of a group's models, failing over to the others in turn.
+type groupService struct {
+	id        string
+	logger    *slog.Logger
+	members   [][]*groupEndpoint // endpoints of each model, in order
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu sync.Mutex
+}
+
+var _ llm.StreamingService = (*groupService)(nil)
+
+func newGroupService(cfg GroupConfig, members [][]*groupEndpoint, logger *slog.Logger) *groupService {
+	cooldown := defaultGroupCooldown
+	if d, err := time.ParseDuration(cfg.Cooldown); err == nil && d > 0 {
+		cooldown = d
+	}
+	for _, endpoints := range members {
+		for _, ep := range endpoints {
+			ep.weight = max(ep.weight, 1)
+		}
+	}
+	return &groupService{
+		id:        cfg.ID,
+		logger:    cmp.Or(logger, slog.Default()),
+		members:   members,
+		threshold: cmp.Or(cfg.FailureThreshold, defaultGroupFailureThreshold),
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// plan returns the endpoints to try for a request, in order: for each model,
+// its healthy endpoints, starting with the one weighted round-robin picks.
+// If every endpoint is sitting out, it returns them all rather than none.
+func (g *groupService) plan() []*groupEndpoint {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	var plan []*groupEndpoint
+	for _, endpoints := range g.members {
+		var healthy []*groupEndpoint
+		for _, ep := range endpoints {
+			if ep.failures < g.threshold || !now.Before(ep.openUntil) {
+				healthy = append(healthy, ep)
+			}
+		}
+		if len(healthy) == 0 {
+			continue
+		}
+		// Smooth weighted round-robin, as in nginx: each endpoint gains its
+		// weight, and the one with the most is picked and pays the total.
+		total := 0
+		picked := healthy[0]
+		for _, ep := range healthy {
+			ep.current += ep.weight
+			total += ep.weight
+			if ep.current > picked.current {
+				picked = ep
+			}
+		}
+		picked.current -= total
+		plan = append(plan, picked)
+		for _, ep := range healthy {
+			if ep != picked {
+				plan = append(plan, ep)
+			}
+		}
+	}
+	if len(plan) == 0 {
+		for _, endpoints := range g.members {
+			plan = append(plan, endpoints...)
+		}
+	}
+	return plan
+}
+
+// record updates an endpoint's circuit breaker after a request. After
+// threshold consecutive failures, the endpoint sits out for the cooldown;
+// a failure when it is tried again sends it back out.
+func (g *groupService) record(ep *groupEndpoint, failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !failed {
+		ep.failures = 0
+		return
+	}
+	ep.failures++
+	if ep.failures >= g.threshold {
+		ep.openUntil = g.now().Add(g.cooldown)
+		g.logger.Warn("Model group endpoint is sitting out", "group", g.id, "backend", ep.backend, "failures", ep.failures, "until", ep.openUntil)
+	}
+}
+
+// Do sends the request to the group's models in turn until one succeeds.
+func (g *groupService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	return g.DoStream(ctx, request, nil)
+}
+
+// DoStream is like Do, but streams deltas to onDelta. If a model fails after
+// streaming, a restart delta is sent before the next one is tried.
+func (g *groupService) DoStream(ctx context.Context, request *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
+	var streamed bool
+	var deltas func(llm.StreamDelta)
+	if onDelta != nil {
+		deltas = func(d llm.StreamDelta) {
+			streamed = true
+			onDelta(d)
+		}
+	}
+
+	plan := g.plan()
+	var errs error
+	for i, ep := range plan {
+		if streamed {
+			onDelta(llm.StreamDelta{Restart: true})
+			streamed = false
+		}
+		attemptCtx := llmhttp.WithProvider(ctx, string(ep.provider))
+		attemptCtx = llmhttp.WithBackend(attemptCtx, ep.backend)
+		// Fail over rather than wait for the service's own retries, except
+		// on the last endpoint, which has nothing to fail over to.
+		if i < len(plan)-1 {
+			attemptCtx = llm.WithoutRetries(attemptCtx)
+		}
+
+		response, err := llm.DoStream(attemptCtx, ep.service, request, deltas)
+		if err == nil {
+			g.record(ep, false)
+			return response, nil
+		}
+		if ctx.Err() != nil || !isFailoverError(err) {
+			return nil, err
+		}
+		g.record(ep, true)
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", ep.backend, err))
+		if i < len(plan)-1 {
+			g.logger.Warn("Model group failing over", "group", g.id, "backend", ep.backend, "next", plan[i+1].backend, "error", err)
+		}
+	}
+	return nil, fmt.Errorf("every model in group %s failed: %w", g.id, errs)
+}
+
+// TokenContextWindow returns the smallest context window of the group's
+// models, since a conversation may move between them.
+func (g *groupService) TokenContextWindow() int {
+	window := 0
+	for _, endpoints := range g.members {
+		if w := endpoints[0].service.TokenContextWindow(); window == 0 || w < window {
+			window = w
+		}
+	}
+	return window
+}
+
+// MaxImageDimension returns the strictest image limit of the group's models.
+func (g *groupService) MaxImageDimension() int {
+	dimension := 0
+	for _, endpoints := range g.members {
+		if d := endpoints[0].service.MaxImageDimension(); d > 0 && (dimension == 0 || d < dimension) {
+			dimension = d
+		}
+	}
+	return dimension
+}
+
+// UseSimplifiedPatch reports whether any of the group's models needs the
+// simplified patch tool.
+func (g *groupService) UseSimplifiedPatch() bool {
+	for _, endpoints := range g.members {
+		if llm.UseSimplifiedPatch(endpoints[0].service) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigDetails returns the group's backends for logging.
+func (g *groupService) ConfigDetails() map[string]string {
+	var backends []string
+	for _, endpoints := range g.members {
+		for _, ep := range endpoints {
+			backends = append(backends, ep.backend)
+		}
+	}
+	return map[string]string{
+		"group":    g.id,
+		"backends": strings.Join(backends, ", "),
+	}
+}
+
+// statusCodePattern finds HTTP status codes in the errors LLM services return,
+// like "status 529 (url=...)" or "HTTP status: 503, ...".
+var statusCodePattern = regexp.MustCompile(`status:? (\d{3})\b`)
+
+// isFailoverError reports whether another backend might succeed where err
+// failed: a rate limit, an overloaded or failing server, or a network error.
+// Errors in the request itself, which any backend would reject, are not.
+func isFailoverError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	msg := err.Error()
+	if strings.Contains(strings.ToLower(msg), "overloaded") {
+		return true
+	}
+	for _, match := range statusCodePattern.FindAllStringSubmatch(msg, -1) {
+		code, _ := strconv.Atoi(match[1])
+		if code == 429 || code >= 500 {
+			return true
+		}
+	}
+	for _, pattern := range []string{
+		"EOF",
+		"connection reset",
+		"connection refused",
+		"no such host",
+		"network is unreachable",
+		"i/o timeout",
+		"TLS handshake timeout",
+	} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadModelGroups adds the groups in m.cfg, after built-in and custom models.
+// m.mu must be held.
+func (m *Manager) loadModelGroups() {
+	for _, cfg := range m.cfg.Groups {
+		if _, exists := m.services[cfg.ID]; exists {
+			m.warn("Skipping model group with the ID of a model", "group", cfg.ID)
+			continue
+		}
+		var members [][]*groupEndpoint
+		var provider Provider
+		for _, member := range cfg.Models {
+			endpoints, memberProvider := m.groupEndpoints(cfg.ID, member)
+			if len(endpoints) == 0 {
+				continue
+			}
+			if provider == "" {
+				provider = memberProvider
+			}
+			members = append(members, endpoints)
+		}
+		if len(members) == 0 {
+			m.warn("Skipping model group with no available models", "group", cfg.ID)
+			continue
+		}
+
+		m.services[cfg.ID] = serviceEntry{
+			service:     newGroupService(cfg, members, m.logger),
+			provider:    provider,
+			modelID:     cfg.ID,
+			source:      string(SourceGroup),
+			displayName: cmp.Or(cfg.DisplayName, cfg.ID),
+		}
+		m.modelOrder = append(m.modelOrder, cfg.ID)
+	}
+}
+
+// groupEndpoints creates the endpoints of a group member, and returns them
+// with the member's provider. A member without endpoints of its own uses the
+// model's service. m.mu must be held.
+func (m *Manager) groupEndpoints(groupID string, member GroupMember) ([]*groupEndpoint, Provider) {
+	entry, ok := m.services[member.Model]
+	if ok && entry.source == string(SourceGroup) {
+		m.warn("Skipping model group member that is a group", "group", groupID, "model", member.Model)
+		return nil, ""
+	}
+	if len(member.Endpoints) == 0 {
+		if !ok {
+			m.warn("Skipping unavailable model group member", "group", groupID, "model", member.Model)
+			return nil, ""
+		}
+		return []*groupEndpoint{{backend: member.Model, provider: entry.provider, service: entry.service, weight: 1}}, entry.provider
+	}
+
+	var endpoints []*groupEndpoint
+	var provider Provider
+	for i, e := range member.Endpoints {
+		var svc llm.Service
+		var err error
+		if model := ByID(member.Model); model != nil {
+			provider = model.Provider
+			svc, err = m.builtInEndpointService(model, e)
+		} else {
+			provider, svc, err = m.customEndpointService(member.Model, e)
+		}
+		backend := e.backend(member.Model, i)
+		if err != nil {
+			m.warn("Skipping model group endpoint", "group", groupID, "backend", backend, "error", err)
+			continue
+		}
+		endpoints = append(endpoints, &groupEndpoint{backend: backend, provider: provider, service: svc, weight: e.Weight})
+	}
+	return endpoints, provider
+}
+
+// builtInEndpointService creates a service for a built-in model with an
+// endpoint's API key and gateway.
+func (m *Manager) builtInEndpointService(model *Model, e GroupEndpoint) (llm.Service, error) {
+	if e.URL != "" {
+		return nil, fmt.Errorf("url is for custom models; use gateway for %s", model.ID)
+	}
+	cfg := *m.cfg
+	key := e.apiKey()
+	if e.Gateway != "" {
+		cfg.Gateway = strings.TrimSuffix(e.Gateway, "/")
+		if key == "" {
+			key = "implicit"
+		}
+	}
+	if key != "" {
+		switch model.Provider {
+		case ProviderAnthropic:
+			cfg.AnthropicAPIKey = key
+		case ProviderOpenAI:
+			cfg.OpenAIAPIKey = key
+		case ProviderGemini:
+			cfg.GeminiAPIKey = key
+		case ProviderFireworks:
+			cfg.FireworksAPIKey = key
+		}
+	}
+	return model.Factory(&cfg, m.httpc)
+}
+
+// customEndpointService creates a service for a custom model with an
+// endpoint's API key and URL.
+func (m *Manager) customEndpointService(modelID string, e GroupEndpoint) (Provider, llm.Service, error) {
+	if m.db == nil {
+		return "", nil, fmt.Errorf("unknown model: %s", modelID)
+	}
+	model, err := m.db.GetModel(context.Background(), modelID)
+	if err != nil {
+		return "", nil, fmt.Errorf("unknown model %s: %w", modelID, err)
+	}
+	if e.Gateway != "" {
+		return "", nil, fmt.Errorf("gateway is for built-in models; use url for %s", modelID)
+	}
+	if key := e.apiKey(); key != "" {
+		model.ApiKey = key
+	}
+	if e.URL != "" {
+		model.Endpoint = e.URL
+	}
+	svc := m.createServiceFromModel(model)
+	if svc == nil {
+		return "", nil, fmt.Errorf("unknown provider type %q", model.ProviderType)
+	}
+	return Provider(model.ProviderType), svc, nil
+}
+
+func (m *Manager) warn(msg string, args ...any) {
+	if m.logger != nil {
+		m.logger.Warn(msg, args...)
+	}
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/llmhttp"
+)
+
+// flakyLLMService implements llm.Service, failing with err if it is set.
+type flakyLLMService struct {
+	mockLLMService
+	name            string
+	err             error
+	calls           int
+	backend         string
+	retriesDisabled bool
+}
+
+func (f *flakyLLMService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	f.calls++
+	f.backend = llmhttp.BackendFromContext(ctx)
+	f.retriesDisabled = llm.RetriesDisabled(ctx)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &llm.Response{Content: llm.TextContent(f.name)}, nil
+}
+
+func testGroup(cfg GroupConfig, members ...[]*flakyLLMService) *groupService {
+	var endpoints [][]*groupEndpoint
+	for _, services := range members {
+		var eps []*groupEndpoint
+		for _, svc := range services {
+			eps = append(eps, &groupEndpoint{backend: svc.name, provider: ProviderBuiltIn, service: svc, weight: 1})
+		}
+		endpoints = append(endpoints, eps)
+	}
+	return newGroupService(cfg, endpoints, nil)
+}
+
+// doText sends a request to the group and returns the answering service's name.
+func doText(t *testing.T, group *groupService) string {
+	t.Helper()
+	resp, err := group.Do(context.Background(), &llm.Request{})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	return resp.Content[0].Text
+}
+
+func TestParseGroupConfigs(t *testing.T) {
+	groups, err := ParseGroupConfigs(`[{
+		"id": "claude",
+		"models": [
+			{"model": "claude-opus-4.6", "endpoints": [
+				{"api_key_env": "ANTHROPIC_KEY_A", "weight": 2},
+				{"gateway": "https://gateway.example"}
+			]},
+			"claude-sonnet-4.5"
+		],
+		"cooldown": "30s"
+	}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Models) != 2 {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+	if m := groups[0].Models[1]; m.Model != "claude-sonnet-4.5" || m.Endpoints != nil {
+		t.Errorf("expected a bare model ID to parse as a member, got %+v", m)
+	}
+	if eps := groups[0].Models[0].Endpoints; len(eps) != 2 || eps[0].Weight != 2 {
+		t.Errorf("unexpected endpoints: %+v", eps)
+	}
+	if got := groups[0].Models[0].Endpoints[0].backend("claude-opus-4.6", 0); got != "claude-opus-4.6 ($ANTHROPIC_KEY_A)" {
+		t.Errorf("unexpected backend name %q", got)
+	}
+
+	for _, bad := range []string{
+		`[{"models": ["predictable"]}]`,
+		`[{"id": "g"}]`,
+		`[{"id": "g", "models": ["predictable"], "cooldown": "soon"}]`,
+		`[{"id": "g", "models": [{"model": "x", "endpoints": [{"gateway": "a", "url": "b"}]}]}]`,
+		`[{"id": "g", "models": ["predictable"]}, {"id": "g", "models": ["predictable"]}]`,
+	} {
+		if _, err := ParseGroupConfigs(bad); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestGroupServiceFailover(t *testing.T) {
+	primary := &flakyLLMService{name: "primary", err: errors.New(`status 529 (url=x): {"type":"overloaded_error"}`)}
+	fallback := &flakyLLMService{name: "fallback"}
+	group := testGroup(GroupConfig{ID: "g"}, []*flakyLLMService{primary}, []*flakyLLMService{fallback})
+
+	if got := doText(t, group); got != "fallback" {
+		t.Errorf("expected the fallback to answer, got %q", got)
+	}
+	if !primary.retriesDisabled || fallback.retriesDisabled {
+		t.Error("expected retries to be disabled for all but the last backend")
+	}
+	if fallback.backend != "fallback" {
+		t.Errorf("expected the backend in the context, got %q", fallback.backend)
+	}
+
+	// Errors in the request aren't worth failing over for.
+	primary.err = errors.New("status 400 Bad Request (url=x): invalid request")
+	fallback.calls = 0
+	if _, err := group.Do(context.Background(), &llm.Request{}); err == nil || fallback.calls != 0 {
+		t.Errorf("expected a bad request to fail without failover, got %v after %d fallback calls", err, fallback.calls)
+	}
+
+	fallback.err = errors.New("gemini: API error: HTTP status: 503, unavailable")
+	primary.err = errors.New("status 429 (rate limited, url=x)")
+	_, err := group.Do(context.Background(), &llm.Request{})
+	if err == nil || !strings.Contains(err.Error(), "every model in group g failed") {
+		t.Errorf("expected every model to fail, got %v", err)
+	}
+}
+
+func TestGroupServiceWeightedRoundRobin(t *testing.T) {
+	a := &flakyLLMService{name: "a"}
+	b := &flakyLLMService{name: "b"}
+	group := testGroup(GroupConfig{ID: "g"}, []*flakyLLMService{a, b})
+	group.members[0][0].weight = 2
+
+	counts := map[string]int{}
+	for range 6 {
+		counts[doText(t, group)]++
+	}
+	if counts["a"] != 4 || counts["b"] != 2 {
+		t.Errorf("expected a 2:1 split, got %v", counts)
+	}
+}
+
+func TestGroupServiceCircuitBreaker(t *testing.T) {
+	bad := &flakyLLMService{name: "bad", err: errors.New("dial tcp: connection refused")}
+	good := &flakyLLMService{name: "good"}
+	group := testGroup(GroupConfig{ID: "g", FailureThreshold: 2, Cooldown: "1m"}, []*flakyLLMService{bad}, []*flakyLLMService{good})
+	now := time.Now()
+	group.now = func() time.Time { return now }
+
+	for range 3 {
+		if got := doText(t, group); got != "good" {
+			t.Fatalf("expected the healthy backend to answer, got %q", got)
+		}
+	}
+	if bad.calls != 2 {
+		t.Errorf("expected the failing backend to sit out after 2 failures, got %d calls", bad.calls)
+	}
+
+	// After the cooldown, the backend is tried again.
+	now = now.Add(2 * time.Minute)
+	bad.err = nil
+	if got := doText(t, group); got != "bad" {
+		t.Errorf("expected the recovered backend to answer, got %q", got)
+	}
+}
+
+func TestManagerModelGroups(t *testing.T) {
+	manager, err := NewManager(&Config{Groups: []GroupConfig{
+		{ID: "fast", DisplayName: "Fast", Models: []GroupMember{{Model: "claude-opus-4.6"}, {Model: "predictable"}}},
+		{ID: "unavailable", Models: []GroupMember{{Model: "claude-opus-4.6"}}},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	info := manager.GetModelInfo("fast")
+	if info == nil || info.DisplayName != "Fast" || info.Source != string(SourceGroup) {
+		t.Fatalf("unexpected group info: %+v", info)
+	}
+	if manager.HasModel("unavailable") {
+		t.Error("a group without available models should be skipped")
+	}
+	models := manager.GetAvailableModels()
+	if models[len(models)-1] != "fast" {
+		t.Errorf("expected the group after the models, got %v", models)
+	}
+	svc, err := manager.GetService("fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.TokenContextWindow() == 0 {
+		t.Error("expected the group to report its model's context window")
+	}
+
+	if err := manager.Reconfigure(&Config{AnthropicAPIKey: "test-key", Groups: []GroupConfig{
+		{ID: "unavailable", Models: []GroupMember{{Model: "claude-opus-4.6", Endpoints: []GroupEndpoint{{APIKey: "a"}, {APIKey: "b"}}}}},
+	}}); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if manager.HasModel("fast") || !manager.HasModel("unavailable") {
+		t.Errorf("expected Reconfigure to replace the groups, got %v", manager.GetAvailableModels())
+	}
+}
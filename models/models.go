@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"shelley.exe.dev/db"
@@ -35,6 +36,7 @@ const (
 	SourceGateway ModelSource = "exe.dev gateway"
 	SourceEnvVar  ModelSource = "env"    // Will be combined with env var name
 	SourceCustom  ModelSource = "custom" // User-configured custom model
+	SourceGroup   ModelSource = "model group"
 )
 
 // Model represents a configured LLM model in Shelley
@@ -115,6 +117,9 @@ type Config struct {
 	// If set, model-specific suffixes will be appended
 	Gateway string
 
+	// Groups are model groups from shelley.json (optional)
+	Groups []GroupConfig
+
 	Logger *slog.Logger
 
 	// Database for recording LLM requests (optional)
@@ -398,10 +403,11 @@ func Default() Model {
 	return All()[0] // claude-opus-4.6
 }
 
-// Manager manages LLM services for all configured models
+// Manager manages LLM services for all configured models and model groups
 type Manager struct {
+	mu         sync.RWMutex // guards services, modelOrder and cfg
 	services   map[string]serviceEntry
-	modelOrder []string // ordered list of model IDs (built-in first, then custom)
+	modelOrder []string // ordered list of model IDs (built-in first, then custom, then groups)
 	logger     *slog.Logger
 	db         *db.DB       // for custom models and LLM request recording
 	httpc      *http.Client // HTTP client with recording middleware
@@ -434,6 +440,11 @@ type loggingService struct {
 
 // Do wraps the underlying service's Do method with logging and database recording
 func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	return l.DoStream(ctx, request, nil)
+}
+
+// DoStream is like Do, but streams deltas to onDelta if the underlying service supports it
+func (l *loggingService) DoStream(ctx context.Context, request *llm.Request, onDelta func(llm.StreamDelta)) (*llm.Response, error) {
 	start := time.Now()
 
 	// Add model ID and provider to context for the HTTP transport
@@ -441,7 +452,7 @@ func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Res
 	ctx = llmhttp.WithProvider(ctx, string(l.provider))
 
 	// Call the underlying service
-	response, err := l.service.Do(ctx, request)
+	response, err := llm.DoStream(ctx, l.service, request, onDelta)
 
 	duration := time.Since(start)
 	durationSeconds := duration.Seconds()
@@ -463,6 +474,11 @@ func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Res
 		logAttrs = append(logAttrs, "error", err)
 		l.logger.Error("LLM request failed", logAttrs...)
 	} else {
+		// Record which model served the request, for usage reports.
+		if response.Usage.ModelID == "" {
+			response.Usage.ModelID = l.modelID
+		}
+
 		// Log successful completion with usage info
 		logAttrs := []any{
 			"model", l.modelID,
@@ -523,6 +539,7 @@ func NewManager(cfg *Config) (*Manager, error) {
 			modelID := llmhttp.ModelIDFromContext(ctx)
 			provider := llmhttp.ProviderFromContext(ctx)
 			conversationID := llmhttp.ConversationIDFromContext(ctx)
+			backend := llmhttp.BackendFromContext(ctx)
 
 			var convIDPtr *string
 			if conversationID != "" {
@@ -545,6 +562,11 @@ func NewManager(cfg *Config) (*Manager, error) {
 				statusCodePtr = &sc
 			}
 
+			var backendPtr *string
+			if backend != "" {
+				backendPtr = &backend
+			}
+
 			var errPtr *string
 			if err != nil {
 				s := err.Error()
@@ -566,6 +588,7 @@ func NewManager(cfg *Config) (*Manager, error) {
 					StatusCode:     statusCodePtr,
 					Error:          errPtr,
 					DurationMs:     durationMsPtr,
+					Backend:        backendPtr,
 				})
 				if insertErr != nil && cfg.Logger != nil {
 					cfg.Logger.Warn("Failed to record LLM request", "error", insertErr)
@@ -583,39 +606,62 @@ func NewManager(cfg *Config) (*Manager, error) {
 	manager.cfg = cfg
 
 	// Load built-in models first
-	useGateway := cfg.Gateway != ""
+	manager.loadBuiltInModels()
+
+	// Load custom models from database
+	if err := manager.loadCustomModels(); err != nil && cfg.Logger != nil {
+		cfg.Logger.Warn("Failed to load custom models", "error", err)
+	}
+
+	// Load model groups last, since their members may be any other model
+	manager.loadModelGroups()
+
+	return manager, nil
+}
+
+// loadBuiltInModels adds the built-in models that m.cfg has keys for.
+func (m *Manager) loadBuiltInModels() {
+	useGateway := m.cfg.Gateway != ""
 	for _, model := range All() {
 		// Skip non-gateway-enabled models when using a gateway
 		if useGateway && !model.GatewayEnabled {
 			continue
 		}
-		svc, err := model.Factory(cfg, httpc)
+		svc, err := model.Factory(m.cfg, m.httpc)
 		if err != nil {
 			// Model not available (e.g., missing API key) - skip it
 			continue
 		}
 
-		manager.services[model.ID] = serviceEntry{
+		m.services[model.ID] = serviceEntry{
 			service:     svc,
 			provider:    model.Provider,
 			modelID:     model.ID,
-			source:      model.Source(cfg),
+			source:      model.Source(m.cfg),
 			displayName: model.ID, // built-in models use ID as display name
 			tags:        model.Tags,
 		}
-		manager.modelOrder = append(manager.modelOrder, model.ID)
-	}
-
-	// Load custom models from database
-	if err := manager.loadCustomModels(); err != nil && cfg.Logger != nil {
-		cfg.Logger.Warn("Failed to load custom models", "error", err)
+		m.modelOrder = append(m.modelOrder, model.ID)
 	}
+}
 
-	return manager, nil
+// Reconfigure rebuilds the built-in models with new API keys and gateway,
+// e.g. after shelley.json changes, and reloads custom models and model groups.
+// Services already handed out keep the configuration they were created with.
+func (m *Manager) Reconfigure(cfg *Config) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	m.services = make(map[string]serviceEntry)
+	m.modelOrder = nil
+	m.loadBuiltInModels()
+	err := m.loadCustomModels()
+	m.loadModelGroups()
+	return err
 }
 
 // loadCustomModels loads custom models from the database into the manager.
-// It adds them after built-in models in the order.
+// It adds them after built-in models in the order. m.mu must be held.
 func (m *Manager) loadCustomModels() error {
 	if m.db == nil {
 		return nil
@@ -658,11 +704,15 @@ func (m *Manager) RefreshCustomModels() error {
 		return nil
 	}
 
-	// Remove existing custom models from services and modelOrder
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Remove existing custom models, and the groups that may use them,
+	// from services and modelOrder
 	newOrder := make([]string, 0, len(m.modelOrder))
 	for _, id := range m.modelOrder {
 		entry, ok := m.services[id]
-		if ok && entry.source != string(SourceCustom) {
+		if ok && entry.source != string(SourceCustom) && entry.source != string(SourceGroup) {
 			newOrder = append(newOrder, id)
 		} else {
 			delete(m.services, id)
@@ -670,13 +720,17 @@ func (m *Manager) RefreshCustomModels() error {
 	}
 	m.modelOrder = newOrder
 
-	// Reload custom models
-	return m.loadCustomModels()
+	// Reload custom models, then the groups
+	err := m.loadCustomModels()
+	m.loadModelGroups()
+	return err
 }
 
 // GetService returns the LLM service for the given model ID, wrapped with logging
 func (m *Manager) GetService(modelID string) (llm.Service, error) {
+	m.mu.RLock()
 	entry, ok := m.services[modelID]
+	m.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unsupported model: %s", modelID)
 	}
@@ -697,6 +751,8 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 // GetAvailableModels returns a list of available model IDs.
 // Returns union of built-in models (in order) followed by custom models.
 func (m *Manager) GetAvailableModels() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	// Return a copy to prevent external modification
 	result := make([]string, len(m.modelOrder))
 	copy(result, m.modelOrder)
@@ -705,6 +761,8 @@ func (m *Manager) GetAvailableModels() []string {
 
 // HasModel reports whether the manager has a service for the given model ID
 func (m *Manager) HasModel(modelID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	_, ok := m.services[modelID]
 	return ok
 }
@@ -718,6 +776,8 @@ type ModelInfo struct {
 
 // GetModelInfo returns the display name, tags, and source for a model
 func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	entry, ok := m.services[modelID]
 	if !ok {
 		return nil
@@ -203,7 +203,10 @@ func TestLoggingService(t *testing.T) {
 	}
 
 	if response == nil {
-		t.Error("Do returned nil response")
+		t.Fatal("Do returned nil response")
+	}
+	if response.Usage.ModelID != "test-model" {
+		t.Errorf("expected the usage to name the model, got %q", response.Usage.ModelID)
 	}
 
 	// Test TokenContextWindow
@@ -310,6 +313,36 @@ func TestManagerHasModel(t *testing.T) {
 	}
 }
 
+func TestManagerReconfigure(t *testing.T) {
+	manager, err := NewManager(&Config{})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if manager.HasModel("claude-opus-4.6") {
+		t.Fatal("HasModel('claude-opus-4.6') should return false without API key")
+	}
+
+	if err := manager.Reconfigure(&Config{AnthropicAPIKey: "test-key"}); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if !manager.HasModel("claude-opus-4.6") {
+		t.Error("HasModel('claude-opus-4.6') should return true after adding an API key")
+	}
+	if info := manager.GetModelInfo("claude-opus-4.6"); info == nil || info.Source != "$ANTHROPIC_API_KEY" {
+		t.Errorf("unexpected model info after Reconfigure: %+v", info)
+	}
+
+	if err := manager.Reconfigure(&Config{}); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if manager.HasModel("claude-opus-4.6") {
+		t.Error("HasModel('claude-opus-4.6') should return false after removing the API key")
+	}
+	if !manager.HasModel("predictable") {
+		t.Error("HasModel('predictable') should return true after Reconfigure")
+	}
+}
+
 func TestConfigGetURLMethods(t *testing.T) {
 	// Test getGeminiURL with no gateway
 	cfg := &Config{}
@@ -0,0 +1,436 @@
+package server
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// AuthConfig controls how requests on the TCP listener are mapped to users.
+// With neither Header nor PasswordLogin set, there are no users and every
+// request can see every conversation. Otherwise API tokens are accepted too
+// (see apiTokenMiddleware). Requests on the Unix socket are never
+// authenticated.
+type AuthConfig struct {
+	// Header names a header holding the username, set by an authenticating
+	// proxy. Users are created the first time their header value is seen.
+	Header string
+	// PasswordLogin enables the built-in login page for users that have a
+	// password (see "shelley users").
+	PasswordLogin bool
+	// Admins are usernames that can see and manage every user's
+	// conversations, in addition to users marked as admins in the database.
+	Admins []string
+}
+
+func (c AuthConfig) enabled() bool {
+	return c.Header != "" || c.PasswordLogin
+}
+
+const (
+	sessionCookieName = "shelley_session"
+	sessionDuration   = 30 * 24 * time.Hour
+
+	passwordHashIterations = 600_000
+)
+
+// SetAuth configures authentication. It must be called before the server starts.
+func (s *Server) SetAuth(cfg AuthConfig) {
+	s.auth = cfg
+}
+
+type userContextKey struct{}
+
+// requestUser returns the user a request was authenticated as, or nil if it
+// is not subject to authentication: auth is disabled, or the request came
+// over the Unix socket.
+func requestUser(r *http.Request) *generated.User {
+	user, _ := r.Context().Value(userContextKey{}).(*generated.User)
+	return user
+}
+
+// requestUserID returns the ID of requestUser, or nil.
+func requestUserID(r *http.Request) *string {
+	if user := requestUser(r); user != nil {
+		return &user.UserID
+	}
+	return nil
+}
+
+func (s *Server) isAdmin(user *generated.User) bool {
+	return user.IsAdmin || slices.Contains(s.auth.Admins, user.Username)
+}
+
+// ownerFilter returns the user whose conversations a request is limited to,
+// or nil if it may see every conversation.
+func (s *Server) ownerFilter(r *http.Request) *string {
+	user := requestUser(r)
+	if user == nil || s.isAdmin(user) {
+		return nil
+	}
+	return &user.UserID
+}
+
+// canAccessConversation reports whether a request may read and act on a
+// conversation. Conversations without an owner are only accessible to admins.
+func (s *Server) canAccessConversation(r *http.Request, conversation *generated.Conversation) bool {
+	owner := s.ownerFilter(r)
+	return owner == nil || (conversation.UserID != nil && *conversation.UserID == *owner)
+}
+
+// canModify reports whether a request may change a custom model or
+// notification channel owned by ownerID. Unowned ones are shared: every user
+// can see them, but only admins can change them.
+func (s *Server) canModify(r *http.Request, ownerID *string) bool {
+	owner := s.ownerFilter(r)
+	return owner == nil || (ownerID != nil && *ownerID == *owner)
+}
+
+// canView reports whether a request may see a custom model or notification
+// channel owned by ownerID.
+func (s *Server) canView(r *http.Request, ownerID *string) bool {
+	return ownerID == nil || s.canModify(r, ownerID)
+}
+
+// requireConversationAccess wraps the /api/conversation/<id>/ routes so that
+// users can only reach their own conversations. Others get a 404, as if the
+// conversation did not exist.
+func (s *Server) requireConversationAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ownerFilter(r) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		conversationID, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		conversation, err := s.db.GetConversationByID(r.Context(), conversationID)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			s.logger.Error("Failed to get conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err != nil || !s.canAccessConversation(r, conversation) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminOnlyPath reports whether a path exposes every user's data or controls
+// the server process, and so is limited to admins when auth is enabled.
+func adminOnlyPath(path string) bool {
+	return strings.HasPrefix(path, "/debug/") || path == "/upgrade" || path == "/exit"
+}
+
+// apiPath reports whether a path is one of the endpoints the UI fetches, as
+// opposed to a page or asset. Requests for these always need a user: handlers
+// treat a request without one as coming from an admin.
+func apiPath(path string) bool {
+	switch path {
+	case "/settings", "/version", "/version-check", "/version-changelog":
+		return true
+	}
+	return strings.HasPrefix(path, "/api/")
+}
+
+// authMiddleware identifies the user behind each request and adds them to
+// the request context. Requests that cannot be identified are turned away:
+// API requests get an error, and pages redirect to the login page if
+// password login is enabled.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path == "/login" || path == "/api/login" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := requestUser(r) // set by apiTokenMiddleware
+		if user == nil {
+			var err error
+			user, err = s.authenticate(r)
+			if err != nil {
+				s.logger.Error("Failed to authenticate request", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if user == nil {
+			switch {
+			case s.auth.PasswordLogin && apiPath(path):
+				http.Error(w, "authentication required", http.StatusUnauthorized)
+			case s.auth.PasswordLogin:
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			case apiPath(path) || adminOnlyPath(path):
+				http.Error(w, "missing required header: "+s.auth.Header, http.StatusForbidden)
+			default:
+				// Pages and assets are served as before; the API calls they make are checked.
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		if adminOnlyPath(path) && !s.isAdmin(user) {
+			http.Error(w, "admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)))
+	})
+}
+
+// authenticate returns the user identified by the configured header or by a
+// session cookie, or nil if there is neither.
+func (s *Server) authenticate(r *http.Request) (*generated.User, error) {
+	if s.auth.Header != "" {
+		if username := r.Header.Get(s.auth.Header); username != "" {
+			return s.db.GetOrCreateUser(r.Context(), username)
+		}
+	}
+	if s.auth.PasswordLogin {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			return nil, nil
+		}
+		user, err := s.db.GetSessionUser(r.Context(), hashToken(cookie.Value))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return user, err
+	}
+	return nil, nil
+}
+
+// hashToken returns the form in which session cookies and API tokens are
+// stored, so that a copy of the database does not hand out credentials.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword returns a salted PBKDF2-SHA256 hash of password for storage.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordHashIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches a hash from HashPassword.
+func VerifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummyPasswordHash is checked against when a login names an unknown user,
+// so that the response takes as long as for a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("")
+	return hash
+})
+
+// LoginRequest is the JSON body for POST /api/login. The login page posts the
+// same fields as a form.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// UserInfo describes the signed-in user.
+type UserInfo struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	IsAdmin  bool   `json:"is_admin"`
+	// TokenScope is the scope of the API token the request used, if any.
+	TokenScope string `json:"token_scope,omitempty"`
+}
+
+// handleLogin handles POST /api/login
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.auth.PasswordLogin {
+		http.Error(w, "password login is not enabled", http.StatusNotFound)
+		return
+	}
+
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	var req LoginRequest
+	if isJSON {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Username = r.FormValue("username")
+		req.Password = r.FormValue("password")
+	}
+	next := safeRedirect(r.FormValue("next"))
+
+	ctx := r.Context()
+	user, err := s.db.GetUserByUsername(ctx, req.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("Failed to look up user", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.PasswordHash == nil {
+		VerifyPassword(dummyPasswordHash(), req.Password)
+		user = nil
+	} else if !VerifyPassword(*user.PasswordHash, req.Password) {
+		user = nil
+	}
+	if user == nil {
+		if isJSON {
+			http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		} else {
+			http.Redirect(w, r, "/login?error=1&next="+url.QueryEscape(next), http.StatusSeeOther)
+		}
+		return
+	}
+
+	token := rand.Text()
+	expires := time.Now().Add(sessionDuration)
+	if err := s.db.CreateUserSession(ctx, hashToken(token), user.UserID, expires); err != nil {
+		s.logger.Error("Failed to create session", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	s.logger.Info("User logged in", "username", user.Username)
+
+	if !isJSON {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UserInfo{UserID: user.UserID, Username: user.Username, IsAdmin: s.isAdmin(user)})
+}
+
+// handleLogout handles POST /api/logout
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := s.db.DeleteUserSession(r.Context(), hashToken(cookie.Value)); err != nil {
+			s.logger.Error("Failed to delete session", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMe handles GET /api/me. Without auth there is no user, and the
+// response is null.
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	var info *UserInfo
+	if user := requestUser(r); user != nil {
+		info = &UserInfo{UserID: user.UserID, Username: user.Username, IsAdmin: s.isAdmin(user)}
+		info.TokenScope, _ = r.Context().Value(tokenScopeContextKey{}).(string)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// safeRedirect returns next if it is a path on this server, and "/" otherwise.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in - Shelley</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+form { display: flex; flex-direction: column; gap: 0.75em; width: 18em; }
+input, button { font: inherit; padding: 0.4em; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<form method="post" action="/api/login">
+<h1>Shelley</h1>
+{{if .Error}}<div class="error">Invalid username or password.</div>{{end}}
+<input name="username" placeholder="Username" autocomplete="username" autofocus required>
+<input name="password" type="password" placeholder="Password" autocomplete="current-password" required>
+<input name="next" type="hidden" value="{{.Next}}">
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// handleLoginPage handles GET /login
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if !s.auth.PasswordLogin {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	loginPage.Execute(w, struct {
+		Error bool
+		Next  string
+	}{
+		Error: r.URL.Query().Get("error") != "",
+		Next:  safeRedirect(r.URL.Query().Get("next")),
+	})
+}
@@ -0,0 +1,408 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+// authHandler returns the server's routes behind its auth middleware, as the
+// TCP listener serves them.
+func authHandler(s *Server, cfg AuthConfig) http.Handler {
+	s.SetAuth(cfg)
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	return s.apiTokenMiddleware(s.authMiddleware(mux))
+}
+
+func listConversationIDs(t *testing.T, handler http.Handler, header, user string) []string {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/conversations", nil)
+	req.Header.Set(header, user)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list as %s: expected 200, got %d: %s", user, w.Code, w.Body.String())
+	}
+	var conversations []struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &conversations); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(conversations))
+	for i, c := range conversations {
+		ids[i] = c.ConversationID
+	}
+	return ids
+}
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyPassword(hash, "correct horse") {
+		t.Error("expected the password to verify")
+	}
+	if VerifyPassword(hash, "wrong horse") {
+		t.Error("expected a wrong password to fail")
+	}
+	if VerifyPassword("not-a-hash", "correct horse") {
+		t.Error("expected a malformed hash to fail")
+	}
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Error("expected hashes of the same password to differ by salt")
+	}
+}
+
+func TestHeaderAuthConversationOwnership(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+	const header = "X-Exedev-Userid"
+	handler := authHandler(s, AuthConfig{Header: header})
+
+	// A conversation from before auth was enabled goes to the first user.
+	legacy, err := database.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := listConversationIDs(t, handler, header, "admin"); len(ids) != 1 || ids[0] != legacy.ConversationID {
+		t.Fatalf("expected the first user to adopt %s, got %v", legacy.ConversationID, ids)
+	}
+
+	alice, err := database.GetOrCreateUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.IsAdmin {
+		t.Fatal("only the first user should be an admin")
+	}
+	conv, err := database.CreateUserConversation(ctx, &alice.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := listConversationIDs(t, handler, header, "alice"); len(ids) != 1 || ids[0] != conv.ConversationID {
+		t.Errorf("alice should see only her conversation, got %v", ids)
+	}
+	if ids := listConversationIDs(t, handler, header, "bob"); len(ids) != 0 {
+		t.Errorf("bob should see no conversations, got %v", ids)
+	}
+	if ids := listConversationIDs(t, handler, header, "admin"); len(ids) != 2 {
+		t.Errorf("admin should see every conversation, got %v", ids)
+	}
+
+	for user, want := range map[string]int{"alice": http.StatusOK, "bob": http.StatusNotFound, "admin": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/api/conversation/"+conv.ConversationID, nil)
+		req.Header.Set(header, user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("GET conversation as %s: expected %d, got %d", user, want, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/conversations", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the header, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/debug/conversations", nil)
+	req.Header.Set(header, "bob")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin on a debug page, got %d", w.Code)
+	}
+}
+
+func TestPasswordLogin(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+	handler := authHandler(s, AuthConfig{PasswordLogin: true})
+
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateUser(ctx, "alice", &hash, false); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/conversations", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 before login, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/login") {
+		t.Fatalf("expected a redirect to the login page, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	req = httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"username":"alice","password":"nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong password, got %d", w.Code)
+	}
+
+	form := url.Values{"username": {"alice"}, "password": {"hunter2"}, "next": {"/c/some-slug"}}
+	req = httptest.NewRequest("POST", "/api/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/c/some-slug" {
+		t.Fatalf("expected a redirect to next, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly session cookie, got %+v", cookies)
+	}
+	session := cookies[0]
+
+	req = httptest.NewRequest("GET", "/api/me", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var me UserInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &me); err != nil {
+		t.Fatalf("failed to decode /api/me (%d): %v", w.Code, err)
+	}
+	if me.Username != "alice" || !me.IsAdmin {
+		t.Errorf("expected alice, the first user, to be an admin; got %+v", me)
+	}
+
+	req = httptest.NewRequest("POST", "/api/logout", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	req = httptest.NewRequest("GET", "/api/conversations", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after logout, got %d", w.Code)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	for next, want := range map[string]string{
+		"":                   "/",
+		"/c/slug":            "/c/slug",
+		"//evil.example":     "/",
+		"https://evil.com/x": "/",
+		"/\\evil.example":    "/",
+	} {
+		if got := safeRedirect(next); got != want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
+
+func TestConversationShare(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+	const header = "X-Exedev-Userid"
+	handler := authHandler(s, AuthConfig{Header: header})
+
+	if _, err := database.GetOrCreateUser(ctx, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := database.GetOrCreateUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := database.CreateUserConversation(ctx, &alice.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(header, user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/conversation/"+conv.ConversationID+"/share", "bob"); w.Code != http.StatusNotFound {
+		t.Fatalf("bob should not be able to share alice's conversation, got %d", w.Code)
+	}
+	w := do("POST", "/api/conversation/"+conv.ConversationID+"/share", "alice")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var share ShareAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &share); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do("GET", share.URL+"?format=json", "bob"); w.Code != http.StatusOK {
+		t.Fatalf("bob should be able to read the shared conversation, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/conversation/"+conv.ConversationID+"/unshare", "alice"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := do("GET", share.URL, "bob"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after unsharing, got %d", w.Code)
+	}
+}
+
+func TestSettingsAccess(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+	const header = "X-Exedev-Userid"
+	handler := authHandler(s, AuthConfig{Header: header})
+
+	if _, err := database.GetOrCreateUser(ctx, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := database.GetOrCreateUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := database.GetOrCreateUser(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceConv, err := database.CreateUserConversation(ctx, &alice.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobConv, err := database.CreateUserConversation(ctx, &bob.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := func(user, key, value string) int {
+		body, _ := json.Marshal(map[string]string{"key": key, "value": value})
+		req := httptest.NewRequest("POST", "/settings", strings.NewReader(string(body)))
+		req.Header.Set(header, user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	policy := `{"rules":[{"program":"rm","action":"deny"}]}`
+	if code := set("alice", mcpServersKey, `[{"name":"x","command":"sh"}]`); code != http.StatusForbidden {
+		t.Errorf("expected a non-admin to be refused mcp_servers, got %d", code)
+	}
+	if code := set("alice", bashPermissionPolicyKey, policy); code != http.StatusForbidden {
+		t.Errorf("expected a non-admin to be refused the global policy, got %d", code)
+	}
+	if code := set("alice", bashPermissionPolicyKey+":"+bobConv.ConversationID, policy); code != http.StatusNotFound {
+		t.Errorf("expected alice to be refused bob's conversation policy, got %d", code)
+	}
+	if code := set("alice", bashPermissionPolicyKey+":"+aliceConv.ConversationID, policy); code != http.StatusOK {
+		t.Errorf("expected alice to set her conversation's policy, got %d", code)
+	}
+	if code := set("admin", mcpServersKey, `[{"name":"x","command":"sh","env":{"TOKEN":"secret"}}]`); code != http.StatusOK {
+		t.Errorf("expected an admin to set mcp_servers, got %d", code)
+	}
+	if code := set("admin", bashPermissionPolicyKey+":"+bobConv.ConversationID, policy); code != http.StatusOK {
+		t.Errorf("expected an admin to set bob's conversation policy, got %d", code)
+	}
+
+	get := func(user string) map[string]string {
+		req := httptest.NewRequest("GET", "/settings", nil)
+		req.Header.Set(header, user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var settings map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+			t.Fatalf("failed to decode settings (%d): %v", w.Code, err)
+		}
+		return settings
+	}
+	settings := get("alice")
+	if _, ok := settings[mcpServersKey]; ok {
+		t.Error("a non-admin should not see mcp_servers")
+	}
+	if _, ok := settings[bashPermissionPolicyKey+":"+bobConv.ConversationID]; ok {
+		t.Error("alice should not see bob's conversation policy")
+	}
+	if _, ok := settings[bashPermissionPolicyKey+":"+aliceConv.ConversationID]; !ok {
+		t.Error("alice should see her conversation's policy")
+	}
+	if _, ok := get("admin")[mcpServersKey]; !ok {
+		t.Error("an admin should see mcp_servers")
+	}
+
+	// Without the header there is no user, which must not mean admin.
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/settings", nil),
+		httptest.NewRequest("POST", "/settings", strings.NewReader(`{"key":"auto_upgrade","value":"true"}`)),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden || strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("%s /settings without the header: expected 403, got %d: %s", req.Method, w.Code, w.Body.String())
+		}
+	}
+	if value, err := database.GetSetting(ctx, "auto_upgrade"); err == nil && value == "true" {
+		t.Error("a request without the header changed a setting")
+	}
+}
+
+func TestForkBelongsToRequester(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+	const header = "X-Exedev-Userid"
+	handler := authHandler(s, AuthConfig{Header: header})
+
+	admin, err := database.GetOrCreateUser(ctx, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := database.GetOrCreateUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := database.CreateUserConversation(ctx, &alice.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           db.MessageTypeUser,
+		UserData:       map[string]string{"text": "hello"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+conv.ConversationID+"/fork", strings.NewReader(`{"sequence_id":1}`))
+	req.Header.Set(header, "admin")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var fork struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &fork); err != nil {
+		t.Fatal(err)
+	}
+	got, err := database.GetConversationByID(ctx, fork.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID == nil || *got.UserID != admin.UserID {
+		t.Errorf("expected the fork to belong to the admin who made it, got %v", got.UserID)
+	}
+}
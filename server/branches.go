@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// MessageBranches describes a point on the active branch where a user message
+// was edited. MessageID is the alternative currently shown; Alternatives lists
+// every version of the message, oldest first, so the UI can switch between them.
+type MessageBranches struct {
+	MessageID    string   `json:"message_id"`
+	Alternatives []string `json:"alternatives"`
+}
+
+// buildMessageBranches finds the messages on the active branch that have siblings.
+func buildMessageBranches(active []generated.Message, branching []generated.ListBranchingMessagesRow) []MessageBranches {
+	siblings := make(map[string][]string)
+	for _, row := range branching {
+		if row.ParentMessageID != nil {
+			siblings[*row.ParentMessageID] = append(siblings[*row.ParentMessageID], row.MessageID)
+		}
+	}
+	var branches []MessageBranches
+	for _, msg := range active {
+		if msg.ParentMessageID == nil {
+			continue
+		}
+		if alternatives := siblings[*msg.ParentMessageID]; len(alternatives) > 1 {
+			branches = append(branches, MessageBranches{MessageID: msg.MessageID, Alternatives: alternatives})
+		}
+	}
+	return branches
+}
+
+// loadMessageBranches returns the branch points along the given active messages.
+func loadMessageBranches(ctx context.Context, database *db.DB, conversationID string, active []generated.Message) ([]MessageBranches, error) {
+	branching, err := database.ListBranchingMessages(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return buildMessageBranches(active, branching), nil
+}
+
+// isEditableUserMessage reports whether msg is a message the user typed, as
+// opposed to a tool result (which is also stored with the user role) or a
+// compaction summary.
+func isEditableUserMessage(msg generated.Message) bool {
+	return isStoredUserTextMessage(msg) && !isCompactionSummary(msg)
+}
+
+// EditMessageRequest represents a request to edit an earlier user message
+type EditMessageRequest struct {
+	SequenceID int64  `json:"sequence_id"`
+	Message    string `json:"message"`
+	Model      string `json:"model,omitempty"`
+}
+
+// handleEditMessage handles POST /conversation/<id>/edit
+// Replaces a user message on the active branch and regenerates from there.
+// The original message and everything after it are kept as an alternate branch.
+func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+
+	active, err := s.db.ListActiveMessages(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var target *generated.Message
+	for i := range active {
+		if active[i].SequenceID == req.SequenceID {
+			target = &active[i]
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, fmt.Sprintf("No message with sequence_id %d", req.SequenceID), http.StatusNotFound)
+		return
+	}
+	if !isEditableUserMessage(*target) || target.ParentMessageID == nil {
+		http.Error(w, "Only user messages can be edited", http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	modelID := req.Model
+	if modelID == "" {
+		modelID = manager.GetModel()
+	}
+	if modelID == "" {
+		modelID = s.getDefaultModel()
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: req.Message}},
+	}
+	err = manager.EditUserMessage(ctx, llmService, modelID, *target.ParentMessageID, userMessage)
+	if errors.Is(err, errAgentWorking) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errConversationModelMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to edit message", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// SwitchBranchRequest represents a request to show a different version of an edited message
+type SwitchBranchRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// handleSwitchBranch handles POST /conversation/<id>/branch
+// Makes the most recent branch through message_id the active one.
+func (s *Server) handleSwitchBranch(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req SwitchBranchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.MessageID == "" {
+		http.Error(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = manager.SwitchBranch(ctx, req.MessageID)
+	if errors.Is(err, errAgentWorking) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to switch branch", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+func (h *TestHarness) editMessage(sequenceID int64, msg string) int {
+	h.t.Helper()
+	body := fmt.Sprintf(`{"sequence_id":%d,"message":%q}`, sequenceID, msg)
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/edit", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleEditMessage(w, req, h.convID)
+	return w.Code
+}
+
+func (h *TestHarness) switchBranch(messageID string) int {
+	h.t.Helper()
+	body := fmt.Sprintf(`{"message_id":%q}`, messageID)
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/branch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleSwitchBranch(w, req, h.convID)
+	return w.Code
+}
+
+// userText returns the text of a user message, or "" for other messages.
+func userText(msg generated.Message) string {
+	if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+		return ""
+	}
+	var llmMsg llm.Message
+	json.Unmarshal([]byte(*msg.LlmData), &llmMsg)
+	for _, c := range llmMsg.Content {
+		if c.Type == llm.ContentTypeText {
+			return c.Text
+		}
+	}
+	return ""
+}
+
+func lastRequestMentions(h *TestHarness, text string) bool {
+	for _, m := range h.llm.GetLastRequest().Messages {
+		for _, c := range m.Content {
+			if c.Type == llm.ContentTypeText && strings.Contains(c.Text, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestEditMessage(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+
+	var second generated.Message
+	active, err := h.db.ListActiveMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range active {
+		if userText(m) == "echo: second" {
+			second = m
+		}
+		if m.Type == string(db.MessageTypeAgent) {
+			if code := h.editMessage(m.SequenceID, "nope"); code != http.StatusBadRequest {
+				t.Errorf("expected 400 editing an agent message, got %d", code)
+			}
+		}
+	}
+
+	// An edit that fails to start leaves the conversation as it was.
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.EditUserMessage(ctx, nil, "predictable", *second.ParentMessageID, llm.UserStringMessage("echo: lost")); err == nil {
+		t.Fatal("expected the edit to fail without an LLM service")
+	}
+	if after, err := h.db.ListActiveMessages(ctx, h.convID); err != nil || len(after) != len(active) {
+		t.Fatalf("expected the failed edit to keep all %d messages, got %d (%v)", len(active), len(after), err)
+	}
+
+	if code := h.editMessage(second.SequenceID, "echo: replaced"); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if got := h.WaitResponse(); got != "replaced" {
+		t.Errorf("expected reply to the edited message, got %q", got)
+	}
+	if lastRequestMentions(h, "echo: second") {
+		t.Error("edited branch should not send the replaced message to the LLM")
+	}
+
+	active, err = h.db.ListActiveMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err := loadMessageBranches(ctx, h.db, h.convID, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 || len(branches[0].Alternatives) != 2 || branches[0].Alternatives[0] != second.MessageID {
+		t.Fatalf("expected one branch point with the original first, got %+v", branches)
+	}
+	for _, m := range active {
+		if userText(m) == "echo: second" {
+			t.Error("original message should be off the active branch")
+		}
+	}
+
+	// Switch back to the original and continue from its tail.
+	if code := h.switchBranch(second.MessageID); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	h.Chat("echo: third")
+	h.WaitResponse()
+	if !lastRequestMentions(h, "echo: second") || lastRequestMentions(h, "echo: replaced") {
+		t.Error("expected the original branch in the LLM context")
+	}
+
+	if code := h.switchBranch("no-such-message"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown message, got %d", code)
+	}
+}
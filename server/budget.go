@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// conversationBudgetKey is the settings key holding the most a conversation may
+// cost in US dollars, subagents included. Empty or "0" means no limit.
+const conversationBudgetKey = "conversation_budget_usd"
+
+// dailyBudgetKey is the settings key holding the most all conversations together
+// may cost in US dollars since local midnight. Empty or "0" means no limit.
+const dailyBudgetKey = "daily_budget_usd"
+
+// errOverBudget is wrapped by the errors returned when a spending limit is reached.
+var errOverBudget = errors.New("spending limit reached")
+
+// parseBudget parses a budget setting in US dollars; 0 means no limit.
+func parseBudget(key, value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	budget, err := strconv.ParseFloat(value, 64)
+	if err != nil || budget < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative amount in US dollars", key)
+	}
+	return budget, nil
+}
+
+// loadBudget returns the configured budget for key, or 0 if there is none.
+func (cm *ConversationManager) loadBudget(ctx context.Context, key string) float64 {
+	value, err := cm.db.GetSetting(ctx, key)
+	if err != nil {
+		cm.logger.Warn("failed to load budget", "key", key, "error", err)
+		return 0
+	}
+	budget, err := parseBudget(key, value)
+	if err != nil {
+		cm.logger.Warn("ignoring invalid budget", "key", key, "value", value, "error", err)
+		return 0
+	}
+	return budget
+}
+
+// rootConversationID returns the top-level conversation that cm's conversation
+// belongs to; subagent spending counts against it.
+func (cm *ConversationManager) rootConversationID(ctx context.Context) (string, error) {
+	id := cm.conversationID
+	seen := make(map[string]bool)
+	for !seen[id] {
+		seen[id] = true
+		conversation, err := cm.db.GetConversationByID(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if conversation.ParentConversationID == nil {
+			break
+		}
+		id = *conversation.ParentConversationID
+	}
+	return id, nil
+}
+
+// checkBudget is the loop's CheckBudget callback. It compares what has been
+// recorded in usage_data against the per-conversation and daily budgets.
+func (cm *ConversationManager) checkBudget(ctx context.Context) error {
+	conversationBudget := cm.loadBudget(ctx, conversationBudgetKey)
+	dailyBudget := cm.loadBudget(ctx, dailyBudgetKey)
+
+	if conversationBudget > 0 {
+		rootID, err := cm.rootConversationID(ctx)
+		if err != nil {
+			cm.logger.Warn("failed to find root conversation for budget", "error", err)
+			return nil
+		}
+		var spent float64
+		err = cm.db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			spent, err = q.GetConversationTreeCost(ctx, rootID)
+			return err
+		})
+		if err != nil {
+			cm.logger.Warn("failed to compute conversation cost", "error", err)
+		} else if spent >= conversationBudget {
+			return fmt.Errorf("%w: this conversation has cost $%.2f (including subagents), and the budget is $%.2f per conversation", errOverBudget, spent, conversationBudget)
+		}
+	}
+
+	if dailyBudget > 0 {
+		var spent float64
+		err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			spent, err = q.GetCostSinceStartOfDay(ctx)
+			return err
+		})
+		if err != nil {
+			cm.logger.Warn("failed to compute daily cost", "error", err)
+		} else if spent >= dailyBudget {
+			return fmt.Errorf("%w: $%.2f has been spent today across all conversations, and the daily budget is $%.2f", errOverBudget, spent, dailyBudget)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/server/notifications"
+)
+
+type recordingChannel struct {
+	mu     sync.Mutex
+	events []notifications.Event
+}
+
+func (c *recordingChannel) Name() string { return "recording" }
+
+func (c *recordingChannel) Send(ctx context.Context, event notifications.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+	return nil
+}
+
+func (c *recordingChannel) find(eventType notifications.EventType) *notifications.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.events {
+		if c.events[i].Type == eventType {
+			return &c.events[i]
+		}
+	}
+	return nil
+}
+
+func TestParseBudget(t *testing.T) {
+	for value, want := range map[string]float64{"": 0, "0": 0, "2.50": 2.5} {
+		got, err := parseBudget(dailyBudgetKey, value)
+		if err != nil || got != want {
+			t.Errorf("parseBudget(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"-1", "$5", "lots"} {
+		if _, err := parseBudget(dailyBudgetKey, value); err == nil {
+			t.Errorf("parseBudget(%q): expected error", value)
+		}
+	}
+}
+
+func TestConversationBudgetIncludesSubagents(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	channel := &recordingChannel{}
+	h.server.RegisterNotificationChannel(channel)
+	if err := h.db.SetSetting(ctx, conversationBudgetKey, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+
+	// A subagent's spending counts against its parent.
+	subagent, err := h.db.CreateSubagentConversation(ctx, "helper", h.convID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: subagent.ConversationID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "done"}}},
+		UsageData:      llm.Usage{CostUSD: 1.25},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := len(h.llm.GetRecentRequests())
+	h.Chat("echo: second")
+	errorMsg := waitForMessageType(t, h, db.MessageTypeError)
+	if !strings.Contains(errorMsg, "$1.25") || !strings.Contains(errorMsg, "$1.00") {
+		t.Errorf("expected the error to give spend and budget, got %q", errorMsg)
+	}
+	if got := len(h.llm.GetRecentRequests()); got != requests {
+		t.Errorf("expected no LLM request once over budget, got %d more", got-requests)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for channel.find(notifications.EventAgentError) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	event := channel.find(notifications.EventAgentError)
+	if event == nil {
+		t.Fatal("expected an agent_error notification")
+	}
+	if payload, ok := event.Payload.(notifications.AgentErrorPayload); !ok || payload.ErrorMessage != errorMsg {
+		t.Errorf("unexpected agent_error payload: %+v", event.Payload)
+	}
+
+	// The subagent itself is held to the same budget.
+	manager, err := h.server.getOrCreateConversationManager(ctx, subagent.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.checkBudget(ctx); err == nil {
+		t.Error("expected the subagent to be over its parent's budget")
+	}
+
+	// Raising the budget lets the conversation continue.
+	if err := h.db.SetSetting(ctx, conversationBudgetKey, "10"); err != nil {
+		t.Fatal(err)
+	}
+	h.Chat("echo: third")
+	h.WaitResponse()
+}
+
+func TestDailyBudget(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	_, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: h.convID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "expensive"}}},
+		UsageData:      llm.Usage{CostUSD: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherManager, err := h.server.getOrCreateConversationManager(ctx, other.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.db.SetSetting(ctx, dailyBudgetKey, "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.checkBudget(ctx); err != nil {
+		t.Errorf("expected to be under the daily budget, got %v", err)
+	}
+	if err := h.db.SetSetting(ctx, dailyBudgetKey, "2"); err != nil {
+		t.Fatal(err)
+	}
+	// Spending in one conversation counts against the others too.
+	for _, m := range []*ConversationManager{manager, otherManager} {
+		if err := m.checkBudget(ctx); err == nil || !strings.Contains(err.Error(), "daily budget is $2.00") {
+			t.Errorf("expected the daily budget to be exceeded, got %v", err)
+		}
+	}
+}
+
+// waitForMessageType waits for a message of the given type and returns its text.
+func waitForMessageType(t *testing.T, h *TestHarness, messageType db.MessageType) string {
+	t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for time.Now().Before(deadline) {
+		messages, err := h.db.ListMessagesByType(context.Background(), h.convID, messageType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) > 0 && messages[len(messages)-1].LlmData != nil {
+			var msg llm.Message
+			if err := json.Unmarshal([]byte(*messages[len(messages)-1].LlmData), &msg); err != nil {
+				t.Fatal(err)
+			}
+			return msg.Content[0].Text
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for a %s message", messageType)
+	return ""
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/llm"
+)
+
+// checkpointRef names the ref that keeps a conversation's snapshot alive.
+func checkpointRef(conversationID, name string) string {
+	return "refs/shelley/" + conversationID + "/" + name
+}
+
+// markCheckpoint notes that the user message about to be recorded starts a new
+// turn, so the loop snapshots the working tree before acting on it. Messages
+// queued while the agent is working join the current turn and need none.
+// Conversations without a working directory are skipped.
+func (cm *ConversationManager) markCheckpoint(ctx context.Context) {
+	cm.mu.Lock()
+	skip := cm.cwd == "" || cm.agentWorking || cm.checkpointSeq != 0
+	cm.mu.Unlock()
+	if skip {
+		return
+	}
+
+	var sequenceID int64
+	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		sequenceID, err = q.GetNextSequenceID(ctx, cm.conversationID)
+		return err
+	})
+	if err != nil {
+		cm.logger.Warn("Failed to get sequence id for checkpoint", "error", err)
+		return
+	}
+	cm.mu.Lock()
+	cm.checkpointSeq = sequenceID
+	cm.mu.Unlock()
+}
+
+// recordCheckpoint snapshots the working tree at the start of a turn marked by
+// markCheckpoint, so the files can later be rolled back to how they were before
+// it. It runs on the loop goroutine rather than the request path, since staging
+// a large tree can take a while. Working directories outside git are skipped.
+func (cm *ConversationManager) recordCheckpoint(ctx context.Context) {
+	cm.mu.Lock()
+	cwd, sequenceID := cm.cwd, cm.checkpointSeq
+	cm.checkpointSeq = 0
+	cm.mu.Unlock()
+	if sequenceID == 0 {
+		return
+	}
+
+	ref := checkpointRef(cm.conversationID, strconv.FormatInt(sequenceID, 10))
+	worktree, commit, err := gitstate.Snapshot(cwd, ref, fmt.Sprintf("Shelley checkpoint before message %d", sequenceID))
+	if err != nil {
+		cm.logger.Debug("Skipping checkpoint", "cwd", cwd, "error", err)
+		return
+	}
+	_, err = cm.db.CreateCheckpoint(ctx, generated.CreateCheckpointParams{
+		ConversationID: cm.conversationID,
+		SequenceID:     sequenceID,
+		Worktree:       worktree,
+		CommitHash:     commit,
+	})
+	if err != nil {
+		cm.logger.Warn("Failed to record checkpoint", "error", err)
+	}
+}
+
+// deleteCheckpointRefs deletes a conversation's checkpoint and rollback backup
+// refs from each worktree it took snapshots in.
+func (s *Server) deleteCheckpointRefs(conversationID string, worktrees []string) {
+	for _, worktree := range worktrees {
+		if err := gitstate.DeleteRefs(worktree, checkpointRef(conversationID, "")); err != nil {
+			s.logger.Warn("Failed to delete checkpoint refs", "conversationID", conversationID, "worktree", worktree, "error", err)
+		}
+	}
+}
+
+// RollbackResult describes a completed rollback.
+type RollbackResult struct {
+	Worktree string `json:"worktree"`
+	Commit   string `json:"commit"`
+	// Backup is a ref holding the files as they were just before the rollback.
+	Backup string `json:"backup"`
+}
+
+// Rollback restores the files to the checkpoint taken at the start of the turn
+// containing sequenceID. The current files are snapshotted first, so the
+// rollback itself can be undone.
+func (cm *ConversationManager) Rollback(ctx context.Context, sequenceID int64) (*RollbackResult, error) {
+	if cm.IsAgentWorking() {
+		return nil, errAgentWorking
+	}
+	checkpoint, err := cm.db.GetCheckpointAt(ctx, cm.conversationID, sequenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := checkpointRef(cm.conversationID, fmt.Sprintf("rollback-%d", time.Now().UnixNano()))
+	if _, _, err := gitstate.Snapshot(checkpoint.Worktree, backup, fmt.Sprintf("Shelley backup before rollback to message %d", checkpoint.SequenceID)); err != nil {
+		return nil, fmt.Errorf("failed to back up working tree: %w", err)
+	}
+	if err := gitstate.Restore(checkpoint.Worktree, checkpoint.CommitHash); err != nil {
+		return nil, fmt.Errorf("failed to restore checkpoint: %w", err)
+	}
+
+	result := &RollbackResult{
+		Worktree: checkpoint.Worktree,
+		Commit:   checkpoint.CommitHash,
+		Backup:   backup,
+	}
+	cm.recordRollback(ctx, checkpoint.SequenceID, result)
+	return result, nil
+}
+
+// recordRollback leaves a gitinfo message in the conversation saying what was restored.
+func (cm *ConversationManager) recordRollback(ctx context.Context, sequenceID int64, result *RollbackResult) {
+	text := fmt.Sprintf("Rolled back files in %s to the checkpoint before message %d. The previous state is saved as %s.", result.Worktree, sequenceID, result.Backup)
+	createdMsg, err := cm.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: cm.conversationID,
+		Type:           db.MessageTypeGitInfo,
+		LLMData: llm.Message{
+			Role:    llm.MessageRoleAssistant,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}},
+		},
+		UserData: GitInfoUserData{
+			Worktree: result.Worktree,
+			Commit:   result.Commit,
+			Text:     text,
+		},
+		UsageData: llm.Usage{},
+	})
+	if err != nil {
+		cm.logger.Error("Failed to record rollback", "error", err)
+		return
+	}
+	go cm.notifyGitStateChange(context.WithoutCancel(ctx), createdMsg)
+}
+
+// handleRollback handles POST /conversation/<id>/rollback?to=<sequence_id>
+// Restores the working tree to the checkpoint taken at the start of that turn.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	sequenceID, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if err != nil || sequenceID <= 0 {
+		http.Error(w, "to must be a sequence id", http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := manager.Rollback(ctx, sequenceID)
+	if errors.Is(err, errAgentWorking) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, fmt.Sprintf("No checkpoint at or before sequence_id %d", sequenceID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to roll back", "conversationID", conversationID, "sequenceID", sequenceID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func (h *TestHarness) rollback(to string) int {
+	h.t.Helper()
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/rollback?to="+to, nil)
+	w := httptest.NewRecorder()
+	h.server.handleRollback(w, req, h.convID)
+	return w.Code
+}
+
+func TestRollbackToCheckpoint(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test User"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(repo, name))
+		if os.IsNotExist(err) {
+			return "<missing>"
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	write("a.txt", "v1")
+	h.NewConversation("echo: first", repo)
+	h.WaitResponse()
+	write("a.txt", "v2")
+	write("new.txt", "created in the first turn")
+	h.Chat("echo: second")
+	h.WaitResponse()
+	write("a.txt", "v3")
+
+	seqs := make(map[string]int64)
+	active, err := h.db.ListActiveMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range active {
+		if text := userText(m); text != "" {
+			seqs[text] = m.SequenceID
+		}
+	}
+
+	// Any message in a turn rolls back to the start of that turn.
+	if code := h.rollback(strconv.FormatInt(seqs["echo: second"]+1, 10)); code != http.StatusOK {
+		t.Fatalf("rollback: expected 200, got %d", code)
+	}
+	if got := read("a.txt"); got != "v2" {
+		t.Errorf("a.txt: expected v2, got %q", got)
+	}
+	if got := read("new.txt"); got != "created in the first turn" {
+		t.Errorf("new.txt: expected it to survive, got %q", got)
+	}
+
+	if code := h.rollback(strconv.FormatInt(seqs["echo: first"], 10)); code != http.StatusOK {
+		t.Fatalf("rollback: expected 200, got %d", code)
+	}
+	if got := read("a.txt"); got != "v1" {
+		t.Errorf("a.txt: expected v1, got %q", got)
+	}
+	if got := read("new.txt"); got != "<missing>" {
+		t.Errorf("new.txt: expected it to be removed, got %q", got)
+	}
+
+	// Each rollback is noted in the conversation.
+	gitInfo, err := h.db.ListMessagesByType(ctx, h.convID, db.MessageTypeGitInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gitInfo) != 2 {
+		t.Errorf("expected 2 rollback messages, got %d", len(gitInfo))
+	}
+
+	if code := h.rollback("1"); code != http.StatusNotFound {
+		t.Errorf("rollback before the first checkpoint: expected 404, got %d", code)
+	}
+	if code := h.rollback("latest"); code != http.StatusBadRequest {
+		t.Errorf("rollback to a non-number: expected 400, got %d", code)
+	}
+
+	// Deleting the conversation deletes its checkpoint and backup refs.
+	refs := func() string {
+		t.Helper()
+		out, err := exec.Command("git", "-C", repo, "for-each-ref", "refs/shelley/").CombinedOutput()
+		if err != nil {
+			t.Fatalf("git for-each-ref: %v\n%s", err, out)
+		}
+		return string(out)
+	}
+	if refs() == "" {
+		t.Fatal("expected checkpoint refs before deleting the conversation")
+	}
+	w := httptest.NewRecorder()
+	h.server.handleDeleteConversation(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/delete", nil), h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for refs() != "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the refs to be deleted, got:\n%s", refs())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
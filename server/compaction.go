@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// autoCompactionThresholdKey is the settings key holding the fraction of the model's
+// context window past which older turns are summarized. "0" turns compaction off.
+const autoCompactionThresholdKey = "auto_compaction_threshold"
+
+// defaultAutoCompactionThreshold applies when the setting is unset. Compaction is
+// off unless turned on.
+const defaultAutoCompactionThreshold = 0
+
+// compactionSummaryPrefix introduces the synthetic message that replaces compacted turns.
+const compactionSummaryPrefix = "The earlier part of this conversation was summarized to save context. Summary:\n\n"
+
+const compactionSystemPrompt = `You are compacting the context of Shelley, an AI coding assistant, whose conversation is about to outgrow the model's context window.
+
+You will receive a transcript of the older part of the conversation. Shelley will continue the conversation with your summary in place of that transcript, followed by the most recent messages verbatim, so the summary must carry everything needed to keep working. If the transcript ends partway through the user's latest request, give that request in full and say what has been done on it so far.
+
+Write the summary in second person, addressed to Shelley ("You were asked to...", "You changed..."). Start with 2-6 sentences on what the user wants and where the work stands, then a "## Retained Facts" section of bullets. Keep: file paths and what they are for, decisions and their reasons, user preferences and corrections, specific values (commands, URLs, ports, versions), errors and how they were fixed, and open tasks. Drop greetings, dead ends, and verbose tool output. Do not wrap the output in a code fence or add commentary.`
+
+// parseCompactionThreshold parses the auto_compaction_threshold setting.
+func parseCompactionThreshold(value string) (float64, error) {
+	if value == "" {
+		return defaultAutoCompactionThreshold, nil
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 || threshold >= 1 {
+		return 0, fmt.Errorf("%s must be a fraction of the context window between 0 (off) and 1", autoCompactionThresholdKey)
+	}
+	return threshold, nil
+}
+
+// compactionThreshold returns the configured threshold, or 0 if compaction is off.
+func (cm *ConversationManager) compactionThreshold(ctx context.Context) float64 {
+	value, err := cm.db.GetSetting(ctx, autoCompactionThresholdKey)
+	if err != nil {
+		cm.logger.Warn("failed to load compaction threshold", "error", err)
+		return 0
+	}
+	threshold, err := parseCompactionThreshold(value)
+	if err != nil {
+		cm.logger.Warn("ignoring invalid compaction threshold", "value", value, "error", err)
+		return 0
+	}
+	return threshold
+}
+
+// isUserTextMessage reports whether msg is a message the user typed (or a
+// compaction summary), as opposed to a tool result, which also has the user role.
+func isUserTextMessage(msg llm.Message) bool {
+	if msg.Role != llm.MessageRoleUser {
+		return false
+	}
+	hasText := false
+	for _, content := range msg.Content {
+		switch content.Type {
+		case llm.ContentTypeToolResult:
+			return false
+		case llm.ContentTypeText:
+			hasText = true
+		}
+	}
+	return hasText
+}
+
+// isStoredUserTextMessage is isUserTextMessage for a stored message.
+func isStoredUserTextMessage(msg generated.Message) bool {
+	if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+		return false
+	}
+	llmMsg, err := convertToLLMMessage(msg)
+	if err != nil {
+		return false
+	}
+	return isUserTextMessage(llmMsg)
+}
+
+// isAssistantMessage reports whether msg is a response from the LLM, as opposed
+// to an error the loop recorded in its place, which is stored separately.
+func isAssistantMessage(msg llm.Message) bool {
+	return msg.Role == llm.MessageRoleAssistant && msg.ErrorType == llm.ErrorTypeNone
+}
+
+// isStoredAssistantMessage is isAssistantMessage for a stored message.
+func isStoredAssistantMessage(msg generated.Message) bool {
+	return msg.Type == string(db.MessageTypeAgent)
+}
+
+// isCompactionSummary reports whether msg is the synthetic summary left by compaction.
+func isCompactionSummary(msg generated.Message) bool {
+	if msg.UserData == nil {
+		return false
+	}
+	var userData struct {
+		Compaction bool `json:"compaction"`
+	}
+	if err := json.Unmarshal([]byte(*msg.UserData), &userData); err != nil {
+		return false
+	}
+	return userData.Compaction
+}
+
+// contextSizeSinceCompaction is the context window used by the latest LLM call,
+// ignoring calls made before the most recent compaction: their usage describes
+// a context that no longer exists.
+func contextSizeSinceCompaction(messages []generated.Message) uint64 {
+	var compactedAt int64
+	for _, msg := range messages {
+		if isCompactionSummary(msg) {
+			compactedAt = max(compactedAt, msg.SequenceID)
+		}
+	}
+	var latest []generated.Message
+	for _, msg := range messages {
+		if msg.SequenceID > compactedAt {
+			latest = append(latest, msg)
+		}
+	}
+	return calculateContextWindowSize(toAPIMessages(latest))
+}
+
+// compactHistory is the loop's CompactHistory callback. Once the context passes the
+// configured fraction of the model's window, it summarizes everything before the
+// last message the user typed or, partway through a turn, before the latest round
+// of tool calls, so that a single long turn can be compacted too. The summary
+// takes the place of the summarized messages, which are kept for the UI, on a
+// new branch that continues with copies of the messages that are kept.
+func (cm *ConversationManager) compactHistory(ctx context.Context, service llm.Service, history []llm.Message) ([]llm.Message, error) {
+	threshold := cm.compactionThreshold(ctx)
+	if threshold <= 0 {
+		return nil, nil
+	}
+
+	// Keep the last message the user typed or the LLM sent, and what follows it:
+	// at the start of a turn, that is the user's message; during a turn, it is the
+	// assistant's latest tool calls and their results.
+	keep := -1
+	for i, msg := range history {
+		if isUserTextMessage(msg) || isAssistantMessage(msg) {
+			keep = i
+		}
+	}
+	if keep <= 0 {
+		return nil, nil
+	}
+	isKept, isStoredKept := isUserTextMessage, isStoredUserTextMessage
+	if isAssistantMessage(history[keep]) {
+		isKept, isStoredKept = isAssistantMessage, isStoredAssistantMessage
+	}
+	ordinal := 0
+	for _, msg := range history[:keep+1] {
+		if isKept(msg) {
+			ordinal++
+		}
+	}
+
+	var messages []generated.Message
+	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessagesForContext(ctx, cm.conversationID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation history: %w", err)
+	}
+	limit := uint64(threshold * float64(service.TokenContextWindow()))
+	if used := contextSizeSinceCompaction(messages); used < limit {
+		return nil, nil
+	}
+
+	// Find the same message among the stored messages. Counting messages of its
+	// kind, rather than indexing, copes with stored messages the loop leaves out
+	// of its history and with a message stored after the history was read.
+	var older []generated.Message
+	var keepFrom *generated.Message
+	seen := 0
+	for i, msg := range messages {
+		if msg.Type == string(db.MessageTypeSystem) {
+			continue
+		}
+		if isStoredKept(msg) {
+			seen++
+			if seen == ordinal {
+				keepFrom = &messages[i]
+				break
+			}
+		}
+		older = append(older, msg)
+	}
+	if keepFrom == nil {
+		return nil, fmt.Errorf("message to keep not found among %d stored messages", len(messages))
+	}
+	compactable := false
+	for _, msg := range older {
+		if !isCompactionSummary(msg) && (msg.Type == string(db.MessageTypeUser) || msg.Type == string(db.MessageTypeAgent)) {
+			compactable = true
+			break
+		}
+	}
+	if !compactable {
+		// Only an earlier summary precedes the kept messages; compacting again would not help.
+		return nil, nil
+	}
+
+	slug := "unknown"
+	if conversation, err := cm.db.GetConversationByID(ctx, cm.conversationID); err == nil && conversation.Slug != nil {
+		slug = *conversation.Slug
+	}
+	cm.logger.Info("Compacting conversation", "messages", len(older), "threshold", threshold)
+
+	summaryCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+	resp, err := service.Do(summaryCtx, &llm.Request{
+		System: []llm.SystemContent{
+			{Text: compactionSystemPrompt, Type: "text"},
+		},
+		Messages: []llm.Message{
+			{
+				Role: llm.MessageRoleUser,
+				Content: []llm.Content{
+					{Type: llm.ContentTypeText, Text: buildDistillTranscript(slug, older)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("summarization failed: %w", err)
+	}
+	var summaryText string
+	for _, content := range resp.Content {
+		if content.Type == llm.ContentTypeText {
+			summaryText += content.Text
+		}
+	}
+	if summaryText == "" {
+		return nil, fmt.Errorf("summarization returned empty result")
+	}
+
+	summary := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: compactionSummaryPrefix + summaryText},
+		},
+	}
+	usage := resp.Usage
+	usage.Model = resp.Model
+	usage.StartTime = resp.StartTime
+	usage.EndTime = resp.EndTime
+	_, err = cm.db.CompactMessages(ctx, keepFrom.MessageID, db.CreateMessageParams{
+		ConversationID: cm.conversationID,
+		Type:           db.MessageTypeUser,
+		LLMData:        summary,
+		UserData:       map[string]any{"compaction": true, "compacted_messages": len(older)},
+		UsageData:      usage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store compaction: %w", err)
+	}
+
+	cm.broadcastActiveBranch(ctx, true)
+	return append([]llm.Message{summary}, history[keep:]...), nil
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+func TestParseCompactionThreshold(t *testing.T) {
+	for value, want := range map[string]float64{"": 0, "0": 0, "0.5": 0.5} {
+		got, err := parseCompactionThreshold(value)
+		if err != nil || got != want {
+			t.Errorf("parseCompactionThreshold(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"1", "-0.1", "80%", "high"} {
+		if _, err := parseCompactionThreshold(value); err == nil {
+			t.Errorf("parseCompactionThreshold(%q): expected error", value)
+		}
+	}
+}
+
+func TestAutoCompaction(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	// Any context at all is past this threshold.
+	if err := h.db.SetSetting(ctx, autoCompactionThresholdKey, "0.00001"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	if lastRequestMentions(h, compactionSummaryPrefix) {
+		t.Fatal("the first turn has nothing to compact")
+	}
+
+	h.Chat("echo: second")
+	h.WaitResponse()
+	req := h.llm.GetLastRequest()
+	if len(req.Messages) == 0 || !strings.HasPrefix(req.Messages[0].Content[0].Text, compactionSummaryPrefix) {
+		t.Fatalf("expected the request to start with the summary, got %+v", req.Messages)
+	}
+	if lastRequestMentions(h, "echo: first") {
+		t.Error("compacted turn was still sent to the LLM")
+	}
+	if !lastRequestMentions(h, "echo: second") {
+		t.Error("current turn was not sent to the LLM")
+	}
+
+	// The compacted messages are still shown, with the summary ahead of the current turn.
+	active, err := h.db.ListActiveMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	var summary, first *generated.Message
+	for i, m := range active {
+		if text := userText(m); text != "" {
+			texts = append(texts, text)
+		}
+		if isCompactionSummary(m) {
+			summary = &active[i]
+		}
+		if userText(m) == "echo: first" {
+			first = &active[i]
+		}
+	}
+	if len(texts) != 3 || texts[0] != "echo: first" || !strings.HasPrefix(texts[1], compactionSummaryPrefix) || texts[2] != "echo: second" {
+		t.Fatalf("unexpected active messages: %q", texts)
+	}
+	if first.ExcludedFromContext || summary.ExcludedFromContext {
+		t.Error("expected compaction to leave the compacted messages as they were")
+	}
+
+	// The summary cannot be edited.
+	if code := h.editMessage(summary.SequenceID, "echo: again"); code != http.StatusBadRequest {
+		t.Errorf("editing the summary: expected 400, got %d", code)
+	}
+
+	// A restarted loop rebuilds the same context from the database.
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.resetLoop()
+	if err := h.db.SetSetting(ctx, autoCompactionThresholdKey, "0"); err != nil {
+		t.Fatal(err)
+	}
+	h.Chat("echo: third")
+	h.WaitResponse()
+	req = h.llm.GetLastRequest()
+	if !strings.HasPrefix(req.Messages[0].Content[0].Text, compactionSummaryPrefix) || lastRequestMentions(h, "echo: first") {
+		t.Errorf("expected the rebuilt context to start with the summary, got %+v", req.Messages)
+	}
+	if !lastRequestMentions(h, "echo: second") || !lastRequestMentions(h, "echo: third") {
+		t.Error("expected later turns to be sent uncompacted")
+	}
+}
+
+func TestAutoCompactionDuringTurn(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	if err := h.db.SetSetting(ctx, autoCompactionThresholdKey, "0.00001"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A single turn with a tool call: before the request that sends the tool's
+	// result, everything up to the assistant's tool call is compacted.
+	h.NewConversation("patch fail", "")
+	h.WaitResponse()
+	req := h.llm.GetLastRequest()
+	if len(req.Messages) != 3 || !strings.HasPrefix(req.Messages[0].Content[0].Text, compactionSummaryPrefix) {
+		t.Fatalf("expected the summary, the tool call and its result, got %+v", req.Messages)
+	}
+	if kept := req.Messages[1].Content; kept[len(kept)-1].Type != llm.ContentTypeToolUse {
+		t.Errorf("expected the tool call to be kept, got %+v", req.Messages[1])
+	}
+	if lastRequestMentions(h, "patch fail") {
+		t.Error("compacted user message was still sent to the LLM")
+	}
+
+	// The stored context matches: the summary sits before the kept tool call.
+	messages, err := h.db.ListMessagesForContext(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, m := range messages {
+		if isCompactionSummary(m) {
+			types = append(types, "summary")
+		} else {
+			types = append(types, m.Type)
+		}
+	}
+	if strings.Join(types, ",") != "system,summary,agent,user,agent" {
+		t.Errorf("unexpected context after compaction: %v", types)
+	}
+
+	// Later requests can compact again, including the earlier summary.
+	h.Chat("echo: second")
+	h.WaitResponse()
+	if summaries := strings.Count(fmt.Sprint(h.llm.GetLastRequest().Messages), compactionSummaryPrefix); summaries != 1 {
+		t.Errorf("expected a single summary in the request, got %d", summaries)
+	}
+	if lastRequestMentions(h, "Done.") {
+		t.Error("expected the first turn to be compacted into the new summary")
+	}
+}
+
+func TestAutoCompactionKeepsSiblingBranches(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+	var second generated.Message
+	active, err := h.db.ListActiveMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range active {
+		if userText(m) == "echo: second" {
+			second = m
+		}
+	}
+	if code := h.editMessage(second.SequenceID, "echo: other"); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	h.WaitResponse()
+
+	// Compacting the edited branch summarizes the turn both branches share.
+	if err := h.db.SetSetting(ctx, autoCompactionThresholdKey, "0.00001"); err != nil {
+		t.Fatal(err)
+	}
+	h.Chat("echo: third")
+	h.WaitResponse()
+	if !lastRequestMentions(h, compactionSummaryPrefix) || lastRequestMentions(h, "echo: first") {
+		t.Fatal("expected the edited branch to be compacted")
+	}
+
+	// The original branch still sends its whole history.
+	if err := h.db.SetSetting(ctx, autoCompactionThresholdKey, "0"); err != nil {
+		t.Fatal(err)
+	}
+	if code := h.switchBranch(second.MessageID); code != http.StatusOK {
+		t.Fatalf("expected 200 switching branches, got %d", code)
+	}
+	h.Chat("echo: fourth")
+	h.WaitResponse()
+	if lastRequestMentions(h, compactionSummaryPrefix) {
+		t.Error("the summary of another branch was sent")
+	}
+	for _, text := range []string{"echo: first", "echo: second", "echo: fourth"} {
+		if !lastRequestMentions(h, text) {
+			t.Errorf("expected %q to be sent", text)
+		}
+	}
+	if lastRequestMentions(h, "echo: other") {
+		t.Error("the edited branch's message was sent")
+	}
+
+	// Both versions of the edited message can still be switched between.
+	active, err = h.db.ListActiveMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err := loadMessageBranches(ctx, h.db, h.convID, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 || branches[0].MessageID != second.MessageID || len(branches[0].Alternatives) != 2 {
+		t.Errorf("expected the edit to remain a branch point, got %+v", branches)
+	}
+}
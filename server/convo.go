@@ -12,13 +12,17 @@ import (
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/hooks"
 	"shelley.exe.dev/llm"
 	"shelley.exe.dev/llm/llmhttp"
 	"shelley.exe.dev/loop"
 	"shelley.exe.dev/subpub"
 )
 
-var errConversationModelMismatch = errors.New("conversation model mismatch")
+var (
+	errConversationModelMismatch = errors.New("conversation model mismatch")
+	errAgentWorking              = errors.New("agent is working; cancel the conversation first")
+)
 
 // ConversationManager manages a single active conversation
 type ConversationManager struct {
@@ -39,7 +43,9 @@ type ConversationManager struct {
 
 	hydrated              bool
 	hasConversationEvents bool
-	cwd                   string // working directory for tools
+	cwd                   string  // working directory for tools
+	checkpointSeq         int64   // sequence ID to checkpoint before when the next turn starts; 0 if none
+	userID                *string // owner of the conversation, set by Hydrate
 
 	// agentWorking tracks whether the agent is currently working.
 	// This is explicitly managed and broadcast to subscribers when it changes.
@@ -48,6 +54,25 @@ type ConversationManager struct {
 	// onStateChange is called when the conversation state changes.
 	// This allows the server to broadcast state changes to all subscribers.
 	onStateChange func(state ConversationState)
+
+	// pendingPermissions holds bash commands awaiting user approval, keyed by request ID.
+	// A subagent's requests are held by its root conversation.
+	pendingPermissions map[string]*pendingPermission
+	// permissionPrompter returns the manager that should show this conversation's
+	// permission requests; nil means this one.
+	permissionPrompter func(ctx context.Context) *ConversationManager
+	// headless is set for conversations run by RunHeadless, which have no user
+	// to answer permission requests.
+	headless bool
+
+	// partial collects streamed assistant output until it is broadcast.
+	partial partialOutput
+
+	// promptDigest is the digest of the guidance files and skills behind the
+	// system prompt, recorded when the file watcher starts watching them.
+	promptDigest string
+	// promptStale is set when those files have changed since.
+	promptStale bool
 }
 
 // NewConversationManager constructs a manager with dependencies but defers hydration until needed.
@@ -100,6 +125,14 @@ func (cm *ConversationManager) IsAgentWorking() bool {
 	return cm.agentWorking
 }
 
+// isSubagent reports whether this is a subagent conversation. It is only
+// meaningful once the manager has been hydrated.
+func (cm *ConversationManager) isSubagent() bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.toolSetConfig.SubagentDepth > 0
+}
+
 // GetModel returns the model ID used by this conversation.
 func (cm *ConversationManager) GetModel() string {
 	cm.mu.Lock()
@@ -132,6 +165,7 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		cwd = *conversation.Cwd
 	}
 	cm.cwd = cwd
+	cm.userID = conversation.UserID
 
 	// Load model from conversation if available
 	var modelID string
@@ -139,6 +173,19 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		modelID = *conversation.Model
 	}
 
+	// Subagents may be restricted to some tools, and nested ones are limited by MaxSubagentDepth.
+	allowedTools, err := db.ParseAllowedTools(conversation)
+	if err != nil {
+		return err
+	}
+	depth := 0
+	if conversation.ParentConversationID != nil {
+		depth, err = cm.db.GetConversationDepth(ctx, cm.conversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get subagent depth: %w", err)
+		}
+	}
+
 	// Generate system prompt if missing:
 	// - For user-initiated conversations: full system prompt
 	// - For subagent conversations (has parent): minimal subagent prompt
@@ -171,6 +218,8 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 	cm.lastActivity = time.Now()
 	cm.hydrated = true
 	cm.modelID = modelID
+	cm.toolSetConfig.AllowedTools = allowedTools
+	cm.toolSetConfig.SubagentDepth = depth
 	cm.mu.Unlock()
 
 	if modelID != "" {
@@ -208,6 +257,15 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		return false, fmt.Errorf("conversation loop not initialized")
 	}
 
+	cm.markCheckpoint(ctx)
+
+	// A skill's limit on the tools lasts until the user's next message.
+	cm.mu.Lock()
+	if cm.toolSet != nil {
+		cm.toolSet.EndSkill()
+	}
+	cm.mu.Unlock()
+
 	// Record the user message to the database immediately so it appears in the UI,
 	// even if the loop is busy processing a previous request
 	if recordMessage != nil {
@@ -406,6 +464,13 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	toolSetConfig.ModelID = modelID
 	toolSetConfig.ConversationID = conversationID
 	toolSetConfig.ParentConversationID = conversationID // For subagent tool
+	toolSetConfig.CheckBashPermission = cm.checkBashPermission
+	toolSetConfig.Skills = discoverSkills(cwd)
+	toolSetConfig.OnSkillLoad = func(name string) {
+		if err := db.AddConversationSkill(context.Background(), conversationID, name); err != nil {
+			logger.Error("failed to record skill use", "error", err, "skill", name)
+		}
+	}
 	toolSetConfig.OnWorkingDirChange = func(newDir string) {
 		// Persist working directory change to database
 		if err := db.UpdateConversationCwd(context.Background(), conversationID, newDir); err != nil {
@@ -443,7 +508,8 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 		LLM:           service,
 		History:       history,
 		Tools:         toolSet.Tools(),
-		RecordMessage: recordMessage,
+		GetTools:      toolSet.Tools,
+		RecordMessage: cm.streamingRecordMessage(recordMessage),
 		Logger:        logger,
 		System:        system,
 		WorkingDir:    cwd,
@@ -451,6 +517,13 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 		OnGitStateChange: func(ctx context.Context, state *gitstate.GitState) {
 			cm.recordGitStateChange(ctx, state)
 		},
+		CompactHistory: func(ctx context.Context, history []llm.Message) ([]llm.Message, error) {
+			return cm.compactHistory(ctx, service, history)
+		},
+		CheckBudget:   cm.checkBudget,
+		OnStreamDelta: cm.onStreamDelta,
+		LoadHooks:     cm.loadToolHooks,
+		OnTurnStart:   cm.recordCheckpoint,
 	})
 
 	cm.mu.Lock()
@@ -512,6 +585,94 @@ func (cm *ConversationManager) stopLoop() {
 	}
 }
 
+// resetLoop stops the loop and forgets hydration so that the next message
+// rebuilds the history from the active branch in the database.
+func (cm *ConversationManager) resetLoop() {
+	cm.stopLoop()
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.mu.Unlock()
+}
+
+// EditUserMessage starts a new branch after parentID with message in place of the
+// user message that followed it, then runs the agent from there. The old message
+// and its tail stay in the database as an alternate branch.
+func (cm *ConversationManager) EditUserMessage(ctx context.Context, service llm.Service, modelID, parentID string, message llm.Message) error {
+	if cm.IsAgentWorking() {
+		return errAgentWorking
+	}
+	conversation, err := cm.db.GetConversationByID(ctx, cm.conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	previous := conversation.ActiveMessageID
+
+	cm.resetLoop()
+	if err := cm.db.SetActiveMessage(ctx, cm.conversationID, &parentID); err != nil {
+		return fmt.Errorf("failed to rewind conversation: %w", err)
+	}
+	cm.broadcastActiveBranch(ctx, true)
+
+	if _, err := cm.AcceptUserMessage(ctx, service, modelID, message); err != nil {
+		// Nothing was added to the new branch, so put the old one back rather
+		// than leave the conversation truncated.
+		ctx := context.WithoutCancel(ctx)
+		if restoreErr := cm.db.SetActiveMessage(ctx, cm.conversationID, previous); restoreErr != nil {
+			cm.logger.Error("Failed to restore active branch after failed edit", "error", restoreErr)
+		}
+		cm.resetLoop()
+		cm.broadcastActiveBranch(ctx, true)
+		return err
+	}
+	// The edited message is now a sibling of the original; let the UI know.
+	cm.broadcastActiveBranch(ctx, false)
+	return nil
+}
+
+// SwitchBranch shows the most recent branch through messageID.
+func (cm *ConversationManager) SwitchBranch(ctx context.Context, messageID string) error {
+	if cm.IsAgentWorking() {
+		return errAgentWorking
+	}
+	if err := cm.db.SwitchBranch(ctx, cm.conversationID, messageID); err != nil {
+		return err
+	}
+	cm.resetLoop()
+	cm.broadcastActiveBranch(ctx, true)
+	return nil
+}
+
+// broadcastActiveBranch sends the branch points of the active branch to subscribers.
+// With reset, it also sends the branch's messages, which replace the client's list.
+func (cm *ConversationManager) broadcastActiveBranch(ctx context.Context, reset bool) {
+	conversation, err := cm.db.GetConversationByID(ctx, cm.conversationID)
+	if err != nil {
+		cm.logger.Error("Failed to get conversation for branch update", "error", err)
+		return
+	}
+	messages, err := cm.db.ListActiveMessages(ctx, cm.conversationID)
+	if err != nil {
+		cm.logger.Error("Failed to list messages for branch update", "error", err)
+		return
+	}
+	branches, err := loadMessageBranches(ctx, cm.db, cm.conversationID, messages)
+	if err != nil {
+		cm.logger.Error("Failed to load branches", "error", err)
+		return
+	}
+
+	streamData := StreamResponse{
+		Conversation: *conversation,
+		Branches:     branches,
+	}
+	if reset {
+		streamData.Messages = toAPIMessages(messages)
+		streamData.ResetMessages = true
+		streamData.ContextWindowSize = calculateContextWindowSize(streamData.Messages)
+	}
+	cm.subpub.Broadcast(streamData)
+}
+
 // CancelConversation cancels the current conversation loop and records a cancelled tool result if a tool was in progress
 func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 	cm.mu.Lock()
@@ -718,3 +879,19 @@ func (cm *ConversationManager) notifyGitStateChange(ctx context.Context, msg *ge
 	}
 	cm.subpub.Publish(msg.SequenceID, streamData)
 }
+
+// loadToolHooks is the loop's LoadHooks callback. A hooks.json that cannot be
+// read blocks the tool calls, since the hooks in it may be guarding them.
+// The agent can write hooks.json, so each hook command is held to the same
+// permission policy as the bash tool.
+func (cm *ConversationManager) loadToolHooks(workingDir string) (*hooks.Config, error) {
+	config, err := hooks.Load(workingDir)
+	if err != nil {
+		cm.logger.Warn("Failed to load tool hooks", "workingDir", workingDir, "error", err)
+		return nil, err
+	}
+	if config != nil {
+		config.Check = cm.checkBashPermission
+	}
+	return config, nil
+}
@@ -90,9 +90,11 @@ func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	apiModels := make([]ModelAPI, len(models))
-	for i, m := range models {
-		apiModels[i] = toModelAPI(m)
+	apiModels := make([]ModelAPI, 0, len(models))
+	for _, m := range models {
+		if s.canView(r, m.UserID) {
+			apiModels = append(apiModels, toModelAPI(m))
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -135,6 +137,7 @@ func (s *Server) handleCreateModel(w http.ResponseWriter, r *http.Request) {
 		ModelName:    req.ModelName,
 		MaxTokens:    req.MaxTokens,
 		Tags:         req.Tags,
+		UserID:       requestUserID(r),
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create model: %v", err), http.StatusInternalServerError)
@@ -162,6 +165,9 @@ func (s *Server) handleCustomModel(w http.ResponseWriter, r *http.Request) {
 	// Check for /duplicate suffix
 	if strings.HasSuffix(path, "/duplicate") {
 		modelID := strings.TrimSuffix(path, "/duplicate")
+		if !s.checkModelAccess(w, r, modelID, false) {
+			return
+		}
 		if r.Method == http.MethodPost {
 			s.handleDuplicateModel(w, r, modelID)
 		} else {
@@ -175,6 +181,9 @@ func (s *Server) handleCustomModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	modelID := path
+	if !s.checkModelAccess(w, r, modelID, r.Method != http.MethodGet) {
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -188,6 +197,44 @@ func (s *Server) handleCustomModel(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// hiddenModels returns the IDs of custom models that belong to other users,
+// which a request may neither list nor use.
+func (s *Server) hiddenModels(r *http.Request) map[string]bool {
+	hidden := map[string]bool{}
+	if s.ownerFilter(r) == nil {
+		return hidden
+	}
+	models, err := s.db.GetModels(r.Context())
+	if err != nil {
+		s.logger.Warn("Failed to get custom models", "error", err)
+		return hidden
+	}
+	for _, m := range models {
+		if !s.canView(r, m.UserID) {
+			hidden[m.ModelID] = true
+		}
+	}
+	return hidden
+}
+
+// checkModelAccess reports whether the request may see, or if modify is set
+// change, a custom model. If not, it writes an error response.
+func (s *Server) checkModelAccess(w http.ResponseWriter, r *http.Request, modelID string, modify bool) bool {
+	if s.ownerFilter(r) == nil {
+		return true
+	}
+	model, err := s.db.GetModel(r.Context(), modelID)
+	if err != nil || !s.canView(r, model.UserID) {
+		http.Error(w, "Model not found", http.StatusNotFound)
+		return false
+	}
+	if modify && !s.canModify(r, model.UserID) {
+		http.Error(w, "Only an admin can change a shared model", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 func (s *Server) handleGetModel(w http.ResponseWriter, r *http.Request, modelID string) {
 	model, err := s.db.GetModel(r.Context(), modelID)
 	if err != nil {
@@ -301,6 +348,7 @@ func (s *Server) handleDuplicateModel(w http.ResponseWriter, r *http.Request, mo
 		ModelName:    source.ModelName,
 		MaxTokens:    source.MaxTokens,
 		Tags:         "", // Don't copy tags
+		UserID:       requestUserID(r),
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to duplicate model: %v", err), http.StatusInternalServerError)
@@ -332,6 +380,9 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 	// If model_id is provided and api_key is empty, look up the stored key
 	if req.ModelID != "" && req.APIKey == "" {
 		model, err := s.db.GetModel(r.Context(), req.ModelID)
+		if err == nil && !s.canView(r, model.UserID) {
+			err = fmt.Errorf("no model %s", req.ModelID)
+		}
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Model not found: %v", err), http.StatusNotFound)
 			return
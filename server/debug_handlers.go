@@ -349,7 +349,7 @@ function renderTable(requests) {
 			<td class="mono">${req.id}</td>
 			<td>${formatDate(req.created_at)}</td>
 			<td>${formatModel(req.model, req.model_display_name)}</td>
-			<td>${req.provider}</td>
+			<td>${req.provider}${req.backend ? '<br><span class="dedup-info">' + req.backend + '</span>' : ''}</td>
 			<td class="${statusClass}">${req.status_code || '-'}${req.error ? ' ⚠' : ''}</td>
 			<td>${formatDuration(req.duration_ms)}</td>
 			<td class="size">${formatSize(req.request_body_length)}</td>
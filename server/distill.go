@@ -85,9 +85,13 @@ func (s *Server) handleDistillConversation(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Source conversation not found", http.StatusNotFound)
 		return
 	}
+	if !s.canAccessConversation(r, sourceConv) {
+		http.Error(w, "Source conversation not found", http.StatusNotFound)
+		return
+	}
 
 	// Get messages from source conversation
-	messages, err := s.db.ListMessages(ctx, req.SourceConversationID)
+	messages, err := s.db.ListActiveMessages(ctx, req.SourceConversationID)
 	if err != nil {
 		s.logger.Error("Failed to get messages", "conversationID", req.SourceConversationID, "error", err)
 		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
@@ -102,6 +106,10 @@ func (s *Server) handleDistillConversation(w http.ResponseWriter, r *http.Reques
 	if modelID == "" {
 		modelID = "gpt-oss-20b-fireworks"
 	}
+	if s.hiddenModels(r)[modelID] {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
 
 	// Create new conversation
 	var cwdPtr *string
@@ -110,7 +118,7 @@ func (s *Server) handleDistillConversation(w http.ResponseWriter, r *http.Reques
 	} else if sourceConv.Cwd != nil {
 		cwdPtr = sourceConv.Cwd
 	}
-	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, &modelID)
+	conversation, err := s.db.CreateUserConversation(ctx, requestUserID(r), nil, true, cwdPtr, &modelID)
 	if err != nil {
 		s.logger.Error("Failed to create conversation", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
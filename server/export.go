@@ -0,0 +1,390 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// exportFormatVersion is the version of ConversationExport written by export.
+// Import rejects other versions.
+const exportFormatVersion = 1
+
+// maxImportSize limits the body of POST /api/conversations/import.
+const maxImportSize = 256 << 20
+
+// ConversationExport is the portable JSON form of a conversation: every message
+// on every branch, and its subagents, recursively.
+type ConversationExport struct {
+	Version      int                    `json:"version"`
+	ExportedAt   time.Time              `json:"exported_at"`
+	Conversation generated.Conversation `json:"conversation"`
+	Messages     []ExportedMessage      `json:"messages"`
+	Subagents    []ConversationExport   `json:"subagents,omitempty"`
+}
+
+// ExportedMessage is a message in a ConversationExport. The JSON columns are
+// embedded as JSON rather than as strings.
+type ExportedMessage struct {
+	MessageID           string          `json:"message_id"`
+	ParentMessageID     *string         `json:"parent_message_id,omitempty"`
+	SequenceID          int64           `json:"sequence_id"`
+	Type                string          `json:"type"`
+	CreatedAt           time.Time       `json:"created_at"`
+	ExcludedFromContext bool            `json:"excluded_from_context,omitempty"`
+	LlmData             json.RawMessage `json:"llm_data,omitempty"`
+	UserData            json.RawMessage `json:"user_data,omitempty"`
+	UsageData           json.RawMessage `json:"usage_data,omitempty"`
+	DisplayData         json.RawMessage `json:"display_data,omitempty"`
+}
+
+// rawJSON converts a nullable JSON column for export.
+func rawJSON(value *string) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	return json.RawMessage(*value)
+}
+
+// columnJSON converts exported JSON back to a nullable column. Exports are
+// indented, so the JSON is compacted to match what was stored originally.
+func columnJSON(value json.RawMessage) *string {
+	if len(value) == 0 || string(value) == "null" {
+		return nil
+	}
+	var compacted bytes.Buffer
+	if json.Compact(&compacted, value) != nil {
+		s := string(value)
+		return &s
+	}
+	s := compacted.String()
+	return &s
+}
+
+// exportConversation builds the export for a conversation and its subagents.
+func (s *Server) exportConversation(ctx context.Context, conversationID string) (*ConversationExport, error) {
+	var export ConversationExport
+	var messages []generated.Message
+	var subagents []generated.Conversation
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		export.Conversation, err = q.GetConversation(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		messages, err = q.ListMessages(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		subagents, err = q.GetSubagents(ctx, &conversationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	export.Version = exportFormatVersion
+	export.ExportedAt = time.Now().UTC()
+	export.Messages = make([]ExportedMessage, len(messages))
+	for i, m := range messages {
+		export.Messages[i] = ExportedMessage{
+			MessageID:           m.MessageID,
+			ParentMessageID:     m.ParentMessageID,
+			SequenceID:          m.SequenceID,
+			Type:                m.Type,
+			CreatedAt:           m.CreatedAt,
+			ExcludedFromContext: m.ExcludedFromContext,
+			LlmData:             rawJSON(m.LlmData),
+			UserData:            rawJSON(m.UserData),
+			UsageData:           rawJSON(m.UsageData),
+			DisplayData:         rawJSON(m.DisplayData),
+		}
+	}
+	for _, subagent := range subagents {
+		child, err := s.exportConversation(ctx, subagent.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		export.Subagents = append(export.Subagents, *child)
+	}
+	return &export, nil
+}
+
+// toConversationImport converts an export into the form the database imports.
+func (e *ConversationExport) toConversationImport() db.ConversationImport {
+	imp := db.ConversationImport{Conversation: e.Conversation}
+	for _, m := range e.Messages {
+		imp.Messages = append(imp.Messages, generated.Message{
+			MessageID:           m.MessageID,
+			ParentMessageID:     m.ParentMessageID,
+			SequenceID:          m.SequenceID,
+			Type:                m.Type,
+			CreatedAt:           m.CreatedAt,
+			ExcludedFromContext: m.ExcludedFromContext,
+			LlmData:             columnJSON(m.LlmData),
+			UserData:            columnJSON(m.UserData),
+			UsageData:           columnJSON(m.UsageData),
+			DisplayData:         columnJSON(m.DisplayData),
+		})
+	}
+	for i := range e.Subagents {
+		imp.Subagents = append(imp.Subagents, e.Subagents[i].toConversationImport())
+	}
+	return imp
+}
+
+// validate checks the parts of an export that import relies on.
+func (e *ConversationExport) validate() error {
+	if e.Version != exportFormatVersion {
+		return fmt.Errorf("unsupported export version %d (expected %d)", e.Version, exportFormatVersion)
+	}
+	seen := make(map[string]bool, len(e.Messages))
+	for _, m := range e.Messages {
+		if m.MessageID == "" || seen[m.MessageID] {
+			return fmt.Errorf("message %d has a missing or duplicate message_id", m.SequenceID)
+		}
+		seen[m.MessageID] = true
+		if m.Type == "" {
+			return fmt.Errorf("message %d has no type", m.SequenceID)
+		}
+		for name, value := range map[string]json.RawMessage{"llm_data": m.LlmData, "user_data": m.UserData, "usage_data": m.UsageData, "display_data": m.DisplayData} {
+			if len(value) > 0 && !json.Valid(value) {
+				return fmt.Errorf("message %d has invalid %s", m.SequenceID, name)
+			}
+		}
+	}
+	for i := range e.Subagents {
+		if err := e.Subagents[i].validate(); err != nil {
+			return fmt.Errorf("subagent %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// handleExportConversation handles GET /conversation/<id>/export?format=json|markdown
+func (s *Server) handleExportConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "markdown" {
+		http.Error(w, "format must be json or markdown", http.StatusBadRequest)
+		return
+	}
+
+	export, err := s.exportConversation(ctx, conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to export conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	name := conversationID
+	if export.Conversation.Slug != nil {
+		name = *export.Conversation.Slug
+	}
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".md"))
+		w.Write([]byte(renderConversationMarkdown(export)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(export)
+}
+
+// handleImportConversation handles POST /api/conversations/import
+// The body is a ConversationExport. The conversation is recreated with new IDs;
+// like fork, it does NOT start the agent.
+func (s *Server) handleImportConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	var export ConversationExport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportSize)).Decode(&export); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := export.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imp := export.toConversationImport()
+	imp.UserID = requestUserID(r)
+	conversation, err := s.db.ImportConversation(ctx, &imp)
+	if err != nil {
+		s.logger.Error("Failed to import conversation", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "created",
+		"conversation_id": conversation.ConversationID,
+		"slug":            conversation.Slug,
+	})
+}
+
+// renderConversationMarkdown renders the active branch of a conversation, and
+// then of each subagent, for reading.
+func renderConversationMarkdown(export *ConversationExport) string {
+	var b strings.Builder
+	writeConversationMarkdown(&b, export, "#")
+	return b.String()
+}
+
+func writeConversationMarkdown(b *strings.Builder, export *ConversationExport, heading string) {
+	conv := export.Conversation
+	title := conv.ConversationID
+	if conv.Slug != nil {
+		title = *conv.Slug
+	}
+	if conv.ParentConversationID != nil {
+		fmt.Fprintf(b, "%s Subagent: %s\n\n", heading, title)
+	} else {
+		fmt.Fprintf(b, "%s %s\n\n", heading, title)
+	}
+	fmt.Fprintf(b, "- Conversation: `%s`\n", conv.ConversationID)
+	fmt.Fprintf(b, "- Created: %s\n", conv.CreatedAt.UTC().Format(time.RFC3339))
+	if conv.Model != nil {
+		fmt.Fprintf(b, "- Model: %s\n", *conv.Model)
+	}
+	if conv.Cwd != nil {
+		fmt.Fprintf(b, "- Working directory: `%s`\n", *conv.Cwd)
+	}
+
+	var total llm.Usage
+	var body strings.Builder
+	for _, m := range activeBranch(export.Messages, conv.ActiveMessageID) {
+		var usage llm.Usage
+		if len(m.UsageData) > 0 && json.Unmarshal(m.UsageData, &usage) == nil {
+			total.Add(usage)
+		}
+		writeMessageMarkdown(&body, m, heading+"#")
+	}
+	fmt.Fprintf(b, "- Usage: %d input tokens, %d output tokens, $%.4f\n\n",
+		total.InputTokens+total.CacheCreationInputTokens+total.CacheReadInputTokens, total.OutputTokens, total.CostUSD)
+	b.WriteString(body.String())
+
+	for i := range export.Subagents {
+		writeConversationMarkdown(b, &export.Subagents[i], heading+"#")
+	}
+}
+
+// activeBranch returns the messages on the branch ending at activeID, in
+// order. If activeID is unknown, all messages are returned.
+func activeBranch(messages []ExportedMessage, activeID *string) []ExportedMessage {
+	byID := make(map[string]ExportedMessage, len(messages))
+	for _, m := range messages {
+		byID[m.MessageID] = m
+	}
+	if activeID == nil {
+		return messages
+	}
+	var branch []ExportedMessage
+	for id := activeID; id != nil; {
+		m, ok := byID[*id]
+		if !ok || len(branch) > len(messages) {
+			return messages
+		}
+		branch = append(branch, m)
+		id = m.ParentMessageID
+	}
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+	return branch
+}
+
+func writeMessageMarkdown(b *strings.Builder, m ExportedMessage, heading string) {
+	if m.Type == string(db.MessageTypeSystem) || len(m.LlmData) == 0 {
+		return
+	}
+	var msg llm.Message
+	if err := json.Unmarshal(m.LlmData, &msg); err != nil {
+		return
+	}
+
+	role := "Assistant"
+	switch {
+	case m.Type == string(db.MessageTypeError):
+		role = "Error"
+	case msg.Role == llm.MessageRoleUser:
+		role = "User"
+		if len(msg.Content) > 0 && msg.Content[0].Type == llm.ContentTypeToolResult {
+			role = "Tool results"
+		}
+	}
+	fmt.Fprintf(b, "%s %s\n\n", heading, role)
+
+	for _, c := range msg.Content {
+		switch c.Type {
+		case llm.ContentTypeText:
+			if c.Text != "" {
+				fmt.Fprintf(b, "%s\n\n", c.Text)
+			}
+		case llm.ContentTypeThinking:
+			if c.Thinking != "" {
+				fmt.Fprintf(b, "<details><summary>Thinking</summary>\n\n%s\n\n</details>\n\n", c.Thinking)
+			}
+		case llm.ContentTypeToolUse:
+			input := string(c.ToolInput)
+			var indented bytes.Buffer
+			if json.Indent(&indented, c.ToolInput, "", "  ") == nil {
+				input = indented.String()
+			}
+			fmt.Fprintf(b, "**Tool call: `%s`**\n\n```json\n%s\n```\n\n", c.ToolName, input)
+		case llm.ContentTypeToolResult:
+			var texts []string
+			for _, r := range c.ToolResult {
+				if r.Type == llm.ContentTypeText && r.Text != "" {
+					texts = append(texts, r.Text)
+				}
+			}
+			label := "Result"
+			if c.ToolError {
+				label = "Error"
+			}
+			fmt.Fprintf(b, "**%s:**\n\n%s\n\n", label, fence(strings.Join(texts, "\n")))
+		}
+	}
+}
+
+// fence wraps text in a code fence long enough not to be closed by the text itself.
+func fence(text string) string {
+	marker := "```"
+	for strings.Contains(text, marker) {
+		marker += "`"
+	}
+	return marker + "\n" + text + "\n" + marker
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func (h *TestHarness) export(conversationID, format string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest("GET", "/api/conversation/"+conversationID+"/export?format="+format, nil)
+	w := httptest.NewRecorder()
+	h.server.handleExportConversation(w, req, conversationID)
+	return w
+}
+
+func TestExportImportConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+	sourceID := h.convID
+	if _, err := h.db.UpdateConversationSlug(ctx, sourceID, "exported"); err != nil {
+		t.Fatal(err)
+	}
+	subagent, err := h.db.CreateSubagentConversation(ctx, "helper", sourceID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: subagent.ConversationID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "subagent done"}}},
+		UsageData:      llm.Usage{InputTokens: 10, OutputTokens: 5, CostUSD: 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := h.export(sourceID, "json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var export ConversationExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	source, err := h.db.ListMessages(ctx, sourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Version != exportFormatVersion || len(export.Messages) != len(source) {
+		t.Fatalf("expected version %d with %d messages, got version %d with %d", exportFormatVersion, len(source), export.Version, len(export.Messages))
+	}
+	if len(export.Subagents) != 1 || len(export.Subagents[0].Messages) != 1 {
+		t.Fatalf("expected one subagent with one message, got %+v", export.Subagents)
+	}
+
+	// Import into the same database: new IDs, a suffixed slug, same content.
+	req := httptest.NewRequest("POST", "/api/conversations/import", strings.NewReader(w.Body.String()))
+	rec := httptest.NewRecorder()
+	h.server.handleImportConversation(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+		Slug           string `json:"slug"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ConversationID == sourceID || resp.Slug != "exported-2" {
+		t.Fatalf("unexpected import response: %+v", resp)
+	}
+
+	imported, err := h.db.ListMessages(ctx, resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(source) {
+		t.Fatalf("expected %d imported messages, got %d", len(source), len(imported))
+	}
+	for i := range source {
+		if imported[i].MessageID == source[i].MessageID {
+			t.Errorf("message %d kept its ID", i)
+		}
+		if *imported[i].LlmData != *source[i].LlmData || !imported[i].CreatedAt.Equal(source[i].CreatedAt) {
+			t.Errorf("message %d differs after import", i)
+		}
+	}
+	conv, err := h.db.GetConversationByID(ctx, resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.ActiveMessageID == nil || *conv.ActiveMessageID != imported[len(imported)-1].MessageID {
+		t.Errorf("expected the active message to be remapped, got %v", conv.ActiveMessageID)
+	}
+	subagents, err := h.db.GetSubagents(ctx, resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subagents) != 1 || *subagents[0].Slug != "helper-2" {
+		t.Fatalf("expected the subagent to be imported as helper-2, got %+v", subagents)
+	}
+
+	// A bad version is rejected.
+	export.Version = 99
+	body, _ := json.Marshal(export)
+	rec = httptest.NewRecorder()
+	h.server.handleImportConversation(rec, httptest.NewRequest("POST", "/api/conversations/import", strings.NewReader(string(body))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown version, got %d", rec.Code)
+	}
+}
+
+func TestExportConversationMarkdown(t *testing.T) {
+	h := NewTestHarness(t)
+
+	h.NewConversation("echo: hello markdown", "")
+	h.WaitResponse()
+
+	w := h.export(h.convID, "markdown")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	md := w.Body.String()
+	for _, want := range []string{"## User", "echo: hello markdown", "## Assistant", "hello markdown", "- Usage:"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	if w := h.export(h.convID, "pdf"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", w.Code)
+	}
+	if w := h.export("missing", "json"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing conversation, got %d", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// ForkConversationRequest represents a request to fork a conversation at a message
+type ForkConversationRequest struct {
+	SequenceID int64 `json:"sequence_id"`
+}
+
+// handleForkConversation handles POST /conversation/<id>/fork
+// Creates a new conversation with the source's messages copied verbatim up to and
+// including sequence_id. Like continue, it does NOT start the agent.
+func (s *Server) handleForkConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req ForkConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.SequenceID <= 0 {
+		http.Error(w, "sequence_id is required", http.StatusBadRequest)
+		return
+	}
+
+	source, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	// The fork belongs to whoever made it; over the Unix socket, to the source's owner.
+	owner := requestUserID(r)
+	if owner == nil {
+		owner = source.UserID
+	}
+	conversation, err := s.db.ForkConversation(ctx, conversationID, req.SequenceID, owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, fmt.Sprintf("No message with sequence_id %d", req.SequenceID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to fork conversation", "conversationID", conversationID, "sequenceID", req.SequenceID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if source.Slug != nil {
+		if updated, err := s.setForkSlug(ctx, conversation.ConversationID, *source.Slug); err != nil {
+			s.logger.Warn("Failed to set slug for fork", "conversationID", conversation.ConversationID, "error", err)
+		} else {
+			conversation = updated
+		}
+	}
+
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "created",
+		"conversation_id": conversation.ConversationID,
+	})
+}
+
+// setForkSlug names a fork after its source, adding a numeric suffix when
+// "<source>-fork" is already taken.
+func (s *Server) setForkSlug(ctx context.Context, conversationID, sourceSlug string) (*generated.Conversation, error) {
+	base := strings.TrimSuffix(sourceSlug, "-fork") + "-fork"
+	candidate := base
+	for attempt := 0; attempt < 100; attempt++ {
+		conversation, err := s.db.UpdateConversationSlug(ctx, conversationID, candidate)
+		if err == nil {
+			return conversation, nil
+		}
+		if !strings.Contains(strings.ToLower(err.Error()), "unique constraint") {
+			return nil, err
+		}
+		candidate = fmt.Sprintf("%s-%d", base, attempt+2)
+	}
+	return nil, fmt.Errorf("no free slug for %q", base)
+}
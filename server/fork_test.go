@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func (h *TestHarness) fork(sequenceID int64) *httptest.ResponseRecorder {
+	h.t.Helper()
+	body := fmt.Sprintf(`{"sequence_id":%d}`, sequenceID)
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/fork", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleForkConversation(w, req, h.convID)
+	return w
+}
+
+func TestForkConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+	sourceID := h.convID
+	if _, err := h.db.UpdateConversationSlug(ctx, sourceID, "source"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fork right after the first turn.
+	messages, err := h.db.ListMessages(ctx, sourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forkAt int64
+	for _, m := range messages {
+		if m.Type == string(db.MessageTypeAgent) {
+			forkAt = m.SequenceID
+			break
+		}
+	}
+
+	w := h.fork(forkAt)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	fork, err := h.db.GetConversationByID(ctx, resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.Slug == nil || *fork.Slug != "source-fork" {
+		t.Errorf("expected slug source-fork, got %v", fork.Slug)
+	}
+	if fork.ForkedFromConversationID == nil || *fork.ForkedFromConversationID != sourceID || *fork.ForkedFromSequenceID != forkAt {
+		t.Errorf("unexpected lineage: %v %v", fork.ForkedFromConversationID, fork.ForkedFromSequenceID)
+	}
+	forked, err := h.db.ListMessages(ctx, fork.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(forked)) != forkAt || *forked[len(forked)-1].LlmData != *messages[forkAt-1].LlmData {
+		t.Fatalf("expected %d verbatim messages, got %d", forkAt, len(forked))
+	}
+
+	// Continuing the fork must not see the source's second turn.
+	h.convID = fork.ConversationID
+	h.responsesCount = 1
+	h.llm.ClearRequests()
+	h.Chat("echo: alternate")
+	h.WaitResponse()
+	var sawSecond bool
+	for _, m := range h.llm.GetLastRequest().Messages {
+		for _, c := range m.Content {
+			if c.Type == llm.ContentTypeText && strings.Contains(c.Text, "second") {
+				sawSecond = true
+			}
+		}
+	}
+	if sawSecond {
+		t.Error("fork context should stop at the fork point")
+	}
+
+	// A second fork of the same source gets a distinct slug.
+	h.convID = sourceID
+	if w := h.fork(forkAt); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := h.db.GetConversationBySlug(ctx, "source-fork-2"); err != nil {
+		t.Errorf("expected second fork slug: %v", err)
+	}
+
+	if w := h.fork(1000); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown sequence_id, got %d", w.Code)
+	}
+	if w := h.fork(0); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without sequence_id, got %d", w.Code)
+	}
+}
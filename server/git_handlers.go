@@ -11,6 +11,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"shelley.exe.dev/claudetool"
 )
 
 // GitDiffInfo represents a commit or working changes
@@ -251,7 +253,7 @@ func (s *Server) handleGitDiffFiles(w http.ResponseWriter, r *http.Request) {
 		if !isGenerated && strings.HasSuffix(parts[1], ".go") && status != "deleted" {
 			fullPath := filepath.Join(gitRoot, parts[1])
 			if content, err := os.ReadFile(fullPath); err == nil {
-				isGenerated = isAutogeneratedGoContent(content)
+				isGenerated = claudetool.IsAutogeneratedGoFile(content)
 			}
 		}
 
@@ -15,11 +15,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/claudetool/mcp"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/gitstate"
@@ -359,13 +362,13 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 	}
 
 	// Build initialization data
-	modelList := s.getModelList()
+	modelList := s.visibleModelList(r)
 
 	// Select default model - use configured default if available, otherwise first ready model
 	// If no models are available, default_model should be empty
 	defaultModel := ""
 	if len(modelList) > 0 {
-		defaultModel = s.defaultModel
+		defaultModel = s.getDefaultModel()
 		if defaultModel == "" {
 			defaultModel = models.Default().ID
 		}
@@ -413,12 +416,14 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 		"default_cwd":   defaultCwd,
 		"home_dir":      homeDir,
 	}
+	s.configMu.RLock()
 	if s.terminalURL != "" {
 		initData["terminal_url"] = s.terminalURL
 	}
 	if len(s.links) > 0 {
 		initData["links"] = s.links
 	}
+	s.configMu.RUnlock()
 
 	// Inject notification channel type metadata for the settings modal
 	initData["notification_channel_types"] = s.getNotificationChannelTypes()
@@ -475,13 +480,13 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 	if query != "" {
 		if searchContent {
 			// Search in both slug and message content
-			conversations, err = s.db.SearchConversationsWithMessages(ctx, query, int64(limit), int64(offset))
+			conversations, err = s.db.SearchConversationsWithMessages(ctx, s.ownerFilter(r), query, int64(limit), int64(offset))
 		} else {
 			// Search only in slug
-			conversations, err = s.db.SearchConversations(ctx, query, int64(limit), int64(offset))
+			conversations, err = s.db.SearchConversations(ctx, s.ownerFilter(r), query, int64(limit), int64(offset))
 		}
 	} else {
-		conversations, err = s.db.ListConversations(ctx, int64(limit), int64(offset))
+		conversations, err = s.db.ListConversations(ctx, s.ownerFilter(r), int64(limit), int64(offset))
 	}
 
 	if err != nil {
@@ -535,6 +540,10 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("GET /{id}/stream", func(w http.ResponseWriter, r *http.Request) {
 		s.handleStreamConversation(w, r, r.PathValue("id"))
 	})
+	// GET /api/conversation/<id>/export - JSON or Markdown export (can be large, compress)
+	mux.Handle("GET /{id}/export", gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleExportConversation(w, r, r.PathValue("id"))
+	})))
 	// POST endpoints - small responses, no compression needed
 	mux.HandleFunc("POST /{id}/chat", func(w http.ResponseWriter, r *http.Request) {
 		s.handleChatConversation(w, r, r.PathValue("id"))
@@ -542,6 +551,9 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("POST /{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
 		s.handleCancelConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/permission", func(w http.ResponseWriter, r *http.Request) {
+		s.handleResolvePermission(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/archive", func(w http.ResponseWriter, r *http.Request) {
 		s.handleArchiveConversation(w, r, r.PathValue("id"))
 	})
@@ -551,12 +563,36 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("POST /{id}/delete", func(w http.ResponseWriter, r *http.Request) {
 		s.handleDeleteConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/edit", func(w http.ResponseWriter, r *http.Request) {
+		s.handleEditMessage(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/branch", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSwitchBranch(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/fork", func(w http.ResponseWriter, r *http.Request) {
+		s.handleForkConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/rollback", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRollback(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/rename", func(w http.ResponseWriter, r *http.Request) {
 		s.handleRenameConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/refresh-system-prompt", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRefreshSystemPrompt(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("GET /{id}/subagents", func(w http.ResponseWriter, r *http.Request) {
 		s.handleGetSubagents(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/share", func(w http.ResponseWriter, r *http.Request) {
+		s.handleShareConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/shares", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListShares(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/unshare", func(w http.ResponseWriter, r *http.Request) {
+		s.handleUnshareConversation(w, r, r.PathValue("id"))
+	})
 	return mux
 }
 
@@ -574,7 +610,7 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 	)
 	err := s.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
-		messages, err = q.ListMessages(ctx, conversationID)
+		messages, err = q.ListActiveMessages(ctx, conversationID)
 		if err != nil {
 			return err
 		}
@@ -590,6 +626,12 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	branches, err := loadMessageBranches(ctx, s.db, conversationID, messages)
+	if err != nil {
+		s.logger.Error("Failed to get conversation branches", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	apiMessages := toAPIMessages(messages)
@@ -598,6 +640,7 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 		Conversation: conversation,
 		// ConversationState is sent via the streaming endpoint, not on initial load
 		ContextWindowSize: calculateContextWindowSize(apiMessages),
+		Branches:          branches,
 	})
 }
 
@@ -632,7 +675,7 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 	// Get LLM service for the requested model
 	modelID := req.Model
 	if modelID == "" {
-		modelID = s.defaultModel
+		modelID = s.getDefaultModel()
 	}
 
 	llmService, err := s.llmManager.GetService(modelID)
@@ -641,6 +684,10 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
 		return
 	}
+	if s.hiddenModels(r)[modelID] {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
 
 	// Get or create conversation manager
 	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
@@ -725,13 +772,17 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
 		return
 	}
+	if s.hiddenModels(r)[modelID] {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
 
 	// Create new conversation with optional cwd
 	var cwdPtr *string
 	if req.Cwd != "" {
 		cwdPtr = &req.Cwd
 	}
-	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, &modelID)
+	conversation, err := s.db.CreateUserConversation(ctx, requestUserID(r), nil, true, cwdPtr, &modelID)
 	if err != nil {
 		s.logger.Error("Failed to create conversation", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -835,9 +886,13 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Source conversation not found", http.StatusNotFound)
 		return
 	}
+	if !s.canAccessConversation(r, sourceConv) {
+		http.Error(w, "Source conversation not found", http.StatusNotFound)
+		return
+	}
 
 	// Get messages from source conversation
-	messages, err := s.db.ListMessages(ctx, req.SourceConversationID)
+	messages, err := s.db.ListActiveMessages(ctx, req.SourceConversationID)
 	if err != nil {
 		s.logger.Error("Failed to get messages", "conversationID", req.SourceConversationID, "error", err)
 		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
@@ -859,6 +914,10 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 	if modelID == "" {
 		modelID = "gpt-oss-20b-fireworks"
 	}
+	if s.hiddenModels(r)[modelID] {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
 
 	// Create new conversation with cwd from request or source conversation
 	var cwdPtr *string
@@ -867,7 +926,7 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 	} else if sourceConv.Cwd != nil {
 		cwdPtr = sourceConv.Cwd
 	}
-	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, &modelID)
+	conversation, err := s.db.CreateUserConversation(ctx, requestUserID(r), nil, true, cwdPtr, &modelID)
 	if err != nil {
 		s.logger.Error("Failed to create conversation", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1047,17 +1106,21 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 	// message during hydration, and we want to return the messages as they were before.
 	var messages []generated.Message
 	var conversation generated.Conversation
+	var branches []MessageBranches
 	resuming := lastSeqID >= 0
 	if lastSeqID < 0 {
 		err := s.db.Queries(ctx, func(q *generated.Queries) error {
 			var err error
-			messages, err = q.ListMessages(ctx, conversationID)
+			messages, err = q.ListActiveMessages(ctx, conversationID)
 			if err != nil {
 				return err
 			}
 			conversation, err = q.GetConversation(ctx, conversationID)
 			return err
 		})
+		if err == nil {
+			branches, err = loadMessageBranches(ctx, s.db, conversationID, messages)
+		}
 		if err != nil {
 			s.logger.Error("Failed to get conversation data", "conversationID", conversationID, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1071,7 +1134,7 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 		// Resuming - fetch any messages we missed while disconnected
 		err := s.db.Queries(ctx, func(q *generated.Queries) error {
 			var err error
-			messages, err = q.ListMessagesSince(ctx, generated.ListMessagesSinceParams{
+			messages, err = q.ListActiveMessagesSince(ctx, generated.ListActiveMessagesSinceParams{
 				ConversationID: conversationID,
 				SequenceID:     lastSeqID,
 			})
@@ -1119,6 +1182,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 				Model:          manager.GetModel(),
 			},
 			ContextWindowSize: ctxSize,
+			PermissionRequest: manager.PendingPermissionRequest(),
+			Branches:          branches,
+			SystemPromptStale: manager.systemPromptStale(),
 		}
 		data, _ := json.Marshal(streamData)
 		fmt.Fprintf(w, "data: %s\n\n", data)
@@ -1132,7 +1198,9 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 				Working:        manager.IsAgentWorking(),
 				Model:          manager.GetModel(),
 			},
-			Heartbeat: true,
+			Heartbeat:         true,
+			PermissionRequest: manager.PendingPermissionRequest(),
+			SystemPromptStale: manager.systemPromptStale(),
 		}
 		data, _ := json.Marshal(streamData)
 		fmt.Fprintf(w, "data: %s\n\n", data)
@@ -1248,7 +1316,13 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.getModelList())
+	json.NewEncoder(w).Encode(s.visibleModelList(r))
+}
+
+// visibleModelList returns the models the request's user may use.
+func (s *Server) visibleModelList(r *http.Request) []ModelInfo {
+	hidden := s.hiddenModels(r)
+	return slices.DeleteFunc(s.getModelList(), func(m ModelInfo) bool { return hidden[m.ID] })
 }
 
 // handleArchivedConversations handles GET /api/conversations/archived
@@ -1280,9 +1354,9 @@ func (s *Server) handleArchivedConversations(w http.ResponseWriter, r *http.Requ
 	var err error
 
 	if query != "" {
-		conversations, err = s.db.SearchArchivedConversations(ctx, query, int64(limit), int64(offset))
+		conversations, err = s.db.SearchArchivedConversations(ctx, s.ownerFilter(r), query, int64(limit), int64(offset))
 	} else {
-		conversations, err = s.db.ListArchivedConversations(ctx, int64(limit), int64(offset))
+		conversations, err = s.db.ListArchivedConversations(ctx, s.ownerFilter(r), int64(limit), int64(offset))
 	}
 
 	if err != nil {
@@ -1353,12 +1427,18 @@ func (s *Server) handleDeleteConversation(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
+	worktrees, err := s.db.ListCheckpointWorktrees(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to list checkpoint worktrees", "conversationID", conversationID, "error", err)
+	}
 	if err := s.db.DeleteConversation(ctx, conversationID); err != nil {
 		s.logger.Error("Failed to delete conversation", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	go s.deleteCheckpointRefs(conversationID, worktrees)
+
 	// Notify conversation list subscribers about the deletion
 	go s.publishConversationListUpdate(ConversationListUpdate{
 		Type:           "delete",
@@ -1384,6 +1464,9 @@ func (s *Server) handleConversationBySlug(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 	conversation, err := s.db.GetConversationBySlug(ctx, slug)
+	if err == nil && !s.canAccessConversation(r, conversation) {
+		err = fmt.Errorf("conversation not found with slug: %s", slug)
+	}
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Conversation not found", http.StatusNotFound)
@@ -1539,6 +1622,19 @@ func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// MCP server configs often carry API keys in their env, and other users'
+	// conversation policies are theirs; only admins see them.
+	if s.ownerFilter(r) != nil {
+		delete(settings, mcpServersKey)
+		for key := range settings {
+			if conversationID, ok := strings.CutPrefix(key, bashPermissionPolicyKey+":"); ok {
+				if conversation, err := s.db.GetConversationByID(r.Context(), conversationID); err != nil || !s.canAccessConversation(r, conversation) {
+					delete(settings, key)
+				}
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(settings)
 }
@@ -1562,12 +1658,40 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow known setting keys
 	allowedKeys := map[string]bool{
-		"auto_upgrade": true,
+		"auto_upgrade":             true,
+		mcpServersKey:              true,
+		autoCompactionThresholdKey: true,
+		conversationBudgetKey:      true,
+		dailyBudgetKey:             true,
 	}
-	if !allowedKeys[req.Key] {
+	if !allowedKeys[req.Key] && !isBashPermissionPolicyKey(req.Key) {
 		http.Error(w, fmt.Sprintf("Invalid setting key: %s", req.Key), http.StatusBadRequest)
 		return
 	}
+	if err := validateSettingValue(req.Key, req.Value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Settings apply to every user's conversations, so only admins may change
+	// them; users may set the bash permission policy of their own conversations.
+	if s.ownerFilter(r) != nil {
+		conversationID, ok := strings.CutPrefix(req.Key, bashPermissionPolicyKey+":")
+		if !ok {
+			http.Error(w, "only admins can change this setting", http.StatusForbidden)
+			return
+		}
+		conversation, err := s.db.GetConversationByID(r.Context(), conversationID)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			s.logger.Error("Failed to get conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err != nil || !s.canAccessConversation(r, conversation) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+	}
 
 	if err := s.db.SetSetting(r.Context(), req.Key, req.Value); err != nil {
 		s.logger.Error("Failed to set setting", "error", err, "key", req.Key)
@@ -1578,3 +1702,25 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
+
+// validateSettingValue checks setting values that have structure beyond a plain string.
+func validateSettingValue(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch {
+	case isBashPermissionPolicyKey(key):
+		_, err := claudetool.ParsePermissionPolicy(value)
+		return err
+	case key == autoCompactionThresholdKey:
+		_, err := parseCompactionThreshold(value)
+		return err
+	case key == mcpServersKey:
+		_, err := mcp.ParseServerConfigs(value)
+		return err
+	case key == conversationBudgetKey || key == dailyBudgetKey:
+		_, err := parseBudget(key, value)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+// HeadlessOutcome says how a headless run ended.
+type HeadlessOutcome string
+
+const (
+	HeadlessSuccess    HeadlessOutcome = "success"     // The agent finished its turn
+	HeadlessLLMError   HeadlessOutcome = "llm_error"   // The turn ended with an error message
+	HeadlessOverBudget HeadlessOutcome = "over_budget" // A spending limit stopped the turn
+	HeadlessTimeout    HeadlessOutcome = "timeout"     // The run was cancelled at its deadline
+)
+
+// HeadlessOptions configures RunHeadless.
+type HeadlessOptions struct {
+	Model   string
+	Cwd     string
+	Prompt  string
+	Timeout time.Duration // 0 means no limit
+}
+
+// HeadlessResult is the outcome and transcript of a headless run.
+type HeadlessResult struct {
+	ConversationID string          `json:"conversation_id"`
+	Outcome        HeadlessOutcome `json:"outcome"`
+	// Response is the text of the last agent or error message.
+	Response string       `json:"response"`
+	Messages []APIMessage `json:"messages"`
+}
+
+// headlessPollInterval is how often RunHeadless checks whether the agent is done.
+const headlessPollInterval = 100 * time.Millisecond
+
+// RunHeadless starts a conversation with a single prompt and runs the agent
+// until it ends its turn, without an HTTP server. An error is returned only if
+// the run could not start; how it ended is reported in the result.
+func (s *Server) RunHeadless(ctx context.Context, opts HeadlessOptions) (*HeadlessResult, error) {
+	if opts.Prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+	if !s.llmManager.HasModel(opts.Model) {
+		return nil, fmt.Errorf("unknown model %q; available models: %s", opts.Model, strings.Join(s.llmManager.GetAvailableModels(), ", "))
+	}
+	llmService, err := s.llmManager.GetService(opts.Model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get LLM service: %w", err)
+	}
+
+	conversation, err := s.db.CreateConversation(ctx, nil, true, &opts.Cwd, &opts.Model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	result := &HeadlessResult{ConversationID: conversation.ConversationID}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversation.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation manager: %w", err)
+	}
+	defer manager.stopLoop()
+	manager.mu.Lock()
+	manager.headless = true
+	manager.mu.Unlock()
+
+	userMessage := llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: opts.Prompt}},
+	}
+	if _, err := manager.AcceptUserMessage(ctx, llmService, opts.Model, userMessage); err != nil {
+		return nil, fmt.Errorf("failed to accept user message: %w", err)
+	}
+
+	var deadline <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(headlessPollInterval)
+	defer ticker.Stop()
+wait:
+	for manager.IsAgentWorking() {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			result.Outcome = HeadlessTimeout
+			break wait
+		case <-ctx.Done():
+			result.Outcome = HeadlessTimeout
+			break wait
+		}
+	}
+	if result.Outcome == HeadlessTimeout {
+		// Use a fresh context: ctx may be the one that ran out.
+		if err := manager.CancelConversation(context.Background()); err != nil {
+			s.logger.Warn("Failed to cancel headless conversation", "error", err)
+		}
+	}
+
+	messages, err := s.db.ListMessages(context.Background(), conversation.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+	result.Messages = toAPIMessages(messages)
+
+	// The last agent or error message decides the outcome.
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.LlmData == nil || (msg.Type != string(db.MessageTypeAgent) && msg.Type != string(db.MessageTypeError)) {
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+			continue
+		}
+		var texts []string
+		for _, content := range llmMsg.Content {
+			if content.Type == llm.ContentTypeText && content.Text != "" {
+				texts = append(texts, content.Text)
+			}
+		}
+		result.Response = strings.Join(texts, "\n")
+		if result.Outcome == "" {
+			switch {
+			case llmMsg.ErrorType == llm.ErrorTypeBudget:
+				result.Outcome = HeadlessOverBudget
+			case msg.Type == string(db.MessageTypeError) || llmMsg.ErrorType != llm.ErrorTypeNone:
+				result.Outcome = HeadlessLLMError
+			default:
+				result.Outcome = HeadlessSuccess
+			}
+		}
+		break
+	}
+	if result.Outcome == "" {
+		// The turn ended without the agent saying anything.
+		result.Outcome = HeadlessLLMError
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func TestRunHeadless(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	cwd := t.TempDir()
+
+	run := func(prompt string, timeout time.Duration) *HeadlessResult {
+		t.Helper()
+		result, err := h.server.RunHeadless(ctx, HeadlessOptions{Model: "predictable", Cwd: cwd, Prompt: prompt, Timeout: timeout})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := run("echo: all fixed", 10*time.Second)
+	if result.Outcome != HeadlessSuccess || result.Response != "all fixed" {
+		t.Errorf("expected success with the echoed response, got %s: %q", result.Outcome, result.Response)
+	}
+	var sawUser bool
+	for _, msg := range result.Messages {
+		sawUser = sawUser || msg.Type == string(db.MessageTypeUser)
+	}
+	if !sawUser || result.Messages[len(result.Messages)-1].Type != string(db.MessageTypeAgent) {
+		t.Errorf("expected the transcript to hold the prompt and the response, got %+v", result.Messages)
+	}
+	conversation, err := h.db.GetConversationByID(ctx, result.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.Cwd == nil || *conversation.Cwd != cwd {
+		t.Errorf("expected the conversation to use %s, got %v", cwd, conversation.Cwd)
+	}
+
+	if result := run("error: boom", 10*time.Second); result.Outcome != HeadlessLLMError || !strings.Contains(result.Response, "boom") {
+		t.Errorf("expected an LLM error, got %s: %q", result.Outcome, result.Response)
+	}
+
+	start := time.Now()
+	if result := run("delay: 30", 300*time.Millisecond); result.Outcome != HeadlessTimeout {
+		t.Errorf("expected a timeout, got %s", result.Outcome)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("the timed out run did not stop promptly")
+	}
+
+	// Put today's spending over the daily budget.
+	_, err = h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: result.ConversationID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "expensive"}}},
+		UsageData:      llm.Usage{CostUSD: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.db.SetSetting(ctx, dailyBudgetKey, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if result := run("echo: too late", 10*time.Second); result.Outcome != HeadlessOverBudget {
+		t.Errorf("expected the budget to stop the run, got %s: %q", result.Outcome, result.Response)
+	}
+
+	if _, err := h.server.RunHeadless(ctx, HeadlessOptions{Model: "no-such-model", Cwd: cwd, Prompt: "hi"}); err == nil {
+		t.Error("expected an error for an unknown model")
+	}
+}
@@ -3,7 +3,11 @@ package server
 import (
 	"log/slog"
 
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/lsp"
+	"shelley.exe.dev/claudetool/mcp"
 	"shelley.exe.dev/db"
+	"shelley.exe.dev/models"
 )
 
 // Link represents a custom link to be displayed in the UI
@@ -24,6 +28,10 @@ type LLMConfig struct {
 	// Gateway is the base URL of the LLM gateway (optional)
 	Gateway string
 
+	// ModelGroups are groups of models, offered like models, that fail over
+	// from one to the next (optional)
+	ModelGroups []models.GroupConfig
+
 	// TerminalURL is the URL to the terminal interface (optional)
 	TerminalURL string
 
@@ -37,6 +45,16 @@ type LLMConfig struct {
 	// Each entry is a map with at least a "type" key, plus channel-specific fields.
 	NotificationChannels []map[string]any
 
+	// MCPServers lists MCP servers from shelley.json whose tools are offered to the agent.
+	MCPServers []mcp.ServerConfig
+
+	// Diagnostics lists extra checks from shelley.json for the patch tool to run on changed files.
+	Diagnostics []claudetool.DiagnosticCommand
+
+	// LanguageServers lists language servers from shelley.json for the code
+	// navigation tool, in addition to gopls.
+	LanguageServers []lsp.ServerConfig
+
 	// DB is the database for recording LLM requests (optional)
 	DB *db.DB
 
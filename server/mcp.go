@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"shelley.exe.dev/claudetool/mcp"
+	"shelley.exe.dev/db"
+)
+
+// mcpServersKey is the settings key holding a JSON array of mcp.ServerConfig.
+// Servers configured here are added to those in shelley.json; a server with
+// the same name replaces the shelley.json entry.
+const mcpServersKey = "mcp_servers"
+
+// NewMCPManager returns an mcp.Manager whose server list is the union of
+// fileServers (from shelley.json) and the mcp_servers setting in the database.
+func NewMCPManager(database *db.DB, fileServers []mcp.ServerConfig, logger *slog.Logger) *mcp.Manager {
+	load := func(ctx context.Context) ([]mcp.ServerConfig, error) {
+		return loadMCPServers(ctx, database, fileServers)
+	}
+	return mcp.NewManager(load, logger)
+}
+
+func loadMCPServers(ctx context.Context, database *db.DB, fileServers []mcp.ServerConfig) ([]mcp.ServerConfig, error) {
+	var dbServers []mcp.ServerConfig
+	if database != nil {
+		value, err := database.GetSetting(ctx, mcpServersKey)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			dbServers, err = mcp.ParseServerConfigs(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	overridden := make(map[string]bool, len(dbServers))
+	for _, s := range dbServers {
+		overridden[s.Name] = true
+	}
+	var servers []mcp.ServerConfig
+	for _, s := range fileServers {
+		if !overridden[s.Name] {
+			servers = append(servers, s)
+		}
+	}
+	return append(servers, dbServers...), nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"shelley.exe.dev/claudetool/mcp"
+)
+
+func TestLoadMCPServers(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	fileServers := []mcp.ServerConfig{
+		{Name: "tickets", Command: "tickets-mcp"},
+		{Name: "deploys", URL: "http://old.example/mcp"},
+	}
+
+	servers, err := loadMCPServers(ctx, database, fileServers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 {
+		t.Fatalf("expected file servers only, got %+v", servers)
+	}
+
+	if err := database.SetSetting(ctx, mcpServersKey, `[{"name":"deploys","url":"http://new.example/mcp"},{"name":"docs","command":"docs-mcp"}]`); err != nil {
+		t.Fatal(err)
+	}
+	servers, err = loadMCPServers(ctx, database, fileServers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]mcp.ServerConfig{}
+	for _, s := range servers {
+		got[s.Name] = s
+	}
+	if len(servers) != 3 || got["deploys"].URL != "http://new.example/mcp" || got["docs"].Command != "docs-mcp" || got["tickets"].Command != "tickets-mcp" {
+		t.Errorf("expected DB entries to extend and override file entries, got %+v", servers)
+	}
+
+	if err := database.SetSetting(ctx, mcpServersKey, `not json`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMCPServers(ctx, database, fileServers); err == nil {
+		t.Error("expected error for invalid mcp_servers setting")
+	}
+}
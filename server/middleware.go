@@ -2,6 +2,10 @@ package server
 
 import (
 	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -21,21 +25,37 @@ func LoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 	return sloghttp.NewWithConfig(logger, config)
 }
 
-// RequireHeaderMiddleware requires a specific header to be present on all API requests.
-// This is used to ensure requests come through an authenticated proxy.
-func RequireHeaderMiddleware(headerName string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Only check API routes
-			if strings.HasPrefix(r.URL.Path, "/api/") {
-				if r.Header.Get(headerName) == "" {
-					http.Error(w, "missing required header: "+headerName, http.StatusForbidden)
-					return
-				}
-			}
+type tokenScopeContextKey struct{}
+
+// apiTokenMiddleware authenticates requests that carry an API token as
+// "Authorization: Bearer <token>". The request acts as the token's user,
+// limited to the token's scope. Requests without a token are passed on to
+// authMiddleware unchanged.
+func (s *Server) apiTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
 			next.ServeHTTP(w, r)
-		})
-	}
+			return
+		}
+		apiToken, user, err := s.db.GetAPITokenUser(r.Context(), hashToken(strings.TrimSpace(token)))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "invalid API token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to look up API token", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !tokenScopeAllows(apiToken.Scope, r) {
+			http.Error(w, fmt.Sprintf("API token scope %q does not allow %s %s", apiToken.Scope, r.Method, r.URL.Path), http.StatusForbidden)
+			return
+		}
+		ctx := context.WithValue(r.Context(), userContextKey{}, user)
+		ctx = context.WithValue(ctx, tokenScopeContextKey{}, apiToken.Scope)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 // gzipResponseWriter wraps http.ResponseWriter to compress responses
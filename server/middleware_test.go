@@ -9,67 +9,6 @@ import (
 	"testing"
 )
 
-func TestRequireHeaderMiddleware_BlocksAPIWithoutHeader(t *testing.T) {
-	handler := RequireHeaderMiddleware("X-Exedev-Userid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-
-	req := httptest.NewRequest("GET", "/api/conversations", nil)
-	w := httptest.NewRecorder()
-
-	handler.ServeHTTP(w, req)
-
-	if w.Code != http.StatusForbidden {
-		t.Errorf("expected status 403 for API request without required header, got %d", w.Code)
-	}
-}
-
-func TestRequireHeaderMiddleware_AllowsAPIWithHeader(t *testing.T) {
-	handler := RequireHeaderMiddleware("X-Exedev-Userid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-
-	req := httptest.NewRequest("GET", "/api/conversations", nil)
-	req.Header.Set("X-Exedev-Userid", "user123")
-	w := httptest.NewRecorder()
-
-	handler.ServeHTTP(w, req)
-
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200 for API request with required header, got %d", w.Code)
-	}
-}
-
-func TestRequireHeaderMiddleware_AllowsNonAPIWithoutHeader(t *testing.T) {
-	handler := RequireHeaderMiddleware("X-Exedev-Userid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-
-	req := httptest.NewRequest("GET", "/", nil)
-	w := httptest.NewRecorder()
-
-	handler.ServeHTTP(w, req)
-
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200 for non-API request without required header, got %d", w.Code)
-	}
-}
-
-func TestRequireHeaderMiddleware_AllowsVersionEndpointWithoutHeader(t *testing.T) {
-	handler := RequireHeaderMiddleware("X-Exedev-Userid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-
-	req := httptest.NewRequest("GET", "/version", nil)
-	w := httptest.NewRecorder()
-
-	handler.ServeHTTP(w, req)
-
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200 for /version without required header, got %d", w.Code)
-	}
-}
-
 func TestGzipHandler_CompressesResponse(t *testing.T) {
 	handler := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
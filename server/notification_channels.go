@@ -97,9 +97,11 @@ func (s *Server) handleListNotificationChannels(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	apiChannels := make([]NotificationChannelAPI, len(channels))
-	for i, ch := range channels {
-		apiChannels[i] = toNotificationChannelAPI(ch)
+	apiChannels := make([]NotificationChannelAPI, 0, len(channels))
+	for _, ch := range channels {
+		if s.canView(r, ch.UserID) {
+			apiChannels = append(apiChannels, toNotificationChannelAPI(ch))
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -150,6 +152,7 @@ func (s *Server) handleCreateNotificationChannel(w http.ResponseWriter, r *http.
 		DisplayName: req.DisplayName,
 		Enabled:     enabled,
 		Config:      string(configJSON),
+		UserID:      requestUserID(r),
 	})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create notification channel: %v", err), http.StatusInternalServerError)
@@ -172,6 +175,9 @@ func (s *Server) handleNotificationChannel(w http.ResponseWriter, r *http.Reques
 
 	if strings.HasSuffix(path, "/test") {
 		channelID := strings.TrimSuffix(path, "/test")
+		if !s.checkNotificationChannelAccess(w, r, channelID, false) {
+			return
+		}
 		if r.Method == http.MethodPost {
 			s.handleTestNotificationChannel(w, r, channelID)
 		} else {
@@ -185,6 +191,9 @@ func (s *Server) handleNotificationChannel(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	channelID := path
+	if !s.checkNotificationChannelAccess(w, r, channelID, r.Method != http.MethodGet) {
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -198,6 +207,24 @@ func (s *Server) handleNotificationChannel(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// checkNotificationChannelAccess reports whether the request may see, or if
+// modify is set change, a notification channel. If not, it writes an error response.
+func (s *Server) checkNotificationChannelAccess(w http.ResponseWriter, r *http.Request, channelID string, modify bool) bool {
+	if s.ownerFilter(r) == nil {
+		return true
+	}
+	ch, err := s.db.GetNotificationChannel(r.Context(), channelID)
+	if err != nil || !s.canView(r, ch.UserID) {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return false
+	}
+	if modify && !s.canModify(r, ch.UserID) {
+		http.Error(w, "Only an admin can change a shared notification channel", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 func (s *Server) handleGetNotificationChannel(w http.ResponseWriter, r *http.Request, channelID string) {
 	ch, err := s.db.GetNotificationChannel(r.Context(), channelID)
 	if err != nil {
@@ -362,6 +389,10 @@ func (s *Server) ReloadNotificationChannels() {
 			s.logger.Warn("Failed to create notification channel", "id", dbCh.ChannelID, "error", err)
 			continue
 		}
+		// A user's channel only hears about their conversations; shared ones hear about all.
+		if dbCh.UserID != nil {
+			ch = notifications.ForUser(ch, *dbCh.UserID)
+		}
 		active = append(active, ch)
 	}
 
@@ -373,6 +404,10 @@ func (s *Server) ReloadNotificationChannels() {
 // from shelley.json config if the DB table is empty. One-time migration for
 // backwards compatibility.
 func (s *Server) SeedNotificationChannelsFromConfig(configs []map[string]any) {
+	s.configMu.Lock()
+	s.notificationChannelConfigs = configs
+	s.configMu.Unlock()
+
 	existing, err := s.db.GetNotificationChannels(context.Background())
 	if err != nil {
 		s.logger.Warn("Failed to check existing notification channels", "error", err)
@@ -386,36 +421,42 @@ func (s *Server) SeedNotificationChannelsFromConfig(configs []map[string]any) {
 	}
 
 	for _, cfg := range configs {
-		typeName, _ := cfg["type"].(string)
-		if typeName == "" {
-			continue
-		}
+		s.createNotificationChannelFromConfig(cfg)
+	}
+}
 
-		// Build config without the "type" key
-		configMap := make(map[string]any)
-		for k, v := range cfg {
-			if k != "type" {
-				configMap[k] = v
-			}
-		}
-		configJSON, err := json.Marshal(configMap)
-		if err != nil {
-			s.logger.Warn("Failed to marshal config for seeding", "type", typeName, "error", err)
-			continue
-		}
+// createNotificationChannelFromConfig stores a notification channel given by
+// a shelley.json entry.
+func (s *Server) createNotificationChannelFromConfig(cfg map[string]any) {
+	typeName, _ := cfg["type"].(string)
+	if typeName == "" {
+		return
+	}
 
-		channelID := "notif-" + uuid.New().String()[:8]
-		_, err = s.db.CreateNotificationChannel(context.Background(), generated.CreateNotificationChannelParams{
-			ChannelID:   channelID,
-			ChannelType: typeName,
-			DisplayName: typeName,
-			Enabled:     1,
-			Config:      string(configJSON),
-		})
-		if err != nil {
-			s.logger.Warn("Failed to seed notification channel", "type", typeName, "error", err)
-			continue
+	// Build config without the "type" key
+	configMap := make(map[string]any)
+	for k, v := range cfg {
+		if k != "type" {
+			configMap[k] = v
 		}
-		s.logger.Info("Seeded notification channel from config", "type", typeName, "id", channelID)
 	}
+	configJSON, err := json.Marshal(configMap)
+	if err != nil {
+		s.logger.Warn("Failed to marshal config for seeding", "type", typeName, "error", err)
+		return
+	}
+
+	channelID := "notif-" + uuid.New().String()[:8]
+	_, err = s.db.CreateNotificationChannel(context.Background(), generated.CreateNotificationChannelParams{
+		ChannelID:   channelID,
+		ChannelType: typeName,
+		DisplayName: typeName,
+		Enabled:     1,
+		Config:      string(configJSON),
+	})
+	if err != nil {
+		s.logger.Warn("Failed to seed notification channel", "type", typeName, "error", err)
+		return
+	}
+	s.logger.Info("Seeded notification channel from config", "type", typeName, "id", channelID)
 }
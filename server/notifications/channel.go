@@ -11,3 +11,20 @@ type Channel interface {
 	// Send delivers a notification event through this channel.
 	Send(ctx context.Context, event Event) error
 }
+
+// ForUser wraps ch so that it only receives events for userID's conversations.
+func ForUser(ch Channel, userID string) Channel {
+	return userChannel{Channel: ch, userID: userID}
+}
+
+type userChannel struct {
+	Channel
+	userID string
+}
+
+func (c userChannel) Send(ctx context.Context, event Event) error {
+	if event.UserID != c.userID {
+		return nil
+	}
+	return c.Channel.Send(ctx, event)
+}
@@ -14,8 +14,10 @@ const (
 type Event struct {
 	Type           EventType `json:"type"`
 	ConversationID string    `json:"conversation_id"`
-	Timestamp      time.Time `json:"timestamp"`
-	Payload        any       `json:"payload,omitempty"`
+	// UserID is the owner of the conversation, or empty if it has none.
+	UserID    string    `json:"user_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Payload   any       `json:"payload,omitempty"`
 }
 
 // AgentDonePayload is the payload for EventAgentDone.
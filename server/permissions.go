@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// bashPermissionPolicyKey is the settings key holding the global bash permission policy.
+// A per-conversation policy is stored under bashPermissionPolicyKey + ":" + conversationID
+// and takes precedence over the global one.
+const bashPermissionPolicyKey = "bash_permission_policy"
+
+// permissionTimeout bounds how long an "ask" rule waits for the user, so an
+// unattended conversation is denied rather than stuck. A variable for tests.
+var permissionTimeout = 10 * time.Minute
+
+// PermissionRequest asks the user to approve a bash command matched by an "ask" rule.
+// It is sent over the stream of the conversation the user is watching (for a
+// subagent, its root conversation) when the request is created, and again with
+// Resolved set once the user has answered (or the tool call was cancelled).
+// ConversationID is the conversation whose command is waiting.
+type PermissionRequest struct {
+	ID             string   `json:"id"`
+	ConversationID string   `json:"conversation_id"`
+	Command        string   `json:"command"`
+	Programs       []string `json:"programs"`
+	Resolved       bool     `json:"resolved,omitempty"`
+	Approved       bool     `json:"approved,omitempty"`
+}
+
+// pendingPermission is a PermissionRequest awaiting a user decision.
+type pendingPermission struct {
+	req      PermissionRequest
+	decision chan bool
+}
+
+// isBashPermissionPolicyKey reports whether key names a global or per-conversation bash policy.
+func isBashPermissionPolicyKey(key string) bool {
+	return key == bashPermissionPolicyKey || strings.HasPrefix(key, bashPermissionPolicyKey+":")
+}
+
+// loadBashPermissionPolicy returns the policy that applies to this conversation,
+// or nil if none is configured. A per-conversation policy set on this conversation
+// or, for a subagent, on the nearest ancestor that has one takes precedence over
+// the global policy, so that subagents are held to their parent's rules.
+func (cm *ConversationManager) loadBashPermissionPolicy(ctx context.Context) (*claudetool.PermissionPolicy, error) {
+	var keys []string
+	id := cm.conversationID
+	seen := map[string]bool{}
+	for !seen[id] {
+		seen[id] = true
+		keys = append(keys, bashPermissionPolicyKey+":"+id)
+		conversation, err := cm.db.GetConversationByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load conversation %s: %w", id, err)
+		}
+		if conversation.ParentConversationID == nil {
+			break
+		}
+		id = *conversation.ParentConversationID
+	}
+	keys = append(keys, bashPermissionPolicyKey)
+
+	for _, key := range keys {
+		value, err := cm.db.GetSetting(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", key, err)
+		}
+		if value == "" {
+			continue
+		}
+		return claudetool.ParsePermissionPolicy(value)
+	}
+	return nil, nil
+}
+
+// checkBashPermission is the claudetool.PermissionCallback for this conversation's bash tool.
+// Denied commands fail immediately; commands matching an "ask" rule block until the
+// user answers the PermissionRequest, permissionTimeout passes, or ctx is cancelled.
+// Headless runs have nobody to ask, so there "ask" means deny.
+func (cm *ConversationManager) checkBashPermission(ctx context.Context, command string) error {
+	policy, err := cm.loadBashPermissionPolicy(ctx)
+	if err != nil {
+		// Fail closed: a broken policy should not silently grant access.
+		cm.logger.Error("failed to load bash permission policy", "error", err)
+		return fmt.Errorf("permission denied: bash permission policy could not be loaded: %w", err)
+	}
+	if policy == nil {
+		return nil
+	}
+
+	action, programs := policy.Evaluate(command)
+	switch action {
+	case claudetool.PermissionAllow:
+		return nil
+	case claudetool.PermissionDeny:
+		cm.logger.Info("bash command denied by policy", "command", command, "programs", programs)
+		return fmt.Errorf("permission denied: command blocked by bash permission policy")
+	}
+
+	prompter := cm
+	if cm.permissionPrompter != nil {
+		if m := cm.permissionPrompter(ctx); m != nil {
+			prompter = m
+		}
+	}
+	prompter.mu.Lock()
+	headless := prompter.headless
+	prompter.mu.Unlock()
+	if headless {
+		cm.logger.Info("bash command needs approval in a headless run", "command", command, "programs", programs)
+		return fmt.Errorf("permission denied: the bash permission policy requires approval for this command, and there is no user to approve it in a headless run")
+	}
+
+	approved, err := prompter.requestPermission(ctx, cm.conversationID, command, programs)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return fmt.Errorf("permission denied: the user rejected this command")
+	}
+	return nil
+}
+
+// requestPermission publishes a PermissionRequest for a command in conversationID
+// (this conversation or one of its subagents) on this conversation's stream, and
+// waits for the user's decision.
+func (cm *ConversationManager) requestPermission(ctx context.Context, conversationID, command string, programs []string) (bool, error) {
+	p := &pendingPermission{
+		req: PermissionRequest{
+			ID:             rand.Text(),
+			ConversationID: conversationID,
+			Command:        command,
+			Programs:       programs,
+		},
+		decision: make(chan bool, 1),
+	}
+
+	cm.mu.Lock()
+	if cm.pendingPermissions == nil {
+		cm.pendingPermissions = make(map[string]*pendingPermission)
+	}
+	cm.pendingPermissions[p.req.ID] = p
+	cm.mu.Unlock()
+
+	cm.logger.Info("waiting for bash command approval", "requestID", p.req.ID, "conversationID", conversationID, "command", command)
+	req := p.req
+	cm.subpub.Broadcast(StreamResponse{PermissionRequest: &req})
+
+	// abandon withdraws the request if it is still pending.
+	abandon := func() {
+		cm.mu.Lock()
+		_, pending := cm.pendingPermissions[p.req.ID]
+		delete(cm.pendingPermissions, p.req.ID)
+		cm.mu.Unlock()
+		if !pending {
+			return
+		}
+		resolved := p.req
+		resolved.Resolved = true
+		cm.subpub.Broadcast(StreamResponse{PermissionRequest: &resolved})
+	}
+
+	timer := time.NewTimer(permissionTimeout)
+	defer timer.Stop()
+	select {
+	case approved := <-p.decision:
+		return approved, nil
+	case <-timer.C:
+		abandon()
+		cm.logger.Info("bash command approval timed out", "requestID", p.req.ID)
+		return false, fmt.Errorf("permission denied: nobody approved this command within %s", permissionTimeout)
+	case <-ctx.Done():
+		abandon()
+		return false, ctx.Err()
+	}
+}
+
+// ResolvePermission records the user's decision for a pending PermissionRequest.
+// It returns false if no such request is pending.
+func (cm *ConversationManager) ResolvePermission(requestID string, approved bool) bool {
+	cm.mu.Lock()
+	p, ok := cm.pendingPermissions[requestID]
+	if ok {
+		delete(cm.pendingPermissions, requestID)
+	}
+	cm.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	cm.logger.Info("bash command approval resolved", "requestID", requestID, "approved", approved)
+	p.decision <- approved
+	resolved := p.req
+	resolved.Resolved = true
+	resolved.Approved = approved
+	cm.subpub.Broadcast(StreamResponse{PermissionRequest: &resolved})
+	return true
+}
+
+// PendingPermissionRequest returns a request still awaiting a decision, if any,
+// so that newly connected clients can show the prompt.
+func (cm *ConversationManager) PendingPermissionRequest() *PermissionRequest {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for _, p := range cm.pendingPermissions {
+		req := p.req
+		return &req
+	}
+	return nil
+}
+
+// permissionPrompter returns the active manager of the root of conversationID's
+// subagent tree, whose stream is the one the user is watching, or nil if it
+// isn't active.
+func (s *Server) permissionPrompter(ctx context.Context, conversationID string) *ConversationManager {
+	rootID := conversationID
+	seen := map[string]bool{}
+	for !seen[rootID] {
+		seen[rootID] = true
+		conversation, err := s.db.GetConversationByID(ctx, rootID)
+		if err != nil || conversation.ParentConversationID == nil {
+			break
+		}
+		rootID = *conversation.ParentConversationID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.activeConversations[rootID]
+}
+
+// handleResolvePermission handles POST /api/conversation/{id}/permission
+func (s *Server) handleResolvePermission(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req struct {
+		RequestID string `json:"request_id"`
+		Approved  bool   `json:"approved"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.RequestID == "" {
+		http.Error(w, "request_id is required", http.StatusBadRequest)
+		return
+	}
+
+	// A subagent's requests are held by its root conversation.
+	manager := s.permissionPrompter(r.Context(), conversationID)
+	if manager == nil {
+		s.mu.Lock()
+		manager = s.activeConversations[conversationID]
+		s.mu.Unlock()
+	}
+	if manager == nil || !manager.ResolvePermission(req.RequestID, req.Approved) {
+		http.Error(w, "Permission request not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/hooks"
+)
+
+// waitPendingPermission waits for the harness conversation to block on a bash approval.
+func (h *TestHarness) waitPendingPermission() *PermissionRequest {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for time.Now().Before(deadline) {
+		h.server.mu.Lock()
+		manager := h.server.activeConversations[h.convID]
+		h.server.mu.Unlock()
+		if manager != nil {
+			if req := manager.PendingPermissionRequest(); req != nil {
+				return req
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.t.Fatal("timed out waiting for permission request")
+	return nil
+}
+
+func (h *TestHarness) resolvePermission(requestID string, approved bool) int {
+	h.t.Helper()
+	body := fmt.Sprintf(`{"request_id":%q,"approved":%t}`, requestID, approved)
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/permission", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleResolvePermission(w, req, h.convID)
+	return w.Code
+}
+
+func TestBashPermissionPolicy(t *testing.T) {
+	h := NewTestHarness(t)
+	policy := `{"rules":[{"program":"rm","action":"deny"},{"program":"git","action":"ask"}],"default":"allow"}`
+	if err := h.db.SetSetting(context.Background(), bashPermissionPolicyKey, policy); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("deny", func(t *testing.T) {
+		h.NewConversation("bash: rm -f /nonexistent/shelley-permission-test", t.TempDir())
+		result := h.WaitToolResult()
+		if !strings.Contains(result, "permission denied") {
+			t.Errorf("expected permission denied, got: %s", result)
+		}
+	})
+
+	t.Run("ask_approved", func(t *testing.T) {
+		h.NewConversation("bash: git --version", t.TempDir())
+		req := h.waitPendingPermission()
+		if req.Command != "git --version" || len(req.Programs) != 1 || req.Programs[0] != "git" {
+			t.Errorf("unexpected permission request: %+v", req)
+		}
+		if code := h.resolvePermission(req.ID, true); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		result := h.WaitToolResult()
+		if !strings.Contains(result, "git version") {
+			t.Errorf("expected git to run after approval, got: %s", result)
+		}
+	})
+
+	t.Run("ask_rejected", func(t *testing.T) {
+		h.NewConversation("bash: git --version", t.TempDir())
+		req := h.waitPendingPermission()
+		if code := h.resolvePermission(req.ID, false); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		result := h.WaitToolResult()
+		if !strings.Contains(result, "rejected") {
+			t.Errorf("expected rejection, got: %s", result)
+		}
+		if code := h.resolvePermission(req.ID, true); code != http.StatusNotFound {
+			t.Errorf("expected 404 for already-resolved request, got %d", code)
+		}
+	})
+
+	t.Run("ask_timed_out", func(t *testing.T) {
+		defer func(d time.Duration) { permissionTimeout = d }(permissionTimeout)
+		permissionTimeout = 50 * time.Millisecond
+		h.NewConversation("bash: git --version", t.TempDir())
+		result := h.WaitToolResult()
+		if !strings.Contains(result, "nobody approved") {
+			t.Errorf("expected the request to time out, got: %s", result)
+		}
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		dir := t.TempDir()
+		marker := filepath.Join(dir, "marker")
+		if err := os.WriteFile(marker, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, ".shelley"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, hooks.ConfigPath), []byte(`{"pre_tool_use": [{"command": "rm -f marker"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		h.NewConversation("bash: echo hello", dir)
+		result := h.WaitToolResult()
+		if !strings.Contains(result, "not run: permission denied") {
+			t.Errorf("expected the hook to be refused and veto the call, got: %s", result)
+		}
+		if _, err := os.Stat(marker); err != nil {
+			t.Errorf("expected the hook not to run: %v", err)
+		}
+	})
+
+	t.Run("conversation_policy_overrides_global", func(t *testing.T) {
+		h.NewConversation("echo: hello", t.TempDir())
+		h.WaitResponse()
+		key := bashPermissionPolicyKey + ":" + h.convID
+		if err := h.db.SetSetting(context.Background(), key, `{"rules":[],"default":"allow"}`); err != nil {
+			t.Fatal(err)
+		}
+		h.Chat("bash: rm -f /nonexistent/shelley-permission-test")
+		result := h.WaitToolResult()
+		if strings.Contains(result, "permission denied") {
+			t.Errorf("expected conversation policy to allow rm, got: %s", result)
+		}
+	})
+}
+
+func TestSetSettingValidatesPermissionPolicy(t *testing.T) {
+	h := NewTestHarness(t)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"key":"bash_permission_policy","value":"{\"rules\":[{\"program\":\"rm\",\"action\":\"deny\"}]}"}`, http.StatusOK},
+		{`{"key":"bash_permission_policy:c123","value":"{\"default\":\"ask\"}"}`, http.StatusOK},
+		{`{"key":"bash_permission_policy","value":"{\"default\":\"never\"}"}`, http.StatusBadRequest},
+		{`{"key":"bash_permission_policy","value":""}`, http.StatusOK},
+		{`{"key":"bash_permission_policyx","value":""}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("POST", "/api/settings", strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		h.server.handleSetSetting(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", tc.body, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSubagentPermissionRequests(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	if err := h.db.SetSetting(ctx, bashPermissionPolicyKey, `{"default":"ask"}`); err != nil {
+		t.Fatal(err)
+	}
+	cwd := t.TempDir()
+	h.NewConversation("echo: hello", cwd)
+	h.WaitResponse()
+	sub, err := h.db.CreateSubagentConversation(ctx, "helper", h.convID, &cwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subManager, err := h.server.getOrCreateConversationManager(ctx, sub.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request shows up on the root conversation, which is the one being watched.
+	result := make(chan error, 1)
+	go func() { result <- subManager.checkBashPermission(ctx, "ls") }()
+	req := h.waitPendingPermission()
+	if req.ConversationID != sub.ConversationID {
+		t.Errorf("expected the request to name the subagent, got %q", req.ConversationID)
+	}
+	if subManager.PendingPermissionRequest() != nil {
+		t.Error("expected the request to be held by the root conversation")
+	}
+	if code := h.resolvePermission(req.ID, true); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("expected the approved command to be allowed, got %v", err)
+	}
+
+	// With nobody watching a headless run, "ask" denies.
+	root := h.server.permissionPrompter(ctx, sub.ConversationID)
+	root.mu.Lock()
+	root.headless = true
+	root.mu.Unlock()
+	if err := subManager.checkBashPermission(ctx, "ls"); err == nil || !strings.Contains(err.Error(), "headless") {
+		t.Errorf("expected a headless denial, got %v", err)
+	}
+
+	// The parent's own policy applies to its subagents, ahead of the global one.
+	if err := h.db.SetSetting(ctx, bashPermissionPolicyKey+":"+h.convID, `{"rules":[{"program":"rm","action":"deny"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	nested, err := h.db.CreateSubagentConversation(ctx, "nested", sub.ConversationID, &cwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedManager, err := h.server.getOrCreateConversationManager(ctx, nested.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nestedManager.checkBashPermission(ctx, "rm -rf x"); err == nil || !strings.Contains(err.Error(), "blocked by bash permission policy") {
+		t.Errorf("expected the parent's policy to deny rm, got %v", err)
+	}
+	if err := nestedManager.checkBashPermission(ctx, "ls"); err != nil {
+		t.Errorf("expected the parent's policy to allow ls, got %v", err)
+	}
+}
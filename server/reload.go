@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+	"shelley.exe.dev/skills"
+)
+
+var errNoSystemPrompt = errors.New("conversation has no system prompt to refresh")
+
+// ReloadedConfig is the part of shelley.json the web UI uses. It is sent to
+// clients in a config_reloaded update when the file changes.
+type ReloadedConfig struct {
+	DefaultModel string `json:"default_model,omitempty"`
+	TerminalURL  string `json:"terminal_url,omitempty"`
+	Links        []Link `json:"links"`
+}
+
+// reconfigurableProvider is implemented by LLM providers, like models.Manager,
+// whose API keys and gateway can change while the server runs.
+type reconfigurableProvider interface {
+	Reconfigure(cfg *models.Config) error
+}
+
+// getDefaultModel returns the model for requests that don't name one.
+func (s *Server) getDefaultModel() string {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.defaultModel
+}
+
+// ReloadConfig applies a reloaded shelley.json: links, the terminal URL and
+// default model, LLM API keys, gateway and model groups, and notification
+// channels added to the file. Conversations already running keep their model's previous
+// settings, and other settings, such as MCP servers, take effect on restart.
+func (s *Server) ReloadConfig(cfg *LLMConfig) {
+	if provider, ok := s.llmManager.(reconfigurableProvider); ok {
+		if err := provider.Reconfigure(modelsConfig(cfg)); err != nil {
+			s.logger.Warn("Failed to reload custom models", "error", err)
+		}
+	}
+
+	s.configMu.Lock()
+	s.terminalURL = cfg.TerminalURL
+	s.defaultModel = cfg.DefaultModel
+	s.links = cfg.Links
+	var added []map[string]any
+	for _, channel := range cfg.NotificationChannels {
+		if !slices.ContainsFunc(s.notificationChannelConfigs, func(c map[string]any) bool { return reflect.DeepEqual(c, channel) }) {
+			added = append(added, channel)
+		}
+	}
+	s.notificationChannelConfigs = cfg.NotificationChannels
+	reloaded := ReloadedConfig{
+		DefaultModel: s.defaultModel,
+		TerminalURL:  s.terminalURL,
+		Links:        s.links,
+	}
+	s.configMu.Unlock()
+
+	// Channels stay in the database once created, so that the ones a user has
+	// edited or deleted in the UI are left alone; only new entries are added.
+	if len(added) > 0 {
+		for _, channel := range added {
+			s.createNotificationChannelFromConfig(channel)
+		}
+		s.ReloadNotificationChannels()
+	}
+
+	s.logger.Info("Reloaded config", "links", len(cfg.Links), "defaultModel", cfg.DefaultModel, "notificationChannelsAdded", len(added))
+	s.publishConversationListUpdate(ConversationListUpdate{
+		Type:   "config_reloaded",
+		Config: &reloaded,
+	})
+}
+
+// watchDebounce is how long the file watcher waits for a burst of changes,
+// such as an editor saving a file, to settle.
+const watchDebounce = 250 * time.Millisecond
+
+// fileWatcher watches shelley.json, and the guidance files and skills behind
+// the system prompts of active conversations, for changes.
+type fileWatcher struct {
+	server     *Server
+	watcher    *fsnotify.Watcher
+	configPath string
+	loadConfig func() *LLMConfig
+
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+// WatchFiles watches configPath, if set, and the guidance files and skills of
+// active conversations. When configPath changes, loadConfig is called and its
+// result applied with ReloadConfig. When the files behind a conversation's
+// system prompt change, the conversation is offered a refresh. It must be
+// called before the server starts; watching stops when the server shuts down.
+func (s *Server) WatchFiles(configPath string, loadConfig func() *LLMConfig) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	fw := &fileWatcher{
+		server:     s,
+		watcher:    watcher,
+		loadConfig: loadConfig,
+		dirs:       make(map[string]bool),
+	}
+	if configPath != "" {
+		if fw.configPath, err = filepath.Abs(configPath); err != nil {
+			watcher.Close()
+			return err
+		}
+		// Watch the directory rather than the file, so that editors that
+		// replace the file when saving are noticed.
+		fw.add([]string{filepath.Dir(fw.configPath)})
+	}
+	s.watcher = fw
+	go fw.run(s.shutdownCh)
+	return nil
+}
+
+// add watches dirs that aren't watched already.
+func (fw *fileWatcher) add(dirs []string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	for _, dir := range dirs {
+		if fw.dirs[dir] {
+			continue
+		}
+		if err := fw.watcher.Add(dir); err != nil {
+			if !os.IsNotExist(err) {
+				fw.server.logger.Debug("Failed to watch directory", "dir", dir, "error", err)
+			}
+			continue
+		}
+		fw.dirs[dir] = true
+	}
+}
+
+func (fw *fileWatcher) run(done <-chan struct{}) {
+	defer fw.watcher.Close()
+	var settled <-chan time.Time
+	var configChanged, promptsChanged bool
+	for {
+		select {
+		case <-done:
+			return
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			fw.server.logger.Warn("File watcher error", "error", err)
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			switch {
+			case fw.configPath != "" && event.Name == fw.configPath:
+				configChanged = true
+			case fw.isPromptSource(event):
+				promptsChanged = true
+			default:
+				continue
+			}
+			settled = time.After(watchDebounce)
+		case <-settled:
+			settled = nil
+			if configChanged {
+				fw.server.ReloadConfig(fw.loadConfig())
+			}
+			if promptsChanged {
+				fw.checkConversations()
+			}
+			configChanged, promptsChanged = false, false
+		}
+	}
+}
+
+// isPromptSource reports whether a change may affect system prompts: a change
+// to a guidance file or SKILL.md, or a skill directory added or removed.
+func (fw *fileWatcher) isPromptSource(event fsnotify.Event) bool {
+	name := strings.ToLower(filepath.Base(event.Name))
+	switch name {
+	case "agent.md", "agents.md", "claude.md", "dear_llm.md", "readme.md", "skill.md":
+		return true
+	}
+	parent := filepath.Dir(event.Name)
+	if filepath.Base(parent) != ".skills" && !slices.Contains(skills.DefaultDirs(), parent) {
+		return false
+	}
+	// Watch a new skill's directory so its SKILL.md is noticed when written.
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			fw.add([]string{event.Name})
+		}
+	}
+	return true
+}
+
+// watchConversation starts watching the files behind a conversation's system
+// prompt, if it isn't watched already.
+func (fw *fileWatcher) watchConversation(cm *ConversationManager) {
+	cm.mu.Lock()
+	watched := cm.promptDigest != ""
+	cm.mu.Unlock()
+	if !watched {
+		go func() {
+			fw.add(cm.checkSystemPromptSources())
+		}()
+	}
+}
+
+// checkConversations offers a refresh to active conversations whose guidance
+// files or skills have changed.
+func (fw *fileWatcher) checkConversations() {
+	s := fw.server
+	s.mu.Lock()
+	managers := make([]*ConversationManager, 0, len(s.activeConversations))
+	for _, cm := range s.activeConversations {
+		managers = append(managers, cm)
+	}
+	s.mu.Unlock()
+
+	for _, cm := range managers {
+		fw.add(cm.checkSystemPromptSources())
+	}
+}
+
+// checkSystemPromptSources records the digest of the guidance files and skills
+// behind the conversation's system prompt the first time it is called. Later,
+// if they have changed, it offers subscribers a refresh. It returns the
+// directories to watch for changes.
+func (cm *ConversationManager) checkSystemPromptSources() []string {
+	if cm.isSubagent() {
+		return nil
+	}
+	cm.mu.Lock()
+	cwd := cm.cwd
+	cm.mu.Unlock()
+
+	digest, dirs := systemPromptSources(cwd)
+	if digest == "" {
+		return nil
+	}
+
+	cm.mu.Lock()
+	changed := cm.promptDigest != "" && cm.promptDigest != digest && !cm.promptStale
+	if cm.promptDigest == "" {
+		cm.promptDigest = digest
+	}
+	if changed {
+		cm.promptStale = true
+	}
+	cm.mu.Unlock()
+
+	if changed {
+		cm.logger.Info("Guidance files or skills changed; offering to refresh the system prompt")
+		stale := true
+		cm.subpub.Broadcast(StreamResponse{SystemPromptStale: &stale})
+	}
+	return dirs
+}
+
+// systemPromptStale returns true if the conversation has been offered a
+// refresh of its system prompt, for a new subscriber, and nil otherwise.
+func (cm *ConversationManager) systemPromptStale() *bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if !cm.promptStale {
+		return nil
+	}
+	stale := true
+	return &stale
+}
+
+// RefreshSystemPrompt regenerates the conversation's system prompt from its
+// current guidance files and skills. The agent sees it from the next message.
+func (cm *ConversationManager) RefreshSystemPrompt(ctx context.Context) error {
+	if cm.IsAgentWorking() {
+		return errAgentWorking
+	}
+	if cm.isSubagent() {
+		return errNoSystemPrompt
+	}
+
+	var messages []generated.Message
+	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessagesForContext(ctx, cm.conversationID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get conversation history: %w", err)
+	}
+	i := slices.IndexFunc(messages, func(m generated.Message) bool { return m.Type == string(db.MessageTypeSystem) })
+	if i < 0 {
+		return errNoSystemPrompt
+	}
+
+	cm.mu.Lock()
+	cwd := cm.cwd
+	cm.mu.Unlock()
+	systemPrompt, err := GenerateSystemPrompt(cwd)
+	if err != nil {
+		return fmt.Errorf("failed to generate system prompt: %w", err)
+	}
+	data, err := json.Marshal(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: systemPrompt}},
+	})
+	if err != nil {
+		return err
+	}
+	llmData := string(data)
+	if err := cm.db.UpdateMessageLLMData(ctx, messages[i].MessageID, &llmData); err != nil {
+		return fmt.Errorf("failed to store system prompt: %w", err)
+	}
+
+	// The loop holds the old system prompt; the next message starts a new one.
+	cm.resetLoop()
+
+	digest, _ := systemPromptSources(cwd)
+	cm.mu.Lock()
+	cm.promptDigest = digest
+	cm.promptStale = false
+	cm.mu.Unlock()
+
+	cm.logger.Info("Refreshed system prompt", "length", len(systemPrompt))
+	stale := false
+	cm.subpub.Broadcast(StreamResponse{SystemPromptStale: &stale})
+	return nil
+}
+
+// handleRefreshSystemPrompt handles POST /api/conversation/<id>/refresh-system-prompt
+func (s *Server) handleRefreshSystemPrompt(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = manager.RefreshSystemPrompt(ctx)
+	if errors.Is(err, errAgentWorking) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errNoSystemPrompt) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to refresh system prompt", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
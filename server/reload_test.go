@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func TestReloadConfig(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+
+	discord := map[string]any{"type": "discord", "webhook_url": "https://discord.example/1"}
+	s.SeedNotificationChannelsFromConfig([]map[string]any{discord})
+
+	email := map[string]any{"type": "email", "to": "me@example.com"}
+	s.ReloadConfig(&LLMConfig{
+		DefaultModel:         "claude-sonnet-4.5",
+		Links:                []Link{{Title: "Docs", URL: "https://docs.example"}},
+		NotificationChannels: []map[string]any{discord, email},
+	})
+
+	if got := s.getDefaultModel(); got != "claude-sonnet-4.5" {
+		t.Errorf("expected the reloaded default model, got %q", got)
+	}
+	s.configMu.RLock()
+	links := s.links
+	s.configMu.RUnlock()
+	if len(links) != 1 || links[0].Title != "Docs" {
+		t.Errorf("expected the reloaded links, got %+v", links)
+	}
+
+	// Only the channel added to the file is created; the seeded one isn't duplicated.
+	channels, err := database.GetNotificationChannels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, ch := range channels {
+		types = append(types, ch.ChannelType)
+	}
+	if len(types) != 2 || !strings.Contains(strings.Join(types, ","), "email") {
+		t.Errorf("expected the discord and email channels, got %v", types)
+	}
+}
+
+func TestWatchFilesReloadsConfig(t *testing.T) {
+	s, _, _ := newTestServer(t)
+	t.Cleanup(func() { close(s.shutdownCh) })
+
+	configPath := filepath.Join(t.TempDir(), "shelley.json")
+	writeConfig := func(model string) {
+		data, _ := json.Marshal(map[string]string{"default_model": model})
+		if err := os.WriteFile(configPath, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("predictable")
+	load := func() *LLMConfig {
+		var cfg struct {
+			DefaultModel string `json:"default_model"`
+		}
+		data, _ := os.ReadFile(configPath)
+		json.Unmarshal(data, &cfg)
+		return &LLMConfig{DefaultModel: cfg.DefaultModel}
+	}
+	if err := s.WatchFiles(configPath, load); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfig("claude-sonnet-4.5")
+	deadline := time.Now().Add(5 * time.Second)
+	for s.getDefaultModel() != "claude-sonnet-4.5" {
+		if time.Now().After(deadline) {
+			t.Fatalf("default model was not reloaded; still %q", s.getDefaultModel())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRefreshSystemPrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	agentsMD := filepath.Join(dir, "AGENTS.md")
+	if err := os.WriteFile(agentsMD, []byte("Use tabs."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := database.CreateConversation(ctx, nil, true, &dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirs := manager.checkSystemPromptSources()
+	if len(dirs) == 0 || manager.systemPromptStale() != nil {
+		t.Fatalf("expected directories to watch and a fresh prompt, got %v", dirs)
+	}
+	if err := os.WriteFile(agentsMD, []byte("Use spaces."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manager.checkSystemPromptSources()
+	if manager.systemPromptStale() == nil {
+		t.Fatal("expected the prompt to be stale after AGENTS.md changed")
+	}
+
+	handler := http.NewServeMux()
+	s.RegisterRoutes(handler)
+	req := httptest.NewRequest("POST", "/api/conversation/"+conv.ConversationID+"/refresh-system-prompt", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if manager.systemPromptStale() != nil {
+		t.Error("expected the prompt to be fresh after refreshing")
+	}
+
+	messages, err := database.ListMessagesByType(ctx, conv.ConversationID, db.MessageTypeSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].LlmData == nil || !strings.Contains(*messages[0].LlmData, "Use spaces.") {
+		t.Errorf("expected the one system prompt to be updated, got %d messages", len(messages))
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SearchHit is one message matching a search.
+type SearchHit struct {
+	ConversationID string  `json:"conversation_id"`
+	Slug           *string `json:"slug"`
+	SequenceID     int64   `json:"sequence_id"`
+	Type           string  `json:"type"`
+	// Snippet is HTML: the surrounding text is escaped and matches are
+	// wrapped in <mark> elements.
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"` // Higher is a better match
+}
+
+// SearchResponse is the response for GET /api/search.
+type SearchResponse struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// highlightSnippet escapes an FTS snippet for HTML and replaces the match
+// markers used by the SearchMessages query with <mark> elements.
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// handleSearch handles GET /api/search?q=&limit=&offset=
+// Hits are messages in unarchived conversations, best match first.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = min(l, 500)
+	}
+	offset := 0
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	rows, err := s.db.SearchMessages(ctx, s.ownerFilter(r), q, int64(limit), int64(offset))
+	if err != nil {
+		s.logger.Error("Failed to search messages", "query", q, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := SearchResponse{Query: q, Hits: make([]SearchHit, len(rows))}
+	for i, row := range rows {
+		resp.Hits[i] = SearchHit{
+			ConversationID: row.ConversationID,
+			Slug:           row.Slug,
+			SequenceID:     row.SequenceID,
+			Type:           row.Type,
+			Snippet:        highlightSnippet(row.Snippet),
+			Score:          row.Score,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
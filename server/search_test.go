@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	h := NewTestHarness(t)
+
+	h.NewConversation("echo: the <b>quarterly</b> report", "")
+	h.WaitResponse()
+
+	req := httptest.NewRequest("GET", "/api/search?q=quarterly", nil)
+	w := httptest.NewRecorder()
+	h.server.handleSearch(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp SearchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// Both the user's message and the echoed reply match.
+	if len(resp.Hits) != 2 {
+		t.Fatalf("expected 2 hits, got %+v", resp.Hits)
+	}
+	for _, hit := range resp.Hits {
+		if hit.ConversationID != h.convID {
+			t.Errorf("unexpected conversation %s", hit.ConversationID)
+		}
+		if want := "&lt;b&gt;<mark>quarterly</mark>&lt;/b&gt;"; !strings.Contains(hit.Snippet, want) {
+			t.Errorf("expected snippet to contain %q, got %q", want, hit.Snippet)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleSearch(w, httptest.NewRequest("GET", "/api/search", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without q, got %d", w.Code)
+	}
+}
@@ -74,6 +74,18 @@ type StreamResponse struct {
 	Heartbeat bool `json:"heartbeat,omitempty"`
 	// NotificationEvent is set when a notification-worthy event occurs (e.g. agent finished).
 	NotificationEvent *notifications.Event `json:"notification_event,omitempty"`
+	// PermissionRequest is set when a bash command needs user approval, and again when it is resolved.
+	PermissionRequest *PermissionRequest `json:"permission_request,omitempty"`
+	// Branches lists the points on the active branch where a user message was edited.
+	Branches []MessageBranches `json:"branches,omitempty"`
+	// ResetMessages means Messages is the whole active branch and replaces the
+	// client's list, e.g. after an edit or a branch switch.
+	ResetMessages bool `json:"reset_messages,omitempty"`
+	// StreamDelta is partial assistant output for the turn in progress; it is not persisted.
+	StreamDelta *StreamDelta `json:"stream_delta,omitempty"`
+	// SystemPromptStale is true when guidance or skill files behind the system
+	// prompt have changed, offering a refresh, and false once it is refreshed.
+	SystemPromptStale *bool `json:"system_prompt_stale,omitempty"`
 }
 
 // LLMProvider is an interface for getting LLM services
@@ -87,24 +99,27 @@ type LLMProvider interface {
 
 // NewLLMServiceManager creates a new LLM service manager from config
 func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
-	// Convert LLMConfig to models.Config
-	modelConfig := &models.Config{
+	manager, err := models.NewManager(modelsConfig(cfg))
+	if err != nil {
+		// This shouldn't happen in practice, but handle it gracefully
+		cfg.Logger.Error("Failed to create models manager", "error", err)
+	}
+
+	return manager
+}
+
+// modelsConfig converts LLMConfig to models.Config
+func modelsConfig(cfg *LLMConfig) *models.Config {
+	return &models.Config{
 		AnthropicAPIKey: cfg.AnthropicAPIKey,
 		OpenAIAPIKey:    cfg.OpenAIAPIKey,
 		GeminiAPIKey:    cfg.GeminiAPIKey,
 		FireworksAPIKey: cfg.FireworksAPIKey,
 		Gateway:         cfg.Gateway,
+		Groups:          cfg.ModelGroups,
 		Logger:          cfg.Logger,
 		DB:              cfg.DB,
 	}
-
-	manager, err := models.NewManager(modelConfig)
-	if err != nil {
-		// This shouldn't happen in practice, but handle it gracefully
-		cfg.Logger.Error("Failed to create models manager", "error", err)
-	}
-
-	return manager
 }
 
 // toAPIMessages converts database messages to API messages.
@@ -213,11 +228,12 @@ func calculateContextWindowSizeFromMsg(msg *generated.Message) uint64 {
 
 // ConversationListUpdate represents an update to the conversation list
 type ConversationListUpdate struct {
-	Type            string                  `json:"type"` // "update", "delete"
+	Type            string                  `json:"type"` // "update", "delete", "config_reloaded"
 	Conversation    *generated.Conversation `json:"conversation,omitempty"`
 	ConversationID  string                  `json:"conversation_id,omitempty"` // For deletes
 	GitRepoRoot     string                  `json:"git_repo_root,omitempty"`
 	GitWorktreeRoot string                  `json:"git_worktree_root,omitempty"`
+	Config          *ReloadedConfig         `json:"config,omitempty"` // For config_reloaded
 }
 
 // Server manages the HTTP API and active conversations
@@ -229,14 +245,24 @@ type Server struct {
 	mu                  sync.Mutex
 	logger              *slog.Logger
 	predictableOnly     bool
-	terminalURL         string
-	defaultModel        string
-	links               []Link
-	requireHeader       string
+	auth                AuthConfig
 	conversationGroup   singleflight.Group[string, *ConversationManager]
 	versionChecker      *VersionChecker
 	notifDispatcher     *notifications.Dispatcher
 	shutdownCh          chan struct{} // Signals background routines to stop
+
+	// maxConcurrentSubagents limits how many subagents may work at once (0 means no limit).
+	maxConcurrentSubagents int
+
+	// configMu guards the settings from shelley.json that can be reloaded.
+	configMu                   sync.RWMutex
+	terminalURL                string
+	defaultModel               string
+	links                      []Link
+	notificationChannelConfigs []map[string]any
+
+	// watcher, if set, watches shelley.json, guidance files and skills.
+	watcher *fileWatcher
 }
 
 // NewServer creates a new server instance
@@ -250,7 +276,7 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 		predictableOnly:     predictableOnly,
 		terminalURL:         terminalURL,
 		defaultModel:        defaultModel,
-		requireHeader:       requireHeader,
+		auth:                AuthConfig{Header: requireHeader},
 		links:               links,
 		versionChecker:      NewVersionChecker(),
 		notifDispatcher:     notifications.NewDispatcher(logger),
@@ -265,6 +291,14 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 	return s
 }
 
+// SetSubagentLimits sets how deeply subagents may nest (1 allows only top-level
+// conversations to spawn them) and how many may work at once. Zero means no limit.
+// It must be called before any conversations are started.
+func (s *Server) SetSubagentLimits(maxDepth, maxConcurrent int) {
+	s.toolSetConfig.MaxSubagentDepth = maxDepth
+	s.maxConcurrentSubagents = maxConcurrent
+}
+
 // RegisterNotificationChannel adds a backend notification channel to the dispatcher.
 func (s *Server) RegisterNotificationChannel(ch notifications.Channel) {
 	s.notifDispatcher.Register(ch)
@@ -279,7 +313,8 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("/api/conversations/new", http.HandlerFunc(s.handleNewConversation))           // Small response
 	mux.Handle("/api/conversations/continue", http.HandlerFunc(s.handleContinueConversation)) // Small response
 	mux.Handle("/api/conversations/distill", http.HandlerFunc(s.handleDistillConversation))   // Small response
-	mux.Handle("/api/conversation/", http.StripPrefix("/api/conversation", s.conversationMux()))
+	mux.Handle("/api/conversations/import", http.HandlerFunc(s.handleImportConversation))     // Small response
+	mux.Handle("/api/conversation/", http.StripPrefix("/api/conversation", s.requireConversationAccess(s.conversationMux())))
 	mux.Handle("/api/conversation-by-slug/", gzipHandler(http.HandlerFunc(s.handleConversationBySlug)))
 	mux.Handle("/api/validate-cwd", http.HandlerFunc(s.handleValidateCwd)) // Small response
 	mux.Handle("/api/list-directory", gzipHandler(http.HandlerFunc(s.handleListDirectory)))
@@ -306,6 +341,22 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// Models API (dynamic list refresh)
 	mux.Handle("/api/models", http.HandlerFunc(s.handleModels))
 
+	// Usage and cost reporting
+	mux.Handle("GET /api/usage", gzipHandler(http.HandlerFunc(s.handleUsage)))
+
+	// Full-text search over message content
+	mux.Handle("GET /api/search", gzipHandler(http.HandlerFunc(s.handleSearch)))
+
+	// Users and sharing
+	mux.Handle("GET /login", http.HandlerFunc(s.handleLoginPage))
+	mux.Handle("POST /api/login", http.HandlerFunc(s.handleLogin))
+	mux.Handle("POST /api/logout", http.HandlerFunc(s.handleLogout))
+	mux.Handle("GET /api/me", http.HandlerFunc(s.handleMe))
+	mux.Handle("GET /api/shared/{id}", gzipHandler(http.HandlerFunc(s.handleSharedConversation)))
+	mux.Handle("GET /api/tokens", http.HandlerFunc(s.handleListAPITokens))
+	mux.Handle("POST /api/tokens", http.HandlerFunc(s.handleCreateAPIToken))
+	mux.Handle("DELETE /api/tokens/{id}", http.HandlerFunc(s.handleRevokeAPIToken))
+
 	// Version endpoints
 	mux.Handle("GET /version", http.HandlerFunc(s.handleVersion))
 	mux.Handle("GET /version-check", http.HandlerFunc(s.handleVersionCheck))
@@ -690,44 +741,9 @@ func (s *Server) getOrCreateConversationManager(ctx context.Context, conversatio
 		}
 
 		manager := NewConversationManager(conversationID, s.db, s.logger, s.toolSetConfig, recordMessage, onStateChange)
-		if err := manager.Hydrate(ctx); err != nil {
-			return nil, err
-		}
-
-		s.activeConversations[conversationID] = manager
-		return manager, nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return manager, nil
-}
-
-// getOrCreateSubagentConversationManager is like getOrCreateConversationManager but
-// uses a toolSetConfig with SubagentDepth incremented by 1, preventing subagents
-// from spawning their own subagents (when MaxSubagentDepth is 1).
-func (s *Server) getOrCreateSubagentConversationManager(ctx context.Context, conversationID string) (*ConversationManager, error) {
-	manager, err, _ := s.conversationGroup.Do(conversationID, func() (*ConversationManager, error) {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-		if manager, exists := s.activeConversations[conversationID]; exists {
-			manager.Touch()
-			return manager, nil
-		}
-
-		recordMessage := func(ctx context.Context, message llm.Message, usage llm.Usage) error {
-			return s.recordMessage(ctx, conversationID, message, usage)
-		}
-
-		onStateChange := func(state ConversationState) {
-			s.publishConversationState(state)
+		manager.permissionPrompter = func(ctx context.Context) *ConversationManager {
+			return s.permissionPrompter(ctx, conversationID)
 		}
-
-		// Use a modified toolSetConfig with incremented depth for subagents
-		subagentConfig := s.toolSetConfig
-		subagentConfig.SubagentDepth = s.toolSetConfig.SubagentDepth + 1
-
-		manager := NewConversationManager(conversationID, s.db, s.logger, subagentConfig, recordMessage, onStateChange)
 		if err := manager.Hydrate(ctx); err != nil {
 			return nil, err
 		}
@@ -738,6 +754,9 @@ func (s *Server) getOrCreateSubagentConversationManager(ctx context.Context, con
 	if err != nil {
 		return nil, err
 	}
+	if s.watcher != nil {
+		s.watcher.watchConversation(manager)
+	}
 	return manager, nil
 }
 
@@ -992,6 +1011,7 @@ func (s *Server) broadcastMessageUpdate(ctx context.Context, conversationID stri
 // publishConversationListUpdate broadcasts a conversation list update to ALL active
 // conversation streams. This allows clients to receive updates about other conversations
 // while they're subscribed to their current conversation's stream.
+// With auth enabled, updates only go to streams of conversations with the same owner.
 func (s *Server) publishConversationListUpdate(update ConversationListUpdate) {
 	// Populate git info from conversation cwd
 	if update.Conversation != nil && update.Conversation.Cwd != nil {
@@ -1001,8 +1021,17 @@ func (s *Server) publishConversationListUpdate(update ConversationListUpdate) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Deletes carry only an ID, which is safe to broadcast.
+	var owner *string
+	if update.Conversation != nil {
+		owner = update.Conversation.UserID
+	}
+
 	// Broadcast to all active conversation managers
 	for _, manager := range s.activeConversations {
+		if update.Conversation != nil && !s.sameOwner(manager, owner) {
+			continue
+		}
 		streamData := StreamResponse{
 			ConversationListUpdate: &update,
 		}
@@ -1013,16 +1042,28 @@ func (s *Server) publishConversationListUpdate(update ConversationListUpdate) {
 // publishConversationState broadcasts a conversation state update to ALL active
 // conversation streams. This allows clients to see the working state of other conversations.
 func (s *Server) publishConversationState(state ConversationState) {
-	// When the agent finishes working, emit a notification event.
+	// When the agent finishes working, emit a notification event: agent_error if
+	// the turn ended with an error message, agent_done otherwise.
 	var notifEvent *notifications.Event
 	if !state.Working {
+		event := notifications.Event{
+			Type:           notifications.EventAgentDone,
+			ConversationID: state.ConversationID,
+			Timestamp:      time.Now(),
+		}
 		payload := notifications.AgentDonePayload{
 			Model: state.Model,
 		}
-		if conv, err := s.db.GetConversationByID(context.Background(), state.ConversationID); err == nil && conv.Slug != nil {
-			payload.ConversationTitle = *conv.Slug
+		if conv, err := s.db.GetConversationByID(context.Background(), state.ConversationID); err == nil {
+			if conv.Slug != nil {
+				payload.ConversationTitle = *conv.Slug
+			}
+			if conv.UserID != nil {
+				event.UserID = *conv.UserID
+			}
 		}
-		if msg, err := s.db.GetLatestMessage(context.Background(), state.ConversationID); err == nil && msg.Type == string(db.MessageTypeAgent) && msg.LlmData != nil {
+		if msg, err := s.db.GetLatestMessage(context.Background(), state.ConversationID); err == nil && msg.LlmData != nil &&
+			(msg.Type == string(db.MessageTypeAgent) || msg.Type == string(db.MessageTypeError)) {
 			var llmMsg llm.Message
 			if json.Unmarshal([]byte(*msg.LlmData), &llmMsg) == nil {
 				var text string
@@ -1034,14 +1075,16 @@ func (s *Server) publishConversationState(state ConversationState) {
 				if len(text) > 255 {
 					text = text[:255] + "..."
 				}
-				payload.FinalResponse = text
+				if msg.Type == string(db.MessageTypeError) {
+					event.Type = notifications.EventAgentError
+					event.Payload = notifications.AgentErrorPayload{ErrorMessage: text}
+				} else {
+					payload.FinalResponse = text
+				}
 			}
 		}
-		event := notifications.Event{
-			Type:           notifications.EventAgentDone,
-			ConversationID: state.ConversationID,
-			Timestamp:      time.Now(),
-			Payload:        payload,
+		if event.Payload == nil {
+			event.Payload = payload
 		}
 		s.notifDispatcher.Dispatch(context.Background(), event)
 		notifEvent = &event
@@ -1050,8 +1093,16 @@ func (s *Server) publishConversationState(state ConversationState) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	var owner *string
+	if source, ok := s.activeConversations[state.ConversationID]; ok {
+		owner = source.userID
+	}
+
 	// Broadcast to all active conversation managers
 	for _, manager := range s.activeConversations {
+		if !s.sameOwner(manager, owner) {
+			continue
+		}
 		streamData := StreamResponse{
 			ConversationState: &state,
 			NotificationEvent: notifEvent,
@@ -1060,6 +1111,18 @@ func (s *Server) publishConversationState(state ConversationState) {
 	}
 }
 
+// sameOwner reports whether manager's stream may receive updates about a
+// conversation owned by owner. Without auth, every stream receives them.
+func (s *Server) sameOwner(manager *ConversationManager, owner *string) bool {
+	if !s.auth.enabled() {
+		return true
+	}
+	if manager.userID == nil || owner == nil {
+		return manager.userID == nil && owner == nil
+	}
+	return *manager.userID == *owner
+}
+
 // getWorkingConversations returns a map of conversation IDs that are currently working.
 func (s *Server) getWorkingConversations() map[string]bool {
 	s.mu.Lock()
@@ -1122,8 +1185,8 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 }
 
 // StartWithListeners starts the HTTP server on the given TCP listener and optionally
-// also on a Unix socket. The TCP listener gets full middleware (CSRF, requireHeader, logger).
-// The Unix socket listener gets only the logger middleware (no CSRF, no requireHeader)
+// also on a Unix socket. The TCP listener gets full middleware (CSRF, auth, logger).
+// The Unix socket listener gets only the logger middleware (no CSRF, no auth)
 // since it is local and trusted.
 func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string) error {
 	// Set up shared mux with routes
@@ -1134,8 +1197,9 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 	tcpHandler := LoggerMiddleware(s.logger)(mux)
 	cop := http.NewCrossOriginProtection()
 	tcpHandler = cop.Handler(tcpHandler)
-	if s.requireHeader != "" {
-		tcpHandler = RequireHeaderMiddleware(s.requireHeader)(tcpHandler)
+	if s.auth.enabled() {
+		tcpHandler = s.authMiddleware(tcpHandler)
+		tcpHandler = s.apiTokenMiddleware(tcpHandler)
 	}
 
 	tcpServer := &http.Server{
@@ -1195,7 +1259,7 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 			s.logger.Warn("Failed to chmod socket", "path", actualSocketPath, "error", err)
 		}
 
-		// Unix socket handler: relaxed middleware (only logger, no CSRF or requireHeader)
+		// Unix socket handler: relaxed middleware (only logger, no CSRF or auth)
 		socketHandler := LoggerMiddleware(s.logger)(mux)
 
 		socketServer = &http.Server{
@@ -1226,6 +1290,13 @@ func (s *Server) StartWithListeners(tcpListener net.Listener, socketPath string)
 	// Signal background routines to stop
 	close(s.shutdownCh)
 
+	if s.toolSetConfig.MCP != nil {
+		s.toolSetConfig.MCP.Close()
+	}
+	if s.toolSetConfig.LSP != nil {
+		s.toolSetConfig.LSP.Close()
+	}
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
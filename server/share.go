@@ -0,0 +1,83 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// ShareAPI is a read-only link to a conversation.
+type ShareAPI struct {
+	ShareID        string `json:"share_id"`
+	ConversationID string `json:"conversation_id"`
+	// URL is the link to give out, relative to the server.
+	URL string `json:"url"`
+}
+
+func toShareAPI(share generated.ConversationShare) ShareAPI {
+	return ShareAPI{
+		ShareID:        share.ShareID,
+		ConversationID: share.ConversationID,
+		URL:            "/api/shared/" + share.ShareID,
+	}
+}
+
+// handleShareConversation handles POST /conversation/<id>/share
+// It creates a read-only link that any signed-in user can open.
+func (s *Server) handleShareConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	share, err := s.db.CreateConversationShare(r.Context(), conversationID, requestUserID(r))
+	if err != nil {
+		s.logger.Error("Failed to share conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toShareAPI(*share))
+}
+
+// handleListShares handles GET /conversation/<id>/shares
+func (s *Server) handleListShares(w http.ResponseWriter, r *http.Request, conversationID string) {
+	shares, err := s.db.ListConversationShares(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list shares", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result := make([]ShareAPI, len(shares))
+	for i, share := range shares {
+		result[i] = toShareAPI(share)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleUnshareConversation handles POST /conversation/<id>/unshare
+// It revokes every read-only link to the conversation.
+func (s *Server) handleUnshareConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if err := s.db.DeleteConversationShares(r.Context(), conversationID); err != nil {
+		s.logger.Error("Failed to unshare conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSharedConversation handles GET /api/shared/<share_id>?format=json|markdown
+// It exports the shared conversation, whoever owns it.
+func (s *Server) handleSharedConversation(w http.ResponseWriter, r *http.Request) {
+	share, err := s.db.GetConversationShare(r.Context(), r.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Shared conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get share", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.handleExportConversation(w, r, share.ConversationID)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// streamFlushInterval is how long partial output is collected before it is
+// broadcast. Subscribers are disconnected if they fall too far behind, so
+// individual tokens are not sent one at a time.
+const streamFlushInterval = 50 * time.Millisecond
+
+// StreamDelta is partial assistant output for the turn in progress. It is sent
+// over the conversation stream as it is generated and is never persisted: the
+// complete message replaces it once the LLM response is recorded.
+type StreamDelta struct {
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+	// Restart means the request is being retried, so earlier partial output
+	// should be discarded before appending Text and Thinking.
+	Restart bool `json:"restart,omitempty"`
+}
+
+// partialOutput collects stream deltas between broadcasts.
+type partialOutput struct {
+	mu        sync.Mutex
+	pending   StreamDelta
+	hasDelta  bool
+	lastFlush time.Time
+	timer     *time.Timer
+}
+
+// onStreamDelta is the loop's OnStreamDelta callback.
+func (cm *ConversationManager) onStreamDelta(delta llm.StreamDelta) {
+	p := &cm.partial
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if delta.Restart {
+		p.pending = StreamDelta{Restart: true}
+	}
+	switch delta.Type {
+	case llm.ContentTypeText:
+		p.pending.Text += delta.Text
+	case llm.ContentTypeThinking:
+		p.pending.Thinking += delta.Text
+	}
+	p.hasDelta = true
+
+	if wait := streamFlushInterval - time.Since(p.lastFlush); wait > 0 {
+		if p.timer == nil {
+			p.timer = time.AfterFunc(wait, cm.flushStreamDeltas)
+		}
+		return
+	}
+	cm.broadcastStreamDeltaLocked()
+}
+
+// flushStreamDeltas broadcasts any partial output collected since the last broadcast.
+func (cm *ConversationManager) flushStreamDeltas() {
+	cm.partial.mu.Lock()
+	defer cm.partial.mu.Unlock()
+	cm.partial.timer = nil
+	if cm.partial.hasDelta {
+		cm.broadcastStreamDeltaLocked()
+	}
+}
+
+// broadcastStreamDeltaLocked sends the pending delta. cm.partial.mu must be held,
+// so that a broadcast cannot land after endStreamDeltas.
+func (cm *ConversationManager) broadcastStreamDeltaLocked() {
+	delta := cm.partial.pending
+	cm.partial.pending = StreamDelta{}
+	cm.partial.hasDelta = false
+	cm.partial.lastFlush = time.Now()
+	cm.subpub.Broadcast(StreamResponse{StreamDelta: &delta})
+}
+
+// endStreamDeltas drops partial output that has not been sent yet. It is called
+// before an assistant message is recorded, since that message supersedes it.
+func (cm *ConversationManager) endStreamDeltas() {
+	cm.partial.mu.Lock()
+	defer cm.partial.mu.Unlock()
+	if cm.partial.timer != nil {
+		cm.partial.timer.Stop()
+		cm.partial.timer = nil
+	}
+	cm.partial.pending = StreamDelta{}
+	cm.partial.hasDelta = false
+}
+
+// streamingRecordMessage wraps recordMessage to end the partial output when
+// the assistant's message is recorded.
+func (cm *ConversationManager) streamingRecordMessage(recordMessage loop.MessageRecordFunc) loop.MessageRecordFunc {
+	return func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+		if message.Role == llm.MessageRoleAssistant {
+			cm.endStreamDeltas()
+		}
+		return recordMessage(ctx, message, usage)
+	}
+}
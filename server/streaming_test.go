@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestStreamDeltasPrecedeAgentMessage(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+
+	manager, err := h.server.getOrCreateConversationManager(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := h.db.ListMessages(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := manager.subpub.Subscribe(ctx, messages[len(messages)-1].SequenceID)
+
+	const reply = "one two three four five"
+	h.Chat("echo: " + reply)
+
+	var streamed strings.Builder
+	for {
+		update, ok := next()
+		if !ok {
+			t.Fatalf("stream ended before the agent message; streamed %q", streamed.String())
+		}
+		if update.StreamDelta != nil {
+			streamed.WriteString(update.StreamDelta.Text)
+			continue
+		}
+		if hasEndOfTurn(update) {
+			break
+		}
+	}
+	if streamed.Len() == 0 || !strings.HasPrefix(reply, streamed.String()) {
+		t.Errorf("expected a prefix of %q to be streamed, got %q", reply, streamed.String())
+	}
+
+	// Nothing more is streamed once the message is recorded.
+	time.Sleep(2 * streamFlushInterval)
+	manager.partial.mu.Lock()
+	hasDelta := manager.partial.hasDelta
+	manager.partial.mu.Unlock()
+	if hasDelta {
+		t.Error("expected no pending partial output after the agent message")
+	}
+}
+
+// hasEndOfTurn reports whether update carries the agent message that ends a turn.
+func hasEndOfTurn(update StreamResponse) bool {
+	for _, m := range update.Messages {
+		if m.Type == "agent" && m.EndOfTurn != nil && *m.EndOfTurn {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStreamDeltaCoalescing(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	conversation, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := h.server.getOrCreateConversationManager(ctx, conversation.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := manager.subpub.Subscribe(ctx, 0)
+
+	// The first delta goes out immediately; the rest are held until the interval passes.
+	for _, word := range []string{"a ", "b ", "c"} {
+		manager.onStreamDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: word})
+	}
+	manager.onStreamDelta(llm.StreamDelta{Type: llm.ContentTypeThinking, Text: "hm"})
+	var got []StreamDelta
+	for len(got) < 2 {
+		update, ok := next()
+		if !ok {
+			t.Fatal("subscription ended")
+		}
+		if update.StreamDelta != nil {
+			got = append(got, *update.StreamDelta)
+		}
+	}
+	if got[0] != (StreamDelta{Text: "a "}) || got[1] != (StreamDelta{Text: "b c", Thinking: "hm"}) {
+		t.Errorf("unexpected deltas: %+v", got)
+	}
+
+	// A restart discards what has not been sent.
+	manager.onStreamDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: "stale"})
+	manager.onStreamDelta(llm.StreamDelta{Restart: true})
+	manager.onStreamDelta(llm.StreamDelta{Type: llm.ContentTypeText, Text: "fresh"})
+	update, ok := next()
+	if !ok || update.StreamDelta == nil || *update.StreamDelta != (StreamDelta{Text: "fresh", Restart: true}) {
+		t.Errorf("unexpected delta after restart: %+v", update.StreamDelta)
+	}
+}
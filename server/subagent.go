@@ -2,13 +2,18 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
 )
@@ -16,6 +21,9 @@ import (
 // SubagentRunner implements claudetool.SubagentRunner.
 type SubagentRunner struct {
 	server *Server
+
+	// startMu makes checking the concurrent subagent limit and starting a subagent atomic.
+	startMu sync.Mutex
 }
 
 // NewSubagentRunner creates a new SubagentRunner.
@@ -24,27 +32,39 @@ func NewSubagentRunner(s *Server) *SubagentRunner {
 }
 
 // RunSubagent implements claudetool.SubagentRunner.
-func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt string, wait bool, timeout time.Duration, modelID string) (string, error) {
+func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt string, wait bool, timeout time.Duration, modelID string, tools []string) (string, error) {
 	s := r.server
 
+	// Use the parent's model if provided, otherwise fall back to server default
+	if modelID == "" {
+		modelID = s.getDefaultModel()
+	}
+	if modelID == "" && s.predictableOnly {
+		modelID = "predictable"
+	}
+	if modelID != "" && !s.llmManager.HasModel(modelID) {
+		return "", fmt.Errorf("unknown model %q; available models: %s", modelID, strings.Join(s.llmManager.GetAvailableModels(), ", "))
+	}
+
+	// The tool allow-list is fixed when the subagent starts, since its tools are
+	// created along with its conversation loop.
+	if tools != nil {
+		if err := r.setSubagentTools(ctx, conversationID, tools); err != nil {
+			return "", err
+		}
+	}
+
 	// Notify the UI about the subagent conversation.
 	// This ensures the sidebar shows the subagent even if it's a newly created conversation.
 	go r.notifySubagentConversation(ctx, conversationID)
 
-	// Get or create conversation manager for the subagent, with incremented depth
-	manager, err := s.getOrCreateSubagentConversationManager(ctx, conversationID)
+	// Get or create conversation manager for the subagent. Hydrating it works out
+	// its depth, which limits whether it can spawn subagents of its own.
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get conversation manager: %w", err)
 	}
 
-	// Use the parent's model if provided, otherwise fall back to server default
-	if modelID == "" {
-		modelID = s.defaultModel
-	}
-	if modelID == "" && s.predictableOnly {
-		modelID = "predictable"
-	}
-
 	// Persist model on the subagent conversation record
 	// UpdateConversationModel only sets the model if it's NULL, so this is safe for re-sends
 	if modelID != "" {
@@ -78,8 +98,14 @@ func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt
 		Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
 	}
 
-	// Accept the user message (this starts processing)
+	// Accept the user message (this starts processing), unless too many subagents are already working
+	r.startMu.Lock()
+	if n := s.maxConcurrentSubagents; n > 0 && r.countWorkingSubagents() >= n {
+		r.startMu.Unlock()
+		return "", fmt.Errorf("%d subagents are already working, which is the limit; wait for one to finish or cancel one", n)
+	}
 	_, err = manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	r.startMu.Unlock()
 	if err != nil {
 		return "", fmt.Errorf("failed to accept user message: %w", err)
 	}
@@ -137,6 +163,151 @@ func (r *SubagentRunner) waitForResponse(ctx context.Context, conversationID, mo
 	}
 }
 
+// setSubagentTools restricts a new subagent to the given tools. Once a
+// subagent has messages its tools can no longer change.
+func (r *SubagentRunner) setSubagentTools(ctx context.Context, conversationID string, tools []string) error {
+	s := r.server
+
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		_, err := q.GetLatestMessage(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.db.UpdateConversationAllowedTools(ctx, conversationID, tools)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get latest message: %w", err)
+	}
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	current, err := db.ParseAllowedTools(conversation)
+	if err != nil {
+		return err
+	}
+	if current == nil || !slices.Equal(slices.Sorted(slices.Values(current)), slices.Sorted(slices.Values(tools))) {
+		return fmt.Errorf("this subagent has already started with different tools; use a new slug to change them")
+	}
+	return nil
+}
+
+// countWorkingSubagents returns how many subagent conversations are working.
+func (r *SubagentRunner) countWorkingSubagents() int {
+	s := r.server
+
+	s.mu.Lock()
+	managers := make([]*ConversationManager, 0, len(s.activeConversations))
+	for _, mgr := range s.activeConversations {
+		managers = append(managers, mgr)
+	}
+	s.mu.Unlock()
+
+	count := 0
+	for _, mgr := range managers {
+		if mgr.isSubagent() && mgr.IsAgentWorking() {
+			count++
+		}
+	}
+	return count
+}
+
+// SubagentStatus implements claudetool.SubagentRunner.
+func (r *SubagentRunner) SubagentStatus(ctx context.Context, conversationID string) (claudetool.SubagentStatus, error) {
+	s := r.server
+	status := claudetool.SubagentStatus{ConversationID: conversationID}
+
+	working, err := r.isAgentWorking(ctx, conversationID)
+	if err != nil {
+		return status, fmt.Errorf("failed to check agent status: %w", err)
+	}
+	status.Working = working
+
+	var totals generated.GetConversationUsageTotalsRow
+	var latest generated.Message
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		totals, err = q.GetConversationUsageTotals(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		latest, err = q.GetLatestMessage(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to get subagent usage: %w", err)
+	}
+	status.InputTokens = uint64(totals.InputTokens)
+	status.OutputTokens = uint64(totals.OutputTokens)
+	status.CostUSD = totals.CostUsd
+	status.LastUpdate = latest.CreatedAt
+
+	if !working {
+		status.LastResponse, err = r.getLastAssistantResponse(ctx, conversationID)
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// WaitForSubagents implements claudetool.SubagentRunner. Like waitForResponse,
+// it polls the conversation managers and keeps them from being evicted.
+func (r *SubagentRunner) WaitForSubagents(ctx context.Context, conversationIDs []string, all bool, timeout time.Duration) error {
+	s := r.server
+
+	deadline := time.Now().Add(timeout)
+	pollInterval := 500 * time.Millisecond
+
+	for {
+		idle := 0
+		for _, id := range conversationIDs {
+			working, err := r.isAgentWorking(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to check agent status: %w", err)
+			}
+			if !working {
+				idle++
+			}
+		}
+		if idle == len(conversationIDs) || (!all && idle > 0) || time.Now().After(deadline) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+
+		s.mu.Lock()
+		for _, id := range conversationIDs {
+			if mgr, ok := s.activeConversations[id]; ok {
+				mgr.Touch()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// CancelSubagent implements claudetool.SubagentRunner.
+func (r *SubagentRunner) CancelSubagent(ctx context.Context, conversationID string) error {
+	s := r.server
+
+	s.mu.Lock()
+	mgr, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+
+	if !ok || !mgr.IsAgentWorking() {
+		return nil
+	}
+	return mgr.CancelConversation(ctx)
+}
+
 func (r *SubagentRunner) isAgentWorking(ctx context.Context, conversationID string) (bool, error) {
 	s := r.server
 
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
@@ -233,3 +236,161 @@ func TestBuildConversationSummary_SkipsSystemMessages(t *testing.T) {
 		t.Error("Summary should include user messages")
 	}
 }
+
+func TestSubagentRunnerStatusWaitCancel(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	parent, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSubagent := func(slug string) string {
+		t.Helper()
+		conv, err := h.db.CreateSubagentConversation(ctx, slug, parent.ConversationID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conv.ConversationID
+	}
+	fast, slow := newSubagent("fast"), newSubagent("slow")
+	runner := NewSubagentRunner(h.server)
+
+	// Nothing has run yet.
+	status, err := runner.SubagentStatus(ctx, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Working || !status.LastUpdate.IsZero() {
+		t.Errorf("expected an idle subagent with no messages, got %+v", status)
+	}
+
+	if _, err := runner.RunSubagent(ctx, fast, "echo: hello", false, time.Second, "predictable", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.RunSubagent(ctx, slow, "delay: 30", false, time.Second, "predictable", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runner.WaitForSubagents(ctx, []string{fast, slow}, false, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	status, err = runner.SubagentStatus(ctx, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Working || status.LastResponse != "hello" || status.OutputTokens == 0 || status.LastUpdate.IsZero() {
+		t.Errorf("unexpected status for the finished subagent: %+v", status)
+	}
+	status, err = runner.SubagentStatus(ctx, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Working || status.LastResponse != "" {
+		t.Errorf("expected the slow subagent to still be working: %+v", status)
+	}
+
+	// wait_all gives up at the timeout while the slow subagent is working.
+	start := time.Now()
+	if err := runner.WaitForSubagents(ctx, []string{fast, slow}, true, 600*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 600*time.Millisecond {
+		t.Error("wait_all returned before the timeout")
+	}
+
+	if err := runner.CancelSubagent(ctx, slow); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.WaitForSubagents(ctx, []string{fast, slow}, true, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if working, _ := runner.isAgentWorking(ctx, slow); working {
+		t.Error("expected the slow subagent to stop after cancel")
+	}
+	// Cancelling an idle subagent is a no-op.
+	if err := runner.CancelSubagent(ctx, fast); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubagentModelToolsAndLimits(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	h.server.SetSubagentLimits(2, 1)
+	runner := NewSubagentRunner(h.server)
+
+	parent, err := h.db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSubagent := func(slug, parentID string) string {
+		t.Helper()
+		conv, err := h.db.CreateSubagentConversation(ctx, slug, parentID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conv.ConversationID
+	}
+	toolNames := func(conversationID string) []string {
+		t.Helper()
+		h.server.mu.Lock()
+		mgr := h.server.activeConversations[conversationID]
+		h.server.mu.Unlock()
+		mgr.mu.Lock()
+		defer mgr.mu.Unlock()
+		var names []string
+		for _, tool := range mgr.toolSet.Tools() {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+
+	explorer := newSubagent("explorer", parent.ConversationID)
+	if _, err := runner.RunSubagent(ctx, explorer, "echo: hi", true, 5*time.Second, "no-such-model", nil); err == nil || !strings.Contains(err.Error(), "unknown model") {
+		t.Errorf("expected an unknown model error, got %v", err)
+	}
+	if _, err := runner.RunSubagent(ctx, explorer, "echo: hi", true, 5*time.Second, "predictable", []string{"bash", "keyword_search"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := toolNames(explorer); !slices.Equal(got, []string{"bash", "keyword_search"}) {
+		t.Errorf("expected the explorer to have only bash and keyword_search, got %v", got)
+	}
+	// The allow-list is fixed once the subagent has started.
+	if _, err := runner.RunSubagent(ctx, explorer, "echo: again", true, 5*time.Second, "predictable", []string{"bash", "patch"}); err == nil {
+		t.Error("expected an error when changing a started subagent's tools")
+	}
+	if _, err := runner.RunSubagent(ctx, explorer, "echo: again", true, 5*time.Second, "predictable", []string{"keyword_search", "bash"}); err != nil {
+		t.Errorf("re-sending with the same tools should work: %v", err)
+	}
+
+	// With a maximum depth of 2, a subagent can spawn subagents but theirs cannot.
+	child := newSubagent("child", parent.ConversationID)
+	grandchild := newSubagent("grandchild", child)
+	for _, id := range []string{child, grandchild} {
+		if _, err := runner.RunSubagent(ctx, id, "echo: hi", true, 5*time.Second, "predictable", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Contains(toolNames(child), "subagent") {
+		t.Error("expected a depth 1 subagent to be able to spawn subagents")
+	}
+	if slices.Contains(toolNames(grandchild), "subagent") {
+		t.Error("expected a depth 2 subagent not to be able to spawn subagents")
+	}
+
+	// Only one subagent may work at a time.
+	slow := newSubagent("slow", parent.ConversationID)
+	if _, err := runner.RunSubagent(ctx, slow, "delay: 30", false, time.Second, "predictable", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.RunSubagent(ctx, child, "echo: busy", false, time.Second, "predictable", nil); err == nil {
+		t.Error("expected the concurrent subagent limit to be enforced")
+	}
+	if err := runner.CancelSubagent(ctx, slow); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.RunSubagent(ctx, child, "echo: free", true, 5*time.Second, "predictable", nil); err != nil {
+		t.Errorf("expected the child to start after the slow subagent was cancelled: %v", err)
+	}
+}
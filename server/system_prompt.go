@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"os/exec"
@@ -308,32 +310,78 @@ func isExeDev() bool {
 // collectSkills discovers skills from default directories, project .skills dirs,
 // and the project tree.
 func collectSkills(workingDir, gitRoot string) string {
-	// Start with default directories (user-level skills)
-	dirs := skills.DefaultDirs()
-
-	// Add .skills directories found in the project tree
-	dirs = append(dirs, skills.ProjectSkillsDirs(workingDir, gitRoot)...)
+	return skills.ToPromptXML(skills.DiscoverAll(workingDir, gitRoot))
+}
 
-	// Discover skills from all directories
-	foundSkills := skills.Discover(dirs)
+// discoverSkills returns the skills available in a working directory: the
+// ones collectSkills lists in the system prompt.
+func discoverSkills(workingDir string) []skills.Skill {
+	wd := workingDir
+	if wd == "" {
+		var err error
+		if wd, err = os.Getwd(); err != nil {
+			return nil
+		}
+	}
+	var gitRoot string
+	if gitInfo, err := collectGitInfo(wd); err == nil {
+		gitRoot = gitInfo.Root
+	}
+	return skills.DiscoverAll(wd, gitRoot)
+}
 
-	// Also discover skills anywhere in the project tree
-	treeSkills := skills.DiscoverInTree(workingDir, gitRoot)
+// systemPromptSources returns a digest of the guidance files and skills that
+// GenerateSystemPrompt includes for workingDir, and the directories they are
+// found in, so that changes to them can be noticed.
+func systemPromptSources(workingDir string) (digest string, dirs []string) {
+	if workingDir == "" {
+		var err error
+		if workingDir, err = os.Getwd(); err != nil {
+			return "", nil
+		}
+	}
+	var gitRoot string
+	gitInfo, err := collectGitInfo(workingDir)
+	if err == nil {
+		gitRoot = gitInfo.Root
+	}
+	codebase, _ := collectCodebaseInfo(workingDir, gitInfo)
+	found := skills.DiscoverAll(workingDir, gitRoot)
 
-	// Merge, avoiding duplicates by path
+	h := sha256.New()
 	seen := make(map[string]bool)
-	for _, s := range foundSkills {
-		seen[s.Path] = true
-	}
-	for _, s := range treeSkills {
-		if !seen[s.Path] {
-			foundSkills = append(foundSkills, s)
-			seen[s.Path] = true
+	addDir := func(dir string) {
+		if dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
 		}
 	}
-
-	// Generate XML
-	return skills.ToPromptXML(foundSkills)
+	if home, err := os.UserHomeDir(); err == nil {
+		addDir(filepath.Join(home, ".config"))
+		addDir(filepath.Join(home, ".config", "shelley"))
+		addDir(filepath.Join(home, ".shelley"))
+	}
+	addDir(gitRoot)
+	addDir(workingDir)
+	for _, file := range codebase.InjectFiles {
+		fmt.Fprintf(h, "%s\x00%s\x00", file, codebase.InjectFileContents[file])
+		addDir(filepath.Dir(file))
+	}
+	for _, file := range codebase.GuidanceFiles {
+		fmt.Fprintf(h, "%s\x00", file)
+		addDir(filepath.Dir(file))
+	}
+	h.Write([]byte(skills.ToPromptXML(found)))
+	for _, dir := range skills.DefaultDirs() {
+		addDir(dir)
+	}
+	for _, dir := range skills.ProjectSkillsDirs(workingDir, gitRoot) {
+		addDir(dir)
+	}
+	for _, skill := range found {
+		addDir(filepath.Dir(skill.Path))
+	}
+	return hex.EncodeToString(h.Sum(nil)), dirs
 }
 
 func isSudoAvailable() bool {
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// API token scopes, from least to most access.
+const (
+	tokenScopeRead  = "read"  // GET requests for conversations, usage, search and models
+	tokenScopeChat  = "chat"  // read, plus creating and acting on conversations
+	tokenScopeAdmin = "admin" // everything the token's user can do, including managing tokens
+)
+
+// tokenScopeAllows reports whether a token with the given scope may make a request.
+func tokenScopeAllows(scope string, r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case scope == tokenScopeAdmin:
+		return true
+	case path == "/api/tokens" || strings.HasPrefix(path, "/api/tokens/"):
+		return false
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return (scope == tokenScopeRead || scope == tokenScopeChat) && tokenReadable(path)
+	case scope == tokenScopeChat:
+		return strings.HasPrefix(path, "/api/conversation/") ||
+			strings.HasPrefix(path, "/api/conversations/") ||
+			path == "/api/upload"
+	default:
+		return false
+	}
+}
+
+// tokenReadable reports whether read and chat tokens may GET path. Other GET
+// endpoints, like /api/read and /api/exec-ws, reach the filesystem or a shell,
+// so only admin tokens may use them.
+func tokenReadable(path string) bool {
+	switch path {
+	case "/api/conversations", "/api/conversations/archived", "/api/usage", "/api/search", "/api/models", "/api/me", "/version":
+		return true
+	}
+	return strings.HasPrefix(path, "/api/conversation/") ||
+		strings.HasPrefix(path, "/api/conversation-by-slug/") ||
+		strings.HasPrefix(path, "/api/shared/")
+}
+
+// APITokenAPI is the API representation of an API token.
+type APITokenAPI struct {
+	TokenID    string     `json:"token_id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// Token is only set in the response to creating the token.
+	Token string `json:"token,omitempty"`
+}
+
+// CreateAPITokenRequest is the request body for creating an API token.
+type CreateAPITokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"` // read, chat or admin
+	// Username is the user the token acts as. It defaults to the signed-in
+	// user; only admins, and requests over the Unix socket, may name another.
+	Username string `json:"username,omitempty"`
+}
+
+func toAPITokenAPI(token generated.ApiToken) APITokenAPI {
+	return APITokenAPI{
+		TokenID:    token.TokenID,
+		UserID:     token.UserID,
+		Name:       token.Name,
+		Scope:      token.Scope,
+		CreatedAt:  token.CreatedAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+// handleListAPITokens handles GET /api/tokens
+// Admins, and requests over the Unix socket, see every user's tokens.
+func (s *Server) handleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.db.ListAPITokens(r.Context(), s.ownerFilter(r))
+	if err != nil {
+		s.logger.Error("Failed to list API tokens", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result := make([]APITokenAPI, len(tokens))
+	for i, token := range tokens {
+		result[i] = toAPITokenAPI(token)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleCreateAPIToken handles POST /api/tokens
+// The response is the only time the token itself is shown.
+func (s *Server) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	switch req.Scope {
+	case tokenScopeRead, tokenScopeChat, tokenScopeAdmin:
+	default:
+		http.Error(w, "scope must be read, chat or admin", http.StatusBadRequest)
+		return
+	}
+
+	user := requestUser(r)
+	if req.Username != "" && (user == nil || req.Username != user.Username) {
+		if user != nil && !s.isAdmin(user) {
+			http.Error(w, "only admins can create tokens for other users", http.StatusForbidden)
+			return
+		}
+		var err error
+		user, err = s.db.GetUserByUsername(r.Context(), req.Username)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "no such user: "+req.Username, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to look up user", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if user == nil {
+		http.Error(w, "username is required when not signed in", http.StatusBadRequest)
+		return
+	}
+
+	secret := "shelley_" + rand.Text()
+	token, err := s.db.CreateAPIToken(r.Context(), user.UserID, req.Name, req.Scope, hashToken(secret))
+	if err != nil {
+		s.logger.Error("Failed to create API token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("Created API token", "tokenID", token.TokenID, "username", user.Username, "scope", token.Scope)
+
+	result := toAPITokenAPI(*token)
+	result.Token = secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleRevokeAPIToken handles DELETE /api/tokens/{id}
+func (s *Server) handleRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	tokenID := r.PathValue("id")
+	token, err := s.db.GetAPIToken(r.Context(), tokenID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("Failed to get API token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !s.canModify(r, &token.UserID) {
+		http.Error(w, "API token not found", http.StatusNotFound)
+		return
+	}
+	if err := s.db.DeleteAPIToken(r.Context(), tokenID); err != nil {
+		s.logger.Error("Failed to revoke API token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info("Revoked API token", "tokenID", tokenID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope, method, path string
+		want                bool
+	}{
+		{"read", "GET", "/api/conversations", true},
+		{"read", "POST", "/api/conversations/new", false},
+		{"read", "GET", "/api/tokens", false},
+		{"read", "GET", "/api/conversation/abc/stream", true},
+		{"read", "GET", "/api/usage", true},
+		{"read", "GET", "/api/exec-ws", false},
+		{"read", "GET", "/api/read", false},
+		{"read", "GET", "/debug/llm_requests", false},
+		{"chat", "GET", "/api/exec-ws", false},
+		{"chat", "GET", "/api/read", false},
+		{"admin", "GET", "/api/exec-ws", true},
+		{"chat", "POST", "/api/conversations/new", true},
+		{"chat", "POST", "/api/conversation/abc/chat", true},
+		{"chat", "POST", "/api/custom-models", false},
+		{"chat", "POST", "/api/tokens", false},
+		{"admin", "POST", "/api/tokens", true},
+		{"admin", "DELETE", "/api/custom-models/m1", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := tokenScopeAllows(tt.scope, r); got != tt.want {
+			t.Errorf("tokenScopeAllows(%q, %s %s) = %v, want %v", tt.scope, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	s, database, _ := newTestServer(t)
+	ctx := context.Background()
+	handler := authHandler(s, AuthConfig{PasswordLogin: true})
+
+	if _, err := database.GetOrCreateUser(ctx, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := database.GetOrCreateUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := database.CreateUserConversation(ctx, &alice.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a signed-in user, as over the Unix socket, the token's user must be named.
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/tokens", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.handleCreateAPIToken(w, req)
+		return w
+	}
+	if w := create(`{"name":"ci","scope":"chat"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a username, got %d", w.Code)
+	}
+	if w := create(`{"name":"ci","scope":"root","username":"alice"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown scope, got %d", w.Code)
+	}
+	w := create(`{"name":"ci","scope":"read","username":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var token APITokenAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token.Token, "shelley_") || token.UserID != alice.UserID {
+		t.Fatalf("unexpected token %+v", token)
+	}
+
+	do := func(method, path, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/conversations", "shelley_wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown token, got %d", w.Code)
+	}
+	if ids := listConversationIDs(t, handler, "Authorization", "Bearer "+token.Token); len(ids) != 1 || ids[0] != conv.ConversationID {
+		t.Errorf("expected the token to see alice's conversation, got %v", ids)
+	}
+	if w := do("POST", "/api/conversation/"+conv.ConversationID+"/archive", token.Token); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a write with a read token, got %d", w.Code)
+	}
+	if w := do("GET", "/api/tokens", token.Token); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for token management with a read token, got %d", w.Code)
+	}
+
+	listed, err := database.ListAPITokens(ctx, &alice.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].LastUsedAt == nil {
+		t.Fatalf("expected one used token, got %+v", listed)
+	}
+
+	// Revoking the token turns it away.
+	req := httptest.NewRequest("DELETE", "/api/tokens/"+token.TokenID, nil)
+	req.SetPathValue("id", token.TokenID)
+	w = httptest.NewRecorder()
+	s.handleRevokeAPIToken(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := do("GET", "/api/conversations", token.Token); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a revoked token, got %d", w.Code)
+	}
+}
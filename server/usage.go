@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// usageGroupings are the accepted values of group_by for GET /api/usage.
+var usageGroupings = []string{"model", "day", "conversation"}
+
+// LatencyPercentiles summarizes LLM request durations in milliseconds.
+type LatencyPercentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+}
+
+// UsageTotals is the token, cost, and latency summary for a set of LLM calls.
+type UsageTotals struct {
+	Requests                 int                `json:"requests"`
+	InputTokens              int64              `json:"input_tokens"`
+	CacheCreationInputTokens int64              `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64              `json:"cache_read_input_tokens"`
+	OutputTokens             int64              `json:"output_tokens"`
+	CostUSD                  float64            `json:"cost_usd"`
+	LatencyMS                LatencyPercentiles `json:"latency_ms"`
+}
+
+// UsageGroup is the usage for one model, UTC day, or conversation.
+type UsageGroup struct {
+	Key string `json:"key"`
+	UsageTotals
+}
+
+// UsageReport is the response for GET /api/usage.
+type UsageReport struct {
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	GroupBy string       `json:"group_by"`
+	Groups  []UsageGroup `json:"groups"`
+	Total   UsageTotals  `json:"total"`
+}
+
+// parseUsageTime parses a from/to parameter: an RFC 3339 timestamp or a UTC date.
+func parseUsageTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// latencyPercentiles computes p50/p90/p99 of durations, sorting them in place.
+func latencyPercentiles(durations []int64) LatencyPercentiles {
+	slices.Sort(durations)
+	return LatencyPercentiles{
+		P50: percentile(durations, 50),
+		P90: percentile(durations, 90),
+		P99: percentile(durations, 99),
+	}
+}
+
+// buildUsageReport rolls the per-conversation-day usage rows and per-request
+// latencies up into groups. Groups are sorted by key.
+func buildUsageReport(groupBy string, usage []generated.ListUsageByConversationDayRow, latencies []generated.ListLLMRequestLatenciesRow) ([]UsageGroup, UsageTotals) {
+	keyOf := func(model, day, conversationID string) string {
+		switch groupBy {
+		case "day":
+			return day
+		case "conversation":
+			return conversationID
+		default:
+			return model
+		}
+	}
+
+	groups := make(map[string]*UsageGroup)
+	group := func(key string) *UsageGroup {
+		g, ok := groups[key]
+		if !ok {
+			g = &UsageGroup{Key: key}
+			groups[key] = g
+		}
+		return g
+	}
+
+	var total UsageTotals
+	for _, row := range usage {
+		for _, t := range []*UsageTotals{&group(keyOf(row.Model, row.Day, row.ConversationID)).UsageTotals, &total} {
+			t.InputTokens += row.InputTokens
+			t.CacheCreationInputTokens += row.CacheCreationInputTokens
+			t.CacheReadInputTokens += row.CacheReadInputTokens
+			t.OutputTokens += row.OutputTokens
+			t.CostUSD += row.CostUsd
+		}
+	}
+
+	durations := make(map[string][]int64)
+	var allDurations []int64
+	for _, row := range latencies {
+		key := keyOf(row.Model, row.Day, row.ConversationID)
+		durations[key] = append(durations[key], row.DurationMs)
+		allDurations = append(allDurations, row.DurationMs)
+	}
+	for key, d := range durations {
+		g := group(key)
+		g.Requests = len(d)
+		g.LatencyMS = latencyPercentiles(d)
+	}
+	total.Requests = len(allDurations)
+	total.LatencyMS = latencyPercentiles(allDurations)
+
+	result := make([]UsageGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	slices.SortFunc(result, func(a, b UsageGroup) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result, total
+}
+
+// handleUsage handles GET /api/usage?from=&to=&group_by=model|day|conversation
+// Tokens and cost come from the usage recorded on messages, grouped by the
+// model that served each request; request counts and latency come from llm_requests.
+// The range is [from, to), defaulting to the last 30 days.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	report := UsageReport{
+		To:      time.Now().UTC(),
+		GroupBy: query.Get("group_by"),
+	}
+	if report.GroupBy == "" {
+		report.GroupBy = "model"
+	}
+	if !slices.Contains(usageGroupings, report.GroupBy) {
+		http.Error(w, fmt.Sprintf("group_by must be one of %v", usageGroupings), http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("to"); value != "" {
+		t, err := parseUsageTime(value)
+		if err != nil {
+			http.Error(w, "to must be a date (YYYY-MM-DD) or RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		report.To = t
+	}
+	report.From = report.To.AddDate(0, 0, -30)
+	if value := query.Get("from"); value != "" {
+		t, err := parseUsageTime(value)
+		if err != nil {
+			http.Error(w, "from must be a date (YYYY-MM-DD) or RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		report.From = t
+	}
+	if !report.From.Before(report.To) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	// Users only see what their own conversations spent.
+	owner := s.ownerFilter(r)
+	var usage []generated.ListUsageByConversationDayRow
+	var latencies []generated.ListLLMRequestLatenciesRow
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		usage, err = q.ListUsageByConversationDay(ctx, generated.ListUsageByConversationDayParams{Since: report.From, Until: report.To, UserID: owner})
+		if err != nil {
+			return err
+		}
+		latencies, err = q.ListLLMRequestLatencies(ctx, generated.ListLLMRequestLatenciesParams{Since: report.From, Until: report.To, UserID: owner})
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to load usage", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	report.Groups, report.Total = buildUsageReport(report.GroupBy, usage, latencies)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
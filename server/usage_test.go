@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	durations := make([]int64, 100)
+	for i := range durations {
+		durations[i] = int64(100 - i)
+	}
+	got := latencyPercentiles(durations)
+	if want := (LatencyPercentiles{P50: 50, P90: 90, P99: 99}); got != want {
+		t.Errorf("latencyPercentiles = %+v, want %+v", got, want)
+	}
+	if got := latencyPercentiles(nil); got != (LatencyPercentiles{}) {
+		t.Errorf("latencyPercentiles(nil) = %+v, want zeros", got)
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+
+	record := func(model string, usage []llm.Usage, durations []int64) string {
+		t.Helper()
+		conversation, err := h.db.CreateConversation(ctx, nil, true, nil, &model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range usage {
+			_, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+				ConversationID: conversation.ConversationID,
+				Type:           db.MessageTypeAgent,
+				LLMData:        llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "ok"}}},
+				UsageData:      u,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, d := range durations {
+			_, err := h.db.InsertLLMRequest(ctx, generated.InsertLLMRequestParams{
+				ConversationID: &conversation.ConversationID,
+				Model:          model,
+				Provider:       "test",
+				Url:            "http://example.invalid",
+				DurationMs:     &d,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return conversation.ConversationID
+	}
+	first := record("model-a", []llm.Usage{
+		{InputTokens: 10, CacheReadInputTokens: 100, OutputTokens: 5, CostUSD: 0.5},
+		{InputTokens: 20, CacheCreationInputTokens: 50, OutputTokens: 7, CostUSD: 0.25},
+	}, []int64{100, 300})
+	record("model-a", []llm.Usage{{InputTokens: 1, OutputTokens: 1, CostUSD: 1}}, []int64{200})
+	record("model-b", []llm.Usage{{InputTokens: 4, OutputTokens: 2, CostUSD: 2}}, []int64{1000})
+
+	get := func(query string) (int, UsageReport) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/usage?"+query, nil)
+		w := httptest.NewRecorder()
+		h.server.handleUsage(w, req)
+		var report UsageReport
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, report
+	}
+
+	code, report := get("group_by=model")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Key != "model-a" || report.Groups[1].Key != "model-b" {
+		t.Fatalf("unexpected groups: %+v", report.Groups)
+	}
+	a := report.Groups[0]
+	if a.InputTokens != 31 || a.CacheReadInputTokens != 100 || a.CacheCreationInputTokens != 50 || a.OutputTokens != 13 || a.CostUSD != 1.75 {
+		t.Errorf("unexpected model-a totals: %+v", a.UsageTotals)
+	}
+	if a.Requests != 3 || a.LatencyMS.P50 != 200 || a.LatencyMS.P99 != 300 {
+		t.Errorf("unexpected model-a latency: %d requests, %+v", a.Requests, a.LatencyMS)
+	}
+	if report.Total.CostUSD != 3.75 || report.Total.Requests != 4 || report.Total.LatencyMS.P99 != 1000 {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+
+	_, report = get("group_by=conversation")
+	var found bool
+	for _, g := range report.Groups {
+		if g.Key == first {
+			found = true
+			if g.CostUSD != 0.75 || g.Requests != 2 {
+				t.Errorf("unexpected totals for the first conversation: %+v", g.UsageTotals)
+			}
+		}
+	}
+	if len(report.Groups) != 3 || !found {
+		t.Errorf("expected one group per conversation, got %+v", report.Groups)
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	_, report = get("group_by=day")
+	if len(report.Groups) != 1 || report.Groups[0].Key != today || report.Groups[0].CostUSD != 3.75 {
+		t.Errorf("expected everything under %s, got %+v", today, report.Groups)
+	}
+
+	// Nothing was recorded in an earlier range.
+	_, report = get("from=2020-01-01&to=2020-02-01")
+	if len(report.Groups) != 0 || report.Total.Requests != 0 {
+		t.Errorf("expected an empty report, got %+v", report)
+	}
+
+	for _, query := range []string{"group_by=week", "from=yesterday", "from=2020-02-01&to=2020-01-01"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, code)
+		}
+	}
+
+	// Tokens count against the model that served each request, not the
+	// conversation's current model.
+	record("model-b", []llm.Usage{{InputTokens: 8, OutputTokens: 1, CostUSD: 4, ModelID: "model-c"}}, nil)
+	_, report = get("group_by=model")
+	if len(report.Groups) != 3 || report.Groups[1].CostUSD != 2 || report.Groups[2].Key != "model-c" || report.Groups[2].CostUSD != 4 {
+		t.Errorf("expected the request's model to be used, got %+v", report.Groups)
+	}
+
+	// With auth enabled, users only see their own conversations' usage.
+	const header = "X-Exedev-Userid"
+	handler := authHandler(h.server, AuthConfig{Header: header})
+	if _, err := h.db.GetOrCreateUser(ctx, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := h.db.GetOrCreateUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversation, err := h.db.CreateUserConversation(ctx, &alice.UserID, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: conversation.ConversationID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "ok"}}},
+		UsageData:      llm.Usage{InputTokens: 1, OutputTokens: 1, CostUSD: 8, ModelID: "model-a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	duration := int64(50)
+	if _, err := h.db.InsertLLMRequest(ctx, generated.InsertLLMRequestParams{
+		ConversationID: &conversation.ConversationID,
+		Model:          "model-a",
+		Provider:       "test",
+		Url:            "http://example.invalid",
+		DurationMs:     &duration,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	getAs := func(user string) UsageReport {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/usage?group_by=model", nil)
+		req.Header.Set(header, user)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("usage as %s: expected 200, got %d", user, w.Code)
+		}
+		var report UsageReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	if report := getAs("alice"); report.Total.CostUSD != 8 || report.Total.Requests != 1 || len(report.Groups) != 1 {
+		t.Errorf("expected alice to see only her own usage, got %+v", report)
+	}
+	if report := getAs("bob"); report.Total.CostUSD != 0 || report.Total.Requests != 0 {
+		t.Errorf("expected bob to see no usage, got %+v", report)
+	}
+	if report := getAs("admin"); report.Total.CostUSD != 15.75 || report.Total.Requests != 5 {
+		t.Errorf("expected an admin to see everyone's usage, got %+v", report.Total)
+	}
+}
@@ -4,10 +4,14 @@ package skills
 
 import (
 	"html"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"unicode"
+
+	"shelley.exe.dev/gitstate"
 )
 
 const (
@@ -151,6 +155,72 @@ func Parse(path string) (Skill, error) {
 	return skill, nil
 }
 
+// AllowedToolNames returns the tool names in the skill's allowed-tools field,
+// which is a space-delimited list such as "Bash(git:*) Read". Arguments in
+// parentheses are dropped. It returns nil if the field is empty.
+func (s Skill) AllowedToolNames() []string {
+	var names []string
+	for _, field := range strings.FieldsFunc(s.AllowedTools, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		name, _, _ := strings.Cut(field, "(")
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Body returns the instructions in a SKILL.md file: everything after the
+// frontmatter.
+func Body(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(string(content), "---", 3)
+	if len(parts) < 3 {
+		return "", &ValidationError{Message: "SKILL.md frontmatter not properly closed with ---"}
+	}
+	return strings.TrimSpace(parts[2]), nil
+}
+
+// MaxResources is the most bundled files Resources lists for a skill.
+const MaxResources = 200
+
+// Resources returns the files bundled with a skill, such as scripts and
+// references, as slash-separated paths relative to the skill's directory.
+// SKILL.md itself and hidden files are left out.
+func Resources(s Skill) ([]string, error) {
+	dir := filepath.Dir(s.Path)
+	var resources []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on errors
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || path == s.Path {
+			return nil
+		}
+		if len(resources) == MaxResources {
+			return filepath.SkipAll
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		resources = append(resources, filepath.ToSlash(rel))
+		return nil
+	})
+	return resources, err
+}
+
 // ValidationError represents a skill validation error.
 type ValidationError struct {
 	Message string
@@ -304,6 +374,36 @@ func ToPromptXML(skills []Skill) string {
 	return sb.String()
 }
 
+// DiscoverAll finds the skills available in a working directory: those in
+// the default directories, in .skills directories from the working directory
+// up to the git root, and anywhere in the project tree.
+func DiscoverAll(workingDir, gitRoot string) []Skill {
+	// Start with default directories (user-level skills)
+	dirs := DefaultDirs()
+
+	// Add .skills directories found in the project tree
+	dirs = append(dirs, ProjectSkillsDirs(workingDir, gitRoot)...)
+
+	// Discover skills from all directories
+	found := Discover(dirs)
+
+	// Also discover skills anywhere in the project tree
+	treeSkills := DiscoverInTree(workingDir, gitRoot)
+
+	// Merge, avoiding duplicates by path
+	seen := make(map[string]bool)
+	for _, s := range found {
+		seen[s.Path] = true
+	}
+	for _, s := range treeSkills {
+		if !seen[s.Path] {
+			found = append(found, s)
+			seen[s.Path] = true
+		}
+	}
+	return found
+}
+
 // DefaultDirs returns the default skill directories to search.
 // These are always returned if they exist, regardless of the current working directory.
 func DefaultDirs() []string {
@@ -347,37 +447,12 @@ func expandPath(path string) string {
 // the working directory to the git root (or filesystem root if no git root).
 func ProjectSkillsDirs(workingDir, gitRoot string) []string {
 	var dirs []string
-	seen := make(map[string]bool)
-
-	// Determine the stopping point
-	stopAt := gitRoot
-	if stopAt == "" {
-		stopAt = "/"
-	}
-
-	// Walk up from working directory
-	current := workingDir
-	for current != "" {
-		skillsDir := filepath.Join(current, ".skills")
-		if !seen[skillsDir] {
-			if info, err := os.Stat(skillsDir); err == nil && info.IsDir() {
-				dirs = append(dirs, skillsDir)
-				seen[skillsDir] = true
-			}
-		}
-
-		// Stop if we've reached the git root or filesystem root
-		if current == stopAt || current == "/" {
-			break
+	for _, dir := range gitstate.ProjectDirs(workingDir, gitRoot) {
+		skillsDir := filepath.Join(dir, ".skills")
+		if info, err := os.Stat(skillsDir); err == nil && info.IsDir() {
+			dirs = append(dirs, skillsDir)
 		}
-
-		parent := filepath.Dir(current)
-		if parent == current {
-			break
-		}
-		current = parent
 	}
-
 	return dirs
 }
 
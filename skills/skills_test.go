@@ -3,6 +3,7 @@ package skills
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -603,3 +604,59 @@ Test instructions.
 		t.Errorf("skill name = %q, want %q", skills[0].Name, "my-skill")
 	}
 }
+
+func TestAllowedToolNames(t *testing.T) {
+	tests := []struct {
+		allowed string
+		want    []string
+	}{
+		{"", nil},
+		{"Bash(git:*) Bash(jq:*) Read", []string{"Bash", "Read"}},
+		{"bash, read_file", []string{"bash", "read_file"}},
+	}
+	for _, tt := range tests {
+		got := Skill{AllowedTools: tt.allowed}.AllowedToolNames()
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("AllowedToolNames(%q) = %v, want %v", tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestBodyAndResources(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pdf")
+	for path, content := range map[string]string{
+		"SKILL.md":             "---\nname: pdf\ndescription: Work with PDFs.\n---\n\n# PDF\n\nUse scripts/extract.py.\n",
+		"scripts/extract.py":   "print('hi')\n",
+		"reference.md":         "More detail.\n",
+		".hidden/ignored.txt":  "no\n",
+		"scripts/.ignored.txt": "no\n",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	skill, err := Parse(filepath.Join(dir, "SKILL.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := Body(skill.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "# PDF\n\nUse scripts/extract.py." {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	resources, err := Resources(skill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"reference.md", "scripts/extract.py"}; !slices.Equal(resources, want) {
+		t.Errorf("Resources() = %v, want %v", resources, want)
+	}
+}
@@ -205,7 +205,7 @@ func TestSubagentNotificationViaStream(t *testing.T) {
 	subagentRunner := server.NewSubagentRunner(svr)
 	go func() {
 		// Call RunSubagent with wait=false so it returns quickly
-		subagentRunner.RunSubagent(ctx, subConv.ConversationID, "Test prompt", false, 10*time.Second, "predictable")
+		subagentRunner.RunSubagent(ctx, subConv.ConversationID, "Test prompt", false, 10*time.Second, "predictable", nil)
 	}()
 
 	// Wait for notification
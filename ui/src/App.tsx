@@ -339,6 +339,17 @@ function App() {
       });
     } else if (update.type === "delete" && update.conversation_id) {
       setConversations((prev) => prev.filter((c) => c.conversation_id !== update.conversation_id));
+    } else if (update.type === "config_reloaded" && update.config) {
+      // shelley.json changed: pick up the new links and defaults, and refresh
+      // the models list since API keys may have changed too.
+      if (window.__SHELLEY_INIT__) {
+        window.__SHELLEY_INIT__.links = update.config.links ?? [];
+        window.__SHELLEY_INIT__.terminal_url = update.config.terminal_url;
+        if (update.config.default_model) {
+          window.__SHELLEY_INIT__.default_model = update.config.default_model;
+        }
+      }
+      setModelsRefreshTrigger((prev) => prev + 1);
     }
   }, []);
 
@@ -576,6 +587,19 @@ function App() {
     }
   };
 
+  const handleForkConversation = async (sourceConversationId: string, sequenceId: number) => {
+    try {
+      const response = await api.forkConversation(sourceConversationId, sequenceId);
+      const updatedConvs = await api.getConversations();
+      setConversations(updatedConvs);
+      setCurrentConversationId(response.conversation_id);
+    } catch (err) {
+      console.error("Failed to fork conversation:", err);
+      setError("Failed to fork conversation");
+      throw err;
+    }
+  };
+
   const handleDistillConversation = async (
     sourceConversationId: string,
     model: string,
@@ -634,6 +658,7 @@ function App() {
             onFirstMessage={handleFirstMessage}
             onContinueConversation={handleContinueConversation}
             onDistillConversation={handleDistillConversation}
+            onForkConversation={handleForkConversation}
             mostRecentCwd={mostRecentCwd}
             isDrawerCollapsed={drawerCollapsed}
             onToggleDrawerCollapse={toggleDrawerCollapsed}
@@ -5,6 +5,8 @@ import {
   StreamResponse,
   LLMContent,
   ConversationListUpdate,
+  PermissionRequest,
+  MessageBranches,
   isDistillStatusMessage,
 } from "../types";
 import { api } from "../services/api";
@@ -499,6 +501,7 @@ interface ChatInterfaceProps {
     model: string,
     cwd?: string,
   ) => Promise<void>;
+  onForkConversation?: (sourceConversationId: string, sequenceId: number) => Promise<void>;
   mostRecentCwd?: string | null;
   isDrawerCollapsed?: boolean;
   onToggleDrawerCollapse?: () => void;
@@ -523,6 +526,7 @@ function ChatInterface({
   onFirstMessage,
   onContinueConversation,
   onDistillConversation,
+  onForkConversation,
   mostRecentCwd,
   isDrawerCollapsed,
   onToggleDrawerCollapse,
@@ -536,6 +540,7 @@ function ChatInterface({
   onConversationUnarchived,
 }: ChatInterfaceProps) {
   const [messages, setMessages] = useState<Message[]>([]);
+  const [branches, setBranches] = useState<MessageBranches[]>([]);
   const [loading, setLoading] = useState(true);
   const [sending, setSending] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -657,6 +662,14 @@ function ChatInterface({
   const [diffCommentText, setDiffCommentText] = useState("");
   const [agentWorking, setAgentWorking] = useState(false);
   const [cancelling, setCancelling] = useState(false);
+  const [pendingPermission, setPendingPermission] = useState<PermissionRequest | null>(null);
+  // Set when guidance or skill files behind the system prompt have changed.
+  const [systemPromptStale, setSystemPromptStale] = useState(false);
+  const [refreshingPrompt, setRefreshingPrompt] = useState(false);
+  // Assistant output streamed so far for the turn in progress; replaced by the recorded message.
+  const [partialOutput, setPartialOutput] = useState<{ text: string; thinking: string } | null>(
+    null,
+  );
   const [contextWindowSize, setContextWindowSize] = useState(0);
   const terminalURL = window.__SHELLEY_INIT__?.terminal_url || null;
   const links = window.__SHELLEY_INIT__?.links || [];
@@ -755,6 +768,9 @@ function ChatInterface({
 
   // Load messages and set up streaming
   useEffect(() => {
+    setPendingPermission(null);
+    setSystemPromptStale(false);
+    setPartialOutput(null);
     if (conversationId) {
       setAgentWorking(false);
       loadMessages();
@@ -762,6 +778,7 @@ function ChatInterface({
     } else {
       // No conversation yet, show empty state
       setMessages([]);
+      setBranches([]);
       setContextWindowSize(0);
       loadingRef.current = false;
       setLoading(false);
@@ -955,6 +972,7 @@ function ChatInterface({
       // Set pending scroll target before state updates so useLayoutEffect can handle it.
       pendingScrollRef.current = scrollStore.load();
       setMessages(response.messages ?? []);
+      setBranches(response.branches ?? []);
       loadingRef.current = false;
       setLoading(false);
       // ConversationState is sent via the streaming endpoint, not on initial load
@@ -1022,8 +1040,36 @@ function ChatInterface({
           ? streamResponse.messages
           : [];
 
+        // An edit rewinds the conversation: the server sends the whole new active
+        // branch, which replaces (rather than merges with) what we have.
+        if (streamResponse.reset_messages) {
+          setMessages(incomingMessages);
+          lastSequenceIdRef.current =
+            incomingMessages.length > 0
+              ? Math.max(...incomingMessages.map((m) => m.sequence_id))
+              : -1;
+        }
+        if (streamResponse.branches || streamResponse.reset_messages) {
+          setBranches(streamResponse.branches ?? []);
+        }
+
+        // Partial output is shown until the agent's message (or an error) is recorded.
+        if (streamResponse.stream_delta) {
+          const delta = streamResponse.stream_delta;
+          setPartialOutput((prev) => {
+            const base = delta.restart || !prev ? { text: "", thinking: "" } : prev;
+            return {
+              text: base.text + (delta.text ?? ""),
+              thinking: base.thinking + (delta.thinking ?? ""),
+            };
+          });
+        }
+        if (incomingMessages.some((m) => m.type === "agent" || m.type === "error")) {
+          setPartialOutput(null);
+        }
+
         // Track the latest sequence ID for reconnection
-        if (incomingMessages.length > 0) {
+        if (incomingMessages.length > 0 && !streamResponse.reset_messages) {
           const maxSeqId = Math.max(...incomingMessages.map((m) => m.sequence_id));
           if (maxSeqId > lastSequenceIdRef.current) {
             lastSequenceIdRef.current = maxSeqId;
@@ -1032,7 +1078,7 @@ function ChatInterface({
 
         // Merge new messages without losing existing ones.
         // If no new messages (e.g., only conversation/slug update or heartbeat), keep existing list.
-        if (incomingMessages.length > 0) {
+        if (incomingMessages.length > 0 && !streamResponse.reset_messages) {
           setMessages((prev) => {
             const byId = new Map<string, Message>();
             for (const m of prev) byId.set(m.message_id, m);
@@ -1066,6 +1112,9 @@ function ChatInterface({
           // Update local state if this is for our conversation
           if (streamResponse.conversation_state.conversation_id === conversationId) {
             setAgentWorking(streamResponse.conversation_state.working);
+            if (!streamResponse.conversation_state.working) {
+              setPartialOutput(null);
+            }
             // Update selected model from conversation (ensures consistency across sessions)
             if (streamResponse.conversation_state.model) {
               setSelectedModel(streamResponse.conversation_state.model);
@@ -1078,6 +1127,21 @@ function ChatInterface({
           handleNotificationEvent(streamResponse.notification_event);
         }
 
+        // Show or clear the bash command approval prompt
+        if (streamResponse.permission_request) {
+          const req = streamResponse.permission_request;
+          if (req.resolved) {
+            setPendingPermission((prev) => (prev?.id === req.id ? null : prev));
+          } else {
+            setPendingPermission(req);
+          }
+        }
+
+        // Offer, or stop offering, to refresh the system prompt
+        if (typeof streamResponse.system_prompt_stale === "boolean") {
+          setSystemPromptStale(streamResponse.system_prompt_stale);
+        }
+
         if (typeof streamResponse.context_window_size === "number") {
           setContextWindowSize(streamResponse.context_window_size);
         }
@@ -1335,6 +1399,32 @@ function ChatInterface({
     }
   };
 
+  const handleResolvePermission = async (approved: boolean) => {
+    if (!conversationId || !pendingPermission) return;
+    const requestId = pendingPermission.id;
+    try {
+      await api.resolvePermission(conversationId, requestId, approved);
+      setPendingPermission((prev) => (prev?.id === requestId ? null : prev));
+    } catch (err) {
+      console.error("Failed to resolve permission request:", err);
+      setError("Failed to send approval. Please try again.");
+    }
+  };
+
+  const handleRefreshSystemPrompt = async () => {
+    if (!conversationId) return;
+    setRefreshingPrompt(true);
+    try {
+      await api.refreshSystemPrompt(conversationId);
+      setSystemPromptStale(false);
+    } catch (err) {
+      console.error("Failed to refresh system prompt:", err);
+      setError("Failed to refresh the system prompt. Please try again.");
+    } finally {
+      setRefreshingPrompt(false);
+    }
+  };
+
   // Handler to continue conversation in a new one
   const handleContinueConversation = async () => {
     if (!conversationId || !onContinueConversation) return;
@@ -1625,6 +1715,8 @@ function ChatInterface({
 
     const rendered = coalescedItems.map((item, index) => {
       if (item.type === "message" && item.message) {
+        const sequenceId = item.message.sequence_id;
+        const messageId = item.message.message_id;
         return (
           <MessageComponent
             key={item.message.message_id}
@@ -1635,6 +1727,28 @@ function ChatInterface({
               setShowDiffViewer(true);
             }}
             onCommentTextChange={setDiffCommentText}
+            onFork={
+              onForkConversation && conversationId
+                ? () => {
+                    onForkConversation(conversationId, sequenceId).catch(() => {});
+                  }
+                : undefined
+            }
+            onEdit={
+              conversationId && !agentWorking
+                ? (text) => api.editMessage(conversationId, sequenceId, text, selectedModel)
+                : undefined
+            }
+            branch={branches.find((b) => b.message_id === messageId)}
+            onSwitchBranch={
+              conversationId && !agentWorking
+                ? (targetId) => {
+                    api.switchBranch(conversationId, targetId).catch((err) => {
+                      console.error("Failed to switch branch:", err);
+                    });
+                  }
+                : undefined
+            }
           />
         );
       } else if (item.type === "tool") {
@@ -1659,9 +1773,26 @@ function ChatInterface({
     // Find system prompt message to render at the top (exclude distill status messages)
     const systemMessage = messages.find((m) => m.type === "system" && !isDistillStatusMessage(m));
 
+    // Render streamed output as a provisional agent message.
+    let partialMessage: Message | null = null;
+    if (partialOutput && conversationId) {
+      const content: Partial<LLMContent>[] = [];
+      if (partialOutput.thinking) content.push({ Type: 3, Thinking: partialOutput.thinking });
+      if (partialOutput.text) content.push({ Type: 2, Text: partialOutput.text });
+      partialMessage = {
+        message_id: "partial-output",
+        conversation_id: conversationId,
+        sequence_id: -1,
+        type: "agent",
+        llm_data: JSON.stringify({ Role: 1, Content: content }),
+        created_at: new Date().toISOString(),
+      };
+    }
+
     return [
       systemMessage && <SystemPromptView key="system-prompt" message={systemMessage} />,
       ...rendered,
+      partialMessage && <MessageComponent key="partial-output" message={partialMessage} />,
     ];
   };
 
@@ -2063,6 +2194,25 @@ function ChatInterface({
                 </svg>
               </button>
             </>
+          ) : pendingPermission && conversationId ? (
+            // Bash command awaiting approval under the permission policy
+            <>
+              <span className="status-message status-warning" title={pendingPermission.command}>
+                Allow command: <code>{pendingPermission.command}</code>
+              </span>
+              <button
+                onClick={() => handleResolvePermission(true)}
+                className="status-button status-button-primary"
+              >
+                Allow
+              </button>
+              <button
+                onClick={() => handleResolvePermission(false)}
+                className="status-button status-button-cancel"
+              >
+                Deny
+              </button>
+            </>
           ) : agentWorking && conversationId ? (
             // Agent working - show status with stop button and context bar
             <div className="status-bar-active" data-testid="agent-thinking">
@@ -2095,6 +2245,26 @@ function ChatInterface({
                 }
               />
             </div>
+          ) : systemPromptStale && conversationId ? (
+            // Guidance files or skills changed since the system prompt was made
+            <>
+              <span className="status-message status-warning">
+                Guidance or skill files changed since this conversation started.
+              </span>
+              <button
+                onClick={handleRefreshSystemPrompt}
+                disabled={refreshingPrompt}
+                className="status-button status-button-primary"
+              >
+                {refreshingPrompt ? "Refreshing..." : "Refresh prompt"}
+              </button>
+              <button
+                onClick={() => setSystemPromptStale(false)}
+                className="status-button status-button-cancel"
+              >
+                Dismiss
+              </button>
+            </>
           ) : // Idle state - show ready message, or configuration for empty conversation
           !conversationId ? (
             // Empty conversation - show model (left) and cwd (right)
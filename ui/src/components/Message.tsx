@@ -5,7 +5,9 @@ import {
   LLMMessage,
   LLMContent,
   Usage,
+  MessageBranches,
   isDistillStatusMessage,
+  isCompactionSummaryMessage,
 } from "../types";
 import BashTool from "./BashTool";
 import PatchTool from "./PatchTool";
@@ -36,6 +38,10 @@ interface MessageProps {
   message: MessageType;
   onOpenDiffViewer?: (commit: string, cwd?: string) => void;
   onCommentTextChange?: (text: string) => void;
+  onFork?: () => void;
+  onEdit?: (text: string) => Promise<void>;
+  branch?: MessageBranches;
+  onSwitchBranch?: (messageId: string) => void;
 }
 
 // Copy icon for the commit hash copy button
@@ -226,6 +232,48 @@ function GitInfoMessage({
   );
 }
 
+// CompactionSummaryMessage renders the summary that replaced older messages in
+// the LLM context. The messages themselves are still shown above it.
+function CompactionSummaryMessage({ message }: { message: MessageType }) {
+  let summary = "";
+  let compacted = 0;
+  try {
+    if (message.llm_data) {
+      const llmData: LLMMessage =
+        typeof message.llm_data === "string" ? JSON.parse(message.llm_data) : message.llm_data;
+      summary = llmData.Content?.find((c) => c.Type === 2)?.Text || "";
+    }
+    if (message.user_data) {
+      const userData =
+        typeof message.user_data === "string" ? JSON.parse(message.user_data) : message.user_data;
+      compacted = userData.compacted_messages || 0;
+    }
+  } catch {
+    // ignore parse errors
+  }
+
+  return (
+    <div
+      className="message message-gitinfo"
+      data-testid="compaction-summary"
+      style={{
+        padding: "0.5rem 1rem",
+        fontSize: "0.8rem",
+        color: "var(--text-secondary)",
+      }}
+    >
+      <details>
+        <summary style={{ cursor: "pointer", textAlign: "center" }}>
+          Context compacted: {compacted > 0 ? compacted : "earlier"} messages were summarized
+        </summary>
+        <div className="whitespace-pre-wrap break-words" style={{ marginTop: "0.5rem" }}>
+          {summary}
+        </div>
+      </details>
+    </div>
+  );
+}
+
 // DistillStatusMessage renders a compact status message for conversation distillation
 function DistillStatusMessage({ message }: { message: MessageType }) {
   let status = "in_progress";
@@ -283,7 +331,15 @@ function DistillStatusMessage({ message }: { message: MessageType }) {
   );
 }
 
-function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProps) {
+function Message({
+  message,
+  onOpenDiffViewer,
+  onCommentTextChange,
+  onFork,
+  onEdit,
+  branch,
+  onSwitchBranch,
+}: MessageProps) {
   // Render system messages with distill_status as status indicators
   if (message.type === "system") {
     if (isDistillStatusMessage(message)) {
@@ -292,6 +348,10 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
     return null;
   }
 
+  if (isCompactionSummaryMessage(message)) {
+    return <CompactionSummaryMessage message={message} />;
+  }
+
   // Render gitinfo messages as compact status updates
   if (message.type === "gitinfo") {
     return <GitInfoMessage message={message} onOpenDiffViewer={onOpenDiffViewer} />;
@@ -301,6 +361,9 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const [showActionBar, setShowActionBar] = useState(false);
   const [isHovered, setIsHovered] = useState(false);
   const [showUsageModal, setShowUsageModal] = useState(false);
+  const [isEditing, setIsEditing] = useState(false);
+  const [editText, setEditText] = useState("");
+  const [editError, setEditError] = useState<string | null>(null);
   const messageRef = useRef<HTMLDivElement | null>(null);
 
   // Show action bar on hover or when explicitly tapped
@@ -438,6 +501,26 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
     setShowActionBar(false);
   };
 
+  const handleStartEdit = () => {
+    setEditText(getMessageText());
+    setEditError(null);
+    setIsEditing(true);
+    setShowActionBar(false);
+  };
+
+  const handleSaveEdit = async () => {
+    const text = editText.trim();
+    if (!onEdit || !text) {
+      return;
+    }
+    try {
+      await onEdit(text);
+      setIsEditing(false);
+    } catch (err) {
+      setEditError(err instanceof Error ? err.message : "Failed to edit message");
+    }
+  };
+
   let displayData: ToolDisplay[] | null = null;
   if (message.display_data) {
     try {
@@ -469,6 +552,9 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const messageText = getMessageText();
   const hasCopyAction = !!messageText;
   const hasUsageAction = message.type === "agent" && !!usage;
+  const hasForkAction = !!onFork;
+  const hasEditAction = isUser && !!onEdit && !isEditing;
+  const hasAnyAction = hasCopyAction || hasUsageAction || hasForkAction || hasEditAction;
 
   // Build a map of tool use IDs to their inputs for linking tool_result back to tool_use
   const toolUseMap: Record<string, { name: string; input: unknown }> = {};
@@ -998,10 +1084,12 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           role="alert"
           aria-label="Error message"
         >
-          {actionBarVisible && (hasCopyAction || hasUsageAction) && (
+          {actionBarVisible && hasAnyAction && (
             <MessageActionBar
               onCopy={hasCopyAction ? handleCopy : undefined}
               onShowUsage={hasUsageAction ? handleShowUsage : undefined}
+              onFork={onFork}
+              onEdit={hasEditAction ? handleStartEdit : undefined}
             />
           )}
           <div className="message-content" data-testid="message-content">
@@ -1033,10 +1121,12 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           data-testid="message"
           role="article"
         >
-          {actionBarVisible && (hasCopyAction || hasUsageAction) && (
+          {actionBarVisible && hasAnyAction && (
             <MessageActionBar
               onCopy={hasCopyAction ? handleCopy : undefined}
               onShowUsage={hasUsageAction ? handleShowUsage : undefined}
+              onFork={onFork}
+              onEdit={hasEditAction ? handleStartEdit : undefined}
             />
           )}
           <div className="message-content" data-testid="message-content">
@@ -1106,18 +1196,58 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         data-testid="message"
         role="article"
       >
-        {actionBarVisible && (hasCopyAction || hasUsageAction) && (
+        {actionBarVisible && hasAnyAction && (
           <MessageActionBar
             onCopy={hasCopyAction ? handleCopy : undefined}
             onShowUsage={hasUsageAction ? handleShowUsage : undefined}
+            onFork={onFork}
+            onEdit={hasEditAction ? handleStartEdit : undefined}
           />
         )}
         {/* Message content */}
         <div className="message-content" data-testid="message-content">
-          {contentToRender.map((content, index) => (
-            <div key={index}>{renderContent(content)}</div>
-          ))}
+          {isEditing ? (
+            <div className="message-edit" onClick={(e) => e.stopPropagation()}>
+              <textarea
+                value={editText}
+                onChange={(e) => setEditText(e.target.value)}
+                onKeyDown={(e) => {
+                  if (e.key === "Enter" && (e.metaKey || e.ctrlKey)) {
+                    e.preventDefault();
+                    handleSaveEdit();
+                  } else if (e.key === "Escape") {
+                    setIsEditing(false);
+                  }
+                }}
+                rows={Math.min(10, Math.max(2, editText.split("\n").length))}
+                autoFocus
+                style={{ width: "100%", resize: "vertical", font: "inherit" }}
+              />
+              {editError && (
+                <div className="text-sm" style={{ color: "var(--error-text)" }}>
+                  {editError}
+                </div>
+              )}
+              <div style={{ display: "flex", gap: "8px", justifyContent: "flex-end" }}>
+                <button className="btn-secondary btn-sm" onClick={() => setIsEditing(false)}>
+                  Cancel
+                </button>
+                <button
+                  className="btn-primary btn-sm"
+                  onClick={() => handleSaveEdit()}
+                  disabled={!editText.trim()}
+                >
+                  Save &amp; Submit
+                </button>
+              </div>
+            </div>
+          ) : (
+            contentToRender.map((content, index) => <div key={index}>{renderContent(content)}</div>)
+          )}
         </div>
+        {branch && onSwitchBranch && (
+          <BranchNavigator branch={branch} onSwitchBranch={onSwitchBranch} />
+        )}
       </div>
       {showUsageModal && usage && (
         <UsageDetailModal
@@ -1130,6 +1260,58 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   );
 }
 
+// BranchNavigator lets the user step between edited versions of a message
+function BranchNavigator({
+  branch,
+  onSwitchBranch,
+}: {
+  branch: MessageBranches;
+  onSwitchBranch: (messageId: string) => void;
+}) {
+  const alternatives = branch.alternatives ?? [];
+  const index = alternatives.indexOf(branch.message_id);
+  if (alternatives.length < 2 || index < 0) {
+    return null;
+  }
+  const go = (e: React.MouseEvent, target: number) => {
+    e.stopPropagation();
+    onSwitchBranch(alternatives[target]);
+  };
+  return (
+    <div
+      className="message-branches"
+      data-testid="message-branches"
+      style={{
+        display: "flex",
+        alignItems: "center",
+        gap: "4px",
+        fontSize: "12px",
+        color: "var(--text-secondary)",
+      }}
+    >
+      <button
+        onClick={(e) => go(e, index - 1)}
+        disabled={index === 0}
+        title="Previous version"
+        aria-label="Previous version"
+      >
+        ‹
+      </button>
+      <span>
+        {index + 1}/{alternatives.length}
+      </span>
+      <button
+        onClick={(e) => go(e, index + 1)}
+        disabled={index === alternatives.length - 1}
+        title="Next version"
+        aria-label="Next version"
+      >
+        ›
+      </button>
+    </div>
+  );
+}
+
 // Helper functions
 function hasToolResult(llmMessage: LLMMessage | null): boolean {
   if (!llmMessage) return false;
@@ -3,9 +3,11 @@ import React, { useState } from "react";
 interface MessageActionBarProps {
   onCopy?: () => void;
   onShowUsage?: () => void;
+  onFork?: () => void;
+  onEdit?: () => void;
 }
 
-function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
+function MessageActionBar({ onCopy, onShowUsage, onFork, onEdit }: MessageActionBarProps) {
   const [copyFeedback, setCopyFeedback] = useState(false);
 
   const handleCopy = (e: React.MouseEvent) => {
@@ -24,6 +26,20 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
     }
   };
 
+  const handleFork = (e: React.MouseEvent) => {
+    e.stopPropagation();
+    if (onFork) {
+      onFork();
+    }
+  };
+
+  const handleEdit = (e: React.MouseEvent) => {
+    e.stopPropagation();
+    if (onEdit) {
+      onEdit();
+    }
+  };
+
   return (
     <div
       className="message-action-bar"
@@ -99,6 +115,45 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
           )}
         </button>
       )}
+      {onEdit && (
+        <button
+          onClick={handleEdit}
+          title="Edit and resend"
+          style={{
+            display: "flex",
+            alignItems: "center",
+            justifyContent: "center",
+            width: "24px",
+            height: "24px",
+            borderRadius: "4px",
+            border: "none",
+            background: "transparent",
+            cursor: "pointer",
+            color: "var(--text-secondary)",
+            transition: "background-color 0.15s",
+          }}
+          onMouseEnter={(e) => {
+            e.currentTarget.style.backgroundColor = "var(--bg-tertiary)";
+          }}
+          onMouseLeave={(e) => {
+            e.currentTarget.style.backgroundColor = "transparent";
+          }}
+        >
+          <svg
+            width="16"
+            height="16"
+            viewBox="0 0 24 24"
+            fill="none"
+            stroke="currentColor"
+            strokeWidth="2"
+            strokeLinecap="round"
+            strokeLinejoin="round"
+          >
+            <path d="M12 20h9"></path>
+            <path d="M16.5 3.5a2.121 2.121 0 0 1 3 3L7 19l-4 1 1-4L16.5 3.5z"></path>
+          </svg>
+        </button>
+      )}
       {onShowUsage && (
         <button
           onClick={handleShowUsage}
@@ -139,6 +194,48 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
           </svg>
         </button>
       )}
+      {onFork && (
+        <button
+          onClick={handleFork}
+          title="Fork conversation from here"
+          style={{
+            display: "flex",
+            alignItems: "center",
+            justifyContent: "center",
+            width: "24px",
+            height: "24px",
+            borderRadius: "4px",
+            border: "none",
+            background: "transparent",
+            cursor: "pointer",
+            color: "var(--text-secondary)",
+            transition: "background-color 0.15s",
+          }}
+          onMouseEnter={(e) => {
+            e.currentTarget.style.backgroundColor = "var(--bg-tertiary)";
+          }}
+          onMouseLeave={(e) => {
+            e.currentTarget.style.backgroundColor = "transparent";
+          }}
+        >
+          <svg
+            width="16"
+            height="16"
+            viewBox="0 0 24 24"
+            fill="none"
+            stroke="currentColor"
+            strokeWidth="2"
+            strokeLinecap="round"
+            strokeLinejoin="round"
+          >
+            <circle cx="6" cy="6" r="3"></circle>
+            <circle cx="6" cy="18" r="3"></circle>
+            <circle cx="18" cy="9" r="3"></circle>
+            <path d="M6 9v6"></path>
+            <path d="M18 12c0 3-4 3-9 4.5"></path>
+          </svg>
+        </button>
+      )}
     </div>
   );
 }
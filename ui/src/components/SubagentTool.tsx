@@ -3,7 +3,7 @@ import { LLMContent } from "../types";
 
 interface SubagentToolProps {
   // For tool_use (pending state)
-  toolInput?: unknown; // { slug: string, prompt: string, timeout_seconds?: number, wait?: boolean }
+  toolInput?: unknown; // { action?: string, slug?: string, slugs?: string[], prompt?: string, timeout_seconds?: number, wait?: boolean }
   isRunning?: boolean;
 
   // For tool_result (completed state)
@@ -26,9 +26,20 @@ function SubagentTool({
   // Extract fields from toolInput
   const input =
     typeof toolInput === "object" && toolInput !== null
-      ? (toolInput as { slug?: string; prompt?: string; timeout_seconds?: number; wait?: boolean })
+      ? (toolInput as {
+          action?: string;
+          slug?: string;
+          slugs?: string[];
+          prompt?: string;
+          timeout_seconds?: number;
+          wait?: boolean;
+        })
       : {};
 
+  // Actions other than send (status, wait_all, wait_any, cancel) apply to several subagents
+  const action = input.action && input.action !== "send" ? input.action : "";
+  const targets = [...(input.slugs || []), ...(input.slug ? [input.slug] : [])];
+
   const slug = input.slug || displayData?.slug || "subagent";
   const prompt = input.prompt || "";
   const wait = input.wait !== false;
@@ -60,10 +71,16 @@ function SubagentTool({
           <span className="tool-name">subagent</span>
           {isComplete && hasError && <span className="tool-error">✗</span>}
           {isComplete && !hasError && <span className="tool-success">✓</span>}
-          <span className="tool-command">
-            Subagent '{slug}' {isRunning ? (wait ? "running..." : "started") : ""}
-            {displayPrompt && !isRunning && ` ${displayPrompt}`}
-          </span>
+          {action ? (
+            <span className="tool-command">
+              Subagents {action} {targets.length > 0 ? targets.join(", ") : "(all)"}
+            </span>
+          ) : (
+            <span className="tool-command">
+              Subagent '{slug}' {isRunning ? (wait ? "running..." : "started") : ""}
+              {displayPrompt && !isRunning && ` ${displayPrompt}`}
+            </span>
+          )}
         </div>
         <button
           className="tool-toggle"
@@ -94,14 +111,16 @@ function SubagentTool({
 
       {isExpanded && (
         <div className="tool-details">
-          <div className="tool-section">
-            <div className="tool-label">
-              Prompt to '{slug}':
-              {!wait && <span className="tool-badge">fire-and-forget</span>}
-              {timeout !== 60 && <span className="tool-badge">timeout: {timeout}s</span>}
+          {!action && (
+            <div className="tool-section">
+              <div className="tool-label">
+                Prompt to '{slug}':
+                {!wait && <span className="tool-badge">fire-and-forget</span>}
+                {timeout !== 60 && <span className="tool-badge">timeout: {timeout}s</span>}
+              </div>
+              <div className="tool-code">{prompt || "(no prompt)"}</div>
             </div>
-            <div className="tool-code">{prompt || "(no prompt)"}</div>
-          </div>
+          )}
 
           {isComplete && (
             <div className="tool-section">
@@ -13,6 +13,12 @@ export interface Conversation {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  forked_from_conversation_id: string | null;
+  forked_from_sequence_id: number | null;
+  active_message_id: string | null;
+  allowed_tools: string | null;
+  user_id: string | null;
+  skills: string | null;
 }
 
 export interface Usage {
@@ -24,6 +30,7 @@ export interface Usage {
   model?: string;
   start_time?: string | null;
   end_time?: string | null;
+  model_id?: string;
 }
 
 export interface ApiMessageForTS {
@@ -53,12 +60,37 @@ export interface NotificationEventForTS {
   payload?: any;
 }
 
+export interface PermissionRequestForTS {
+  id: string;
+  conversation_id: string;
+  command: string;
+  programs: string[] | null;
+  resolved?: boolean;
+  approved?: boolean;
+}
+
+export interface MessageBranchesForTS {
+  message_id: string;
+  alternatives: string[] | null;
+}
+
+export interface StreamDeltaForTS {
+  text?: string;
+  thinking?: string;
+  restart?: boolean;
+}
+
 export interface StreamResponseForTS {
   messages: ApiMessageForTS[] | null;
   conversation: Conversation;
   conversation_state?: ConversationStateForTS | null;
   heartbeat?: boolean;
   notification_event?: NotificationEventForTS | null;
+  permission_request?: PermissionRequestForTS | null;
+  branches?: MessageBranchesForTS[] | null;
+  reset_messages?: boolean;
+  stream_delta?: StreamDeltaForTS | null;
+  system_prompt_stale?: boolean | null;
 }
 
 export interface ConversationWithStateForTS {
@@ -71,6 +103,12 @@ export interface ConversationWithStateForTS {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  forked_from_conversation_id: string | null;
+  forked_from_sequence_id: number | null;
+  active_message_id: string | null;
+  allowed_tools: string | null;
+  user_id: string | null;
+  skills: string | null;
   working: boolean;
   git_repo_root?: string;
   git_worktree_root?: string;
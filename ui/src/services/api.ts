@@ -105,6 +105,21 @@ class ApiService {
     return response.json();
   }
 
+  async forkConversation(
+    conversationId: string,
+    sequenceId: number,
+  ): Promise<{ conversation_id: string }> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/fork`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ sequence_id: sequenceId }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to fork conversation: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   async getConversation(conversationId: string): Promise<StreamResponse> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}`);
     if (!response.ok) {
@@ -132,6 +147,33 @@ class ApiService {
     return new EventSource(url);
   }
 
+  async editMessage(
+    conversationId: string,
+    sequenceId: number,
+    message: string,
+    model?: string,
+  ): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/edit`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ sequence_id: sequenceId, message, model: model || "" }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to edit message: ${response.statusText}`);
+    }
+  }
+
+  async switchBranch(conversationId: string, messageId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/branch`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ message_id: messageId }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to switch branch: ${response.statusText}`);
+    }
+  }
+
   async cancelConversation(conversationId: string): Promise<void> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/cancel`, {
       method: "POST",
@@ -141,6 +183,31 @@ class ApiService {
     }
   }
 
+  async resolvePermission(
+    conversationId: string,
+    requestId: string,
+    approved: boolean,
+  ): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/permission`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ request_id: requestId, approved }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to resolve permission request: ${response.statusText}`);
+    }
+  }
+
+  async refreshSystemPrompt(conversationId: string): Promise<void> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/refresh-system-prompt`,
+      { method: "POST" },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to refresh system prompt: ${response.statusText}`);
+    }
+  }
+
   async validateCwd(path: string): Promise<{ valid: boolean; error?: string }> {
     const response = await fetch(`${this.baseUrl}/validate-cwd?path=${encodeURIComponent(path)}`);
     if (!response.ok) {
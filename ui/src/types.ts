@@ -5,6 +5,8 @@ import {
   ApiMessageForTS,
   StreamResponseForTS,
   NotificationEventForTS,
+  PermissionRequestForTS,
+  MessageBranchesForTS,
   Usage as GeneratedUsage,
   MessageType as GeneratedMessageType,
 } from "./generated-types";
@@ -14,6 +16,8 @@ export type Conversation = GeneratedConversation;
 export type ConversationWithState = ConversationWithStateForTS;
 export type Usage = GeneratedUsage;
 export type MessageType = GeneratedMessageType;
+export type PermissionRequest = PermissionRequestForTS;
+export type MessageBranches = MessageBranchesForTS;
 
 // Extend the generated Message type with parsed data
 export interface Message extends Omit<ApiMessageForTS, "type"> {
@@ -143,11 +147,19 @@ export interface DiffComment {
 
 // Conversation list streaming update
 export interface ConversationListUpdate {
-  type: "update" | "delete";
+  type: "update" | "delete" | "config_reloaded";
   conversation?: Conversation;
   conversation_id?: string; // For deletes
   git_repo_root?: string;
   git_worktree_root?: string;
+  config?: ReloadedConfig; // For config_reloaded
+}
+
+// ReloadedConfig is the part of shelley.json the UI uses, sent when the file changes
+export interface ReloadedConfig {
+  default_model?: string;
+  terminal_url?: string;
+  links: Link[] | null;
 }
 
 // Version check types
@@ -187,3 +199,15 @@ export function isDistillStatusMessage(message: Message): boolean {
     return false;
   }
 }
+
+// Helper to check if a message is the summary left by automatic context compaction
+export function isCompactionSummaryMessage(message: Message): boolean {
+  if (message.type !== "user" || !message.user_data) return false;
+  try {
+    const userData =
+      typeof message.user_data === "string" ? JSON.parse(message.user_data) : message.user_data;
+    return userData.compaction === true;
+  } catch {
+    return false;
+  }
+}